@@ -2,434 +2,1198 @@ package assemble
 
 import (
 	"fmt"
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/ir"
 	"github.com/arrietty-lang/arrtty/preprocess/analyze"
 	"github.com/arrietty-lang/arrtty/preprocess/parse"
 	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
 	"github.com/arrietty-lang/arrtty/vm"
-	"math/rand"
 )
 
-var currentFunctionName string
-
-var semOverall *analyze.Semantics
-var currentNest int
-var currentFnVariableBPs map[int]map[string]int
+// Compiler 命令を生成している間の状態を持つ
+// それぞれのCompilerは独立しているので、別々のゴルーチンで同時に使える
+type Compiler struct {
+	// currentFunction 命令を生成している関数
+	currentFunction *ir.Function
+	// dataSection グローバル変数を初期化する命令, mainの始めに実行する
+	dataSection []vm.Data
+	// separate パッケージごとに別々にコンパイルしている
+	// mainのラベルはリンク時に作る入口に付けるので、main関数はMainBodyから始める
+	separate bool
+	// labelCount 生成中の関数で作った制御構造のラベルの数
+	labelCount int
+}
 
-var globals []string
-var dataSection []vm.Data
+// NewCompiler 新しいCompiler
+func NewCompiler() *Compiler {
+	return &Compiler{}
+}
 
 func init() {
 }
 
-var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+// newLabel 制御構造のラベル, 関数のラベルと通し番号から作るので同じソースからは同じラベルになる
+func (c *Compiler) newLabel(kind string) string {
+	c.labelCount++
+	function := "init"
+	if c.currentFunction != nil {
+		function = c.currentFunction.Symbol.Label()
+	}
+	return fmt.Sprintf("%s_%s_%d", function, kind, c.labelCount)
+}
+
+// slot 変数の領域
+func slot(sym *ir.Symbol) vm.Data {
+	return *vm.NewOffsetData(*vm.NewOffset(vm.BP, -sym.Slot))
+}
+
+// declareVariable スタックのトップの値で変数を宣言する
+// キャプチャされる変数は宣言のたびに新しくヒープに置く
+func declareVariable(sym *ir.Symbol) ([]vm.Data, error) {
+	if sym.IsVariable() && sym.Captured {
+		return []vm.Data{
+			*vm.NewOpcodeData(vm.NEW), *vm.NewLiteralDataWithRaw(1),
+			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
+			*vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.R1), slot(sym),
+		}, nil
+	}
+	return storeVariable(sym)
+}
+
+// storeVariable スタックのトップの値を取り出して変数に格納する
+func storeVariable(sym *ir.Symbol) ([]vm.Data, error) {
+	switch {
+	case sym.IsVariable() && sym.Captured:
+		// キャプチャされた変数はヒープの値を書き換える
+		return []vm.Data{
+			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
+			*vm.NewOpcodeData(vm.PUSH), slot(sym),
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(0),
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewRegisterTagData(vm.R1),
+			*vm.NewOpcodeData(vm.SET),
+		}, nil
+	case sym.IsVariable():
+		// 関数内の変数
+		return []vm.Data{
+			// valの結果を取り出す
+			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
+			// 変数の場所に格納
+			*vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.R1), slot(sym),
+		}, nil
+	case sym.Kind == ir.Global:
+		return []vm.Data{
+			// valの結果を取り出す
+			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
+			// 変数の場所に格納
+			*vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.R1), *vm.NewLabelData(*vm.NewLabel(false, sym.Label())),
+		}, nil
+	}
+	return nil, diagnostic.Errorf("C0002", sym.Name)
+}
+
+// arithmetics 演算子に対応する命令
+var arithmetics = map[ir.Op]vm.Opcode{
+	ir.OpAdd: vm.ADD,
+	ir.OpSub: vm.SUB,
+	ir.OpMul: vm.MUL,
+	ir.OpDiv: vm.DIV,
+	ir.OpMod: vm.MOD,
+}
+
+// compoundAssign 変数に対して演算と代入を同時に行う
+// ローカル変数に即値を作用させる場合は、オフセットを直接書き換える命令を使用する
+func (c *Compiler) compoundAssign(op vm.Opcode, to *ir.Node, value *ir.Node) ([]vm.Data, error) {
+	var program []vm.Data
+	calc := []vm.Data{
+		*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R2),
+		*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
+		// r1 op= r2
+		*vm.NewOpcodeData(op), *vm.NewRegisterTagData(vm.R2), *vm.NewRegisterTagData(vm.R1),
+		*vm.NewOpcodeData(vm.PUSH), *vm.NewRegisterTagData(vm.R1),
+	}
+	val, err := c.expr(value)
+	if err != nil {
+		return nil, err
+	}
+
+	// フィールド, 要素
+	if to.Kind == ir.Field || to.Kind == ir.Index {
+		target, err := c.element(to)
+		if err != nil {
+			return nil, err
+		}
+		program = append(program, target...)
+		program = append(program, []vm.Data{
+			// 格納先のためにポインタとインデックスを残したまま、現在の値を取り出す
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewOffsetData(*vm.NewOffset(vm.SP, 1)),
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewOffsetData(*vm.NewOffset(vm.SP, 1)),
+		}...)
+		if isMapElement(to) {
+			// 存在しないキーはゼロ値として計算する
+			zero, err := zeroValue(to.Type())
+			if err != nil {
+				return nil, err
+			}
+			program = append(program, zero...)
+			program = append(program, *vm.NewOpcodeData(vm.LOOKUP), *vm.NewLiteralDataWithRaw(1))
+		} else {
+			program = append(program, *vm.NewOpcodeData(vm.GET))
+		}
+		program = append(program, val...)
+		program = append(program, calc...)
+		program = append(program, *vm.NewOpcodeData(vm.SET))
+		return program, nil
+	}
+
+	sym := to.Symbol
+	if sym.IsVariable() && !sym.Captured && value.Kind == ir.Literal && (op == vm.ADD || op == vm.SUB) {
+		return []vm.Data{
+			*vm.NewOpcodeData(op), *vm.NewLiteralData(*literalFromField(value.Literal)), slot(sym),
+		}, nil
+	}
+	// 変数の値
+	current, err := load(sym)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, current...)
+	// 作用させる値
+	program = append(program, val...)
+	program = append(program, calc...)
+	store, err := storeVariable(sym)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, store...)
+	return program, nil
+}
+
+// zeroValue 型のゼロ値をスタックにプッシュする
+func zeroValue(typ *parse.DataType) ([]vm.Data, error) {
+	switch typ.Type {
+	case parse.Int, parse.Bool:
+		return []vm.Data{*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(0)}, nil
+	case parse.Float:
+		return []vm.Data{*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(0.0)}, nil
+	case parse.String:
+		return []vm.Data{*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw("")}, nil
+	case parse.Struct:
+		var program []vm.Data
+		for _, f := range typ.Fields {
+			z, err := zeroValue(f.DataType)
+			if err != nil {
+				return nil, err
+			}
+			program = append(program, z...)
+		}
+		program = append(program, *vm.NewOpcodeData(vm.NEW), *vm.NewLiteralDataWithRaw(len(typ.Fields)))
+		return program, nil
+	case parse.Array:
+		var program []vm.Data
+		for i := 0; i < typ.Len; i++ {
+			z, err := zeroValue(typ.Base)
+			if err != nil {
+				return nil, err
+			}
+			program = append(program, z...)
+		}
+		program = append(program, *vm.NewOpcodeData(vm.ARRAY), *vm.NewLiteralDataWithRaw(typ.Len))
+		return program, nil
+	case parse.Map:
+		return []vm.Data{*vm.NewOpcodeData(vm.MAP), *vm.NewLiteralDataWithRaw(0)}, nil
+	case parse.Slice:
+		// 空の配列を参照する長さ0のスライス
+		return []vm.Data{
+			*vm.NewOpcodeData(vm.ARRAY), *vm.NewLiteralDataWithRaw(0),
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(0),
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(0),
+			*vm.NewOpcodeData(vm.SLICE),
+		}, nil
+	case parse.Func, parse.Interface:
+		// 呼び出すとCALLR, CALLIがエラーにする
+		return []vm.Data{*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(0)}, nil
+	}
+	return nil, diagnostic.Errorf("C0003", typ.Ident)
+}
+
+// address 構造体を複製せずにそのポインタをプッシュする
+// フィールドの読み書きは元の構造体に対して行う必要がある
+func (c *Compiler) address(node *ir.Node) ([]vm.Data, error) {
+	switch node.Kind {
+	case ir.Var:
+		return load(node.Symbol)
+	case ir.Field:
+		target, err := c.address(node.FieldField.Target)
+		if err != nil {
+			return nil, err
+		}
+		return append(target,
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(node.FieldField.Index),
+			*vm.NewOpcodeData(vm.GET),
+		), nil
+	case ir.Index:
+		if isMapElement(node) {
+			return c.lookup(node, 1)
+		}
+		target, err := c.address(node.IndexField.Target)
+		if err != nil {
+			return nil, err
+		}
+		index, err := c.expr(node.IndexField.Index)
+		if err != nil {
+			return nil, err
+		}
+		target = append(target, index...)
+		return append(target, *vm.NewOpcodeData(vm.GET)), nil
+	}
+	// 関数の戻り値などは既に複製されている
+	return c.expr(node)
+}
+
+// element フィールド、要素を読み書きするためのポインタとインデックスをプッシュする
+func (c *Compiler) element(node *ir.Node) ([]vm.Data, error) {
+	switch node.Kind {
+	case ir.Field:
+		target, err := c.address(node.FieldField.Target)
+		if err != nil {
+			return nil, err
+		}
+		return append(target, *vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(node.FieldField.Index)), nil
+	case ir.Index:
+		target, err := c.address(node.IndexField.Target)
+		if err != nil {
+			return nil, err
+		}
+		index, err := c.expr(node.IndexField.Index)
+		if err != nil {
+			return nil, err
+		}
+		return append(target, index...), nil
+	}
+	return nil, diagnostic.Errorf("C0004")
+}
+
+// isMapElement マップの要素か
+func isMapElement(node *ir.Node) bool {
+	return node.Kind == ir.Index && node.IndexField.IsMap()
+}
 
-func RandStringRunes(n int) string {
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letterRunes[rand.Intn(len(letterRunes))]
+// lookup マップの要素をプッシュする, 存在しない場合はゼロ値
+// n=2の場合は値の下に、キーが存在したかをプッシュする
+func (c *Compiler) lookup(node *ir.Node, n int) ([]vm.Data, error) {
+	program, err := c.address(node.IndexField.Target)
+	if err != nil {
+		return nil, err
+	}
+	key, err := c.expr(node.IndexField.Index)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, key...)
+	zero, err := zeroValue(node.Types[0])
+	if err != nil {
+		return nil, err
 	}
-	return string(b)
+	program = append(program, zero...)
+	return append(program, *vm.NewOpcodeData(vm.LOOKUP), *vm.NewLiteralDataWithRaw(n)), nil
 }
 
-func searchBPDistFromVarName(variables map[int]map[string]int, nest int, varName string) int {
-	for name, distance := range variables[nest] {
-		if varName == name {
-			return distance
+// multiAssign 値は1つ目がスタックのトップに来るので、代入先の順に取り出す
+func (c *Compiler) multiAssign(node *ir.Node) ([]vm.Data, error) {
+	field := node.MultiAssignField
+	var program []vm.Data
+	var err error
+	if field.Value.Kind == ir.Index && field.Value.IndexField.CommaOk {
+		program, err = c.lookup(field.Value, 2)
+	} else {
+		program, err = c.expr(field.Value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, target := range field.Targets {
+		if target == nil {
+			program = append(program, *vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1))
+			continue
+		}
+		storeFn := storeVariable
+		if field.Declare[i] {
+			storeFn = declareVariable
 		}
+		store, err := storeFn(target)
+		if err != nil {
+			return nil, err
+		}
+		program = append(program, store...)
+	}
+	return program, nil
+}
+
+// load 変数の値をそのままプッシュする
+func load(sym *ir.Symbol) ([]vm.Data, error) {
+	switch {
+	case sym.IsVariable() && sym.Captured:
+		return []vm.Data{
+			*vm.NewOpcodeData(vm.PUSH), slot(sym),
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(0),
+			*vm.NewOpcodeData(vm.GET),
+		}, nil
+	case sym.IsVariable():
+		return []vm.Data{
+			*vm.NewOpcodeData(vm.PUSH), slot(sym),
+		}, nil
+	case sym.Kind == ir.Global:
+		// global変数として存在する
+		return []vm.Data{
+			*vm.NewOpcodeData(vm.MOV), *vm.NewLabelData(*vm.NewLabel(false, sym.Label())), *vm.NewRegisterTagData(vm.R1),
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewRegisterTagData(vm.R1),
+		}, nil
+	}
+	return nil, diagnostic.Errorf("C0005", sym.Name)
+}
+
+// copyValue 構造体、配列は値として扱うので、読み出した時点で複製する
+func copyValue(typ *parse.DataType) []vm.Data {
+	if typ != nil && (typ.Type == parse.Struct || typ.Type == parse.Array) {
+		return []vm.Data{*vm.NewOpcodeData(vm.COPY)}
 	}
-	return -1
+	return nil
 }
 
-func defFunction(node *parse.Node) ([]vm.Data, error) {
-	defFn := node.FuncDefField
-	currentFunctionName = defFn.Identifier.IdentField.Ident
+func (c *Compiler) defFunction(f *ir.Function) ([]vm.Data, error) {
+	c.currentFunction = f
+	c.labelCount = 0
 	// bpをプッシュする前に戻り値の分だけぷっしゅしておく？
 	// 関数として呼び出された場合に必要な命令を始めに入れとく
 
+	label := f.Symbol.Label()
+	if f.IsMain() && c.separate {
+		label = MainBody
+	}
 	var program []vm.Data
 	program = append(program,
-		*vm.NewLabelData(*vm.NewLabel(true, defFn.Identifier.IdentField.Ident)))
+		*vm.NewLabelData(*vm.NewLabel(true, label)))
 
-	if defFn.Identifier.IdentField.Ident != "main" {
+	if !f.IsMain() {
 		program = append(program, []vm.Data{
 			*vm.NewOpcodeData(vm.PUSH), *vm.NewRegisterTagData(vm.RBP),
-			*vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.RSP), *vm.NewRegisterTagData(vm.RBP),
 		}...)
 	}
+	// mainもローカル変数をBPから参照するのでBPを設定する
+	program = append(program, []vm.Data{
+		*vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.RSP), *vm.NewRegisterTagData(vm.RBP),
+	}...)
 
-	// 関数で使用されている変数(引数もむくむ)のBPからの距離
-	var totalVariables = 0
-	var varDistFromBP = map[int]map[string]int{}
-	var dist = 1
-	for nest, variables := range semOverall.KnownValues[defFn.Identifier.IdentField.Ident] {
-		varDistFromBP[nest] = map[string]int{}
-		for varName := range variables {
-			varDistFromBP[nest][varName] = dist
-			dist++
-			totalVariables++
-		}
+	if f.IsMain() {
+		program = append(program, c.dataSection...)
 	}
-	currentFnVariableBPs = varDistFromBP
 
-	// 関数内で使用される変数の数だけSPを下げる(変数用の領域確保)
+	// 関数内で使用される変数(引数もふくむ)の数だけSPを下げる(変数用の領域確保)
 	program = append(program, []vm.Data{
-		*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(totalVariables),
+		*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(f.Frame),
 		*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
 		*vm.NewOpcodeData(vm.SUB), *vm.NewRegisterTagData(vm.R1), *vm.NewRegisterTagData(vm.RSP),
 	}...)
 
+	// 無名関数は呼び出し元がR11に格納した関数の値から、キャプチャした変数を取り出す
+	for i, sym := range f.Captures {
+		program = append(program, []vm.Data{
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewRegisterTagData(vm.R11),
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(i + 1),
+			*vm.NewOpcodeData(vm.GET),
+			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
+			*vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.R1), slot(sym),
+		}...)
+	}
+
 	// 引数と変数を結びつける(代入によって)
-	if defFn.Parameters != nil {
-		for i, param := range defFn.Parameters.PolynomialField.Values {
-			relation_ := -searchBPDistFromVarName(varDistFromBP, 0, param.FuncParam.Identifier.IdentField.Ident)
+	for i, sym := range f.Params {
+		program = append(program, []vm.Data{
+			*vm.NewOpcodeData(vm.MOV), *vm.NewOffsetData(*vm.NewOffset(vm.BP, i+2)), slot(sym),
+		}...)
+		// キャプチャされる引数はヒープに移す
+		if sym.Captured {
 			program = append(program, []vm.Data{
-				*vm.NewOpcodeData(vm.MOV), *vm.NewOffsetData(*vm.NewOffset(vm.BP, i+2)), *vm.NewOffsetData(*vm.NewOffset(vm.BP, relation_)),
+				*vm.NewOpcodeData(vm.PUSH), slot(sym),
+				*vm.NewOpcodeData(vm.NEW), *vm.NewLiteralDataWithRaw(1),
+				*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
+				*vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.R1), slot(sym),
 			}...)
 		}
 	}
 	// こんな感じになってる
 	// [ stack ]
+	// | 戻り値2
+	// | 戻り値1
 	// | 引数2
 	// | 引数1
 	// | ret-pc
 	// | bp
 
-	for _, s := range defFn.Body.BlockField.Statements {
-		f, err := stmt(s)
+	body, err := c.stmts(f.Body)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, body...)
+
+	// returnを書かずに末尾まで到達した場合、次の関数に突入しないように戻る
+	program = append(program, c.epilogue()...)
+
+	return program, nil
+}
+
+// epilogue 関数から戻るための命令
+// mainの場合はそのまま終了する
+func (c *Compiler) epilogue() []vm.Data {
+	if c.currentFunction.IsMain() {
+		return []vm.Data{
+			*vm.NewOpcodeData(vm.EXIT),
+		}
+	}
+	return []vm.Data{
+		*vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.RBP), *vm.NewRegisterTagData(vm.RSP),
+		*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.RBP),
+		*vm.NewOpcodeData(vm.RET),
+	}
+}
+
+// returnSlotOffset i番目の戻り値を格納するBPからの距離
+// 戻り値の領域は呼び出し元が引数をプッシュする前に確保している
+func (c *Compiler) returnSlotOffset(i int) int {
+	return 2 + len(c.currentFunction.Params) + i
+}
+
+func (c *Compiler) stmts(nodes []*ir.Node) ([]vm.Data, error) {
+	var program []vm.Data
+	for _, n := range nodes {
+		f, err := c.stmt(n)
 		if err != nil {
 			return nil, err
 		}
 		program = append(program, f...)
 	}
-
 	return program, nil
 }
 
-func stmt(node *parse.Node) ([]vm.Data, error) {
+func (c *Compiler) stmt(node *ir.Node) ([]vm.Data, error) {
 	var program []vm.Data
 	switch node.Kind {
-	case parse.NdReturn:
-		if len(node.PolynomialField.Values) > 2 {
-			return nil, fmt.Errorf("２つ以上の戻り値は現在サポートされていません")
-		}
-		// 戻り値の準備
-		returnRegs := []vm.RegisterTag{vm.R10, vm.R11}
-		for i, rn := range node.PolynomialField.Values {
-			rv, err := expr(rn)
+	case ir.Return:
+		// 戻り値は引数と同じく、1つ目の値がスタックのトップに来るように逆順で計算する
+		values := node.ReturnField.Values
+		for i := len(values) - 1; 0 <= i; i-- {
+			rv, err := c.expr(values[i])
 			if err != nil {
 				return nil, err
 			}
 			program = append(program, rv...)
-			// 計算結果をR1に移動
-			program = append(program, []vm.Data{
-				*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
-				*vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.R1), *vm.NewRegisterTagData(returnRegs[i]),
-			}...)
 		}
-		// リターン本文
-		// メインだけはリターンで何も返さない..?
-		if currentFunctionName != "main" {
+		// mainの戻り値は終了コードとしてR10に格納する
+		if c.currentFunction.IsMain() {
+			if len(values) != 0 {
+				program = append(program, []vm.Data{
+					*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R10),
+				}...)
+			}
+			program = append(program, c.epilogue()...)
+			return program, nil
+		}
+		// 呼び出し元が確保した戻り値の領域に順番に格納
+		for i := range c.currentFunction.Returns {
 			program = append(program, []vm.Data{
-				*vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.RBP), *vm.NewRegisterTagData(vm.RSP),
-				*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.RBP),
-				*vm.NewOpcodeData(vm.RET),
+				*vm.NewOpcodeData(vm.POP), *vm.NewOffsetData(*vm.NewOffset(vm.BP, c.returnSlotOffset(i))),
 			}...)
 		}
+		// リターン本文
+		program = append(program, c.epilogue()...)
 		return program, nil
-	case parse.NdAssign:
-		val, err := expr(node.AssignField.Value)
+	case ir.Decl:
+		// 領域は関数のはじめにspまとめて引かれているので、値がなければゼロ値で初期化する
+		var val []vm.Data
+		var err error
+		if node.DeclField.Value == nil {
+			val, err = zeroValue(node.Symbol.Type)
+		} else {
+			val, err = c.expr(node.DeclField.Value)
+		}
 		if err != nil {
 			return nil, err
 		}
-		var loc int
-		var ident string
-		switch node.AssignField.To.Kind {
-		case parse.NdVarDecl:
-			ident = node.AssignField.To.VarDeclField.Identifier.IdentField.Ident
-			loc = searchBPDistFromVarName(currentFnVariableBPs, currentNest, ident)
-		case parse.NdIdent:
-			ident = node.AssignField.To.IdentField.Ident
-			loc = searchBPDistFromVarName(currentFnVariableBPs, currentNest, ident)
-		}
-		// 変数の中身をスタックにプッシュ
 		program = append(program, val...)
-		// 関数内の変数
-		if loc != -1 {
-			program = append(program, []vm.Data{
-				// valの結果を取り出す
-				*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
-				// 変数の場所に格納
-				*vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.R1), *vm.NewOffsetData(*vm.NewOffset(vm.BP, -loc)),
-			}...)
-			return program, nil
-		}
-
-		// グローバル変数ならば
-		for _, g := range globals {
-			if g == ident {
-				program = append(program, []vm.Data{
-					// valの結果を取り出す
-					*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
-					// 変数の場所に格納
-					*vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.R1), *vm.NewLabelData(*vm.NewLabel(false, ident)),
-				}...)
-				return program, nil
-			}
+		store, err := declareVariable(node.Symbol)
+		if err != nil {
+			return nil, err
 		}
-
-		return nil, fmt.Errorf("変数の位置を特定できませんでした: %v", ident)
-	case parse.NdVarDecl:
-		// アナライズされて関数のはじめにspまとめて引かれているので特にすることはないはず
-		return nil, nil
-	case parse.NdIfElse:
-		// 条件を計算
-		cond, err := expr(node.IfElseField.Cond)
+		program = append(program, store...)
+		return program, nil
+	case ir.Assign:
+		to := node.AssignField.Target
+		val, err := c.expr(node.AssignField.Value)
 		if err != nil {
 			return nil, err
 		}
-		program = append(program, cond...)
-		// 結果を取り出す
-		//program = append(program, []vm3.Data{
-		//	*vm3.NewOpcodeData(vm3.POP), *vm3.NewRegisterTagData(vm3.R1),
-		//}...)
-
-		// 各ジャンプ先のラベルを用意
-		ifBlockLabel := "if_if_block_" + RandStringRunes(20)
-		endLabel := "if_end_" + RandStringRunes(20)
-
-		// 条件に合致したらIFブロックへ飛ぶ
-		program = append(program, []vm.Data{
-			*vm.NewOpcodeData(vm.JZ), *vm.NewLabelData(*vm.NewLabel(false, ifBlockLabel)),
-		}...)
-
-		// エルスを使用していればエルスのブロックを展開
-		if node.IfElseField.UseElse {
-			elseBlock, err := stmt(node.IfElseField.ElseBlock)
+		if to.Kind == ir.Field || to.Kind == ir.Index {
+			// フィールド、要素への代入
+			target, err := c.element(to)
 			if err != nil {
 				return nil, err
 			}
-			program = append(program, elseBlock...)
+			program = append(program, target...)
+			program = append(program, val...)
+			program = append(program, *vm.NewOpcodeData(vm.SET))
+			return program, nil
 		}
-		// エンドラベルに飛ぶ命令を挿入(条件に合致しなかった場合、IFを読み飛ばすため)
-		program = append(program, []vm.Data{
-			*vm.NewOpcodeData(vm.JMP), *vm.NewLabelData(*vm.NewLabel(false, endLabel)),
-		}...)
-
-		// if-block
-		program = append(program, *vm.NewLabelData(*vm.NewLabel(true, ifBlockLabel)))
-		ifBlock, err := stmt(node.IfElseField.IfBlock)
+		// 変数の中身をスタックにプッシュ
+		program = append(program, val...)
+		store, err := storeVariable(to.Symbol)
 		if err != nil {
 			return nil, err
 		}
-		program = append(program, ifBlock...)
-
-		// 最終的なジャンプ先
-		program = append(program, *vm.NewLabelData(*vm.NewLabel(true, endLabel)))
+		program = append(program, store...)
 		return program, nil
-	case parse.NdBlock:
-		for _, n := range node.BlockField.Statements {
-			f, err := stmt(n)
-			if err != nil {
-				return nil, err
-			}
-			program = append(program, f...)
+	case ir.MultiAssign:
+		return c.multiAssign(node)
+	case ir.Update:
+		return c.compoundAssign(arithmetics[node.AssignField.Op], node.AssignField.Target, node.AssignField.Value)
+	case ir.If:
+		return c.ifElse(node)
+	case ir.Switch:
+		return c.switch_(node)
+	case ir.For:
+		return c.for_(node)
+	case ir.ForRange:
+		return c.forRange(node)
+	case ir.Block:
+		return c.stmts(node.BlockField.Statements)
+	case ir.ExprStmt:
+		value := node.UnaryField.Value
+		val, err := c.expr(value)
+		if err != nil {
+			return nil, err
+		}
+		program = append(program, val...)
+		// 文として呼び出された場合、戻り値は使用されないので捨てる
+		if n := len(value.Types); n != 0 {
+			program = append(program, discard(n)...)
 		}
 		return program, nil
 	}
-	return expr(node)
-}
-
-func expr(node *parse.Node) ([]vm.Data, error) {
-	return assign(node)
+	return c.expr(node)
 }
 
-func assign(node *parse.Node) ([]vm.Data, error) {
-	return equality(node)
-}
+func (c *Compiler) for_(node *ir.Node) ([]vm.Data, error) {
+	var program []vm.Data
+	field := node.ForField
 
-func equality(node *parse.Node) ([]vm.Data, error) {
-	return relation(node)
-}
+	condLabel := c.newLabel("for_cond")
+	endLabel := c.newLabel("for_end")
 
-func relation(node *parse.Node) ([]vm.Data, error) {
-	var program []vm.Data
-	switch node.Kind {
-	case parse.NdLt:
-		lhs, err := relation(node.BinaryField.Lhs)
+	if field.Init != nil {
+		init, err := c.stmt(field.Init)
 		if err != nil {
 			return nil, err
 		}
-		rhs, err := relation(node.BinaryField.Rhs)
+		program = append(program, init...)
+	}
+
+	// 条件がなければ無限ループ
+	program = append(program, *vm.NewLabelData(*vm.NewLabel(true, condLabel)))
+	if field.Cond != nil {
+		cond, err := c.branch(field.Cond, endLabel, false)
 		if err != nil {
 			return nil, err
 		}
-		program = append(program, lhs...)
-		program = append(program, rhs...)
+		program = append(program, cond...)
+	}
 
-		program = append(program, []vm.Data{
-			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R2),
-			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
-			*vm.NewOpcodeData(vm.LT), *vm.NewRegisterTagData(vm.R1), *vm.NewRegisterTagData(vm.R2),
-		}...)
+	body, err := c.stmt(field.Body)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, body...)
 
-		return program, nil
-	case parse.NdLe:
-		lhs, err := relation(node.BinaryField.Lhs)
+	if field.Loop != nil {
+		loop, err := c.stmt(field.Loop)
 		if err != nil {
 			return nil, err
 		}
-		rhs, err := relation(node.BinaryField.Rhs)
+		program = append(program, loop...)
+	}
+	program = append(program, []vm.Data{
+		*vm.NewOpcodeData(vm.JMP), *vm.NewLabelData(*vm.NewLabel(false, condLabel)),
+	}...)
+	program = append(program, *vm.NewLabelData(*vm.NewLabel(true, endLabel)))
+	return program, nil
+}
+
+// forRange 対象と現在の位置を隠れた変数に保持し、通常のforと同様にループする
+// マップはループの開始時点のキーを順に辿り、途中で削除されたキーは飛ばす
+func (c *Compiler) forRange(node *ir.Node) ([]vm.Data, error) {
+	var program []vm.Data
+	field := node.ForRangeField
+
+	condLabel := c.newLabel("range_cond")
+	nextLabel := c.newLabel("range_next")
+	endLabel := c.newLabel("range_end")
+	pos := node.Pos
+	variable := func(sym *ir.Symbol) *ir.Node {
+		n := ir.NewNode(ir.Var, pos, []*parse.DataType{sym.Type})
+		n.Symbol = sym
+		return n
+	}
+	element := func(target *ir.Node, index *ir.Node) *ir.Node {
+		container := target.Type()
+		n := ir.NewNode(ir.Index, pos, []*parse.DataType{container.Base})
+		n.IndexField = &ir.IndexField{Target: target, Index: index, Container: container}
+		return n
+	}
+	store := func(sym *ir.Symbol) error {
+		s, err := declareVariable(sym)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		program = append(program, lhs...)
-		program = append(program, rhs...)
-
-		program = append(program, []vm.Data{
-			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R2),
-			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
-			*vm.NewOpcodeData(vm.LE), *vm.NewRegisterTagData(vm.R1), *vm.NewRegisterTagData(vm.R2),
-		}...)
+		program = append(program, s...)
+		return nil
+	}
 
-		return program, nil
-	case parse.NdGt:
-		lhs, err := relation(node.BinaryField.Lhs)
+	// 対象は一度だけ評価する
+	target, err := c.expr(field.Target)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, target...)
+	if err := store(field.Iterated); err != nil {
+		return nil, err
+	}
+	iterated := field.Iterated
+	if field.Keys != nil {
+		keys, err := load(field.Iterated)
 		if err != nil {
 			return nil, err
 		}
-		rhs, err := relation(node.BinaryField.Rhs)
-		if err != nil {
+		program = append(program, keys...)
+		program = append(program, *vm.NewOpcodeData(vm.KEYS))
+		if err := store(field.Keys); err != nil {
 			return nil, err
 		}
-		program = append(program, lhs...)
-		program = append(program, rhs...)
+		iterated = field.Keys
+	}
+	program = append(program, *vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(0))
+	if err := store(field.Position); err != nil {
+		return nil, err
+	}
 
-		program = append(program, []vm.Data{
-			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R2),
-			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
-			*vm.NewOpcodeData(vm.LT), *vm.NewRegisterTagData(vm.R2), *vm.NewRegisterTagData(vm.R1),
-		}...)
-		return program, nil
-	case parse.NdGe:
-		lhs, err := relation(node.BinaryField.Lhs)
+	// 位置 < len(対象)
+	program = append(program, *vm.NewLabelData(*vm.NewLabel(true, condLabel)))
+	length := ir.NewNode(ir.Builtin, pos, []*parse.DataType{parse.RuntimeInt})
+	length.CallField = &ir.CallField{Name: "len", Args: []*ir.Node{variable(iterated)}}
+	less := ir.NewNode(ir.Binary, pos, []*parse.DataType{parse.RuntimeBool})
+	less.BinaryField = &ir.BinaryField{Op: ir.OpLt, Lhs: variable(field.Position), Rhs: length}
+	cond, err := c.branch(less, endLabel, false)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, cond...)
+
+	// 現在の要素
+	current := element(variable(iterated), variable(field.Position))
+	if field.Keys != nil {
+		value := element(variable(field.Iterated), current)
+		value.Types = append(value.Types, parse.RuntimeBool)
+		v, err := c.lookup(value, 2)
 		if err != nil {
 			return nil, err
 		}
-		rhs, err := relation(node.BinaryField.Rhs)
-		if err != nil {
-			return nil, err
+		program = append(program, v...)
+		program = append(program, *vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1))
+		if field.Value == nil {
+			program = append(program, *vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1))
+		} else {
+			// 値を変数に格納してから、存在したかを確認する
+			program = append(program, *vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R2))
+			program = append(program, *vm.NewOpcodeData(vm.PUSH), *vm.NewRegisterTagData(vm.R1))
+			if err := store(field.Value); err != nil {
+				return nil, err
+			}
+			program = append(program, *vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.R2), *vm.NewRegisterTagData(vm.R1))
 		}
-		program = append(program, lhs...)
-		program = append(program, rhs...)
-
+		// 削除されたキー
 		program = append(program, []vm.Data{
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(0),
 			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R2),
-			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
-			*vm.NewOpcodeData(vm.LE), *vm.NewRegisterTagData(vm.R2), *vm.NewRegisterTagData(vm.R1),
+			*vm.NewOpcodeData(vm.CMP), *vm.NewRegisterTagData(vm.R1), *vm.NewRegisterTagData(vm.R2),
+			*vm.NewOpcodeData(vm.JZ), *vm.NewLabelData(*vm.NewLabel(false, nextLabel)),
 		}...)
+		if field.Key != nil {
+			k, err := c.expr(current)
+			if err != nil {
+				return nil, err
+			}
+			program = append(program, k...)
+			if err := store(field.Key); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if field.Key != nil {
+			k, err := load(field.Position)
+			if err != nil {
+				return nil, err
+			}
+			program = append(program, k...)
+			if err := store(field.Key); err != nil {
+				return nil, err
+			}
+		}
+		if field.Value != nil {
+			v, err := c.expr(current)
+			if err != nil {
+				return nil, err
+			}
+			program = append(program, v...)
+			if err := store(field.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
 
-		return program, nil
+	body, err := c.stmt(field.Body)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, body...)
+
+	program = append(program, *vm.NewLabelData(*vm.NewLabel(true, nextLabel)))
+	one := ir.NewNode(ir.Literal, pos, []*parse.DataType{parse.RuntimeInt})
+	one.Literal = tokenize.NewIntLiteral(1)
+	next, err := c.compoundAssign(vm.ADD, variable(field.Position), one)
+	if err != nil {
+		return nil, err
 	}
-	return add(node)
+	program = append(program, next...)
+	program = append(program, []vm.Data{
+		*vm.NewOpcodeData(vm.JMP), *vm.NewLabelData(*vm.NewLabel(false, condLabel)),
+	}...)
+	program = append(program, *vm.NewLabelData(*vm.NewLabel(true, endLabel)))
+	return program, nil
 }
 
-func add(node *parse.Node) ([]vm.Data, error) {
+// ifElse `if ... else if ... else ...`の連鎖を一つの比較の列として展開する
+// それぞれのブロックの末尾から終了ラベルへ直接ジャンプするので、ネストしたifを経由しない
+func (c *Compiler) ifElse(node *ir.Node) ([]vm.Data, error) {
 	var program []vm.Data
-	switch node.Kind {
-	case parse.NdAdd, parse.NdSub:
-		var op vm.Opcode
-		if node.Kind == parse.NdAdd {
-			op = vm.ADD
-		} else {
-			op = vm.SUB
+	var conds []*ir.Node
+	var blocks []*ir.Node
+	var elseBlock *ir.Node
+	for n := node; ; {
+		conds = append(conds, n.IfField.Cond)
+		blocks = append(blocks, n.IfField.Then)
+		if n.IfField.Else == nil {
+			break
 		}
+		if n.IfField.Else.Kind == ir.If {
+			n = n.IfField.Else
+			continue
+		}
+		elseBlock = n.IfField.Else
+		break
+	}
 
-		// 左辺を計算
-		lhs, err := add(node.BinaryField.Lhs)
+	// 各ジャンプ先のラベルを用意
+	var blockLabels []string
+	for range blocks {
+		blockLabels = append(blockLabels, c.newLabel("if_block"))
+	}
+	endLabel := c.newLabel("if_end")
+
+	// 条件に合致したらそれぞれのブロックへ飛ぶ
+	for i, condition := range conds {
+		cond, err := c.branch(condition, blockLabels[i], true)
 		if err != nil {
 			return nil, err
 		}
-		program = append(program, lhs...)
-		// 右辺を計算
-		rhs, err := add(node.BinaryField.Rhs)
+		program = append(program, cond...)
+	}
+
+	// どの条件にも合致しなかった場合はelseのブロックを展開
+	if elseBlock != nil {
+		e, err := c.stmt(elseBlock)
 		if err != nil {
 			return nil, err
 		}
-		program = append(program, rhs...)
-		// 結果をスタックから取り出す
-		program = append(program, []vm.Data{
-			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R2),
-			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
-		}...)
-		// 足し算/引き算
-		program = append(program, []vm.Data{
-			// r1 += r2
-			*vm.NewOpcodeData(op), *vm.NewRegisterTagData(vm.R2), *vm.NewRegisterTagData(vm.R1),
-		}...)
-		// 結果R1をスタックにプッシュ
+		program = append(program, e...)
+	}
+	program = append(program, []vm.Data{
+		*vm.NewOpcodeData(vm.JMP), *vm.NewLabelData(*vm.NewLabel(false, endLabel)),
+	}...)
+
+	for i, block := range blocks {
+		program = append(program, *vm.NewLabelData(*vm.NewLabel(true, blockLabels[i])))
+		b, err := c.stmt(block)
+		if err != nil {
+			return nil, err
+		}
+		program = append(program, b...)
+		// 最後のブロックはそのまま終了ラベルに到達する
+		if i != len(blocks)-1 {
+			program = append(program, []vm.Data{
+				*vm.NewOpcodeData(vm.JMP), *vm.NewLabelData(*vm.NewLabel(false, endLabel)),
+			}...)
+		}
+	}
+
+	// 最終的なジャンプ先
+	program = append(program, *vm.NewLabelData(*vm.NewLabel(true, endLabel)))
+	return program, nil
+}
+
+// switch_ caseの値を上から順に比較してジャンプする
+// タグがある場合はタグの値をスタックに置いたまま比較し、ジャンプ先で取り除く
+func (c *Compiler) switch_(node *ir.Node) ([]vm.Data, error) {
+	var program []vm.Data
+	field := node.SwitchField
+	hasTag := field.Tag != nil
+
+	endLabel := c.newLabel("switch_end")
+	var caseLabels []string
+	defaultLabel := ""
+	for _, cs := range field.Cases {
+		label := c.newLabel("switch_case")
+		caseLabels = append(caseLabels, label)
+		if cs.IsDefault {
+			defaultLabel = label
+		}
+	}
+
+	if hasTag {
+		tag, err := c.expr(field.Tag)
+		if err != nil {
+			return nil, err
+		}
+		program = append(program, tag...)
+	}
+
+	for i, cs := range field.Cases {
+		for _, v := range cs.Values {
+			if !hasTag {
+				cond, err := c.branch(v, caseLabels[i], true)
+				if err != nil {
+					return nil, err
+				}
+				program = append(program, cond...)
+				continue
+			}
+			val, err := c.expr(v)
+			if err != nil {
+				return nil, err
+			}
+			program = append(program, val...)
+			program = append(program, []vm.Data{
+				*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R2),
+				// スタックのトップに残っているタグの値
+				*vm.NewOpcodeData(vm.MOV), *vm.NewOffsetData(*vm.NewOffset(vm.SP, 0)), *vm.NewRegisterTagData(vm.R1),
+				*vm.NewOpcodeData(vm.CMP), *vm.NewRegisterTagData(vm.R1), *vm.NewRegisterTagData(vm.R2),
+				*vm.NewOpcodeData(vm.JZ), *vm.NewLabelData(*vm.NewLabel(false, caseLabels[i])),
+			}...)
+		}
+	}
+
+	// どれにも合致しなかった場合
+	noMatchLabel := defaultLabel
+	if noMatchLabel == "" {
+		noMatchLabel = c.newLabel("switch_no_match")
+	}
+	program = append(program, []vm.Data{
+		*vm.NewOpcodeData(vm.JMP), *vm.NewLabelData(*vm.NewLabel(false, noMatchLabel)),
+	}...)
+
+	for i, cs := range field.Cases {
+		program = append(program, *vm.NewLabelData(*vm.NewLabel(true, caseLabels[i])))
+		if hasTag {
+			program = append(program, discard(1)...)
+		}
+		body, err := c.stmt(cs.Body)
+		if err != nil {
+			return nil, err
+		}
+		program = append(program, body...)
 		program = append(program, []vm.Data{
-			*vm.NewOpcodeData(vm.PUSH), *vm.NewRegisterTagData(vm.R1),
+			*vm.NewOpcodeData(vm.JMP), *vm.NewLabelData(*vm.NewLabel(false, endLabel)),
 		}...)
-		return program, nil
 	}
-	return mul(node)
+	if defaultLabel == "" {
+		program = append(program, *vm.NewLabelData(*vm.NewLabel(true, noMatchLabel)))
+		if hasTag {
+			program = append(program, discard(1)...)
+		}
+	}
+
+	program = append(program, *vm.NewLabelData(*vm.NewLabel(true, endLabel)))
+	return program, nil
+}
+
+// discard スタックのトップからn個の値を捨てる
+func discard(n int) []vm.Data {
+	return []vm.Data{
+		*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralData(*vm.NewLiteral(n)),
+		*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
+		*vm.NewOpcodeData(vm.ADD), *vm.NewRegisterTagData(vm.R1), *vm.NewRegisterTagData(vm.RSP),
+	}
+}
+
+// reserve 呼び出す関数の戻り値の領域をn個確保する
+func reserve(n int) []vm.Data {
+	return []vm.Data{
+		*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralData(*vm.NewLiteral(n)),
+		*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
+		*vm.NewOpcodeData(vm.SUB), *vm.NewRegisterTagData(vm.R1), *vm.NewRegisterTagData(vm.RSP),
+	}
 }
 
-func mul(node *parse.Node) ([]vm.Data, error) {
+// isComparison 比較の演算子か
+func isComparison(op ir.Op) bool {
+	switch op {
+	case ir.OpEq, ir.OpNe, ir.OpLt, ir.OpLe, ir.OpGt, ir.OpGe:
+		return true
+	}
+	return false
+}
+
+// compare 比較を行いZFを設定する
+// OpNeの場合は一致したときにZF=1となるので、呼び出し側で反転して扱う
+func (c *Compiler) compare(node *ir.Node) ([]vm.Data, error) {
 	var program []vm.Data
-	switch node.Kind {
-	case parse.NdMul, parse.NdDiv:
-		var op vm.Opcode
-		if node.Kind == parse.NdMul {
-			op = vm.MUL
-		} else {
-			op = vm.DIV
+	lhs, err := c.expr(node.BinaryField.Lhs)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := c.expr(node.BinaryField.Rhs)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, lhs...)
+	program = append(program, rhs...)
+	program = append(program, []vm.Data{
+		*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R2),
+		*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
+	}...)
+
+	switch node.BinaryField.Op {
+	case ir.OpLt:
+		program = append(program, *vm.NewOpcodeData(vm.LT), *vm.NewRegisterTagData(vm.R1), *vm.NewRegisterTagData(vm.R2))
+	case ir.OpLe:
+		program = append(program, *vm.NewOpcodeData(vm.LE), *vm.NewRegisterTagData(vm.R1), *vm.NewRegisterTagData(vm.R2))
+	case ir.OpGt:
+		program = append(program, *vm.NewOpcodeData(vm.LT), *vm.NewRegisterTagData(vm.R2), *vm.NewRegisterTagData(vm.R1))
+	case ir.OpGe:
+		program = append(program, *vm.NewOpcodeData(vm.LE), *vm.NewRegisterTagData(vm.R2), *vm.NewRegisterTagData(vm.R1))
+	case ir.OpEq, ir.OpNe:
+		program = append(program, *vm.NewOpcodeData(vm.CMP), *vm.NewRegisterTagData(vm.R1), *vm.NewRegisterTagData(vm.R2))
+	default:
+		return nil, diagnostic.Errorf("C0006")
+	}
+	return program, nil
+}
+
+// branch 条件の真偽がwhenと一致した場合にlabelへジャンプする
+// 一致しなかった場合はそのまま次の命令へ進む
+func (c *Compiler) branch(node *ir.Node, label string, when bool) ([]vm.Data, error) {
+	var program []vm.Data
+	jump := func(zf bool) []vm.Data {
+		op := vm.JZ
+		if !zf {
+			op = vm.JNZ
+		}
+		return []vm.Data{
+			*vm.NewOpcodeData(op), *vm.NewLabelData(*vm.NewLabel(false, label)),
 		}
-		// 左辺を計算
-		lhs, err := mul(node.BinaryField.Lhs)
+	}
+	switch {
+	case node.Kind == ir.Binary && isComparison(node.BinaryField.Op):
+		cmp, err := c.compare(node)
 		if err != nil {
 			return nil, err
 		}
-		program = append(program, lhs...)
-		// 右辺を計算
-		rhs, err := mul(node.BinaryField.Rhs)
+		program = append(program, cmp...)
+		program = append(program, jump(when != (node.BinaryField.Op == ir.OpNe))...)
+		return program, nil
+	case node.Kind == ir.Not:
+		return c.branch(node.UnaryField.Value, label, !when)
+	case node.Kind == ir.Binary && (node.BinaryField.Op == ir.OpAnd || node.BinaryField.Op == ir.OpOr):
+		// 左辺だけで結果が決まる場合は右辺を評価しない
+		op := node.BinaryField.Op
+		shortCircuit := (op == ir.OpAnd && when) || (op == ir.OpOr && !when)
+		if !shortCircuit {
+			lhs, err := c.branch(node.BinaryField.Lhs, label, when)
+			if err != nil {
+				return nil, err
+			}
+			rhs, err := c.branch(node.BinaryField.Rhs, label, when)
+			if err != nil {
+				return nil, err
+			}
+			program = append(program, lhs...)
+			program = append(program, rhs...)
+			return program, nil
+		}
+		skipLabel := c.newLabel("cond_skip")
+		lhs, err := c.branch(node.BinaryField.Lhs, skipLabel, !when)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := c.branch(node.BinaryField.Rhs, label, when)
 		if err != nil {
 			return nil, err
 		}
+		program = append(program, lhs...)
 		program = append(program, rhs...)
-		// 結果をスタックから取り出す
-		program = append(program, []vm.Data{
-			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R2),
-			*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
-		}...)
-		// 掛け算割り算
-		program = append(program, []vm.Data{
-			// r1 += r2
-			*vm.NewOpcodeData(op), *vm.NewRegisterTagData(vm.R2), *vm.NewRegisterTagData(vm.R1),
-		}...)
-		// 結果R1をスタックにプッシュ
-		program = append(program, []vm.Data{
-			*vm.NewOpcodeData(vm.PUSH), *vm.NewRegisterTagData(vm.R1),
-		}...)
+		program = append(program, *vm.NewLabelData(*vm.NewLabel(true, skipLabel)))
 		return program, nil
-
 	}
-	return unary(node)
+	// boolの値(1 or 0)として計算されるもの
+	val, err := c.expr(node)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, val...)
+	program = append(program, []vm.Data{
+		*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
+		*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralData(*vm.NewLiteral(1)),
+		*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R2),
+		*vm.NewOpcodeData(vm.CMP), *vm.NewRegisterTagData(vm.R1), *vm.NewRegisterTagData(vm.R2),
+	}...)
+	program = append(program, jump(when)...)
+	return program, nil
 }
 
-func unary(node *parse.Node) ([]vm.Data, error) {
-	return primary(node)
+// boolValue 条件式の結果をboolの値(1 or 0)としてスタックにプッシュする
+func (c *Compiler) boolValue(node *ir.Node) ([]vm.Data, error) {
+	var program []vm.Data
+	trueLabel := c.newLabel("bool_true")
+	endLabel := c.newLabel("bool_end")
+	cond, err := c.branch(node, trueLabel, true)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, cond...)
+	program = append(program, []vm.Data{
+		*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralData(*vm.NewLiteral(0)),
+		*vm.NewOpcodeData(vm.JMP), *vm.NewLabelData(*vm.NewLabel(false, endLabel)),
+	}...)
+	program = append(program, *vm.NewLabelData(*vm.NewLabel(true, trueLabel)))
+	program = append(program, []vm.Data{
+		*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralData(*vm.NewLiteral(1)),
+	}...)
+	program = append(program, *vm.NewLabelData(*vm.NewLabel(true, endLabel)))
+	return program, nil
 }
 
-func primary(node *parse.Node) ([]vm.Data, error) {
-	return access(node)
+// expr 式の値をスタックにプッシュする
+// 複数の値を返す呼び出しは1つ目の値がトップに来る
+func (c *Compiler) expr(node *ir.Node) ([]vm.Data, error) {
+	switch node.Kind {
+	case ir.Binary:
+		if _, ok := arithmetics[node.BinaryField.Op]; ok {
+			return c.arithmetic(node)
+		}
+		return c.boolValue(node)
+	case ir.Not:
+		return c.boolValue(node)
+	case ir.Field, ir.Index:
+		program, err := c.address(node)
+		if err != nil {
+			return nil, err
+		}
+		return append(program, copyValue(node.Type())...), nil
+	case ir.Slice:
+		return c.slice(node)
+	case ir.Literal:
+		l := literalFromField(node.Literal)
+		if l == nil {
+			break
+		}
+		return []vm.Data{
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralData(*l),
+		}, nil
+	case ir.Var:
+		program, err := load(node.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		return append(program, copyValue(node.Symbol.Type)...), nil
+	case ir.FuncRef:
+		// 関数名は何もキャプチャしない関数の値になる
+		return []vm.Data{
+			*vm.NewOpcodeData(vm.CLOSURE), *vm.NewLabelData(*vm.NewLabel(false, node.Symbol.Label())), *vm.NewLiteralDataWithRaw(0),
+		}, nil
+	case ir.Call:
+		return c.call(node)
+	case ir.CallValue:
+		return c.indirectCall(node)
+	case ir.CallMethod:
+		return c.methodCall(node)
+	case ir.Builtin:
+		return c.builtinCall(node)
+	case ir.StructLit:
+		return c.structLit(node)
+	case ir.ListLit:
+		return c.list(node)
+	case ir.DictLit:
+		// キー、値の順にプッシュする
+		var program []vm.Data
+		for i, key := range node.DictField.Keys {
+			k, err := c.expr(key)
+			if err != nil {
+				return nil, err
+			}
+			program = append(program, k...)
+			v, err := c.expr(node.DictField.Values[i])
+			if err != nil {
+				return nil, err
+			}
+			program = append(program, v...)
+		}
+		return append(program, *vm.NewOpcodeData(vm.MAP), *vm.NewLiteralDataWithRaw(len(node.DictField.Keys))), nil
+	case ir.FuncLit:
+		return funcLit(node)
+	case ir.Convert:
+		return c.convert(node)
+	}
+	return nil, diagnostic.Errorf("C0007")
 }
 
-func access(node *parse.Node) ([]vm.Data, error) {
-	return literal(node)
+// arithmetic 四則演算と剰余算
+func (c *Compiler) arithmetic(node *ir.Node) ([]vm.Data, error) {
+	var program []vm.Data
+	// 左辺を計算
+	lhs, err := c.expr(node.BinaryField.Lhs)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, lhs...)
+	// 右辺を計算
+	rhs, err := c.expr(node.BinaryField.Rhs)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, rhs...)
+	// 結果をスタックから取り出す
+	program = append(program, []vm.Data{
+		*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R2),
+		*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
+	}...)
+	program = append(program, []vm.Data{
+		// r1 op= r2
+		*vm.NewOpcodeData(arithmetics[node.BinaryField.Op]), *vm.NewRegisterTagData(vm.R2), *vm.NewRegisterTagData(vm.R1),
+	}...)
+	// 結果R1をスタックにプッシュ
+	program = append(program, []vm.Data{
+		*vm.NewOpcodeData(vm.PUSH), *vm.NewRegisterTagData(vm.R1),
+	}...)
+	return program, nil
 }
 
-func literalFromField(l *parse.LiteralField) *vm.Literal {
+func literalFromField(l *tokenize.Literal) *vm.Literal {
 	switch l.Kind {
 	case tokenize.LInt:
 		return vm.NewLiteral(l.I)
@@ -447,103 +1211,331 @@ func literalFromField(l *parse.LiteralField) *vm.Literal {
 	}
 }
 
-func literal(node *parse.Node) ([]vm.Data, error) {
-	switch node.Kind {
-	case parse.NdLiteral:
-		return []vm.Data{
-			*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralData(*literalFromField(node.LiteralField)),
-		}, nil
-	case parse.NdParenthesis:
-		return expr(node.UnaryField.Value)
-	case parse.NdCall:
-		var program []vm.Data
-		// 引数があるかチェック
-		if node.CallField.Args != nil {
-			args := node.CallField.Args.PolynomialField.Values
-			for i, j := 0, len(args)-1; i < j; i, j = i+1, j-1 {
-				args[i], args[j] = args[j], args[i]
-			}
-			for _, arg := range args {
-				// 計算結果はプッシュされるので、逆順に実行してあげるだけで良い..?
-				p, err := expr(arg)
-				if err != nil {
-					return nil, err
-				}
-				program = append(program, p...)
-			}
-			program = append(program, []vm.Data{
-				*vm.NewOpcodeData(vm.CALL), *vm.NewLabelData(*vm.NewLabel(false, node.CallField.Identifier.IdentField.Ident)),
-			}...)
-			// 引数分spを加算
-			program = append(program, []vm.Data{
-				*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralData(*vm.NewLiteral(len(args))),
-				*vm.NewOpcodeData(vm.POP), *vm.NewRegisterTagData(vm.R1),
-				*vm.NewOpcodeData(vm.ADD), *vm.NewRegisterTagData(vm.R1), *vm.NewRegisterTagData(vm.RSP),
-			}...)
+// args 引数は1つ目の値がスタックのトップに来るように、逆順に計算する
+// 複数の値を返す関数も1つ目の値がトップに来るので、そのまま引数として扱える
+func (c *Compiler) args(node *ir.Node) ([]vm.Data, error) {
+	var program []vm.Data
+	values := node.CallField.Args
+	for i := len(values) - 1; 0 <= i; i-- {
+		p, err := c.expr(values[i])
+		if err != nil {
+			return nil, err
 		}
+		program = append(program, p...)
+	}
+	return program, nil
+}
 
-		// 戻り地に関する記述..?
-		switch len(semOverall.KnownFunctions[node.CallField.Identifier.IdentField.Ident].Returns) {
-		case 1:
-			program = append(program, []vm.Data{
-				*vm.NewOpcodeData(vm.PUSH), *vm.NewRegisterTagData(vm.R10),
-			}...)
-		case 2:
-			program = append(program, []vm.Data{
-				*vm.NewOpcodeData(vm.PUSH), *vm.NewRegisterTagData(vm.R11),
-				*vm.NewOpcodeData(vm.PUSH), *vm.NewRegisterTagData(vm.R10),
-			}...)
-		default:
-			return nil, fmt.Errorf("2つより多い戻り値には対応していません")
+// call 関数、メソッドを名前で呼び出す
+func (c *Compiler) call(node *ir.Node) ([]vm.Data, error) {
+	var program []vm.Data
+	fnType := node.CallField.FuncType
+	// 戻り値を格納する領域を引数よりも先に確保する
+	if n := len(fnType.Returns); n != 0 {
+		program = append(program, reserve(n)...)
+	}
+	a, err := c.args(node)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, a...)
+	program = append(program, []vm.Data{
+		*vm.NewOpcodeData(vm.CALL), *vm.NewLabelData(*vm.NewLabel(false, node.Symbol.Label())),
+	}...)
+	// 引数分spを加算
+	// 取り除いた後は戻り値がスタックのトップに残る
+	if n := len(fnType.Params); n != 0 {
+		program = append(program, discard(n)...)
+	}
+	return program, nil
+}
+
+// structLit 宣言された順にフィールドの値をプッシュする
+func (c *Compiler) structLit(node *ir.Node) ([]vm.Data, error) {
+	var program []vm.Data
+	typ := node.Type()
+	for i, f := range typ.Fields {
+		var v []vm.Data
+		var err error
+		if value := node.ListField.Values[i]; value == nil {
+			v, err = zeroValue(f.DataType)
+		} else {
+			v, err = c.expr(value)
 		}
-		return program, nil
-	case parse.NdIdent:
-		loc := searchBPDistFromVarName(currentFnVariableBPs, currentNest, node.IdentField.Ident)
-		if loc == -1 {
-			for _, g := range globals {
-				if node.IdentField.Ident == g {
-					// global変数として存在する
-					return []vm.Data{
-						*vm.NewOpcodeData(vm.MOV), *vm.NewLabelData(*vm.NewLabel(false, node.IdentField.Ident)), *vm.NewRegisterTagData(vm.R1),
-						*vm.NewOpcodeData(vm.PUSH), *vm.NewRegisterTagData(vm.R1),
-					}, nil
-				}
-			}
-			return nil, fmt.Errorf("変数が定義されていません: %s", node.IdentField.Ident)
+		if err != nil {
+			return nil, err
 		}
-		return []vm.Data{
-			*vm.NewOpcodeData(vm.PUSH), *vm.NewOffsetData(*vm.NewOffset(vm.BP, -loc)),
-		}, nil
+		program = append(program, v...)
 	}
-	return nil, fmt.Errorf("サポートされていないリテラルです")
+	program = append(program, *vm.NewOpcodeData(vm.NEW), *vm.NewLiteralDataWithRaw(len(typ.Fields)))
+	return program, nil
 }
 
-func Compile(sem *analyze.Semantics) ([]vm.Data, error) {
-	semOverall = sem
-	currentNest = 0
-	if len(sem.OutsideValues) != 0 || len(sem.OutsideFunctions) != 0 {
-		return nil, fmt.Errorf("リンクが不完全です")
+// funcLit キャプチャした変数のポインタと組み合わせて関数の値を作る
+// 本体はプログラムの関数の一つとして出力される
+func funcLit(node *ir.Node) ([]vm.Data, error) {
+	var program []vm.Data
+	field := node.FuncLitField
+	for _, c := range field.Captures {
+		if !c.IsVariable() {
+			return nil, diagnostic.Errorf("C0008", c.Name)
+		}
+		program = append(program, *vm.NewOpcodeData(vm.PUSH), slot(c))
+	}
+	program = append(program,
+		*vm.NewOpcodeData(vm.CLOSURE), *vm.NewLabelData(*vm.NewLabel(false, field.Func.Symbol.Label())), *vm.NewLiteralDataWithRaw(len(field.Captures)),
+	)
+	return program, nil
+}
+
+// indirectCall 関数の値をCALLRで呼び出す
+// 引数と戻り値の扱いは関数名での呼び出しと同じ
+func (c *Compiler) indirectCall(node *ir.Node) ([]vm.Data, error) {
+	var program []vm.Data
+	fnType := node.CallField.FuncType
+	if n := len(fnType.Returns); n != 0 {
+		program = append(program, reserve(n)...)
 	}
+	a, err := c.args(node)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, a...)
+	callee, err := c.expr(node.CallField.Callee)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, callee...)
+	program = append(program, *vm.NewOpcodeData(vm.CALLR))
+	program = append(program, discard(len(fnType.Params))...)
+	return program, nil
+}
+
+// methodCall インターフェースの値を通してメソッドを呼び出す
+// CALLIがインターフェースの値をレシーバに置き換えるので、引数の数はレシーバの分多くなる
+func (c *Compiler) methodCall(node *ir.Node) ([]vm.Data, error) {
 	var program []vm.Data
-	for _, n := range sem.Tree {
-		switch n.Kind {
-		case parse.NdFuncDef:
-			frags, err := defFunction(n)
+	fnType := node.CallField.FuncType
+	if n := len(fnType.Returns); n != 0 {
+		program = append(program, reserve(n)...)
+	}
+	a, err := c.args(node)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, a...)
+	iface, err := c.expr(node.CallField.Callee)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, iface...)
+	program = append(program, *vm.NewOpcodeData(vm.CALLI), *vm.NewLiteralDataWithRaw(node.CallField.Name))
+	program = append(program, discard(len(fnType.Params)+1)...)
+	return program, nil
+}
+
+// convert 値をインターフェースの値[メソッド名から関数の値へのマップ, 値]にする
+// インターフェース同士の変換ではマップに必要なメソッドが揃っているのでそのまま使用する
+func (c *Compiler) convert(node *ir.Node) ([]vm.Data, error) {
+	field := node.ConvertField
+	if field.From.Type == parse.Interface {
+		return c.expr(field.Value)
+	}
+	var program []vm.Data
+	for i, m := range field.To.Methods {
+		program = append(program,
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(m.Name),
+			*vm.NewOpcodeData(vm.CLOSURE), *vm.NewLabelData(*vm.NewLabel(false, field.Methods[i].Label())), *vm.NewLiteralDataWithRaw(0),
+		)
+	}
+	program = append(program, *vm.NewOpcodeData(vm.MAP), *vm.NewLiteralDataWithRaw(len(field.To.Methods)))
+	value, err := c.expr(field.Value)
+	if err != nil {
+		return nil, err
+	}
+	program = append(program, value...)
+	program = append(program, *vm.NewOpcodeData(vm.NEW), *vm.NewLiteralDataWithRaw(2))
+	return program, nil
+}
+
+// list 要素を順にプッシュして配列を作る, スライスの場合は配列全体を参照するスライスにする
+func (c *Compiler) list(node *ir.Node) ([]vm.Data, error) {
+	var program []vm.Data
+	typ := node.Type()
+	for _, v := range node.ListField.Values {
+		p, err := c.expr(v)
+		if err != nil {
+			return nil, err
+		}
+		program = append(program, p...)
+	}
+	n := len(node.ListField.Values)
+	if typ.Type == parse.Array {
+		// 省略された要素はゼロ値
+		for ; n < typ.Len; n++ {
+			z, err := zeroValue(typ.Base)
 			if err != nil {
 				return nil, err
 			}
-			program = append(program, frags...)
-		case parse.NdAssign:
-			globals = append(globals, n.AssignField.To.VarDeclField.Identifier.IdentField.Ident)
-			dataSection = append(dataSection, []vm.Data{
-				*vm.NewOpcodeData(vm.MOV), *vm.NewLiteralData(*literalFromField(n.AssignField.Value.LiteralField)), *vm.NewLabelData(*vm.NewLabel(false, n.AssignField.To.VarDeclField.Identifier.IdentField.Ident)),
-			}...)
-		case parse.NdVarDecl:
-			globals = append(globals, n.VarDeclField.Identifier.IdentField.Ident)
+			program = append(program, z...)
+		}
+		return append(program, *vm.NewOpcodeData(vm.ARRAY), *vm.NewLiteralDataWithRaw(n)), nil
+	}
+	return append(program,
+		*vm.NewOpcodeData(vm.ARRAY), *vm.NewLiteralDataWithRaw(n),
+		*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(0),
+		*vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(n),
+		*vm.NewOpcodeData(vm.SLICE),
+	), nil
+}
+
+// slice `a[low:high]`
+// 配列は複製せずに元の配列を参照する
+func (c *Compiler) slice(node *ir.Node) ([]vm.Data, error) {
+	field := node.SliceField
+	program, err := c.address(field.Target)
+	if err != nil {
+		return nil, err
+	}
+	if field.Low == nil {
+		program = append(program, *vm.NewOpcodeData(vm.PUSH), *vm.NewLiteralDataWithRaw(0))
+	} else {
+		low, err := c.expr(field.Low)
+		if err != nil {
+			return nil, err
+		}
+		program = append(program, low...)
+	}
+	if field.High == nil {
+		// 対象の長さ
+		program = append(program,
+			*vm.NewOpcodeData(vm.PUSH), *vm.NewOffsetData(*vm.NewOffset(vm.SP, 1)),
+			*vm.NewOpcodeData(vm.LEN),
+		)
+	} else {
+		high, err := c.expr(field.High)
+		if err != nil {
+			return nil, err
+		}
+		program = append(program, high...)
+	}
+	return append(program, *vm.NewOpcodeData(vm.SLICE)), nil
+}
+
+// builtinCall 組み込み関数は専用の命令に置き換える
+func (c *Compiler) builtinCall(node *ir.Node) ([]vm.Data, error) {
+	args := node.CallField.Args
+	switch node.CallField.Name {
+	case "len":
+		// 長さを調べるだけなので配列を複製する必要はない
+		program, err := c.address(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return append(program, *vm.NewOpcodeData(vm.LEN)), nil
+	case "delete":
+		program, err := c.address(args[0])
+		if err != nil {
+			return nil, err
+		}
+		key, err := c.expr(args[1])
+		if err != nil {
+			return nil, err
 		}
+		program = append(program, key...)
+		return append(program, *vm.NewOpcodeData(vm.DELETE)), nil
+	case "append":
+		program, err := c.expr(args[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, arg := range args[1:] {
+			v, err := c.expr(arg)
+			if err != nil {
+				return nil, err
+			}
+			program = append(program, v...)
+			program = append(program, *vm.NewOpcodeData(vm.APPEND))
+		}
+		return program, nil
+	case "int", "float", "string":
+		program, err := c.expr(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return append(program, *vm.NewOpcodeData(vm.CONV), *vm.NewLiteralDataWithRaw(node.CallField.Name)), nil
+	case "atoi", "atof":
+		program, err := c.expr(args[0])
+		if err != nil {
+			return nil, err
+		}
+		kind := map[string]string{"atoi": "int", "atof": "float"}[node.CallField.Name]
+		return append(program, *vm.NewOpcodeData(vm.PARSE), *vm.NewLiteralDataWithRaw(kind)), nil
 	}
+	return nil, diagnostic.Errorf("C0009", node.CallField.Name)
+}
+
+// Compile 新しいCompilerで解析したプログラムをコンパイルする
+func Compile(sem *analyze.Semantics) ([]vm.Data, error) {
+	return NewCompiler().Compile(sem)
+}
 
-	program = append(program, *vm.NewLabelData(*vm.NewLabel(true, ".data")))
-	program = append(program, dataSection...)
+// Compile 解析したプログラムをコンパイルする
+func (c *Compiler) Compile(sem *analyze.Semantics) ([]vm.Data, error) {
+	// 他のパッケージへの参照はLinkで解決されている必要がある
+	if len(sem.Program.Externals()) != 0 {
+		return nil, diagnostic.Errorf("C0010")
+	}
+	// 関数より後に宣言されたグローバル変数も参照できるように先に集める
+	if err := c.globalVariables(sem.Program.Globals); err != nil {
+		return nil, err
+	}
+	// init関数はグローバル変数を初期化した後、関数の並びの順に呼び出す
+	for _, f := range sem.Program.Functions {
+		if f.Symbol.Name == "init" {
+			c.dataSection = append(c.dataSection,
+				*vm.NewOpcodeData(vm.CALL), *vm.NewLabelData(*vm.NewLabel(false, f.Symbol.Label())))
+		}
+	}
+	var program []vm.Data
+	for _, f := range sem.Program.Functions {
+		frags, err := c.defFunction(f)
+		if err != nil {
+			return nil, err
+		}
+		program = append(program, frags...)
+	}
 	return program, nil
 }
+
+// globalVariables グローバル変数に初期値かゼロ値を代入する命令を作る
+func (c *Compiler) globalVariables(globals []*ir.Node) error {
+	c.dataSection = nil
+	for _, g := range globals {
+		init, err := c.globalVariable(g)
+		if err != nil {
+			return err
+		}
+		c.dataSection = append(c.dataSection, init...)
+	}
+	return nil
+}
+
+// globalVariable 1つのグローバル変数に初期値かゼロ値を代入する命令
+func (c *Compiler) globalVariable(g *ir.Node) ([]vm.Data, error) {
+	var init []vm.Data
+	var err error
+	if g.DeclField.Value == nil {
+		init, err = zeroValue(g.Symbol.Type)
+	} else {
+		init, err = c.expr(g.DeclField.Value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	store, err := storeVariable(g.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	return append(init, store...), nil
+}
@@ -1,11 +1,14 @@
 package assemble
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/arrietty-lang/arrtty/preprocess/analyze"
 	"github.com/arrietty-lang/arrtty/preprocess/parse"
 	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
 	"github.com/arrietty-lang/arrtty/vm"
 	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
 )
 
@@ -30,6 +33,16 @@ func main() int {
 		`,
 			3,
 		},
+		{
+			"negative operands",
+			`
+func main() int {
+	x := 3
+	return 100 + x * -1 + 7 / -2 + -5 % 3 + -x * 2
+}
+		`,
+			86,
+		},
 		{
 			"f",
 			`
@@ -74,6 +87,716 @@ func main() int {
 				`,
 			55,
 		},
+		{
+			"multiple returns",
+			`
+func three() (int, int, int) {
+	return 10, 3, 2
+}
+func four() (int, int, int, int) {
+	return 1, three()
+}
+func sub4(a int, b int, c int, d int) int {
+	return a - b - c - d
+}
+func main() int {
+	return sub4(four())
+}
+				`,
+			-14,
+		},
+		{
+			"void call",
+			`
+func nothing() {
+}
+func one() int {
+	return 1
+}
+func main() int {
+	var x int = 2
+	nothing()
+	one()
+	return x
+}
+				`,
+			2,
+		},
+		{
+			"for inc",
+			`
+func main() int {
+	sum := 0
+	for i := 0; i < 10; i++ {
+		sum += i
+	}
+	return sum
+}
+				`,
+			45,
+		},
+		{
+			"compound assign",
+			`
+func main() int {
+	x := 7
+	y := 6
+	x *= y
+	x -= 2
+	x /= 4
+	x %= 7
+	x--
+	x += y
+	return x
+}
+				`,
+			8,
+		},
+		{
+			"for cond only",
+			`
+func main() int {
+	n := 1
+	for n < 100 {
+		n *= 2
+	}
+	return n
+}
+				`,
+			128,
+		},
+		{
+			"else if",
+			`
+func grade(n int) int {
+	if n < 10 {
+		return 1
+	} else if n < 20 {
+		return 2
+	} else if n == 20 {
+		return 3
+	}
+	return 4
+}
+func main() int {
+	x := 0
+	if x == 1 {
+		x = 1
+	} else if x == 2 {
+		x = 2
+	} else {
+		x = grade(5)*1000 + grade(15)*100 + grade(20)*10 + grade(25)
+	}
+	return x
+}
+				`,
+			1234,
+		},
+		{
+			"switch",
+			`
+func kind(n int) int {
+	r := 0
+	switch n % 4 {
+	case 0:
+		return 10
+	case 1, 2:
+		r = 20
+	default:
+		r = 30
+	}
+	return r
+}
+func main() int {
+	sum := 0
+	for i := 0; i < 8; i++ {
+		sum += kind(i)
+	}
+	return sum
+}
+				`,
+			160,
+		},
+		{
+			"tagless switch",
+			`
+func sign(n int) int {
+	r := 0
+	switch {
+	case n < 0:
+		r = -1
+	case n > 0 && n != 100:
+		r = 1
+	}
+	return r
+}
+func main() int {
+	return sign(-5)*100 + sign(0)*10 + sign(7) + sign(100)
+}
+				`,
+			-99,
+		},
+		{
+			"bool value",
+			`
+func main() int {
+	x := 3
+	small := x < 5
+	if !small || x == 4 {
+		return 0
+	}
+	switch "b" {
+	case "a":
+		return 1
+	case "b":
+		return 2
+	}
+	return 3
+}
+				`,
+			2,
+		},
+		{
+			"struct",
+			`
+type Point struct {
+	x int
+	y int
+}
+
+type Line struct {
+	from Point
+	to Point
+}
+
+func length(l Line) int {
+	return l.to.x - l.from.x + l.to.y - l.from.y
+}
+
+func origin() Point {
+	return Point{}
+}
+
+func main() int {
+	p := Point{x: 1, y: 2}
+	q := p
+	q.x = 10
+	var l Line
+	l.to = q
+	l.to.y += 5
+	l.from = origin()
+	l.from.x++
+	return p.x*1000 + length(l)*10 + l.to.y
+}
+				`,
+			1000 + (10-1+7-0)*10 + 7,
+		},
+		{
+			"array",
+			`
+func sum(a [4]int) int {
+	total := 0
+	for i := 0; i < len(a); i++ {
+		total += a[i]
+	}
+	return total
+}
+
+func main() int {
+	a := [4]int{1, 2, 3}
+	b := a
+	b[3] = 10
+	b[0] += 5
+	var grid [2][2]int
+	grid[1][0] = 7
+	return sum(a)*1000 + sum(b)*10 + grid[1][0]
+}
+				`,
+			6*1000 + 21*10 + 7,
+		},
+		{
+			"slice",
+			`
+func push(s []int, n int) []int {
+	for i := 0; i < n; i++ {
+		s = append(s, i)
+	}
+	return s
+}
+
+func main() int {
+	var s []int
+	s = push(s, 5)
+	t := s[1:3]
+	t[0] = 100
+	u := append(t, 7, 8)
+	a := [3]int{1, 2, 3}
+	v := a[:]
+	v[2] = 30
+	return len(s)*10000 + s[1] + len(u)*1000 + a[2] + len(s[2:]) + len("abc")
+}
+				`,
+			5*10000 + 100 + 4*1000 + 30 + 3 + 3,
+		},
+		{
+			"map",
+			`
+func count(words []string) map[string]int {
+	counts := map[string]int{}
+	for _, w := range words {
+		counts[w]++
+	}
+	return counts
+}
+
+func main() int {
+	m := count([]string{"a", "b", "a", "c", "a"})
+	v, ok := m["a"]
+	_, missing := m["z"]
+	delete(m, "c")
+	total := 0
+	for k, n := range m {
+		if k != "z" {
+			total += n
+		}
+	}
+	flags := map[int]bool{1: true}
+	flags[2] = false
+	result := v*1000 + len(m)*100 + total*10 + m["z"]
+	if ok && !missing && flags[1] && !flags[2] && !flags[3] {
+		result++
+	}
+	return result
+}
+				`,
+			3*1000 + 2*100 + 4*10 + 1,
+		},
+		{
+			"range delete",
+			`
+func main() int {
+	m := map[int]int{1: 10, 2: 20, 3: 30}
+	sum := 0
+	for k := range m {
+		delete(m, 2)
+		sum += k
+	}
+	indexes := 0
+	for i := range [3]int{} {
+		indexes += i
+	}
+	return sum*10 + indexes
+}
+				`,
+			4*10 + 3,
+		},
+		{
+			"multi assign",
+			`
+func divmod(a int, b int) (int, int) {
+	return a / b, a % b
+}
+
+func main() int {
+	q, r := divmod(17, 5)
+	q, s := divmod(q, 2)
+	_, r = divmod(r, 1)
+	return q*100 + r*10 + s
+}
+				`,
+			1*100 + 0*10 + 1,
+		},
+		{
+			"closure",
+			`
+func makeCounter() func() int {
+	c := 0
+	return func() int {
+		c++
+		return c
+	}
+}
+
+func makeAdder(n int) func(int) int {
+	return func(x int) int { return x + n }
+}
+
+func apply(f func(int) int, v int) int {
+	return f(v)
+}
+
+func double(x int) int {
+	return x * 2
+}
+
+func main() int {
+	next := makeCounter()
+	next()
+	next()
+	a := next()
+	b := apply(makeAdder(5), 10)
+	c := apply(double, 4)
+	d := 1
+	f := func() {
+		g := func() { d += 10 }
+		g()
+	}
+	f()
+	return a + b + c + d
+}
+				`,
+			3 + 15 + 8 + 11,
+		},
+		{
+			"closure per iteration",
+			`
+type Op struct {
+	name string
+	fn func(int, int) int
+}
+
+func main() int {
+	op := Op{name: "add", fn: func(a int, b int) int { return a + b }}
+	var fs []func() int
+	for i, v := range []int{10, 20, 30} {
+		fs = append(fs, func() int { return i + v })
+	}
+	total := 0
+	for _, f := range fs {
+		total += f()
+	}
+	return op.fn(total, 1)
+}
+				`,
+			10 + 20 + 30 + 0 + 1 + 2 + 1,
+		},
+		{
+			"method interface",
+			`
+type Shape interface {
+	Area() int
+	Name() string
+}
+
+type Named interface {
+	Name() string
+}
+
+type Rect struct {
+	w int
+	h int
+}
+
+type Square struct {
+	s int
+}
+
+func (r Rect) Area() int {
+	return r.w * r.h
+}
+
+func (r Rect) Name() string {
+	return "rect"
+}
+
+func (r Rect) Grow(n int) int {
+	r.w += n
+	return r.w
+}
+
+func (s Square) Area() int {
+	return s.s * s.s
+}
+
+func (s Square) Name() string {
+	return "square"
+}
+
+func total(shapes []Shape) int {
+	sum := 0
+	for _, s := range shapes {
+		sum += s.Area()
+	}
+	return sum
+}
+
+func pick(big bool) Shape {
+	if big {
+		return Square{s: 10}
+	}
+	return Rect{w: 1, h: 2}
+}
+
+func main() int {
+	r := Rect{w: 2, h: 3}
+	g := r.Grow(4)
+	var s Shape = r
+	var n Named = s
+	shapes := []Shape{r, Square{s: 3}}
+	shapes = append(shapes, pick(false))
+	bonus := 0
+	if n.Name() == "rect" {
+		bonus = 100
+	}
+	return total(shapes) + r.w + g + bonus + pick(true).Area()
+}
+				`,
+			(6 + 9 + 2) + 2 + 6 + 100 + 100,
+		},
+		{
+			"const iota",
+			`
+const Size = 4
+const Name string = "arr" + "tty"
+
+const (
+	Zero = iota
+	One
+	Two
+	_
+	Four
+)
+
+const Debug = Size > Two && !(Name == "")
+
+func main() int {
+	var xs [Size]int
+	for i := 0; i < Size; i++ {
+		xs[i] = i * Two
+	}
+	if !Debug {
+		return 0
+	}
+	switch len(Name) {
+	case Size + Two:
+		return xs[3] + Four*10 + len(xs)*100
+	}
+	return 1
+}
+				`,
+			6 + 4*10 + 4*100,
+		},
+		{
+			"const conversion",
+			`
+const Big = 9007199254740993 > 9007199254740992
+const Half = float(3) / 2.0
+const Three = int(Half * 2.0)
+const Label = string(Three) + "x"
+
+func main() int {
+	n := 0
+	if Big {
+		n = 1
+	}
+	if Label == "3x" {
+		n += 10
+	}
+	return n + Three*100
+}
+				`,
+			1 + 10 + 3*100,
+		},
+		{
+			"constant expressions in global declarations",
+			`
+const L = 3
+
+var G int = L * 2
+var H float = 1.5 * 2
+var xs [L*2]int
+
+func main() int {
+	return G*10 + int(H) + len(xs)*100
+}
+				`,
+			60 + 3 + 600,
+		},
+		{
+			"nested function types",
+			`
+func counter() func() func() int {
+	n := 0
+	return func() func() int {
+		n++
+		return func() int {
+			return n * 10
+		}
+	}
+}
+
+func apply(f func() func() int) int {
+	return f()()
+}
+
+func main() int {
+	var f func() func() int
+	f = counter()
+	var g func()
+	x := 1
+	g = func() {
+	}
+	g()
+	x += apply(f)
+	return x + apply(f)
+}
+				`,
+			1 + 10 + 20,
+		},
+		{
+			"const promotion",
+			`
+const Rate = 1 + 2.5
+const Scaled = Rate * 2
+const Above = Scaled > 6
+
+func main() int {
+	if !Above {
+		return 0
+	}
+	return int(Scaled * 10.0)
+}
+				`,
+			70,
+		},
+		{
+			"conversion promotion",
+			`
+func average(xs []int) float {
+	sum := 0
+	for _, x := range xs {
+		sum += x
+	}
+	return float(sum) / float(len(xs))
+}
+
+func main() int {
+	avg := average([]int{1, 2, 4})
+	f := 1 + 2.5
+	f += 1
+	half := -0.5
+	n, ok := atoi("42")
+	_, bad := atoi("x")
+	g, _ := atof("1.25")
+	s := string(7) + string(1.5)
+	if !ok || bad || s != "71.5" {
+		return 1
+	}
+	if 3 < f && f == 4.5 && 1.0 < 2 {
+		return int(avg*3) + int(f) + n + int(g*4) + int(half*10)
+	}
+	return 2
+}
+				`,
+			7 + 4 + 42 + 5 - 5,
+		},
+		{
+			"block scope shadowing",
+			`
+func main() int {
+	x := 1
+	total := 0
+	if 0 < x {
+		x := x + 10
+		total += x
+	} else {
+		x := "else"
+		total += len(x)
+	}
+	{
+		x := 100
+		{
+			x := x + 1
+			total += x
+		}
+		total += x
+	}
+	for i := 0; i < 3; i++ {
+		i := i * 2
+		total += i
+	}
+	f := func() int {
+		x := x + 1000
+		return x
+	}
+	return total + x + f()
+}
+				`,
+			11 + 101 + 100 + (0 + 2 + 4) + 1 + 1001,
+		},
+		{
+			"return in every branch",
+			`
+func sign(x int) int {
+	if x < 0 {
+		return 1
+	} else if x == 0 {
+		return 2
+	} else {
+		switch {
+		case 100 < x:
+			return 4
+		default:
+			return 3
+		}
+	}
+}
+
+func main() int {
+	return sign(-5)*100 + sign(0)*10 + sign(7) + sign(1000)*1000
+}
+				`,
+			100 + 20 + 3 + 4000,
+		},
+		{
+			"functions declared later",
+			`
+func main() int {
+	return collatz(6)
+}
+
+func collatz(n int) int {
+	if n == 1 {
+		return 0
+	}
+	if n%2 == 0 {
+		return 1 + half(n)
+	}
+	return 1 + triple(n)
+}
+
+func half(n int) int {
+	return collatz(n / 2)
+}
+
+func triple(n int) int {
+	return collatz(3*n + 1)
+}
+				`,
+			8,
+		},
+		{
+			"global variables are initialized",
+			`
+func main() int {
+	count = count + 2
+	origin.x = 3
+	items = append(items, 4)
+	return base + count + origin.x + items[0] + len(names)
+}
+
+var base int = 5
+var count int
+var origin Point
+var items []int
+var names map[string]int
+
+type Point struct {
+	x int
+}
+				`,
+			5 + 2 + 3 + 4,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -120,3 +843,146 @@ func main() int {
 		})
 	}
 }
+
+func TestCompile_Deterministic(t *testing.T) {
+	code := `
+type Point struct {
+	x int
+}
+
+func (p Point) Get() int {
+	return p.x
+}
+
+func main() int {
+	total := 0
+	for i := 0; i < 3; i++ {
+		if i == 1 {
+			total += 2
+		} else {
+			total += 1
+		}
+	}
+	m := map[string]int{"a": 1, "b": 2}
+	for k, v := range m {
+		switch k {
+		case "a":
+			total += v
+		default:
+			total -= v
+		}
+	}
+	add := func(n int) int { return n + total }
+	return add(Point{x: 1}.Get())
+}
+`
+	// encode 同じソースを解析し直してオブジェクトファイルにする
+	encode := func() []byte {
+		file, err := CompileObject(analyzeObject(t, "", code))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := file.Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	assert.Equal(t, encode(), encode())
+}
+
+func TestCompiler_Concurrent(t *testing.T) {
+	// それぞれのゴルーチンが自身のTokenizer, Parser, Analyzer, Compilerで別々のプログラムをコンパイルする
+	source := func(n int) string {
+		return fmt.Sprintf(`
+var scale int = %d
+
+type Counter struct {
+	n int
+}
+
+func (c Counter) Add(v int) int {
+	return c.n + v
+}
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+
+func main() int {
+	total := 0
+	for i := 0; i < scale; i++ {
+		switch i %% 3 {
+		case 0:
+			total += 1
+		default:
+			total += 2
+		}
+	}
+	double := func(v int) int { return v * 2 }
+	return double(Counter{n: total}.Add(fib(scale %% 10)))
+}
+`, n)
+	}
+	expect := func(n int) int {
+		total := 0
+		for i := 0; i < n; i++ {
+			if i%3 == 0 {
+				total += 1
+			} else {
+				total += 2
+			}
+		}
+		fib := []int{0, 1, 1, 2, 3, 5, 8, 13, 21, 34}
+		return (total + fib[n%10]) * 2
+	}
+	compile := func(n int) (int, error) {
+		token, err := tokenize.NewTokenizer().Tokenize(source(n))
+		if err != nil {
+			return 0, err
+		}
+		nodes, err := parse.NewParser().Parse(token)
+		if err != nil {
+			return 0, err
+		}
+		sem, err := analyze.NewAnalyzer().Analyze(nodes)
+		if err != nil {
+			return 0, err
+		}
+		obj, err := Link([]*Object{{Identifier: "", SemanticsNode: sem}})
+		if err != nil {
+			return 0, err
+		}
+		program, err := NewCompiler().Compile(obj.SemanticsNode)
+		if err != nil {
+			return 0, err
+		}
+		virtualMachine := vm.NewVm(program, 100)
+		if err := virtualMachine.Execute(); err != nil {
+			return 0, err
+		}
+		return virtualMachine.ExitCode()
+	}
+
+	const programs = 32
+	results := make([]int, programs)
+	errs := make([]error, programs)
+	var wg sync.WaitGroup
+	for i := 0; i < programs; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			results[n], errs[n] = compile(n)
+		}(i)
+	}
+	wg.Wait()
+	for n := 0; n < programs; n++ {
+		if errs[n] != nil {
+			t.Fatal(errs[n])
+		}
+		assert.Equal(t, expect(n), results[n], "program %d", n)
+	}
+}
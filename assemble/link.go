@@ -1,6 +1,250 @@
 package assemble
 
+import (
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/ir"
+	"github.com/arrietty-lang/arrtty/preprocess/analyze"
+	"github.com/arrietty-lang/arrtty/vm"
+)
+
+// Link 別々に解析されたオブジェクトを一つのプログラムにまとめる
+// Identifierが同じオブジェクトは同じパッケージとして扱い、mainのパッケージは""とする
+// 他のパッケージへの参照は、そのパッケージのオブジェクトで定義された関数、グローバル変数に解決する
 func Link(objs []*Object) (*Object, error) {
-	// todo : 別々になっているオブジェクトをつなげて一つにする
-	return objs[0], nil
+	var diagnostics diagnostic.List
+
+	// 定義されたシンボルをパッケージごとに登録する
+	defined := map[string]*ir.Symbol{}
+	definedIn := map[*ir.Symbol]int{}
+	define := func(i int, sym *ir.Symbol) {
+		sym.Package = objs[i].Identifier
+		if prev, ok := defined[sym.Label()]; ok && prev != sym {
+			d := diagnostic.New(symbolSpan(sym), "L0002", sym.Label())
+			if prev.Pos != nil {
+				d.WithRelated(prev.Pos.Span(len([]rune(prev.Name))), "N0003")
+			}
+			diagnostics = append(diagnostics, d)
+			return
+		}
+		defined[sym.Label()] = sym
+		definedIn[sym] = i
+	}
+	for i, obj := range objs {
+		prog := obj.SemanticsNode.Program
+		if prog == nil {
+			continue
+		}
+		for _, g := range prog.Globals {
+			define(i, g.Symbol)
+		}
+		for _, f := range prog.Functions {
+			define(i, f.Symbol)
+		}
+	}
+
+	// 他のパッケージへの参照を解決し、参照先のオブジェクトを記録する
+	deps := make([][]int, len(objs))
+	for i, obj := range objs {
+		sem := obj.SemanticsNode
+		if sem.Program == nil {
+			continue
+		}
+		for _, node := range sem.Program.Externals() {
+			ref := node.Symbol
+			sym, ok := defined[ref.Label()]
+			if !ok || sym.Kind != ref.Kind {
+				diagnostics = append(diagnostics, diagnostic.New(node.Pos.Span(len([]rune(ref.Label()))), "L0001", ref.Label()))
+				continue
+			}
+			if sym.Type.Ident != ref.Type.Ident {
+				diagnostics = append(diagnostics, diagnostic.New(node.Pos.Span(len([]rune(ref.Label()))), "L0003", ref.Label(), ref.Type.Ident, sym.Type.Ident))
+				continue
+			}
+			// 小文字で始まる名前は定義したパッケージの中でのみ参照できる
+			if !analyze.IsExported(sym.Name) && objs[definedIn[sym]].Identifier != obj.Identifier {
+				diagnostics = append(diagnostics, diagnostic.New(node.Pos.Span(len([]rune(ref.Label()))), "L0005", ref.Label()))
+				continue
+			}
+			node.Symbol = sym
+			deps[i] = append(deps[i], definedIn[sym])
+		}
+	}
+
+	// IRを持たないオブジェクトのmainは分からないので、他のエラーがなければ報告する
+	if main, ok := defined["main"]; (!ok || main.Kind != ir.Func) && len(diagnostics) == 0 {
+		diagnostics = append(diagnostics, diagnostic.Errorf("L0004"))
+	}
+	if err := diagnostics.Err(); err != nil {
+		return nil, err
+	}
+
+	// グローバル変数は参照されるパッケージのものから初期化する
+	// init関数もその順に呼び出すので、関数も同じ順に並べる
+	linked := &ir.Program{}
+	visited := make([]bool, len(objs))
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		for _, dep := range deps[i] {
+			visit(dep)
+		}
+		linked.Globals = append(linked.Globals, objs[i].SemanticsNode.Program.Globals...)
+		linked.Functions = append(linked.Functions, objs[i].SemanticsNode.Program.Functions...)
+	}
+	for i := range objs {
+		visit(i)
+	}
+
+	sem := &analyze.Semantics{Program: linked}
+	for _, obj := range objs {
+		// mainのパッケージの宣言はそのまま残す
+		if obj.Identifier == "" {
+			sem.Globals = obj.SemanticsNode.Globals
+			sem.KnownFunctions = obj.SemanticsNode.KnownFunctions
+			sem.KnownTypes = obj.SemanticsNode.KnownTypes
+			sem.Constants = obj.SemanticsNode.Constants
+			break
+		}
+	}
+	return &Object{Identifier: "", SemanticsNode: sem}, nil
+}
+
+// symbolSpan 宣言された位置の名前の範囲
+func symbolSpan(sym *ir.Symbol) *diagnostic.Span {
+	if sym.Pos == nil {
+		return nil
+	}
+	return sym.Pos.Span(len([]rune(sym.Name)))
+}
+
+// LinkObjectFiles 別々にコンパイルしたオブジェクトファイルを一つのプログラムにまとめる
+// mainのラベルには、依存されるパッケージからグローバル変数を初期化してinit関数を呼び出し、main関数に移る入口を置く
+// main関数とinit関数から到達できない関数とグローバル変数は取り除き、そのシンボルを定義された順に返す
+func LinkObjectFiles(files []*ObjectFile) ([]vm.Data, []*ObjectSymbol, error) {
+	var diagnostics diagnostic.List
+
+	defined := map[string]*ObjectSymbol{}
+	definedIn := map[string]int{}
+	for i, f := range files {
+		for _, sym := range f.Symbols {
+			if _, ok := defined[sym.Label]; ok {
+				diagnostics = append(diagnostics, diagnostic.Errorf("L0002", sym.Label))
+				continue
+			}
+			defined[sym.Label] = sym
+			definedIn[sym.Label] = i
+		}
+	}
+
+	deps := make([][]int, len(files))
+	for i, f := range files {
+		for _, ref := range f.References {
+			sym, ok := defined[ref.Label]
+			switch {
+			case !ok || sym.Kind != ref.Kind:
+				diagnostics = append(diagnostics, diagnostic.Errorf("L0001", ref.Label))
+			case sym.Type != ref.Type:
+				diagnostics = append(diagnostics, diagnostic.Errorf("L0003", ref.Label, ref.Type, sym.Type))
+			case !analyze.IsExported(sym.Name) && files[definedIn[ref.Label]].Identifier != f.Identifier:
+				diagnostics = append(diagnostics, diagnostic.Errorf("L0005", ref.Label))
+			default:
+				deps[i] = append(deps[i], definedIn[ref.Label])
+			}
+		}
+	}
+	if main, ok := defined["main"]; !ok || main.Kind != ir.Func {
+		diagnostics = append(diagnostics, diagnostic.Errorf("L0004"))
+	}
+	if err := diagnostics.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	reachable := reachableSymbols(files)
+	program := []vm.Data{
+		*vm.NewLabelData(*vm.NewLabel(true, "main")),
+		*vm.NewOpcodeData(vm.MOV), *vm.NewRegisterTagData(vm.RSP), *vm.NewRegisterTagData(vm.RBP),
+	}
+	visited := make([]bool, len(files))
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		for _, dep := range deps[i] {
+			visit(dep)
+		}
+		for _, init := range files[i].Init {
+			if reachable[init.Label] {
+				program = append(program, init.Code...)
+			}
+		}
+		if label, ok := initFunction(files[i]); ok {
+			program = append(program, *vm.NewOpcodeData(vm.CALL), *vm.NewLabelData(*vm.NewLabel(false, label)))
+		}
+	}
+	for i := range files {
+		visit(i)
+	}
+	program = append(program, *vm.NewOpcodeData(vm.JMP), *vm.NewLabelData(*vm.NewLabel(false, MainBody)))
+	var removed []*ObjectSymbol
+	for _, f := range files {
+		for _, code := range f.Code {
+			if reachable[code.Label] {
+				program = append(program, code.Code...)
+			}
+		}
+		for _, sym := range f.Symbols {
+			if !reachable[sym.Label] {
+				removed = append(removed, sym)
+			}
+		}
+	}
+	return program, removed, nil
+}
+
+// initFunction パッケージのinit関数のラベル
+func initFunction(f *ObjectFile) (string, bool) {
+	for _, sym := range f.Symbols {
+		if sym.Kind == ir.Func && sym.Name == "init" {
+			return sym.Label, true
+		}
+	}
+	return "", false
+}
+
+// reachableSymbols main関数、init関数から命令が参照するラベルを辿って到達できる関数とグローバル変数
+// 関数の値やメソッドもラベルで参照されるので、呼び出しと同じように辿る
+func reachableSymbols(files []*ObjectFile) map[string]bool {
+	sections := map[string]*Section{}
+	for _, f := range files {
+		for _, s := range append(append([]*Section{}, f.Init...), f.Code...) {
+			sections[s.Label] = s
+		}
+	}
+	reachable := map[string]bool{}
+	var visit func(label string)
+	visit = func(label string) {
+		s, ok := sections[label]
+		if !ok || reachable[label] {
+			return
+		}
+		reachable[label] = true
+		for _, d := range s.Code {
+			// 制御構造のラベルなど、シンボルでないラベルは辿らない
+			if l, ok := d.GetLabel(); ok && !l.GetIsDefine() {
+				visit(l.GetName())
+			}
+		}
+	}
+	visit("main")
+	for _, f := range files {
+		if label, ok := initFunction(f); ok {
+			visit(label)
+		}
+	}
+	return reachable
 }
@@ -0,0 +1,479 @@
+package assemble
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/preprocess/analyze"
+	"github.com/arrietty-lang/arrtty/preprocess/parse"
+	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
+	"github.com/arrietty-lang/arrtty/vm"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+// analyzeObject 他のパッケージを参照できるようにしてコードを解析する
+func analyzeObject(t *testing.T, identifier string, code string, imports ...*Object) *Object {
+	t.Helper()
+	token, err := tokenize.Tokenize(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := parse.Parse(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var packages []*analyze.Package
+	for _, obj := range imports {
+		packages = append(packages, &analyze.Package{Name: obj.Identifier, Semantics: obj.SemanticsNode})
+	}
+	sem, err := analyze.Analyze(nodes, packages...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Object{Identifier: identifier, SemanticsNode: sem}
+}
+
+func TestLink(t *testing.T) {
+	geo := analyzeObject(t, "geo", `
+const Scale = 10
+
+var Origin int = 3
+
+func Add(a int, b int) int {
+	return a + b
+}
+
+func main() int {
+	return 100
+}
+`)
+	counter := analyzeObject(t, "counter", `
+import "geo"
+
+var count int = 2
+
+func Next() int {
+	count += geo.Origin
+	return count
+}
+`, geo)
+	main := analyzeObject(t, "", `
+import (
+	"geo"
+	c "counter"
+)
+
+func main() int {
+	f := geo.Add
+	n := c.Next()
+	return geo.Add(n, geo.Scale) + f(geo.Origin, 0)
+}
+`, geo, counter)
+
+	// 参照されるパッケージが後に並んでいても、先に初期化される
+	obj, err := Link([]*Object{main, counter, geo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := Compile(obj.SemanticsNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	virtualMachine := vm.NewVm(program, 100)
+	if err := virtualMachine.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	ec, err := virtualMachine.ExitCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, (2+3)+10+3, ec)
+}
+
+// compileObjectFile パッケージをコンパイルして書き出し、読み込み直す
+func compileObjectFile(t *testing.T, obj *Object) *ObjectFile {
+	t.Helper()
+	file, err := CompileObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := file.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeObjectFile(obj.Identifier+ObjectFileExt, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestLinkObjectFiles(t *testing.T) {
+	// 依存するパッケージは、読み込み直したオブジェクトファイルの宣言を参照して解析する
+	declarations := func(file *ObjectFile) *Object {
+		return &Object{Identifier: file.Identifier, SemanticsNode: file.Declarations}
+	}
+	geo := compileObjectFile(t, analyzeObject(t, "geo", `
+const Scale = 10
+
+var Origin int = 3
+
+var Names []string
+
+func Add(a int, b int) int {
+	return a + b
+}
+`))
+	counter := compileObjectFile(t, analyzeObject(t, "counter", `
+import "geo"
+
+var count int = 1
+
+func Next() int {
+	count += geo.Origin * 2
+	return count
+}
+`, declarations(geo)))
+	main := compileObjectFile(t, analyzeObject(t, "", `
+import (
+	"geo"
+	"counter"
+)
+
+func main() int {
+	f := geo.Add
+	return f(counter.Next(), geo.Scale) + len(geo.Names)
+}
+`, declarations(geo), declarations(counter)))
+
+	program, removed, err := LinkObjectFiles([]*ObjectFile{main, counter, geo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, removed)
+	virtualMachine := vm.NewVm(program, 100)
+	if err := virtualMachine.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	ec, err := virtualMachine.ExitCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, (1+3*2)+10+0, ec)
+
+	// 定義と一致しないオブジェクトファイルはリンクできない
+	changed := compileObjectFile(t, analyzeObject(t, "geo", "var Origin string = \"o\"\n\nvar Names []string\n\nfunc Add(a int, b int) int {\n\treturn 0\n}"))
+	_, _, err = LinkObjectFiles([]*ObjectFile{main, counter, changed})
+	var codes []string
+	for _, d := range diagnostic.Flatten(err) {
+		codes = append(codes, d.Code)
+	}
+	assert.Equal(t, []string{"L0003"}, codes)
+}
+
+func TestLinkObjectFiles_Unreachable(t *testing.T) {
+	geo := compileObjectFile(t, analyzeObject(t, "geo", `
+var Origin int = 3
+
+var Unused int = 4
+
+type Point struct {
+	X int
+}
+
+func (p Point) Get() int {
+	return p.X
+}
+
+func Add(a int, b int) int {
+	p := Point{X: a}
+	return p.Get() + b
+}
+
+func Scale(n int) int {
+	return double(n) + Unused
+}
+
+func double(n int) int {
+	return n * 2
+}
+`))
+	main := compileObjectFile(t, analyzeObject(t, "", `
+import "geo"
+
+func main() int {
+	f := func(n int) int { return geo.Add(n, geo.Origin) }
+	return f(1)
+}
+
+func never() int {
+	return geo.Scale(1)
+}
+`, &Object{Identifier: geo.Identifier, SemanticsNode: geo.Declarations}))
+
+	program, removed, err := LinkObjectFiles([]*ObjectFile{main, geo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 無名関数とメソッドは参照されているので残り、mainから呼ばれない関数とそれだけが参照するものは取り除く
+	var labels []string
+	for _, sym := range removed {
+		labels = append(labels, sym.Label)
+	}
+	assert.Equal(t, []string{"never", "geo.Unused", "geo.Scale", "geo.double"}, labels)
+	for _, d := range program {
+		if l, ok := d.GetLabel(); ok {
+			assert.NotContains(t, labels, l.GetName())
+		}
+	}
+
+	virtualMachine := vm.NewVm(program, 100)
+	if err := virtualMachine.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	ec, err := virtualMachine.ExitCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1+3, ec)
+}
+
+func TestLinkObjectFiles_Methods(t *testing.T) {
+	geo := compileObjectFile(t, analyzeObject(t, "geo", `
+type Point struct {
+	X int
+	Y int
+}
+
+func New(x int, y int) Point {
+	return Point{X: x, Y: y}
+}
+
+func (p Point) Sum() int {
+	return p.X + p.Y
+}
+`))
+	tests := []struct {
+		name   string
+		code   string
+		expect int
+	}{
+		{
+			"imported type",
+			`
+import "geo"
+
+type Summer interface {
+	Sum() int
+}
+
+func main() int {
+	p := geo.New(2, 3)
+	var s Summer = p
+	return p.Sum() + s.Sum()*10
+}
+`,
+			5 + 5*10,
+		},
+		{
+			// 同じ名前の型とメソッドがあっても、geoの型のメソッドはgeoのものを呼び出す
+			"shadowed type name",
+			`
+import "geo"
+
+type Point struct {
+	X int
+}
+
+func (p Point) Sum() int {
+	return 100 + p.X
+}
+
+type Summer interface {
+	Sum() int
+}
+
+func main() int {
+	p := geo.New(2, 3)
+	var s Summer = p
+	var l Summer = Point{X: 1}
+	return p.Sum() + s.Sum()*10 + l.Sum()
+}
+`,
+			5 + 5*10 + 101,
+		},
+		{
+			"qualified type name",
+			`
+import "geo"
+
+var q geo.Point
+
+func double(p geo.Point) geo.Point {
+	return geo.Point{X: p.X * 2, Y: p.Y * 2}
+}
+
+func main() int {
+	q = geo.Point{X: 4}
+	ps := []geo.Point{q, double(geo.New(1, 2))}
+	return ps[0].Sum() + ps[1].Sum()*10
+}
+`,
+			4 + 6*10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decl := &Object{Identifier: geo.Identifier, SemanticsNode: geo.Declarations}
+			main := compileObjectFile(t, analyzeObject(t, "", tt.code, decl))
+			program, _, err := LinkObjectFiles([]*ObjectFile{main, geo})
+			if err != nil {
+				t.Fatal(err)
+			}
+			virtualMachine := vm.NewVm(program, 100)
+			if err := virtualMachine.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			ec, err := virtualMachine.ExitCode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expect, ec)
+		})
+	}
+}
+
+func TestLinkObjectFiles_Concurrent(t *testing.T) {
+	// 同じパッケージの宣言を参照する複数のパッケージを同時に解析してコンパイルする
+	geo := compileObjectFile(t, analyzeObject(t, "geo", `
+var Origin int = 3
+
+func Add(a int, b int) int {
+	return a + b
+}
+`))
+	imports := []*analyze.Package{{Name: geo.Identifier, Semantics: geo.Declarations}}
+	compile := func(n int) (*ObjectFile, error) {
+		token, err := tokenize.NewTokenizer().TokenizeFile("main.arr", fmt.Sprintf(`
+import "geo"
+
+func main() int {
+	return geo.Add(geo.Origin, %d)
+}
+`, n))
+		if err != nil {
+			return nil, err
+		}
+		nodes, err := parse.NewParser().Parse(token)
+		if err != nil {
+			return nil, err
+		}
+		sem, err := analyze.NewAnalyzer().Analyze(nodes, imports...)
+		if err != nil {
+			return nil, err
+		}
+		return NewCompiler().CompileObject(&Object{Identifier: "", SemanticsNode: sem})
+	}
+
+	const programs = 16
+	files := make([]*ObjectFile, programs)
+	errs := make([]error, programs)
+	var wg sync.WaitGroup
+	for i := 0; i < programs; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			files[n], errs[n] = compile(n)
+		}(i)
+	}
+	wg.Wait()
+	for n := 0; n < programs; n++ {
+		if errs[n] != nil {
+			t.Fatal(errs[n])
+		}
+		program, _, err := LinkObjectFiles([]*ObjectFile{files[n], geo})
+		if err != nil {
+			t.Fatal(err)
+		}
+		virtualMachine := vm.NewVm(program, 100)
+		if err := virtualMachine.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		ec, err := virtualMachine.ExitCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 3+n, ec)
+	}
+}
+
+func TestLink_Errors(t *testing.T) {
+	lib := `
+var V int = 1
+
+func F(n int) int {
+	return n
+}
+
+func private() int {
+	return 0
+}
+`
+	tests := []struct {
+		name  string
+		objs  func(t *testing.T) []*Object
+		codes []string
+	}{
+		{
+			"unresolved",
+			func(t *testing.T) []*Object {
+				m := analyzeObject(t, "m", lib)
+				return []*Object{analyzeObject(t, "", "import \"m\"\n\nfunc main() int {\n\treturn m.F(m.V)\n}", m)}
+			},
+			[]string{"L0001", "L0001"},
+		},
+		{
+			"duplicate",
+			func(t *testing.T) []*Object {
+				return []*Object{
+					analyzeObject(t, "", "func main() int {\n\treturn 0\n}"),
+					analyzeObject(t, "m", lib),
+					analyzeObject(t, "m", "func F(n int) int {\n\treturn 0\n}"),
+				}
+			},
+			[]string{"L0002"},
+		},
+		{
+			"changed signature",
+			func(t *testing.T) []*Object {
+				old := analyzeObject(t, "m", lib)
+				main := analyzeObject(t, "", "import \"m\"\n\nfunc main() int {\n\treturn m.F(1)\n}", old)
+				changed := analyzeObject(t, "m", "func F(s string) int {\n\treturn 0\n}")
+				return []*Object{main, changed}
+			},
+			[]string{"L0003"},
+		},
+		{
+			"no main",
+			func(t *testing.T) []*Object {
+				return []*Object{analyzeObject(t, "m", lib)}
+			},
+			[]string{"L0004"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Link(tt.objs(t))
+			if err == nil {
+				t.Fatal("リンクできないオブジェクトがリンクできてしまいました")
+			}
+			var codes []string
+			for _, d := range diagnostic.Flatten(err) {
+				codes = append(codes, d.Code)
+			}
+			assert.Equal(t, tt.codes, codes)
+		})
+	}
+}
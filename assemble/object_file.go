@@ -0,0 +1,446 @@
+package assemble
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/ir"
+	"github.com/arrietty-lang/arrtty/preprocess/analyze"
+	"github.com/arrietty-lang/arrtty/preprocess/parse"
+	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
+	"github.com/arrietty-lang/arrtty/vm"
+	"io"
+	"os"
+	"runtime/debug"
+	"sort"
+	"sync"
+)
+
+// ObjectFileExt パッケージごとにコンパイルしたオブジェクトファイルの拡張子
+const ObjectFileExt = ".arro"
+
+// CompilerVersion 実行しているコンパイラを識別する文字列
+// ビルドキャッシュのキーに含め、別のコンパイラで作ったオブジェクトファイルを使わないようにする
+// 手で更新しなくてもよいように実行ファイルのハッシュを使い、読めなければビルド情報を使う
+func CompilerVersion() string {
+	compilerVersionOnce.Do(func() {
+		compilerVersion = readCompilerVersion()
+	})
+	return compilerVersion
+}
+
+var (
+	compilerVersionOnce sync.Once
+	compilerVersion     string
+)
+
+func readCompilerVersion() string {
+	format := fmt.Sprintf("arrtty-%d", objectFormat)
+	if path, err := os.Executable(); err == nil {
+		if f, err := os.Open(path); err == nil {
+			defer f.Close()
+			h := sha256.New()
+			if _, err := io.Copy(h, f); err == nil {
+				return format + "-" + hex.EncodeToString(h.Sum(nil))
+			}
+		}
+	}
+	// 実行ファイルを読めない場合、同じリビジョンでも作業中の変更は区別できない
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return format
+	}
+	version := format + "-" + info.Main.Path + "@" + info.Main.Version
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" || s.Key == "vcs.modified" {
+			version += "-" + s.Value
+		}
+	}
+	return version
+}
+
+// MainBody 別々にコンパイルしたmain関数の始まりのラベル
+const MainBody = "-main-"
+
+// objectMagic, objectFormat ファイルの先頭に書き、読み込めるオブジェクトファイルかを確かめる
+// 保存する内容を変えた場合はobjectFormatを増やす
+const (
+	objectMagic  = "arro"
+	objectFormat = 3
+)
+
+// ObjectFile 他のパッケージと別々にコンパイルしたパッケージ
+type ObjectFile struct {
+	// Identifier インポートパス, mainのパッケージは""
+	Identifier string
+	// Imports インポートしたパッケージのインポートパス
+	Imports []string
+	// Declarations インポートしたパッケージを解析する時に参照する宣言, IRは持たない
+	Declarations *analyze.Semantics
+	// Symbols 定義した関数、グローバル変数
+	Symbols []*ObjectSymbol
+	// References 他のパッケージの関数、グローバル変数への参照, リンク時に解決する
+	References []*ObjectSymbol
+	// Init グローバル変数ごとの初期化する命令
+	Init []*Section
+	// Code 関数ごとの命令, 他のパッケージへの参照は定義されていないラベルのまま残る
+	Code []*Section
+}
+
+// Section 1つのシンボルを定義する命令, リンク時にmainから到達できないものは取り除く
+type Section struct {
+	// Label 定義するシンボルのラベル
+	Label string
+	Code  []vm.Data
+}
+
+// ObjectSymbol シンボル表の要素
+type ObjectSymbol struct {
+	Kind ir.SymbolKind
+	Name string
+	// Label 命令で使うラベル, 他のパッケージのものは`パッケージ.名前`
+	Label string
+	// Type 型の名前, 定義と参照で一致するかを確かめる
+	Type string
+}
+
+func objectSymbol(sym *ir.Symbol) *ObjectSymbol {
+	return &ObjectSymbol{Kind: sym.Kind, Name: sym.Name, Label: sym.Label(), Type: sym.Type.Ident}
+}
+
+// CompileObject 新しいCompilerでパッケージをコンパイルする
+func CompileObject(obj *Object) (*ObjectFile, error) {
+	return NewCompiler().CompileObject(obj)
+}
+
+// CompileObject 解析したパッケージを、他のパッケージへの参照を残したままコンパイルする
+func (c *Compiler) CompileObject(obj *Object) (*ObjectFile, error) {
+	sem := obj.SemanticsNode
+	file := &ObjectFile{
+		Identifier: obj.Identifier,
+		Declarations: &analyze.Semantics{
+			Globals:        sem.Globals,
+			KnownFunctions: sem.KnownFunctions,
+			KnownTypes:     sem.KnownTypes,
+			Constants:      sem.Constants,
+		},
+	}
+	prog := sem.Program
+	for _, g := range prog.Globals {
+		g.Symbol.Package = obj.Identifier
+		file.Symbols = append(file.Symbols, objectSymbol(g.Symbol))
+	}
+	for _, f := range prog.Functions {
+		f.Symbol.Package = obj.Identifier
+		file.Symbols = append(file.Symbols, objectSymbol(f.Symbol))
+	}
+	referenced := map[string]bool{}
+	for _, node := range prog.Externals() {
+		if label := node.Symbol.Label(); !referenced[label] {
+			referenced[label] = true
+			file.References = append(file.References, objectSymbol(node.Symbol))
+		}
+	}
+
+	c.separate = true
+	defer func() { c.separate = false }()
+	for _, g := range prog.Globals {
+		init, err := c.globalVariable(g)
+		if err != nil {
+			return nil, err
+		}
+		file.Init = append(file.Init, &Section{Label: g.Symbol.Label(), Code: init})
+	}
+	for _, f := range prog.Functions {
+		frags, err := c.defFunction(f)
+		if err != nil {
+			return nil, err
+		}
+		file.Code = append(file.Code, &Section{Label: f.Symbol.Label(), Code: frags})
+	}
+	return file, nil
+}
+
+// objectFileData 保存する形式, 型は表に並べて番号で参照する
+type objectFileData struct {
+	Magic      string
+	Format     int
+	Identifier string
+	Imports    []string
+	Types      []typeData
+	Globals    []declData
+	Functions  []funcData
+	Constants  []constData
+	NamedTypes []declData
+	Symbols    []*ObjectSymbol
+	References []*ObjectSymbol
+	Init       []*Section
+	Code       []*Section
+}
+
+// typeData 型, 他の型は表の番号で参照し、-1はnil
+type typeData struct {
+	Ident   string
+	Package string
+	Type    parse.RuntimeDataType
+	Base    int
+	Key     int
+	Len     int
+	Fields  []declData
+	Params  []int
+	Returns []int
+	Methods []declData
+}
+
+type declData struct {
+	Name  string
+	Types []int
+}
+
+// funcData 関数, Typeは引数と戻り値を持つ関数の型
+type funcData struct {
+	Name    string
+	Params  []int
+	Returns []int
+	Type    int
+}
+
+type constData struct {
+	Name    string
+	Literal tokenize.Literal
+	Type    int
+}
+
+// typeTable 型を表に並べる, 自身を参照する型も一度だけ並べる
+type typeTable struct {
+	types []typeData
+	index map[*parse.DataType]int
+}
+
+func (t *typeTable) add(typ *parse.DataType) int {
+	if typ == nil {
+		return -1
+	}
+	if i, ok := t.index[typ]; ok {
+		return i
+	}
+	i := len(t.types)
+	t.index[typ] = i
+	t.types = append(t.types, typeData{Ident: typ.Ident, Package: typ.Package, Type: typ.Type, Len: typ.Len})
+	base, key := t.add(typ.Base), t.add(typ.Key)
+	fields, methods := t.members(typ.Fields, nil), t.members(nil, typ.Methods)
+	params, returns := t.list(typ.Params), t.list(typ.Returns)
+	d := &t.types[i]
+	d.Base, d.Key, d.Fields, d.Methods, d.Params, d.Returns = base, key, fields, methods, params, returns
+	return i
+}
+
+func (t *typeTable) list(types []*parse.DataType) []int {
+	var list []int
+	for _, typ := range types {
+		list = append(list, t.add(typ))
+	}
+	return list
+}
+
+func (t *typeTable) members(fields []*parse.StructField, methods []*parse.Method) []declData {
+	var members []declData
+	for _, f := range fields {
+		members = append(members, declData{Name: f.Name, Types: []int{t.add(f.DataType)}})
+	}
+	for _, m := range methods {
+		members = append(members, declData{Name: m.Name, Types: []int{t.add(m.DataType)}})
+	}
+	return members
+}
+
+// sortedKeys 保存した内容が毎回同じになるように名前の順に並べる
+func sortedKeys[T any](m map[string]T) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Encode オブジェクトファイルとして書き出す
+func (f *ObjectFile) Encode(w io.Writer) error {
+	table := &typeTable{index: map[*parse.DataType]int{}}
+	data := &objectFileData{
+		Magic:      objectMagic,
+		Format:     objectFormat,
+		Identifier: f.Identifier,
+		Imports:    f.Imports,
+		Symbols:    f.Symbols,
+		References: f.References,
+		Init:       f.Init,
+		Code:       f.Code,
+	}
+	decl := f.Declarations
+	for _, name := range sortedKeys(decl.Globals) {
+		data.Globals = append(data.Globals, declData{Name: name, Types: table.list(decl.Globals[name])})
+	}
+	for _, name := range sortedKeys(decl.KnownFunctions) {
+		fn := decl.KnownFunctions[name]
+		data.Functions = append(data.Functions, funcData{
+			Name:    name,
+			Params:  table.list(fn.Params),
+			Returns: table.list(fn.Returns),
+			Type:    table.add(fn.Symbol.Type),
+		})
+	}
+	for _, name := range sortedKeys(decl.Constants) {
+		c := decl.Constants[name]
+		data.Constants = append(data.Constants, constData{Name: name, Literal: *c.Literal, Type: table.add(c.DataType)})
+	}
+	for _, name := range sortedKeys(decl.KnownTypes) {
+		data.NamedTypes = append(data.NamedTypes, declData{Name: name, Types: []int{table.add(decl.KnownTypes[name])}})
+	}
+	data.Types = table.types
+	if err := gob.NewEncoder(w).Encode(data); err != nil {
+		return diagnostic.Errorf("L0007", f.Identifier, err)
+	}
+	return nil
+}
+
+// builtinType 組み込み型は、読み込んだ時に解析器と同じ値を指すようにする
+func builtinType(d typeData) (*parse.DataType, bool) {
+	for _, typ := range []*parse.DataType{parse.RuntimeInt, parse.RuntimeFloat, parse.RuntimeString, parse.RuntimeBool, parse.RuntimeNil, parse.RuntimeUnknown} {
+		if typ.Type == d.Type && typ.Ident == d.Ident {
+			return typ, true
+		}
+	}
+	return nil, false
+}
+
+// DecodeObjectFile Encodeで書き出したオブジェクトファイルを読み込む
+// nameはエラーの表示に使う
+func DecodeObjectFile(name string, r io.Reader) (*ObjectFile, error) {
+	var data objectFileData
+	if err := gob.NewDecoder(r).Decode(&data); err != nil {
+		return nil, diagnostic.Errorf("L0006", name, err)
+	}
+	if data.Magic != objectMagic || data.Format != objectFormat {
+		return nil, diagnostic.Errorf("L0006", name, "unsupported format")
+	}
+
+	// 先に全ての型を作り、参照を後から繋ぐ
+	types := make([]*parse.DataType, len(data.Types))
+	for i, d := range data.Types {
+		if typ, ok := builtinType(d); ok {
+			types[i] = typ
+			continue
+		}
+		types[i] = &parse.DataType{Ident: d.Ident, Package: d.Package, Type: d.Type, Len: d.Len}
+	}
+	typeAt := func(i int) (*parse.DataType, error) {
+		if i == -1 {
+			return nil, nil
+		}
+		if i < 0 || len(types) <= i {
+			return nil, diagnostic.Errorf("L0006", name, "type index out of range")
+		}
+		return types[i], nil
+	}
+	list := func(indices []int) ([]*parse.DataType, error) {
+		var list []*parse.DataType
+		for _, i := range indices {
+			typ, err := typeAt(i)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, typ)
+		}
+		return list, nil
+	}
+	for i, d := range data.Types {
+		if _, ok := builtinType(d); ok {
+			continue
+		}
+		typ := types[i]
+		var err error
+		if typ.Base, err = typeAt(d.Base); err != nil {
+			return nil, err
+		}
+		if typ.Key, err = typeAt(d.Key); err != nil {
+			return nil, err
+		}
+		if typ.Params, err = list(d.Params); err != nil {
+			return nil, err
+		}
+		if typ.Returns, err = list(d.Returns); err != nil {
+			return nil, err
+		}
+		for _, f := range d.Fields {
+			ft, err := list(f.Types)
+			if err != nil || len(ft) != 1 {
+				return nil, diagnostic.Errorf("L0006", name, "invalid field")
+			}
+			typ.Fields = append(typ.Fields, &parse.StructField{Name: f.Name, DataType: ft[0]})
+		}
+		for _, m := range d.Methods {
+			mt, err := list(m.Types)
+			if err != nil || len(mt) != 1 {
+				return nil, diagnostic.Errorf("L0006", name, "invalid method")
+			}
+			typ.Methods = append(typ.Methods, &parse.Method{Name: m.Name, DataType: mt[0]})
+		}
+	}
+
+	decl := &analyze.Semantics{
+		Globals:        map[string][]*parse.DataType{},
+		KnownFunctions: map[string]*analyze.FnDataType{},
+		KnownTypes:     map[string]*parse.DataType{},
+		Constants:      map[string]*analyze.Constant{},
+	}
+	for _, g := range data.Globals {
+		typ, err := list(g.Types)
+		if err != nil {
+			return nil, err
+		}
+		decl.Globals[g.Name] = typ
+	}
+	for _, fn := range data.Functions {
+		params, err := list(fn.Params)
+		if err != nil {
+			return nil, err
+		}
+		returns, err := list(fn.Returns)
+		if err != nil {
+			return nil, err
+		}
+		typ, err := typeAt(fn.Type)
+		if err != nil {
+			return nil, err
+		}
+		sym := &ir.Symbol{Kind: ir.Func, Name: fn.Name, Package: data.Identifier, Type: typ}
+		decl.KnownFunctions[fn.Name] = &analyze.FnDataType{Params: params, Returns: returns, Symbol: sym}
+	}
+	for _, c := range data.Constants {
+		typ, err := typeAt(c.Type)
+		if err != nil {
+			return nil, err
+		}
+		literal := c.Literal
+		decl.Constants[c.Name] = &analyze.Constant{Literal: &literal, DataType: typ}
+	}
+	for _, t := range data.NamedTypes {
+		typ, err := list(t.Types)
+		if err != nil || len(typ) != 1 {
+			return nil, diagnostic.Errorf("L0006", name, "invalid type")
+		}
+		decl.KnownTypes[t.Name] = typ[0]
+	}
+	return &ObjectFile{
+		Identifier:   data.Identifier,
+		Imports:      data.Imports,
+		Declarations: decl,
+		Symbols:      data.Symbols,
+		References:   data.References,
+		Init:         data.Init,
+		Code:         data.Code,
+	}, nil
+}
@@ -0,0 +1,97 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/arrietty-lang/arrtty/assemble"
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"os"
+	"path/filepath"
+)
+
+// CacheDir プロジェクトのビルドキャッシュを置くディレクトリ
+func (m *Module) CacheDir() string {
+	return filepath.Join(m.Root, ".arrtty", "cache")
+}
+
+// cacheKey パッケージのオブジェクトファイルを識別するハッシュ
+// ソースファイルかインポートしたパッケージの内容、コンパイラのバージョンが変わると変わる
+func cacheKey(pkg *Package, sources map[string]string) string {
+	h := sha256.New()
+	write := func(s string) {
+		// 区切りが曖昧にならないように長さを付ける
+		_, _ = h.Write([]byte{byte(len(s) >> 24), byte(len(s) >> 16), byte(len(s) >> 8), byte(len(s))})
+		_, _ = h.Write([]byte(s))
+	}
+	write(assemble.CompilerVersion())
+	write(pkg.Path)
+	for _, file := range pkg.Files {
+		write(filepath.Base(file))
+		write(sources[file])
+	}
+	for _, dep := range pkg.Imports {
+		write(dep.Key)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// objectPath キーのオブジェクトファイル, 一つのディレクトリに多くならないように先頭の2文字で分ける
+func (l *loader) objectPath(key string) string {
+	return filepath.Join(l.config.CacheDir, key[:2], key+assemble.ObjectFileExt)
+}
+
+// cached キャッシュに同じキーのオブジェクトファイルがあれば、それを使う
+// 読み込めないオブジェクトファイルはソースファイルからコンパイルし直す
+func (l *loader) cached(pkg *Package) bool {
+	if l.config.CacheDir == "" {
+		return false
+	}
+	path := l.objectPath(pkg.Key)
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	obj, err := assemble.DecodeObjectFile(path, f)
+	if err != nil || obj.Identifier != pkg.Path {
+		return false
+	}
+	pkg.Semantics = obj.Declarations
+	pkg.ObjectFile = obj
+	pkg.Cached = true
+	return true
+}
+
+// compile 解析したパッケージをコンパイルしてキャッシュに書き出す
+// 書き出している途中のファイルを他のビルドが読まないように、書き終えてから名前を変える
+func (l *loader) compile(pkg *Package) error {
+	obj, err := assemble.CompileObject(pkg.Object())
+	if err != nil {
+		return err
+	}
+	for _, dep := range pkg.Imports {
+		obj.Imports = append(obj.Imports, dep.Path)
+	}
+	pkg.ObjectFile = obj
+
+	path := l.objectPath(pkg.Key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return diagnostic.Errorf("B0007", path, err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "tmp-*")
+	if err != nil {
+		return diagnostic.Errorf("B0007", path, err)
+	}
+	defer os.Remove(f.Name())
+	if err := obj.Encode(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return diagnostic.Errorf("B0007", path, err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return diagnostic.Errorf("B0007", path, err)
+	}
+	return nil
+}
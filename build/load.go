@@ -0,0 +1,299 @@
+package build
+
+import (
+	"github.com/arrietty-lang/arrtty/assemble"
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/preprocess/analyze"
+	"github.com/arrietty-lang/arrtty/preprocess/parse"
+	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SourceExt パッケージのディレクトリから読み込むソースファイルの拡張子
+const SourceExt = ".arr"
+
+// ExecutableExt リンクしたプログラムを書き出すファイルの拡張子
+const ExecutableExt = ".arrx"
+
+// Config パッケージの読み込み方
+type Config struct {
+	Module *Module
+	// SearchPath プロジェクトの外のパッケージを探すディレクトリ, arrtty.modのpathより先に探す
+	SearchPath []string
+	// CacheDir パッケージごとにコンパイルしたオブジェクトファイルを置くディレクトリ
+	// 空でなければ、同じキーのオブジェクトファイルがあるパッケージは解析しない
+	CacheDir string
+	// Jobs 同時に解析するパッケージの数, 0ならGOMAXPROCS
+	Jobs int
+}
+
+// Package 読み込んで解析したパッケージ
+type Package struct {
+	// Path インポートパス, mainのパッケージは""
+	Path string
+	// Name 他のパッケージから参照する名前, インポートパスの最後の要素
+	Name  string
+	Dir   string
+	Files []string
+	// Imports インポートしたパッケージ, インポートした順
+	Imports []*Package
+	// Semantics 解析の結果, オブジェクトファイルを使った場合は宣言のみを持つ
+	Semantics *analyze.Semantics
+	// Key ソースファイルの内容、インポートしたパッケージのKey、コンパイラのバージョンのハッシュ
+	Key string
+	// ObjectFile Config.CacheDirを指定した場合にコンパイルしたもの
+	ObjectFile *assemble.ObjectFile
+	// Cached 解析せずに、キャッシュにあったオブジェクトファイルを使った
+	Cached bool
+	// nodes 構文解析したソースファイル, 解析を終えると捨てる
+	nodes []*parse.Node
+}
+
+// Object リンクするためのオブジェクト, インポートパスで識別する
+func (p *Package) Object() *assemble.Object {
+	return &assemble.Object{Identifier: p.Path, SemanticsNode: p.Semantics}
+}
+
+// Program mainのパッケージとインポートされた全てのパッケージ
+type Program struct {
+	// Packages 依存されるパッケージから順に並べる, 最後がmain
+	Packages []*Package
+	// Sources 読み込んだファイルの内容, 診断の表示に使う
+	Sources map[string]string
+}
+
+// Objects リンクする全てのオブジェクト
+func (p *Program) Objects() []*assemble.Object {
+	var objs []*assemble.Object
+	for _, pkg := range p.Packages {
+		objs = append(objs, pkg.Object())
+	}
+	return objs
+}
+
+// ObjectFiles リンクする全てのオブジェクトファイル, Config.CacheDirを指定した場合のみ
+func (p *Program) ObjectFiles() []*assemble.ObjectFile {
+	var files []*assemble.ObjectFile
+	for _, pkg := range p.Packages {
+		files = append(files, pkg.ObjectFile)
+	}
+	return files
+}
+
+type loader struct {
+	config   *Config
+	packages map[string]*Package
+	// dirs 読み込んだパッケージのディレクトリとインポートパス
+	dirs map[string]string
+	// failed エラーのあったパッケージ, エラーは報告済み
+	failed map[string]bool
+	// stack 読み込み中のパッケージ, 循環の検出に使う
+	stack []string
+	// order 読み込めたパッケージ, 依存されるパッケージから順に並べる
+	order   []*Package
+	program *Program
+}
+
+// Load filesをmainのパッケージとして、インポートされたパッケージを再帰的に読み込んで解析する
+// インポートの関係が分かってから、依存していないパッケージ同士を並行に解析する
+// エラーがあっても、読み込んだファイルの内容を持つProgramを返す
+func Load(config *Config, files []string) (*Program, error) {
+	l := &loader{
+		config:   config,
+		packages: map[string]*Package{},
+		dirs:     map[string]string{},
+		failed:   map[string]bool{},
+		program:  &Program{Sources: map[string]string{}},
+	}
+	var diagnostics diagnostic.List
+	_, err := l.load("", "main", "", files)
+	diagnostics.Add(err, nil)
+	// 読み込めたパッケージは、他のパッケージを読み込めなくても解析してエラーを報告する
+	diagnostics.Add(l.analyzeAll(), nil)
+	return l.program, diagnostics.Err()
+}
+
+// SourceFiles ディレクトリにあるソースファイル, 名前の順
+func SourceFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, diagnostic.Errorf("B0005", dir, err)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == SourceExt {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// load パッケージの全てのファイルを構文解析する, 意味の解析はanalyzeAllで行う
+// インポートされたパッケージは先に読み込む
+func (l *loader) load(importPath string, name string, dir string, files []string) (*Package, error) {
+	pkg := &Package{Path: importPath, Name: name, Dir: dir, Files: files}
+	l.stack = append(l.stack, importPath)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+
+	var diagnostics diagnostic.List
+	var nodes []*parse.Node
+	sources := map[string]string{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			diagnostics = append(diagnostics, diagnostic.Errorf("B0005", file, err))
+			continue
+		}
+		l.program.Sources[file] = string(data)
+		sources[file] = string(data)
+		token, err := tokenize.TokenizeFile(file, string(data))
+		if err != nil {
+			diagnostics.Add(err, nil)
+			continue
+		}
+		n, err := parse.Parse(token)
+		if err != nil {
+			diagnostics.Add(err, nil)
+			continue
+		}
+		nodes = append(nodes, n...)
+	}
+	if err := diagnostics.Err(); err != nil {
+		return nil, err
+	}
+
+	complete := true
+	for _, node := range parse.Imports(nodes) {
+		dep, err := l.importPackage(node.ImportField.Target)
+		if err != nil {
+			diagnostics.Add(err, node.Pos.Span(len([]rune(node.ImportField.Target))+2))
+		}
+		if dep == nil {
+			complete = false
+			continue
+		}
+		if !contains(pkg.Imports, dep) {
+			pkg.Imports = append(pkg.Imports, dep)
+		}
+	}
+	if err := diagnostics.Err(); err != nil || !complete {
+		return nil, err
+	}
+
+	pkg.nodes = nodes
+	pkg.Key = cacheKey(pkg, sources)
+	l.order = append(l.order, pkg)
+	return pkg, nil
+}
+
+// analyzePackage パッケージを解析し、Config.CacheDirを指定した場合はコンパイルする
+// インポートしたパッケージの解析を終えてから呼ぶ
+func (l *loader) analyzePackage(pkg *Package) error {
+	defer func() { pkg.nodes = nil }()
+	if l.cached(pkg) {
+		return nil
+	}
+	// インポートしたパッケージの型のメソッドは、その型を定義したパッケージの宣言から探すので
+	// 間接的にインポートしたパッケージも渡す, importで名前を付けていなければ参照はできない
+	var imports []*analyze.Package
+	for _, dep := range dependencies(pkg) {
+		imports = append(imports, &analyze.Package{Name: dep.Name, Path: dep.Path, Semantics: dep.Semantics})
+	}
+	sem, err := analyze.NewAnalyzer().Analyze(pkg.nodes, imports...)
+	if err != nil {
+		return err
+	}
+	pkg.Semantics = sem
+	if l.config.CacheDir != "" {
+		return l.compile(pkg)
+	}
+	return nil
+}
+
+// importPackage インポートパスのパッケージを読み込む
+// 既にエラーを報告したパッケージの場合はnilのみを返す
+func (l *loader) importPackage(importPath string) (*Package, error) {
+	for i, p := range l.stack {
+		if p == importPath {
+			cycle := append(append([]string{}, l.stack[i:]...), importPath)
+			return nil, diagnostic.Errorf("B0002", strings.Join(quote(cycle), " -> "))
+		}
+	}
+	if pkg, ok := l.packages[importPath]; ok {
+		return pkg, nil
+	}
+	if l.failed[importPath] {
+		return nil, nil
+	}
+	l.failed[importPath] = true
+
+	dir, ok := l.config.Module.importDir(importPath, l.config.SearchPath)
+	if !ok {
+		return nil, diagnostic.Errorf("B0001", importPath)
+	}
+	// 一つのディレクトリを別のパッケージとして二度読み込むと、型やグローバル変数が二つになる
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	if other, ok := l.dirs[dir]; ok {
+		return nil, diagnostic.Errorf("B0008", dir, quote([]string{other})[0], quote([]string{importPath})[0])
+	}
+	l.dirs[dir] = importPath
+	files, err := SourceFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, diagnostic.Errorf("B0004", importPath)
+	}
+	pkg, err := l.load(importPath, path.Base(importPath), dir, files)
+	if err != nil {
+		return nil, err
+	}
+	delete(l.failed, importPath)
+	l.packages[importPath] = pkg
+	return pkg, nil
+}
+
+// dependencies 直接、間接にインポートした全てのパッケージ
+func dependencies(pkg *Package) []*Package {
+	var deps []*Package
+	var visit func(p *Package)
+	visit = func(p *Package) {
+		for _, dep := range p.Imports {
+			if !contains(deps, dep) {
+				deps = append(deps, dep)
+				visit(dep)
+			}
+		}
+	}
+	visit(pkg)
+	return deps
+}
+
+func contains(packages []*Package, pkg *Package) bool {
+	for _, p := range packages {
+		if p == pkg {
+			return true
+		}
+	}
+	return false
+}
+
+// quote mainのパッケージは空のパスなのでmainと表示する
+func quote(paths []string) []string {
+	var quoted []string
+	for _, p := range paths {
+		if p == "" {
+			quoted = append(quoted, "main")
+			continue
+		}
+		quoted = append(quoted, `"`+p+`"`)
+	}
+	return quoted
+}
@@ -0,0 +1,449 @@
+package build
+
+import (
+	"github.com/arrietty-lang/arrtty/assemble"
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/vm"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles ディレクトリにファイルを作る, 名前は/で区切る
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// loadDir ディレクトリをmainのパッケージとして読み込む
+func loadDir(t *testing.T, dir string, searchPath ...string) (*Program, error) {
+	t.Helper()
+	module, err := FindModule(dir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := SourceFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Load(&Config{Module: module, SearchPath: searchPath}, files)
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	lib := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"arrtty.mod": "// 例\nmodule example.com/geo\n",
+		"main.arr": `import "example.com/geo/shapes"
+import "strs"
+
+func main() int {
+	return shapes.Area(3, 4) + strs.Twice(shapes.Unit) + helper()
+}`,
+		"helper.arr": `func helper() int {
+	return 1
+}`,
+		"shapes/area.arr": `import "strs"
+
+func Area(w int, h int) int {
+	return strs.Twice(w * h)
+}`,
+		"shapes/unit.arr": `var Unit int = 5`,
+	})
+	writeFiles(t, lib, map[string]string{
+		"strs/twice.arr": `func Twice(n int) int {
+	return n * 2
+}`,
+	})
+
+	prog, err := loadDir(t, root, lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, pkg := range prog.Packages {
+		paths = append(paths, pkg.Path)
+	}
+	// 依存されるパッケージが先に並び、一度だけ読み込まれる
+	assert.Equal(t, []string{"strs", "example.com/geo/shapes", ""}, paths)
+
+	obj, err := assemble.Link(prog.Objects())
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := assemble.Compile(obj.SemanticsNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	virtualMachine := vm.NewVm(program, 100)
+	if err := virtualMachine.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	ec, err := virtualMachine.ExitCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 24+10+1, ec)
+}
+
+func TestLoad_ImportedConstants(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"arrtty.mod": "module m\n",
+		"main.arr":   "import \"m/a\"\n\nconst Z = a.K * 2\n\nvar G int = Z + a.K\n\nvar xs [a.K + 1]int\n\nfunc main() int {\n\treturn G*10 + len(xs)\n}",
+		"a/a.arr":    "const K = 3",
+	})
+	prog, err := loadDir(t, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := assemble.Link(prog.Objects())
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := assemble.Compile(obj.SemanticsNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	virtualMachine := vm.NewVm(program, 100)
+	if err := virtualMachine.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	ec, err := virtualMachine.ExitCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, (6+3)*10+4, ec)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		codes []string
+	}{
+		{
+			"not found",
+			map[string]string{
+				"arrtty.mod": "module m\n",
+				"main.arr":   "import \"m/nowhere\"\n\nfunc main() int {\n\treturn 0\n}",
+			},
+			[]string{"B0001"},
+		},
+		{
+			// モジュールに名前があれば、名前で始まらないパスはルートから探さない
+			"not under the module name",
+			map[string]string{
+				"arrtty.mod": "module m\n",
+				"main.arr":   "import \"a\"\n\nfunc main() int {\n\treturn a.F()\n}",
+				"a/a.arr":    "func F() int {\n\treturn 1\n}",
+			},
+			[]string{"B0001"},
+		},
+		{
+			"one directory as two packages",
+			map[string]string{
+				"arrtty.mod": "module m\npath .\n",
+				"main.arr":   "import (\n\t\"a\"\n\tb \"m/a\"\n)\n\nfunc main() int {\n\treturn a.F() + b.F()\n}",
+				"a/a.arr":    "func F() int {\n\treturn 1\n}",
+			},
+			[]string{"B0008"},
+		},
+		{
+			"cycle",
+			map[string]string{
+				"arrtty.mod": "module m\n",
+				"main.arr":   "import \"m/a\"\n\nfunc main() int {\n\treturn a.F()\n}",
+				"a/a.arr":    "import \"m/b\"\n\nfunc F() int {\n\treturn b.G()\n}",
+				"b/b.arr":    "import \"m/a\"\n\nfunc G() int {\n\treturn a.F()\n}",
+			},
+			[]string{"B0002"},
+		},
+		{
+			"invalid manifest",
+			map[string]string{
+				"arrtty.mod": "module m\nrequire x\n",
+				"main.arr":   "func main() int {\n\treturn 0\n}",
+			},
+			[]string{"B0003"},
+		},
+		{
+			"no module",
+			map[string]string{
+				"arrtty.mod": "path lib\n",
+				"main.arr":   "func main() int {\n\treturn 0\n}",
+			},
+			[]string{"B0006"},
+		},
+		{
+			"no source files",
+			map[string]string{
+				"arrtty.mod":   "module m\n",
+				"main.arr":     "import \"m/empty\"\n\nfunc main() int {\n\treturn 0\n}",
+				"empty/README": "",
+			},
+			[]string{"B0004"},
+		},
+		{
+			"errors in an imported package",
+			map[string]string{
+				"arrtty.mod": "module m\n",
+				"main.arr":   "import \"m/a\"\n\nfunc main() int {\n\treturn a.F()\n}",
+				"a/a.arr":    "func F() int {\n\treturn \"x\"\n}",
+			},
+			[]string{"A0025"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tt.files)
+			_, err := loadDir(t, root)
+			if err == nil {
+				t.Fatal("エラーになるべきプロジェクトが読み込めてしまいました")
+			}
+			var codes []string
+			for _, d := range diagnostic.Flatten(err) {
+				codes = append(codes, d.Code)
+			}
+			assert.Equal(t, tt.codes, codes)
+		})
+	}
+}
+
+func TestLoad_Cache(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"arrtty.mod": "module m\n",
+		"main.arr":   "import \"m/a\"\n\nfunc main() int {\n\treturn a.F() + a.N\n}",
+		"a/a.arr":    "import \"m/b\"\n\nvar N int = 2\n\nfunc F() int {\n\treturn b.G()\n}",
+		"b/b.arr":    "func G() int {\n\treturn 10\n}",
+	})
+	cacheDir := filepath.Join(root, "cache")
+	// build 読み込んでリンクし、実行した終了コードとオブジェクトファイルを使ったパッケージ
+	build := func() (int, []string) {
+		t.Helper()
+		module, err := FindModule(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := SourceFiles(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prog, err := Load(&Config{Module: module, CacheDir: cacheDir}, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cached []string
+		for _, pkg := range prog.Packages {
+			if pkg.Cached {
+				cached = append(cached, pkg.Path)
+			}
+		}
+		program, _, err := assemble.LinkObjectFiles(prog.ObjectFiles())
+		if err != nil {
+			t.Fatal(err)
+		}
+		virtualMachine := vm.NewVm(program, 100)
+		if err := virtualMachine.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		ec, err := virtualMachine.ExitCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ec, cached
+	}
+
+	ec, cached := build()
+	assert.Equal(t, 12, ec)
+	assert.Empty(t, cached)
+
+	// 変更がなければ全てのパッケージのオブジェクトファイルを使う
+	ec, cached = build()
+	assert.Equal(t, 12, ec)
+	assert.Equal(t, []string{"m/b", "m/a", ""}, cached)
+
+	// 変更したパッケージとそれをインポートするパッケージのみコンパイルし直す
+	original := "import \"m/b\"\n\nvar N int = 2\n\nfunc F() int {\n\treturn b.G()\n}"
+	writeFiles(t, root, map[string]string{"a/a.arr": "import \"m/b\"\n\nvar N int = 5\n\nfunc F() int {\n\treturn b.G()\n}"})
+	ec, cached = build()
+	assert.Equal(t, 15, ec)
+	assert.Equal(t, []string{"m/b"}, cached)
+
+	// 内容が元に戻れば、以前のオブジェクトファイルを使う
+	writeFiles(t, root, map[string]string{"a/a.arr": original})
+	ec, cached = build()
+	assert.Equal(t, 12, ec)
+	assert.Equal(t, []string{"m/b", "m/a", ""}, cached)
+}
+
+func TestLoad_Jobs(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"arrtty.mod": "module m\n",
+		"main.arr":   "import (\n\t\"m/a\"\n\t\"m/b\"\n\t\"m/c\"\n)\n\nfunc main() int {\n\treturn a.F() + b.F() + c.F()\n}",
+		"a/a.arr":    "import \"m/base\"\n\nfunc F() int {\n\treturn base.N + 1\n}",
+		"b/b.arr":    "import \"m/base\"\n\nfunc F() int {\n\treturn base.N + 2\n}",
+		"c/c.arr":    "func F() int {\n\treturn 3\n}",
+		"base/n.arr": "var N int = 10",
+	})
+	module, err := FindModule(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := SourceFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 並行に解析しても、パッケージは読み込んだ順に並ぶ
+	for _, jobs := range []int{1, 2, 4, 0} {
+		prog, err := Load(&Config{Module: module, Jobs: jobs}, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, pkg := range prog.Packages {
+			paths = append(paths, pkg.Path)
+		}
+		assert.Equal(t, []string{"m/base", "m/a", "m/b", "m/c", ""}, paths, "jobs %d", jobs)
+
+		obj, err := assemble.Link(prog.Objects())
+		if err != nil {
+			t.Fatal(err)
+		}
+		program, err := assemble.Compile(obj.SemanticsNode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		virtualMachine := vm.NewVm(program, 100)
+		if err := virtualMachine.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		ec, err := virtualMachine.ExitCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 11+12+3, ec)
+	}
+
+	// 互いに依存しないパッケージのエラーは、終わった順に関わらず読み込んだ順に報告する
+	writeFiles(t, root, map[string]string{
+		"a/a.arr": "import \"m/base\"\n\nfunc F() int {\n\treturn base.N + \"a\"\n}",
+		"c/c.arr": "func F() int {\n\treturn \"c\"\n}",
+	})
+	for i := 0; i < 10; i++ {
+		_, err := Load(&Config{Module: module, Jobs: 4}, files)
+		var found []string
+		for _, d := range diagnostic.Flatten(err) {
+			found = append(found, d.Code+" "+filepath.Base(d.Span.File))
+		}
+		assert.Equal(t, []string{"A0032 a.arr", "A0025 c.arr"}, found)
+	}
+}
+
+func TestLoad_Init(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"arrtty.mod": "module m\n",
+		"main.arr":   "import \"m/b\"\n\nvar X int\n\nfunc init() {\n\tX = b.M - 100\n}\n\nfunc main() int {\n\treturn X\n}",
+		"a/a.arr":    "var N int = 1\n\nfunc init() {\n\tN = N*10 + 2\n}",
+		"b/b.arr":    "import \"m/a\"\n\nvar M int\n\nfunc init() {\n\tM = a.N*10 + 3\n}",
+	})
+	// run 実行した終了コード
+	run := func(program []vm.Data) int {
+		t.Helper()
+		virtualMachine := vm.NewVm(program, 100)
+		if err := virtualMachine.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		ec, err := virtualMachine.ExitCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ec
+	}
+	module, err := FindModule(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := SourceFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := Load(&Config{Module: module, CacheDir: filepath.Join(root, "cache")}, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// init関数はmainから参照されなくても、依存されるパッケージから順にmainより前に呼び出す
+	program, removed, err := assemble.LinkObjectFiles(prog.ObjectFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sym := range removed {
+		assert.NotEqual(t, "init", sym.Name)
+	}
+	assert.Equal(t, 123-100, run(program))
+
+	obj, err := assemble.Link(prog.Objects())
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err = assemble.Compile(obj.SemanticsNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 123-100, run(program))
+}
+
+// TestExamples エラーコードの説明に載せた例が、そのコードのエラーになることを確かめる
+func TestExamples(t *testing.T) {
+	lib := t.TempDir()
+	writeFiles(t, lib, map[string]string{
+		"a/util/util.arr": "func F() int {\n\treturn 1\n}",
+		"b/util/util.arr": "func F() int {\n\treturn 2\n}",
+	})
+	for _, code := range diagnostic.Codes() {
+		example := diagnostic.Example(code)
+		if example == "" {
+			continue
+		}
+		t.Run(code, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{"main.arr": example})
+			module, err := FindModule(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files, err := SourceFiles(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config := &Config{Module: module, SearchPath: []string{lib}, CacheDir: filepath.Join(root, "cache")}
+			prog, err := Load(config, files)
+			if err == nil {
+				var program []vm.Data
+				program, _, err = assemble.LinkObjectFiles(prog.ObjectFiles())
+				if err == nil {
+					err = vm.NewVm(program, 100).Execute()
+				}
+			}
+			var codes []string
+			for _, d := range diagnostic.Flatten(err) {
+				codes = append(codes, d.Code)
+			}
+			assert.Equal(t, []string{code}, codes, example)
+		})
+	}
+}
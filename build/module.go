@@ -0,0 +1,119 @@
+package build
+
+import (
+	"errors"
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestName プロジェクトのルートに置き、モジュールの名前を書くファイル
+const ManifestName = "arrtty.mod"
+
+// Module arrtty.modで名前を付けられたプロジェクト
+type Module struct {
+	// Name プロジェクト内のパッケージのインポートパスの先頭に付ける名前, arrtty.modがなければ空
+	Name string
+	// Root プロジェクトのルート
+	Root string
+	// Manifest arrtty.modのパス, なければ空
+	Manifest string
+	// Path arrtty.modで追加された検索パス
+	Path []string
+}
+
+// FindModule dirから親のディレクトリへ遡ってarrtty.modを探す
+// 見つからなければdirをルートとする名前のないモジュールとする
+func FindModule(dir string) (*Module, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, diagnostic.Errorf("B0005", dir, err)
+	}
+	for d := root; ; {
+		manifest := filepath.Join(d, ManifestName)
+		data, err := os.ReadFile(manifest)
+		if err == nil {
+			return parseManifest(d, manifest, string(data))
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, diagnostic.Errorf("B0005", manifest, err)
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return &Module{Root: root}, nil
+		}
+		d = parent
+	}
+}
+
+// parseManifest
+//
+//	module 名前
+//	path ディレクトリ
+func parseManifest(root string, manifest string, src string) (*Module, error) {
+	m := &Module{Root: root, Manifest: manifest}
+	var diagnostics diagnostic.List
+	for i, line := range strings.Split(src, "\n") {
+		if j := strings.Index(line, "//"); j != -1 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case len(fields) == 2 && fields[0] == "module" && m.Name == "":
+			m.Name = fields[1]
+		case len(fields) == 2 && fields[0] == "path":
+			dir := filepath.FromSlash(fields[1])
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(root, dir)
+			}
+			m.Path = append(m.Path, dir)
+		default:
+			text := strings.TrimSpace(line)
+			col := strings.Index(line, fields[0])
+			span := &diagnostic.Span{File: manifest, Line: i + 1, Col: len([]rune(line[:col])), Len: len([]rune(text))}
+			diagnostics = append(diagnostics, diagnostic.New(span, "B0003", i+1, text))
+		}
+	}
+	if m.Name == "" && len(diagnostics) == 0 {
+		diagnostics = append(diagnostics, diagnostic.New(&diagnostic.Span{File: manifest, Line: 1}, "B0006"))
+	}
+	if err := diagnostics.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// importDir インポートパスのディレクトリをプロジェクトのルート、検索パスの順に探す
+// モジュールの名前で始まるパスはルートからの相対パスとする
+// モジュールに名前があれば、それ以外のパスはルートからは探さない
+// 同じディレクトリを二つのインポートパスで読み込まないため
+func (m *Module) importDir(path string, searchPath []string) (string, bool) {
+	if path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "/") {
+		return "", false
+	}
+	for _, elem := range strings.Split(path, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return "", false
+		}
+	}
+	var candidates []string
+	if m.Name == "" {
+		candidates = append(candidates, filepath.Join(m.Root, filepath.FromSlash(path)))
+	} else if rel, ok := strings.CutPrefix(path, m.Name+"/"); ok {
+		candidates = append(candidates, filepath.Join(m.Root, filepath.FromSlash(rel)))
+	}
+	for _, dir := range append(searchPath, m.Path...) {
+		candidates = append(candidates, filepath.Join(dir, filepath.FromSlash(path)))
+	}
+	for _, dir := range candidates {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, true
+		}
+	}
+	return "", false
+}
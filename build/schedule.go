@@ -0,0 +1,102 @@
+package build
+
+import (
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"runtime"
+	"sync"
+)
+
+// jobs 解析するワーカーの数, GOMAXPROCSより多くはしない
+func (c *Config) jobs() int {
+	n := runtime.GOMAXPROCS(0)
+	if c.Jobs > 0 && c.Jobs < n {
+		return c.Jobs
+	}
+	return n
+}
+
+// result ワーカーが1つのパッケージを解析した結果
+type result struct {
+	pkg *Package
+	err error
+}
+
+// analyzeAll 読み込んだパッケージを、インポートしたパッケージの解析を終えたものから並行に解析する
+// エラーのあったパッケージをインポートするパッケージは解析しない, エラーは報告済み
+// エラーは解析を終えた順ではなく、読み込んだ順に並べるので実行ごとに変わらない
+func (l *loader) analyzeAll() error {
+	order := l.order
+	index := map[*Package]int{}
+	for i, pkg := range order {
+		index[pkg] = i
+	}
+	// waiting 解析を待っているインポートの数, dependents インポートしているパッケージ
+	waiting := make([]int, len(order))
+	dependents := make([][]*Package, len(order))
+	for i, pkg := range order {
+		waiting[i] = len(pkg.Imports)
+		for _, dep := range pkg.Imports {
+			dependents[index[dep]] = append(dependents[index[dep]], pkg)
+		}
+	}
+
+	ready := make(chan *Package, len(order))
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for w := 0; w < l.config.jobs(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pkg := range ready {
+				results <- result{pkg: pkg, err: l.analyzePackage(pkg)}
+			}
+		}()
+	}
+	for i, pkg := range order {
+		if waiting[i] == 0 {
+			ready <- pkg
+		}
+	}
+
+	errs := make([]error, len(order))
+	skipped := make([]bool, len(order))
+	settled := 0
+	var skip func(pkg *Package)
+	skip = func(pkg *Package) {
+		for _, d := range dependents[index[pkg]] {
+			if !skipped[index[d]] {
+				skipped[index[d]] = true
+				settled++
+				skip(d)
+			}
+		}
+	}
+	for settled < len(order) {
+		r := <-results
+		settled++
+		i := index[r.pkg]
+		if r.err != nil {
+			errs[i] = r.err
+			skip(r.pkg)
+			continue
+		}
+		for _, d := range dependents[i] {
+			j := index[d]
+			waiting[j]--
+			if waiting[j] == 0 && !skipped[j] {
+				ready <- d
+			}
+		}
+	}
+	close(ready)
+	wg.Wait()
+
+	var diagnostics diagnostic.List
+	for i, pkg := range order {
+		diagnostics.Add(errs[i], nil)
+		if errs[i] == nil && !skipped[i] {
+			l.program.Packages = append(l.program.Packages, pkg)
+		}
+	}
+	return diagnostics.Err()
+}
@@ -1,56 +1,184 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"github.com/arrietty-lang/arrtty/assemble"
-	"github.com/arrietty-lang/arrtty/preprocess/analyze"
-	"github.com/arrietty-lang/arrtty/preprocess/parse"
-	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
+	"github.com/arrietty-lang/arrtty/build"
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/ir"
 	"github.com/arrietty-lang/arrtty/vm"
+	"github.com/gookit/color"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
 )
 
+const usage = "bin [-lang ja|en] [-path dirs] <file...|dir|program.arrx>\n       bin [-lang ja|en] [-path dirs] build [-j n] [-v] [-o program.arrx] <file...|dir>\n       bin [-lang ja|en] explain <code>"
+
 func main() {
-	if len(os.Args) <= 1 {
-		log.Fatalf("bin <filepath>")
+	// 指定がなければ環境変数から決めた言語のまま
+	lang := flag.String("lang", "", "message language (ja, en)")
+	path := flag.String("path", "", "directories to search for imported packages, separated by "+string(os.PathListSeparator))
+	flag.Usage = func() {
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *lang != "" {
+		if err := diagnostic.SetLanguage(*lang); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	bytes, err := os.ReadFile(os.Args[1])
+	args := flag.Args()
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+	if args[0] == "explain" {
+		explain(args[1:])
+		return
+	}
+	// -pathの後にARRTTY_PATHを探す
+	searchPath := filepath.SplitList(*path)
+	searchPath = append(searchPath, filepath.SplitList(os.Getenv("ARRTTY_PATH"))...)
+	if args[0] == "build" {
+		buildProgram(args[1:], searchPath)
+		return
+	}
+	if len(args) == 1 && filepath.Ext(args[0]) == build.ExecutableExt {
+		runBuilt(args[0])
+		return
+	}
+	run(args, searchPath)
+}
+
+// buildProgram パッケージごとにオブジェクトファイルへコンパイルしてリンクする
+// 変更されていないパッケージは保存されたオブジェクトファイルを使う
+func buildProgram(args []string, searchPath []string) {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	output := flags.String("o", "", "write the linked program to this file")
+	jobs := flags.Int("j", runtime.GOMAXPROCS(0), "number of packages to compile in parallel, at most GOMAXPROCS")
+	verbose := flags.Bool("v", false, "print the functions and global variables removed because main never reaches them")
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 || *jobs < 1 {
+		log.Fatal(usage)
+	}
+	files, dir, err := mainFiles(flags.Args())
 	if err != nil {
-		log.Fatalf("failed to read: %s", os.Args[1])
+		log.Fatal(err)
+	}
+	renderer := &diagnostic.Renderer{Colored: color.SupportColor() && isTerminal(os.Stderr)}
+	module, err := build.FindModule(dir)
+	if err != nil {
+		renderer.Render(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	token, err := tokenize.Tokenize(string(bytes))
+	config := &build.Config{Module: module, SearchPath: searchPath, CacheDir: module.CacheDir(), Jobs: *jobs}
+	prog, err := build.Load(config, files)
+	renderer.Files = prog.Sources
+	if err != nil {
+		renderer.Render(os.Stderr, err)
+		os.Exit(1)
+	}
+	program, removed, err := assemble.LinkObjectFiles(prog.ObjectFiles())
+	if err != nil {
+		renderer.Render(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *verbose {
+		for _, sym := range removed {
+			kind := "global"
+			if sym.Kind == ir.Func {
+				kind = "func"
+			}
+			_, _ = fmt.Fprintf(os.Stderr, "removed %s %s\n", kind, sym.Label)
+		}
+	}
+	if *output == "" {
+		return
+	}
+	f, err := os.Create(*output)
+	if err == nil {
+		err = vm.EncodeProgram(f, program)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
 	if err != nil {
-		log.Fatalf("failed to tokenize: %s", err)
+		renderer.Render(os.Stderr, diagnostic.Errorf("B0007", *output, err))
+		os.Exit(1)
 	}
+}
 
-	nodes, err := parse.Parse(token)
+// runBuilt buildで書き出したプログラムを実行する
+func runBuilt(path string) {
+	f, err := os.Open(path)
 	if err != nil {
-		log.Fatalf("failed to parse: %s", err)
+		log.Fatalf("failed to read: %s", path)
 	}
+	program, err := vm.DecodeProgram(f)
+	_ = f.Close()
+	if err != nil {
+		log.Fatalf("failed to load: %s", err)
+	}
+	execute(program)
+}
+
+// explain エラーコードの詳しい説明を表示する
+func explain(codes []string) {
+	if len(codes) == 0 {
+		log.Fatal(usage)
+	}
+	for i, code := range codes {
+		text, err := diagnostic.Explain(code)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if i != 0 {
+			fmt.Println()
+		}
+		fmt.Print(text)
+	}
+}
 
-	sem, err := analyze.Analyze(nodes)
+// run ファイルかディレクトリをmainのパッケージとして、インポートしたパッケージと合わせて実行する
+func run(paths []string, searchPath []string) {
+	files, dir, err := mainFiles(paths)
 	if err != nil {
-		log.Fatalf("failed to analyze: %s", err)
+		log.Fatal(err)
+	}
+	module, err := build.FindModule(dir)
+	// ソースに関するエラーは該当箇所と合わせて全て表示する
+	renderer := &diagnostic.Renderer{Colored: color.SupportColor() && isTerminal(os.Stderr)}
+	if err != nil {
+		renderer.Render(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	obj, err := assemble.Link([]*assemble.Object{
-		{
-			"", sem,
-		},
-	})
+	// buildと同じくパッケージごとにコンパイルし、変更のないパッケージはキャッシュを使う
+	config := &build.Config{Module: module, SearchPath: searchPath, CacheDir: module.CacheDir()}
+	prog, err := build.Load(config, files)
+	renderer.Files = prog.Sources
 	if err != nil {
-		log.Fatalf("failed to link: %s", err)
+		renderer.Render(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	program, err := assemble.Compile(obj.SemanticsNode)
+	program, _, err := assemble.LinkObjectFiles(prog.ObjectFiles())
 	if err != nil {
-		log.Fatalf("failed to compile: %s", err)
+		renderer.Render(os.Stderr, err)
+		os.Exit(1)
 	}
+	execute(program)
+}
 
+// execute プログラムを実行し、mainの戻り値を終了コードとして終了する
+func execute(program []vm.Data) {
 	virtualMachine := vm.NewVm(program, 100)
-	err = virtualMachine.Execute()
+	err := virtualMachine.Execute()
 	if err != nil {
 		log.Fatalf("failed to run: %s", err)
 	}
@@ -60,5 +188,32 @@ func main() {
 		log.Fatalf("failed to get exitCode: %s", err)
 	}
 	os.Exit(exitCode)
-	return
+}
+
+// mainFiles mainのパッケージのファイルと、arrtty.modを探し始めるディレクトリ
+// ディレクトリが指定された場合はその中のソースファイルを全て使う
+func mainFiles(paths []string) ([]string, string, error) {
+	if len(paths) == 1 {
+		info, err := os.Stat(paths[0])
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read: %s", paths[0])
+		}
+		if info.IsDir() {
+			files, err := build.SourceFiles(paths[0])
+			if err != nil {
+				return nil, "", err
+			}
+			if len(files) == 0 {
+				return nil, "", fmt.Errorf("no %s files in %s", build.SourceExt, paths[0])
+			}
+			return files, paths[0], nil
+		}
+	}
+	return paths, filepath.Dir(paths[0]), nil
+}
+
+// isTerminal リダイレクトされていない端末への出力か
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
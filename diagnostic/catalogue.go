@@ -0,0 +1,173 @@
+package diagnostic
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Message コードに対応するメッセージの雛形と説明
+type Message struct {
+	// Ja, En fmtの書式, 引数の順番が言語によって異なる場合は%[n]sで指定する
+	Ja string
+	En string
+	// ExplainJa, ExplainEn explainで表示する詳しい説明
+	ExplainJa string
+	ExplainEn string
+	// Example エラーになるコードの例
+	Example string
+	// Internal 処理系の不具合でのみ起こるエラー, 説明は共通のものを使う
+	Internal bool
+}
+
+// catalogue エラーコードとメッセージ
+// コードは一度公開したら意味を変えずに使い続ける
+//
+//	T: 字句解析, P: 構文解析, B: パッケージの読み込み, A: 意味解析, L: リンク, C: コード生成, V: 実行
+var catalogue = map[string]Message{}
+
+// notes 補足や関係する箇所に付ける文, エラーコードとしては表示しない
+var notes = map[string]Message{}
+
+func register(messages map[string]Message) {
+	for code, m := range messages {
+		if _, ok := catalogue[code]; ok {
+			panic("duplicate diagnostic code: " + code)
+		}
+		catalogue[code] = m
+	}
+}
+
+const (
+	Japanese = "ja"
+	English  = "en"
+)
+
+var language = detectLanguage(os.Getenv)
+
+// detectLanguage ARRTTY_LANG, LC_ALL, LC_MESSAGES, LANGの順に最初に設定されているものから言語を決める
+// C, POSIXは言語の指定とみなさず、何も指定されていなければ日本語にする
+func detectLanguage(getenv func(string) string) string {
+	for _, key := range []string{"ARRTTY_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := getenv(key)
+		if v == "" || v == "C" || v == "POSIX" || strings.HasPrefix(v, "C.") {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(v), Japanese) {
+			return Japanese
+		}
+		return English
+	}
+	return Japanese
+}
+
+// SetLanguage 以降に作る診断の言語を変える
+func SetLanguage(lang string) error {
+	switch lang {
+	case Japanese, English:
+		language = lang
+		return nil
+	}
+	return fmt.Errorf("unsupported language: %s (ja, en)", lang)
+}
+
+// Language 現在の言語
+func Language() string {
+	return language
+}
+
+func (m Message) template() string {
+	if language == English {
+		return m.En
+	}
+	return m.Ja
+}
+
+// verbPattern 書式の動詞, %[n]sのように引数の番号を指定したものも含む
+var verbPattern = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z%]`)
+
+// title 書式の引数を…に置き換えた見出し
+func (m Message) title() string {
+	return verbPattern.ReplaceAllStringFunc(m.template(), func(verb string) string {
+		if verb == "%%" {
+			return "%"
+		}
+		return "…"
+	})
+}
+
+// Text コードに対応する文を現在の言語で作る
+func Text(code string, a ...any) string {
+	m, ok := catalogue[code]
+	if !ok {
+		m, ok = notes[code]
+	}
+	if !ok {
+		// 登録し忘れても内容が分かるように引数はそのまま残す
+		return strings.TrimSpace(code + " " + fmt.Sprint(a...))
+	}
+	return fmt.Sprintf(m.template(), a...)
+}
+
+// Codes 登録されている全てのエラーコード
+func Codes() []string {
+	var codes []string
+	for code := range catalogue {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Example コードのエラーになるプログラムの例, なければ空
+func Example(code string) string {
+	return catalogue[code].Example
+}
+
+var internalExplain = Message{
+	ExplainJa: "処理系の内部で想定していない状態になりました。\n" +
+		"正しく解析されたプログラムでは起こらないエラーなので、arrttyの不具合の可能性があります。\n" +
+		"エラーになったプログラムを添えて報告してください。",
+	ExplainEn: "The toolchain reached a state it does not expect.\n" +
+		"A program that passed analysis should never cause this error, so it is most likely a bug in arrtty.\n" +
+		"Please report it together with the program that triggered it.",
+}
+
+// Explain コードの詳しい説明を現在の言語で作る
+func Explain(code string) (string, error) {
+	m, ok := catalogue[strings.ToUpper(code)]
+	if !ok {
+		if language == English {
+			return "", fmt.Errorf("unknown error code: %s", code)
+		}
+		return "", fmt.Errorf("未知のエラーコードです: %s", code)
+	}
+	explain := m
+	if m.Internal {
+		explain = internalExplain
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(code) + ": " + m.title() + "\n\n")
+	if language == English {
+		b.WriteString(explain.ExplainEn + "\n")
+	} else {
+		b.WriteString(explain.ExplainJa + "\n")
+	}
+	if m.Example != "" {
+		if language == English {
+			b.WriteString("\nExample:\n\n")
+		} else {
+			b.WriteString("\n例:\n\n")
+		}
+		for _, line := range strings.Split(m.Example, "\n") {
+			if line != "" {
+				b.WriteString("    " + line)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,733 @@
+package diagnostic
+
+// 意味解析のエラー
+func init() {
+	register(map[string]Message{
+		"A0001": {
+			Ja:        "配列の長さはIntの定数である必要があります: %s",
+			En:        "array length must be an Int constant: %s",
+			ExplainJa: "配列の型の長さにはIntになる定数式のみを指定できます。\n変数や他の型の定数は使用できません。",
+			ExplainEn: "The length of an array type must be a constant expression of type Int.\nVariables and constants of other types cannot be used.",
+			Example:   "const N = \"3\"\n\nvar a [N]int",
+		},
+		"A0002": {
+			Ja:        "配列の長さが負の値です: %s = %d",
+			En:        "array length is negative: %s = %d",
+			ExplainJa: "配列の長さに使用した定数式の値が負になっています。",
+			ExplainEn: "The constant expression used as an array length evaluates to a negative number.",
+			Example:   "const N = 0 - 1\n\nvar a [N]int",
+		},
+		"A0003": {
+			Ja:        "マップのキーにできない型です: %s",
+			En:        "invalid map key type: %s",
+			ExplainJa: "マップのキーには比較可能な型(Int, Float, String, Bool)のみを使用できます。",
+			ExplainEn: "Only comparable types (Int, Float, String, Bool) can be used as map keys.",
+			Example:   "var m map[[]int]int",
+		},
+		"A0004": {
+			Ja:        "型%sは定義されていません",
+			En:        "undefined type %s",
+			ExplainJa: "組み込み型でも、typeで宣言した型でもない名前を型として使用しています。",
+			ExplainEn: "The name is used as a type, but it is neither a builtin type nor declared with type.",
+			Example:   "var p Point",
+		},
+		"A0005": {
+			Ja:        "メソッド%sが重複しています: %s",
+			En:        "duplicate method %s in %s",
+			ExplainJa: "インターフェースに同じ名前のメソッドが複数あります。",
+			ExplainEn: "An interface declares the same method name more than once.",
+			Example:   "type Shape interface {\n\tArea() int\n\tArea() float\n}",
+		},
+		"A0006": {
+			Ja:        "%sはメソッド%sを持たないため%sとして使用できません",
+			En:        "%[1]s cannot be used as %[3]s: missing method %[2]s",
+			ExplainJa: "インターフェースの値として使用する型は、インターフェースの全てのメソッドを持っている必要があります。",
+			ExplainEn: "A value stored in an interface must have every method the interface declares.",
+			Example:   "type Shape interface {\n\tArea() int\n}\n\ntype Point struct {\n\tx int\n}\n\nfunc main() int {\n\tvar s Shape = Point{x: 1}\n\treturn s.Area()\n}",
+		},
+		"A0007": {
+			Ja:        "%sのメソッド%sの型が%sと一致しません: %s",
+			En:        "method %[2]s of %[1]s does not match %[3]s: %[4]s",
+			ExplainJa: "型は同じ名前のメソッドを持っていますが、引数か戻り値の型がインターフェースの宣言と異なります。",
+			ExplainEn: "The type has a method with the right name, but its parameters or results differ from the interface declaration.",
+			Example:   "type Shape interface {\n\tArea() int\n}\n\ntype Square struct {\n\tw int\n}\n\nfunc (s Square) Area() float {\n\treturn 1.0\n}\n\nfunc main() int {\n\tvar s Shape = Square{w: 1}\n\treturn s.Area()\n}",
+		},
+		"A0008": {
+			Ja:       "型が一致しません: %s",
+			En:       "type mismatch: expected %s",
+			Internal: true,
+		},
+		"A0009": {
+			Ja:       "型が一致しません",
+			En:       "type mismatch",
+			Internal: true,
+		},
+		"A0010": {
+			Ja:       "値の数が一致しません: %d <- %d",
+			En:       "wrong number of values: %d <- %d",
+			Internal: true,
+		},
+		"A0011": {
+			Ja:        "型%sは既に定義されています",
+			En:        "type %s is already declared",
+			ExplainJa: "同じ名前の型を2回以上宣言しています。",
+			ExplainEn: "A type with the same name has already been declared.",
+			Example:   "type Point struct {\n\tx int\n}\n\ntype Point struct {\n\ty int\n}",
+		},
+		"A0012": {
+			Ja:        "組み込み型%sを再定義することはできません",
+			En:        "cannot redeclare builtin type %s",
+			ExplainJa: "int, float, string, boolなどの組み込み型と同じ名前の型は宣言できません。",
+			ExplainEn: "A type cannot be declared with the name of a builtin type such as int, float, string or bool.",
+			Example:   "type int struct {\n\tv float\n}",
+		},
+		"A0013": {
+			Ja:        "フィールド%sが重複しています: %s",
+			En:        "duplicate field %s in %s",
+			ExplainJa: "構造体に同じ名前のフィールドが複数あります。",
+			ExplainEn: "A struct declares the same field name more than once.",
+			Example:   "type Point struct {\n\tx int\n\tx int\n}",
+		},
+		"A0014": {
+			Ja:        "組み込み関数%sを再定義することはできません",
+			En:        "cannot redeclare builtin function %s",
+			ExplainJa: "len, append, printなどの組み込み関数と同じ名前の関数は宣言できません。",
+			ExplainEn: "A function cannot be declared with the name of a builtin function such as len, append or print.",
+			Example:   "func len(s string) int {\n\treturn 0\n}",
+		},
+		"A0015": {
+			Ja:        "関数%sの最後にreturnがありません",
+			En:        "missing return at end of function %s",
+			ExplainJa: "戻り値を持つ関数は、全ての経路でreturnする必要があります。\nifにelseがない場合や、switchにdefaultがない場合は、その後にもreturnが必要です。",
+			ExplainEn: "A function with results must return on every path.\nAn if without else, or a switch without default, must be followed by a return.",
+			Example:   "func sign(x int) int {\n\tif x < 0 {\n\t\treturn -1\n\t}\n}",
+		},
+		"A0016": {
+			Ja:        "mainの戻り値の型はIntかなしのみ使用できます",
+			En:        "main must return Int or nothing",
+			ExplainJa: "mainの戻り値はプログラムの終了コードになるため、Intか戻り値なしのみ使用できます。",
+			ExplainEn: "The result of main becomes the exit code of the program, so main may only return Int or nothing.",
+			Example:   "func main() string {\n\treturn \"ok\"\n}",
+		},
+		"A0017": {
+			Ja:        "レシーバには定義された構造体の型のみ使用できます: %s",
+			En:        "receiver must be a declared struct type: %s",
+			ExplainJa: "メソッドはtypeで宣言した構造体にのみ定義できます。",
+			ExplainEn: "Methods can only be declared on struct types declared with type.",
+			Example:   "func (x int) Double() int {\n\treturn x * 2\n}",
+		},
+		"A0018": {
+			Ja:        "%sにはフィールド%sがあるため、同じ名前のメソッドは定義できません",
+			En:        "%s has a field %s, cannot declare a method with the same name",
+			ExplainJa: "フィールドとメソッドは同じ書き方で参照するため、同じ名前にはできません。",
+			ExplainEn: "Fields and methods are accessed with the same syntax, so they cannot share a name.",
+			Example:   "type Point struct {\n\tx int\n}\n\nfunc (p Point) x() int {\n\treturn 0\n}",
+		},
+		"A0019": {
+			Ja:        "メソッド%sは既に定義されています",
+			En:        "method %s is already declared",
+			ExplainJa: "同じ型に同じ名前のメソッドを2回以上定義しています。",
+			ExplainEn: "A method with the same name has already been declared on this type.",
+			Example:   "type Point struct {\n\tx int\n}\n\nfunc (p Point) Len() int {\n\treturn 0\n}\n\nfunc (p Point) Len() int {\n\treturn 1\n}",
+		},
+		"A0020": {
+			Ja:        "ループの条件はBoolである必要があります",
+			En:        "loop condition must be Bool",
+			ExplainJa: "forの条件にはBoolの値のみを使用できます。数値を暗黙に真偽値として扱うことはありません。",
+			ExplainEn: "The condition of a for loop must be a Bool. Numbers are never treated as truth values.",
+			Example:   "func main() int {\n\tfor i := 3; i; i-- {\n\t}\n\treturn 0\n}",
+		},
+		"A0021": {
+			Ja:        "rangeの対象は1つの値である必要があります",
+			En:        "range expression must be a single value",
+			ExplainJa: "rangeには1つの値を返す式のみを指定できます。",
+			ExplainEn: "range accepts only an expression that yields exactly one value.",
+			Example:   "func pair() (int, int) {\n\treturn 1, 2\n}\n\nfunc main() int {\n\tfor i, v := range pair() {\n\t}\n\treturn 0\n}",
+		},
+		"A0022": {
+			Ja:        "配列、スライス、マップ以外はrangeの対象にできません: %s",
+			En:        "cannot range over %s: only arrays, slices and maps",
+			ExplainJa: "rangeで繰り返せるのは配列、スライス、マップのみです。",
+			ExplainEn: "Only arrays, slices and maps can be iterated with range.",
+			Example:   "func main() int {\n\tfor i, c := range \"abc\" {\n\t}\n\treturn 0\n}",
+		},
+		"A0023": {
+			Ja:        "switchのタグは比較可能な型(Int, Float, String, Bool)である必要があります",
+			En:        "switch tag must be comparable (Int, Float, String, Bool)",
+			ExplainJa: "switchで比較する値には、比較可能な型のみを使用できます。",
+			ExplainEn: "The value a switch compares against must be of a comparable type.",
+			Example:   "func main() int {\n\tswitch []int{1} {\n\t}\n\treturn 0\n}",
+		},
+		"A0024": {
+			Ja:        "caseの値の型がswitchと一致しません: %v",
+			En:        "case value does not match switch type %v",
+			ExplainJa: "caseの値は、switchのタグと同じ型である必要があります。",
+			ExplainEn: "Each case value must have the same type as the switch tag.",
+			Example:   "func main() int {\n\tswitch 1 {\n\tcase \"one\":\n\t}\n\treturn 0\n}",
+		},
+		"A0025": {
+			Ja:        "戻り値の型が定義と一致しません",
+			En:        "return values do not match the function results",
+			ExplainJa: "returnの値の型か数が、関数の宣言の戻り値と一致しません。",
+			ExplainEn: "The types or number of values in return do not match the declared results.",
+			Example:   "func f() int {\n\treturn \"x\"\n}",
+		},
+		"A0026": {
+			Ja:        "ifの条件はBoolである必要があります",
+			En:        "if condition must be Bool",
+			ExplainJa: "ifの条件にはBoolの値のみを使用できます。数値を暗黙に真偽値として扱うことはありません。",
+			ExplainEn: "The condition of an if statement must be a Bool. Numbers are never treated as truth values.",
+			Example:   "func main() int {\n\tif 1 {\n\t}\n\treturn 0\n}",
+		},
+		"A0027": {
+			Ja:        "代入先に指定できない値です",
+			En:        "cannot assign to this expression",
+			ExplainJa: "代入できるのは変数、フィールド、要素のみです。",
+			ExplainEn: "Only variables, fields and elements can be assigned to.",
+			Example:   "func main() int {\n\t1 = 2\n\treturn 0\n}",
+		},
+		"A0028": {
+			Ja:        "代入は1つの値のみで行えます",
+			En:        "assignment needs a single value",
+			ExplainJa: "1つの代入先には、1つの値を返す式のみを代入できます。",
+			ExplainEn: "A single destination can only be assigned an expression that yields one value.",
+			Example:   "func pair() (int, int) {\n\treturn 1, 2\n}\n\nfunc main() int {\n\tx := 0\n\tx = pair()\n\treturn x\n}",
+		},
+		"A0029": {
+			Ja:        "代入された値と宣言の型が一致しません: %v <- %v",
+			En:        "cannot assign %[2]v to a variable of type %[1]v",
+			ExplainJa: "変数に、宣言された型と異なる型の値を代入しています。",
+			ExplainEn: "The value assigned has a different type from the declared type of the variable.",
+			Example:   "func main() int {\n\tvar x int = \"1\"\n\treturn x\n}",
+		},
+		"A0030": {
+			Ja:        "複合代入の代入先は変数である必要があります",
+			En:        "compound assignment needs a variable",
+			ExplainJa: "+=などの複合代入は、変数にのみ使用できます。",
+			ExplainEn: "Compound assignments such as += can only be applied to variables.",
+			Example:   "func main() int {\n\t1 += 2\n\treturn 0\n}",
+		},
+		"A0031": {
+			Ja:        "IntにFloatを作用させるにはint()で変換する必要があります",
+			En:        "convert the Float with int() to apply it to an Int",
+			ExplainJa: "Intの変数にFloatの値を複合代入すると小数部が失われるため、明示的にint()で変換する必要があります。",
+			ExplainEn: "Applying a Float to an Int variable would drop the fraction, so it must be converted with int() explicitly.",
+			Example:   "func main() int {\n\tx := 1\n\tx += 0.5\n\treturn x\n}",
+		},
+		"A0032": {
+			Ja:        "計算は同じ型のみで行えます: L:%s, R:%s",
+			En:        "mismatched types in arithmetic: L:%s, R:%s",
+			ExplainJa: "算術演算の左辺と右辺は同じ型である必要があります。\n定数以外のIntとFloatを混ぜる場合は、int()やfloat()で変換してください。",
+			ExplainEn: "Both operands of an arithmetic operator must have the same type.\nTo mix non-constant Int and Float values, convert with int() or float().",
+			Example:   "func main() int {\n\tx := \"a\" + 1\n\treturn x\n}",
+		},
+		"A0033": {
+			Ja:        "複合代入は計算可能な型(Int, Float)のみで使用できます: %v",
+			En:        "compound assignment needs a numeric type (Int, Float): %v",
+			ExplainJa: "+=などの複合代入は、IntかFloatの変数にのみ使用できます。",
+			ExplainEn: "Compound assignments such as += can only be used on Int or Float variables.",
+			Example:   "func main() int {\n\tb := true\n\tb += false\n\treturn 0\n}",
+		},
+		"A0034": {
+			Ja:        "インクリメント、デクリメントの対象は変数である必要があります",
+			En:        "increment or decrement needs a variable",
+			ExplainJa: "++と--は変数にのみ使用できます。",
+			ExplainEn: "++ and -- can only be applied to variables.",
+			Example:   "func main() int {\n\t1++\n\treturn 0\n}",
+		},
+		"A0035": {
+			Ja:        "インクリメント、デクリメントは計算可能な型(Int, Float)のみで使用できます: %v",
+			En:        "increment or decrement needs a numeric type (Int, Float): %v",
+			ExplainJa: "++と--はIntかFloatの変数にのみ使用できます。",
+			ExplainEn: "++ and -- can only be used on Int or Float variables.",
+			Example:   "func main() int {\n\ts := \"a\"\n\ts++\n\treturn len(s)\n}",
+		},
+		"A0036": {
+			Ja:        "代入先と値の数が一致しません: %d <- %d",
+			En:        "assignment count mismatch: %d <- %d",
+			ExplainJa: "複数の値の代入で、左辺の数と右辺の値の数が異なります。",
+			ExplainEn: "In a multiple assignment the number of destinations differs from the number of values.",
+			Example:   "func pair() (int, int) {\n\treturn 1, 2\n}\n\nfunc main() int {\n\ta, b, c := pair()\n\treturn a\n}",
+		},
+		"A0037": {
+			Ja:        "複数の値の代入先は変数である必要があります",
+			En:        "multiple assignment needs variables",
+			ExplainJa: "複数の値を一度に代入する場合、代入先は全て変数である必要があります。",
+			ExplainEn: "When assigning several values at once, every destination must be a variable.",
+			Example:   "func pair() (int, int) {\n\treturn 1, 2\n}\n\nfunc main() int {\n\ts := []int{0}\n\ts[0], n := pair()\n\treturn n\n}",
+		},
+		"A0038": {
+			Ja:        "%sは定義されていません",
+			En:        "undefined: %s",
+			ExplainJa: "宣言されていない名前を参照しています。\n変数はそれを宣言したブロックの中でのみ参照できます。",
+			ExplainEn: "The name has not been declared.\nA variable can only be referred to inside the block that declares it.",
+			Example:   "func main() int {\n\treturn x\n}",
+		},
+		"A0039": {
+			Ja:        ":=の左辺に新しい変数がありません",
+			En:        "no new variables on left side of :=",
+			ExplainJa: ":=は少なくとも1つの新しい変数を宣言する必要があります。\n既存の変数への代入には=を使用してください。",
+			ExplainEn: ":= must declare at least one new variable.\nUse = to assign to existing variables.",
+			Example:   "func pair() (int, int) {\n\treturn 1, 2\n}\n\nfunc main() int {\n\ta, b := pair()\n\ta, b := pair()\n\treturn a + b\n}",
+		},
+		"A0040": {
+			Ja:        "条件連結は同じ型のみで使用可能です: L:%s, R:%s",
+			En:        "mismatched types in logical operator: L:%s, R:%s",
+			ExplainJa: "&&と||の左辺と右辺は同じ型である必要があります。",
+			ExplainEn: "Both operands of && and || must have the same type.",
+			Example:   "func main() int {\n\tb := true && 1\n\treturn 0\n}",
+		},
+		"A0041": {
+			Ja:        "条件連結はBoolのみで使用可能です: L:%s, R:%s",
+			En:        "logical operator needs Bool operands: L:%s, R:%s",
+			ExplainJa: "&&と||はBoolの値にのみ使用できます。",
+			ExplainEn: "&& and || can only be used with Bool operands.",
+			Example:   "func main() int {\n\tb := 1 && 2\n\treturn 0\n}",
+		},
+		"A0042": {
+			Ja:        "値比較は同じ型のみで使用可能です: L:%v, R:%v",
+			En:        "mismatched types in comparison: L:%v, R:%v",
+			ExplainJa: "==と!=の左辺と右辺は同じ型である必要があります。",
+			ExplainEn: "Both operands of == and != must have the same type.",
+			Example:   "func main() int {\n\tb := 1 == \"1\"\n\treturn 0\n}",
+		},
+		"A0043": {
+			Ja:        "大小比較は同じ型のみで使用できます: L:%v, R:%v",
+			En:        "mismatched types in ordering: L:%v, R:%v",
+			ExplainJa: "<, <=, >, >=の左辺と右辺は同じ型である必要があります。",
+			ExplainEn: "Both operands of <, <=, > and >= must have the same type.",
+			Example:   "func main() int {\n\tb := \"a\" < 1\n\treturn 0\n}",
+		},
+		"A0044": {
+			Ja:        "大小比較は比較可能な型(Int, Float)のみで使用できます: L:%v, R:%v",
+			En:        "ordering needs numeric operands (Int, Float): L:%v, R:%v",
+			ExplainJa: "<, <=, >, >=はIntかFloatの値にのみ使用できます。",
+			ExplainEn: "<, <=, > and >= can only be used with Int or Float operands.",
+			Example:   "func main() int {\n\tb := \"a\" < \"b\"\n\treturn 0\n}",
+		},
+		"A0045": {
+			Ja:        "算術演算は計算可能な型(Int, Float)のみで使用できます: L:%v, R:%v",
+			En:        "arithmetic needs numeric operands (Int, Float): L:%v, R:%v",
+			ExplainJa: "+, -, *, /, %はIntかFloatの値にのみ使用できます。",
+			ExplainEn: "+, -, *, / and % can only be used with Int or Float operands.",
+			Example:   "func main() int {\n\tb := true + false\n\treturn 0\n}",
+		},
+		"A0046": {
+			Ja:        "notはBool以外を値にできません: %v",
+			En:        "operator ! needs a Bool operand: %v",
+			ExplainJa: "!はBoolの値にのみ使用できます。",
+			ExplainEn: "! can only be applied to a Bool value.",
+			Example:   "func main() int {\n\tb := !1\n\treturn 0\n}",
+		},
+		"A0047": {
+			Ja:        "%sのフィールドとして使用できない値です",
+			En:        "invalid field access on %s",
+			ExplainJa: ".の後ろにはフィールド名か、フィールドに格納された関数の呼び出しのみを書くことができます。",
+			ExplainEn: "Only a field name, or a call of a function stored in a field, may follow the dot.",
+			Example:   "type Point struct {\n\tx int\n}\n\nfunc main() int {\n\tp := Point{x: 1}\n\treturn p.(1)\n}",
+		},
+		"A0048": {
+			Ja:        "構造体以外のフィールドにはアクセスできません: %s",
+			En:        "cannot access field %s of a non-struct value",
+			ExplainJa: "フィールドを持つのは構造体の値のみです。",
+			ExplainEn: "Only struct values have fields.",
+			Example:   "func main() int {\n\tx := 1\n\treturn x.v\n}",
+		},
+		"A0049": {
+			Ja:        "%sにフィールド%sは存在しません",
+			En:        "%s has no field %s",
+			ExplainJa: "構造体の宣言にないフィールドを参照しています。",
+			ExplainEn: "The struct declaration has no field with this name.",
+			Example:   "type Point struct {\n\tx int\n}\n\nfunc main() int {\n\tp := Point{x: 1}\n\treturn p.y\n}",
+		},
+		"A0050": {
+			Ja:        "マップのキーの型が一致しません: %s",
+			En:        "map key must be of type %s",
+			ExplainJa: "マップのキーに、宣言と異なる型の値を使用しています。",
+			ExplainEn: "The key has a different type from the key type of the map.",
+			Example:   "func main() int {\n\tm := map[string]int{}\n\treturn m[1]\n}",
+		},
+		"A0051": {
+			Ja:        "配列、スライス、マップ以外にはインデックスでアクセスできません",
+			En:        "cannot index a value that is not an array, slice or map",
+			ExplainJa: "[]で要素を参照できるのは配列、スライス、マップのみです。",
+			ExplainEn: "Only arrays, slices and maps can be indexed with [].",
+			Example:   "func main() int {\n\tx := 1\n\treturn x[0]\n}",
+		},
+		"A0052": {
+			Ja:        "インデックスはIntである必要があります",
+			En:        "index must be Int",
+			ExplainJa: "配列とスライスのインデックスにはIntの値のみを使用できます。",
+			ExplainEn: "Arrays and slices can only be indexed with Int values.",
+			Example:   "func main() int {\n\ta := []int{1}\n\treturn a[\"0\"]\n}",
+		},
+		"A0053": {
+			Ja:        "インデックスが範囲外です: %d (len %d)",
+			En:        "index out of range: %d (len %d)",
+			ExplainJa: "配列の長さ以上、または負の定数のインデックスは、コンパイル時にエラーになります。",
+			ExplainEn: "A constant index that is negative or not less than the array length is rejected at compile time.",
+			Example:   "func main() int {\n\tvar a [3]int\n\treturn a[3]\n}",
+		},
+		"A0054": {
+			Ja:        "配列、スライス以外はスライスにできません",
+			En:        "cannot slice a value that is not an array or slice",
+			ExplainJa: "[low:high]で切り出せるのは配列とスライスのみです。",
+			ExplainEn: "Only arrays and slices can be sliced with [low:high].",
+			Example:   "func main() int {\n\tm := map[int]int{}\n\ts := m[0:1]\n\treturn len(s)\n}",
+		},
+		"A0055": {
+			Ja:        "変数ではない配列はスライスにできません",
+			En:        "cannot slice an array that is not a variable",
+			ExplainJa: "スライスは元の配列を参照するため、変数に格納された配列のみをスライスにできます。",
+			ExplainEn: "A slice refers to its underlying array, so only an array stored in a variable can be sliced.",
+			Example:   "func main() int {\n\ts := [3]int{1, 2, 3}[0:2]\n\treturn len(s)\n}",
+		},
+		"A0056": {
+			Ja:        "スライスの範囲はIntである必要があります",
+			En:        "slice bounds must be Int",
+			ExplainJa: "[low:high]の範囲にはIntの値のみを使用できます。",
+			ExplainEn: "The bounds in [low:high] must be Int values.",
+			Example:   "func main() int {\n\ta := []int{1, 2}\n\ts := a[0.5:1]\n\treturn len(s)\n}",
+		},
+		"A0057": {
+			Ja:        "%sは構造体ではありません",
+			En:        "%s is not a struct type",
+			ExplainJa: "Name{...}の形の値は構造体の型にのみ使用できます。",
+			ExplainEn: "The Name{...} literal form can only be used with struct types.",
+			Example:   "type Shape interface {\n\tArea() int\n}\n\nfunc main() int {\n\ts := Shape{}\n\treturn 0\n}",
+		},
+		"A0058": {
+			Ja:        "フィールド%sが重複しています",
+			En:        "duplicate field %s in struct literal",
+			ExplainJa: "構造体の値の中で、同じフィールドに2回以上値を指定しています。",
+			ExplainEn: "The struct literal sets the same field more than once.",
+			Example:   "type Point struct {\n\tx int\n}\n\nfunc main() int {\n\tp := Point{x: 1, x: 2}\n\treturn p.x\n}",
+		},
+		"A0059": {
+			Ja:        "フィールド%sの型と値の型が一致しません",
+			En:        "value does not match the type of field %s",
+			ExplainJa: "構造体の値で、フィールドの型と異なる型の値を指定しています。",
+			ExplainEn: "The struct literal gives a field a value of a different type.",
+			Example:   "type Point struct {\n\tx int\n}\n\nfunc main() int {\n\tp := Point{x: \"1\"}\n\treturn p.x\n}",
+		},
+		"A0060": {
+			Ja:       "%sはマップではありません",
+			En:       "%s is not a map type",
+			Internal: true,
+		},
+		"A0061": {
+			Ja:        "マップの値の型が一致しません: %s",
+			En:        "map value must be of type %s",
+			ExplainJa: "マップの値に、宣言と異なる型の値を指定しています。",
+			ExplainEn: "The value has a different type from the value type of the map.",
+			Example:   "func main() int {\n\tm := map[string]int{\"a\": \"b\"}\n\treturn len(m)\n}",
+		},
+		"A0062": {
+			Ja:        "配列の長さを超える要素が指定されています: %d (len %d)",
+			En:        "too many elements for array: %d (len %d)",
+			ExplainJa: "配列の値に、配列の長さより多くの要素を指定しています。",
+			ExplainEn: "The array literal has more elements than the length of the array.",
+			Example:   "func main() int {\n\ta := [2]int{1, 2, 3}\n\treturn a[0]\n}",
+		},
+		"A0063": {
+			Ja:        "要素の型が一致しません: %s <- %s",
+			En:        "element must be of type %s, got %s",
+			ExplainJa: "配列やスライスの値に、要素の型と異なる型の値を指定しています。",
+			ExplainEn: "An element of the array or slice literal has a different type from the element type.",
+			Example:   "func main() int {\n\ta := []int{1, \"2\"}\n\treturn len(a)\n}",
+		},
+		"A0064": {
+			Ja:        "lenの引数は配列、スライス、マップ、文字列のいずれか1つである必要があります",
+			En:        "len needs one array, slice, map or string",
+			ExplainJa: "lenは配列、スライス、マップ、文字列の値を1つだけ受け取ります。",
+			ExplainEn: "len takes exactly one array, slice, map or string.",
+			Example:   "func main() int {\n\treturn len(1)\n}",
+		},
+		"A0065": {
+			Ja:        "appendの最初の引数はスライスである必要があります",
+			En:        "first argument to append must be a slice",
+			ExplainJa: "appendはスライスの後ろに要素を追加します。配列には追加できません。",
+			ExplainEn: "append adds elements to the end of a slice. Arrays cannot be appended to.",
+			Example:   "func main() int {\n\tvar a [2]int\n\ta = append(a, 1)\n\treturn a[0]\n}",
+		},
+		"A0066": {
+			Ja:        "appendする値の型が一致しません: %s",
+			En:        "cannot append a value that is not %s",
+			ExplainJa: "appendする値は、スライスの要素と同じ型である必要があります。",
+			ExplainEn: "Appended values must have the element type of the slice.",
+			Example:   "func main() int {\n\ts := []int{}\n\ts = append(s, \"1\")\n\treturn len(s)\n}",
+		},
+		"A0067": {
+			Ja:        "deleteの引数はマップとキーである必要があります",
+			En:        "delete needs a map and a key",
+			ExplainJa: "deleteはマップとキーの2つの引数を受け取ります。",
+			ExplainEn: "delete takes two arguments: a map and a key.",
+			Example:   "func main() int {\n\ts := []int{1}\n\tdelete(s, 0)\n\treturn len(s)\n}",
+		},
+		"A0068": {
+			Ja:        "%sに変換できるのはInt, Floatのいずれか1つの値です",
+			En:        "%s conversion needs one Int or Float",
+			ExplainJa: "int()とfloat()は数値の値を1つだけ受け取ります。\n文字列を数値として読む場合はatoiやatofを使用してください。",
+			ExplainEn: "int() and float() take exactly one numeric value.\nUse atoi or atof to read a number from a string.",
+			Example:   "func main() int {\n\treturn int(\"1\")\n}",
+		},
+		"A0069": {
+			Ja:        "stringに変換できるのはInt, Float, Stringのいずれか1つの値です",
+			En:        "string conversion needs one Int, Float or String",
+			ExplainJa: "string()は数値か文字列の値を1つだけ受け取ります。",
+			ExplainEn: "string() takes exactly one number or string.",
+			Example:   "func main() int {\n\ts := string(true)\n\treturn len(s)\n}",
+		},
+		"A0070": {
+			Ja:        "%sの引数は文字列1つである必要があります",
+			En:        "%s needs one string argument",
+			ExplainJa: "この組み込み関数は文字列の値を1つだけ受け取ります。",
+			ExplainEn: "This builtin function takes exactly one string.",
+			Example:   "func main() int {\n\treturn atoi(1)\n}",
+		},
+		"A0071": {
+			Ja:       "組み込み関数%sは定義されていません",
+			En:       "undefined builtin function %s",
+			Internal: true,
+		},
+		"A0072": {
+			Ja:        "関数以外の値は呼び出せません",
+			En:        "cannot call a non-function value",
+			ExplainJa: "()で呼び出せるのは関数の値のみです。",
+			ExplainEn: "Only function values can be called with ().",
+			Example:   "func main() int {\n\tx := 1\n\treturn x()\n}",
+		},
+		"A0073": {
+			Ja:        "関数呼び出しの引数と与えられた型が異なります",
+			En:        "arguments do not match the function parameters",
+			ExplainJa: "関数に渡した値の型か数が、引数の宣言と一致しません。",
+			ExplainEn: "The types or number of arguments do not match the declared parameters.",
+			Example:   "func f(x int) int {\n\treturn x\n}\n\nfunc main() int {\n\treturn f(1, 2)\n}",
+		},
+		"A0074": {
+			Ja:        "%sにメソッド%sは存在しません",
+			En:        "%s has no method %s",
+			ExplainJa: "型に定義されていないメソッドを呼び出しています。",
+			ExplainEn: "The type has no method with this name.",
+			Example:   "type Shape interface {\n\tArea() int\n}\n\nfunc main() int {\n\tvar s Shape\n\treturn s.Perimeter()\n}",
+		},
+		"A0075": {
+			Ja:        "メソッド%sの引数と与えられた型が異なります",
+			En:        "arguments do not match the parameters of method %s",
+			ExplainJa: "メソッドに渡した値の型か数が、引数の宣言と一致しません。",
+			ExplainEn: "The types or number of arguments do not match the parameters of the method.",
+			Example:   "type Point struct {\n\tx int\n}\n\nfunc (p Point) Move(dx int) int {\n\treturn p.x + dx\n}\n\nfunc main() int {\n\tp := Point{x: 1}\n\treturn p.Move(\"1\")\n}",
+		},
+		"A0076": {
+			Ja:        "iotaはconstの宣言の中でのみ使用できます",
+			En:        "iota can only be used in a const declaration",
+			ExplainJa: "iotaはconst()のグループの中での位置を表す定数で、それ以外の場所では使用できません。",
+			ExplainEn: "iota is the index within a const() group and cannot be used anywhere else.",
+			Example:   "func main() int {\n\treturn iota\n}",
+		},
+		"A0077": {
+			Ja:        "関数%sは定義されていません",
+			En:        "undefined function %s",
+			ExplainJa: "宣言されていない関数を呼び出しています。",
+			ExplainEn: "The called function has not been declared.",
+			Example:   "func main() int {\n\treturn f()\n}",
+		},
+		"A0078": {
+			Ja:       "未知の値です",
+			En:       "unknown expression",
+			Internal: true,
+		},
+		"A0079": {
+			Ja:        "%sは既に定数として定義されています",
+			En:        "%s is already declared as a constant",
+			ExplainJa: "定数と同じ名前のグローバル変数は宣言できません。",
+			ExplainEn: "A global variable cannot have the same name as a constant.",
+			Example:   "const N = 1\n\nvar N int",
+		},
+		"A0080": {
+			Ja:        "グローバル変数では即値以外を代入することはできません",
+			En:        "global variables can only be initialized with constant values",
+			ExplainJa: "グローバル変数の初期値には、リテラルか定数式のみを使用できます。\n関数の呼び出しや他の変数は使用できません。",
+			ExplainEn: "A global variable can only be initialized with a literal or a constant expression.\nCalls and other variables cannot be used.",
+			Example:   "func f() int {\n\treturn 1\n}\n\nvar x int = f()",
+		},
+		"A0081": {
+			Ja:        "グローバル変数に型の異なる値を代入することはできません",
+			En:        "cannot initialize a global variable with a value of a different type",
+			ExplainJa: "グローバル変数の初期値は、宣言された型と同じ型である必要があります。",
+			ExplainEn: "The initial value of a global variable must have its declared type.",
+			Example:   "var x int = \"1\"",
+		},
+		"A0082": {
+			Ja:        "定数%sは既に定義されています",
+			En:        "constant %s is already declared",
+			ExplainJa: "同じ名前の定数を2回以上宣言しています。",
+			ExplainEn: "A constant with the same name has already been declared.",
+			Example:   "const N = 1\nconst N = 2",
+		},
+		"A0083": {
+			Ja:        "%sは既に変数として定義されています",
+			En:        "%s is already declared as a variable",
+			ExplainJa: "グローバル変数と同じ名前の定数は宣言できません。",
+			ExplainEn: "A constant cannot have the same name as a global variable.",
+			Example:   "var N int\n\nconst N = 1\n\nfunc main() int {\n\treturn N\n}",
+		},
+		"A0084": {
+			Ja:        "定数%sの型と値の型が一致しません: %s <- %s",
+			En:        "constant %s of type %s cannot hold a value of type %s",
+			ExplainJa: "型を指定した定数に、異なる型の値を指定しています。",
+			ExplainEn: "A typed constant is given a value of a different type.",
+			Example:   "const N int = \"1\"",
+		},
+		"A0085": {
+			Ja:        "定数%sには代入できません",
+			En:        "cannot assign to constant %s",
+			ExplainJa: "定数の値は変更できません。",
+			ExplainEn: "The value of a constant cannot be changed.",
+			Example:   "const N = 1\n\nfunc main() int {\n\tN = 2\n\treturn N\n}",
+		},
+		"A0086": {
+			Ja:        "定数にできない値です",
+			En:        "value cannot be a constant",
+			ExplainJa: "定数にできるのはInt, Float, String, Boolの値のみです。",
+			ExplainEn: "Only Int, Float, String and Bool values can be constants.",
+			Example:   "const n = nil",
+		},
+		"A0087": {
+			Ja:        "%sは定数ではありません",
+			En:        "%s is not a constant",
+			ExplainJa: "定数式の中では、他の定数、インポートしたパッケージの公開された定数、リテラルのみを使用できます。",
+			ExplainEn: "A constant expression can only refer to other constants, exported constants of imported packages and literals.",
+			Example:   "var x int\n\nconst N = x + 1",
+		},
+		"A0088": {
+			Ja:        "定数式ではありません",
+			En:        "not a constant expression",
+			ExplainJa: "定数の値には、コンパイル時に計算できる式のみを使用できます。\nint(), float(), string()による型変換以外の、関数の呼び出しなどは使用できません。",
+			ExplainEn: "A constant value must be an expression that can be evaluated at compile time.\nCalls other than the conversions int(), float() and string() cannot be used.",
+			Example:   "const N = len(\"abc\")",
+		},
+		"A0089": {
+			Ja:        "0で除算しています",
+			En:        "division by zero",
+			ExplainJa: "定数式の中で0による除算、剰余算をしています。",
+			ExplainEn: "A constant expression divides by zero or takes a remainder by zero.",
+			Example:   "const N = 1 / 0",
+		},
+		"A0090": {
+			Ja:        "%sには使用できない演算です",
+			En:        "invalid operation on %s",
+			ExplainJa: "定数式の中で、値の型に対応していない演算をしています。",
+			ExplainEn: "A constant expression applies an operator its operand type does not support.",
+			Example:   "const S = \"a\" - \"b\"",
+		},
+		"A0091": {
+			Ja:        "到達できないコードです",
+			En:        "unreachable code",
+			ExplainJa: "returnや終わらないforなど、必ず関数から抜ける文の後ろの文は実行されません。",
+			ExplainEn: "Statements after one that always leaves the function, such as return or an endless for, are never executed.",
+			Example:   "func f() int {\n\treturn 1\n\treturn 2\n}",
+		},
+		"A0092": {
+			Ja:        "%sは既にこのスコープで宣言されています",
+			En:        "%s redeclared in this block",
+			ExplainJa: "同じブロックの中で同じ名前の変数を2回宣言しています。\n内側のブロックで外側と同じ名前を宣言することはできます。",
+			ExplainEn: "A variable with the same name has already been declared in this block.\nAn inner block may declare a name that shadows an outer one.",
+			Example:   "func main() int {\n\tx := 1\n\tvar x int\n\treturn x\n}",
+		},
+		"A0093": {
+			Ja:        "%sが宣言されましたが使用されていません",
+			En:        "%s declared and not used",
+			ExplainJa: "宣言された変数が一度も参照されていません。代入のみでは使用したことになりません。\n値が不要な場合は_で受け取ってください。",
+			ExplainEn: "The variable is declared but never read. Assigning to it does not count as a use.\nUse _ when the value is not needed.",
+			Example:   "func main() int {\n\tx := 1\n\treturn 0\n}",
+		},
+		"A0094": {
+			Ja:        "関数%sは既に定義されています",
+			En:        "function %s is already declared",
+			ExplainJa: "同じ名前の関数を2回以上宣言しています。",
+			ExplainEn: "A function with the same name has already been declared.",
+			Example:   "func f() int {\n\treturn 1\n}\n\nfunc f() int {\n\treturn 2\n}",
+		},
+		"A0095": {
+			Ja:        "定数%sの値が自身に依存しています",
+			En:        "initialization cycle: constant %s refers to itself",
+			ExplainJa: "定数は宣言の順序によらず他の定数を参照できますが、参照をたどって自身に戻ることはできません。",
+			ExplainEn: "A constant may refer to constants declared anywhere in the file, but following the references must not lead back to itself.",
+			Example:   "const A = B + 1\nconst B = A * 2",
+		},
+		"A0096": {Ja: "IRに変換できないノードです: %v", En: "cannot lower node to IR: %v", Internal: true},
+		"A0097": {
+			Ja:        "パッケージ%sに%sは定義されていません",
+			En:        "undefined: %s.%s",
+			ExplainJa: "参照したパッケージに、その名前の関数、グローバル変数、定数、型はありません。",
+			ExplainEn: "The referenced package has no function, global variable, constant or type with that name.",
+		},
+		"A0098": {
+			Ja:        "%sは既にインポートされています",
+			En:        "%s redeclared in this file",
+			ExplainJa: "同じファイルで、同じ名前のパッケージを2回以上インポートしています。\n別名を付けると同じ名前のパッケージを両方インポートできます。",
+			ExplainEn: "Two imports in the same file use the same name.\nGive one of them an alias to import both.",
+			Example:   "import \"a/util\"\nimport \"b/util\"\n\nfunc main() int {\n\treturn util.F()\n}",
+		},
+		"A0099": {
+			Ja:        "%sがインポートされましたが使用されていません",
+			En:        "\"%s\" imported and not used",
+			ExplainJa: "インポートしたパッケージがそのファイルで一度も参照されていません。\n不要なimportは削除してください。",
+			ExplainEn: "The imported package is never referenced in the file that imports it.\nRemove the unnecessary import.",
+		},
+		"A0100": {
+			Ja:        "%sはこのファイルでインポートされていません",
+			En:        "undefined: %s (imported in another file)",
+			ExplainJa: "importはそれを書いたファイルの中でのみ有効です。\n同じパッケージの他のファイルでインポートしていても、参照するファイルでもインポートしてください。",
+			ExplainEn: "An import only applies to the file it is written in.\nImport the package in every file that refers to it, even if another file of the package already does.",
+		},
+		"A0101": {
+			Ja:        "%s.%sは公開されていないため参照できません",
+			En:        "cannot refer to unexported name %s.%s",
+			ExplainJa: "大文字で始まる名前の関数、グローバル変数、定数、型のみが他のパッケージから参照できます。\n小文字で始まる名前はそのパッケージの中でのみ使えます。",
+			ExplainEn: "Only functions, global variables, constants and types whose names start with an uppercase letter can be referred to from another package.\nNames starting with a lowercase letter are private to their package.",
+		},
+		"A0102": {
+			Ja:        "%sは定義されていません, インポートされたパッケージではありません",
+			En:        "undefined: %s (not an imported package)",
+			ExplainJa: "`名前.識別子`の名前が、変数でもそのファイルでインポートしたパッケージでもありません。\n他のパッケージを参照する場合はimportしてください。",
+			ExplainEn: "The name in `name.identifier` is neither a variable nor a package imported in that file.\nImport the package to refer to it.",
+			Example:   "func main() int {\n\tfmt.Println(1)\n\treturn 0\n}",
+		},
+		"A0103": {
+			Ja:        "%sのフィールドまたはメソッド%sは公開されていないため参照できません",
+			En:        "cannot refer to unexported field or method %s.%s",
+			ExplainJa: "他のパッケージの型は、大文字で始まる名前のフィールドとメソッドのみを参照できます。\n小文字で始まるフィールドとメソッドは、型を定義したパッケージの中でのみ使えます。",
+			ExplainEn: "Only fields and methods whose names start with an uppercase letter can be used on a type from another package.\nLowercase fields and methods are private to the package that defines the type.",
+		},
+		"A0104": {
+			Ja:        "init関数は引数と戻り値を持てません",
+			En:        "func init must have no arguments and no return values",
+			ExplainJa: "init関数は、パッケージのグローバル変数を初期化した後、mainより前に自動で呼び出されます。\n呼び出し元がないため、引数と戻り値は持てません。",
+			ExplainEn: "func init is called automatically after the package's global variables are initialized and before main.\nNothing calls it directly, so it cannot take arguments or return values.",
+			Example:   "func init() int {\n\treturn 1\n}",
+		},
+		"A0105": {
+			Ja:        "init関数は呼び出したり値として使ったりできません",
+			En:        "cannot call or refer to func init",
+			ExplainJa: "init関数はプログラムの開始時に一度だけ自動で呼び出されます。\n同じ処理を何度も行うには、別の名前の関数に分けてください。",
+			ExplainEn: "func init runs automatically once when the program starts.\nMove the code into a function with another name to run it again.",
+			Example:   "func init() {\n}\n\nfunc main() int {\n\tinit()\n\treturn 0\n}",
+		},
+		"A0106": {
+			Ja:        "%sの値は==, !=で比較できません",
+			En:        "cannot compare values of type %s with == or !=",
+			ExplainJa: "==と!=で比較できるのはInt, Float, String, Boolの値のみです。\n構造体や配列はフィールドや要素ごとに比較してください。",
+			ExplainEn: "Only Int, Float, String and Bool values can be compared with == and !=.\nCompare structs and arrays field by field or element by element.",
+			Example:   "type P struct {\n\tx int\n}\n\nfunc main() int {\n\ta := P{x: 1}\n\tb := P{x: 1}\n\tif a == b {\n\t\treturn 1\n\t}\n\treturn 0\n}",
+		},
+	})
+
+	notes = map[string]Message{
+		"N0001": {Ja: "戻り値のある関数は、全ての経路でreturnする必要があります", En: "a function with results must return on every path"},
+		"N0002": {Ja: "ここで関数から抜けます", En: "the function always leaves here"},
+		"N0003": {Ja: "前の宣言", En: "previous declaration"},
+		"N0004": {Ja: "定数%sの値を計算しています", En: "while evaluating constant %s"},
+	}
+}
@@ -0,0 +1,56 @@
+package diagnostic
+
+// パッケージを読み込む時のエラー
+func init() {
+	register(map[string]Message{
+		"B0001": {
+			Ja:        "パッケージ%sが見つかりません",
+			En:        "package %s not found",
+			ExplainJa: "インポートしたパスのディレクトリが、プロジェクトのルートと検索パスのどこにもありません。\narrtty.modのmoduleで始まるパスはプロジェクトのルートからの相対パスとして扱い、それ以外のパスは検索パスからのみ探します。\n検索パスは-pathオプション、環境変数ARRTTY_PATH、arrtty.modのpathで指定できます。",
+			ExplainEn: "No directory for the imported path exists under the project root or on the search path.\nA path starting with the module name in arrtty.mod is relative to the project root; any other path is looked up only on the search path.\nThe search path is set with the -path option, the ARRTTY_PATH environment variable and path lines in arrtty.mod.",
+			Example:   "import \"nowhere\"\n\nfunc main() int {\n\treturn 0\n}",
+		},
+		"B0002": {
+			Ja:        "インポートが循環しています: %s",
+			En:        "import cycle not allowed: %s",
+			ExplainJa: "パッケージが自身を直接、または他のパッケージを通してインポートしています。\n共通する部分を別のパッケージに分けてください。",
+			ExplainEn: "A package imports itself, directly or through other packages.\nMove the shared parts into a separate package.",
+		},
+		"B0003": {
+			Ja:        "arrtty.modの%d行目が不正です: %s",
+			En:        "invalid arrtty.mod at line %d: %s",
+			ExplainJa: "arrtty.modには`module 名前`を1行と、検索パスを追加する`path ディレクトリ`を書くことができます。\n//から行末まではコメントです。",
+			ExplainEn: "arrtty.mod holds one `module name` line and any number of `path directory` lines adding to the search path.\nText from // to the end of a line is a comment.",
+		},
+		"B0004": {
+			Ja:        "パッケージ%sにソースファイルがありません",
+			En:        "no source files in package %s",
+			ExplainJa: "インポートしたディレクトリに拡張子.arrのファイルがありません。",
+			ExplainEn: "The imported directory has no files with the .arr extension.",
+		},
+		"B0005": {
+			Ja:        "%sを読み込めません: %s",
+			En:        "cannot read %s: %s",
+			ExplainJa: "ソースファイルやディレクトリ、arrtty.modを読み込めませんでした。\nパスと権限を確認してください。",
+			ExplainEn: "A source file, directory or arrtty.mod could not be read.\nCheck the path and its permissions.",
+		},
+		"B0006": {
+			Ja:        "arrtty.modにmoduleがありません",
+			En:        "arrtty.mod has no module line",
+			ExplainJa: "arrtty.modには、プロジェクト内のパッケージのインポートパスの先頭になる名前を`module 名前`の形で書く必要があります。",
+			ExplainEn: "arrtty.mod must name the module with a `module name` line; the name prefixes the import paths of the packages in the project.",
+		},
+		"B0007": {
+			Ja:        "%sに書き込めません: %s",
+			En:        "cannot write %s: %s",
+			ExplainJa: "オブジェクトファイルやビルドしたプログラムを書き出せませんでした。\nディレクトリの権限と空き容量を確認してください。",
+			ExplainEn: "An object file or the built program could not be written.\nCheck the permissions of the directory and the free space.",
+		},
+		"B0008": {
+			Ja:        "ディレクトリ%sが%sと%sの二つのインポートパスでインポートされています",
+			En:        "directory %s is imported as both %s and %s",
+			ExplainJa: "同じディレクトリを別のインポートパスでインポートすると、別のパッケージとして二度読み込まれてしまいます。\nプロジェクト内のパッケージは、arrtty.modのmoduleで始まるパスでインポートしてください。",
+			ExplainEn: "Importing one directory through two import paths would load it twice as two different packages.\nImport packages inside the project with paths starting with the module name in arrtty.mod.",
+		},
+	})
+}
@@ -0,0 +1,44 @@
+package diagnostic
+
+// リンク時のエラー
+func init() {
+	register(map[string]Message{
+		"L0001": {
+			Ja:        "%sは定義されていません",
+			En:        "undefined reference to %s",
+			ExplainJa: "他のパッケージの関数、グローバル変数を参照していますが、リンクしたオブジェクトのどれにも定義されていません。\n参照しているパッケージを解析してオブジェクトとして渡してください。",
+			ExplainEn: "A function or global variable of another package is referenced, but none of the linked objects defines it.\nAnalyze the referenced package and pass it as an object.",
+		},
+		"L0002": {
+			Ja:        "%sが複数のオブジェクトで定義されています",
+			En:        "%s is defined in more than one object",
+			ExplainJa: "同じパッケージの関数、グローバル変数は一つのオブジェクトでのみ定義できます。",
+			ExplainEn: "A function or global variable of a package may be defined in only one object.",
+		},
+		"L0003": {
+			Ja:        "%sの型が解析時と異なります: %s, 定義: %s",
+			En:        "%s has a different type than when analyzed: %s, defined as %s",
+			ExplainJa: "参照しているパッケージを解析した後で、参照先のパッケージの定義が変わっています。\n参照しているパッケージを解析し直してください。",
+			ExplainEn: "The definition in the referenced package changed after the referencing package was analyzed.\nAnalyze the referencing package again.",
+		},
+		"L0004": {
+			Ja:        "main関数が定義されていません",
+			En:        "function main is undefined",
+			ExplainJa: "プログラムの入口となるmain関数が、mainのパッケージのオブジェクトに定義されていません。",
+			ExplainEn: "No object of the main package defines the function main, which is the entry point of the program.",
+		},
+		"L0005": {
+			Ja:        "%sは公開されていないため他のパッケージから参照できません",
+			En:        "cannot refer to unexported %s from another package",
+			ExplainJa: "小文字で始まる名前の関数、グローバル変数は、それを定義したパッケージの中でのみ参照できます。\n他のパッケージから使う場合は大文字で始まる名前にしてください。",
+			ExplainEn: "A function or global variable whose name starts with a lowercase letter can only be referred to inside the package that defines it.\nRename it to start with an uppercase letter to use it from another package.",
+		},
+		"L0006": {
+			Ja:        "オブジェクトファイル%sを読み込めません: %s",
+			En:        "invalid object file %s: %s",
+			ExplainJa: "オブジェクトファイルが壊れているか、別のバージョンのarrttyで作られています。\nパッケージをコンパイルし直してください。",
+			ExplainEn: "The object file is corrupted or was written by a different version of arrtty.\nCompile the package again.",
+		},
+		"L0007": {Ja: "オブジェクトファイル%sを書き出せません: %v", En: "cannot encode object file %s: %v", Internal: true},
+	})
+}
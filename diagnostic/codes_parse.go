@@ -0,0 +1,63 @@
+package diagnostic
+
+// 字句解析と構文解析のエラー
+func init() {
+	register(map[string]Message{
+		"T0001": {
+			Ja:        "読み取れない文字です: %q",
+			En:        "unexpected character: %q",
+			ExplainJa: "ソースに言語で使用しない文字が含まれています。\n文字列リテラルの外で使用できるのは識別子、数値、演算子、区切り文字と空白のみです。",
+			ExplainEn: "The source contains a character that is not part of the language.\nOutside string literals only identifiers, numbers, operators, punctuation and whitespace are allowed.",
+			Example:   "func main() int {\n\treturn 1 @ 2\n}",
+		},
+		"P0001": {
+			Ja:        "予期しないトークンです: %s, %sが必要です",
+			En:        "unexpected token: %s, expected %s",
+			ExplainJa: "文法上、この位置には別のトークンが必要です。\n括弧の閉じ忘れや、区切りの抜けがないか確認してください。",
+			ExplainEn: "The grammar requires a different token at this position.\nCheck for unclosed brackets or missing separators.",
+			Example:   "func main() int {\n\treturn (1 + 2\n}",
+		},
+		"P0002": {
+			Ja:        "予期しないトークンです: %s, structかinterfaceが必要です",
+			En:        "unexpected token: %s, expected struct or interface",
+			ExplainJa: "型の宣言では、名前の後にstructかinterfaceで型の内容を書きます。",
+			ExplainEn: "A type declaration must be followed by a struct or interface body after its name.",
+			Example:   "type Point int",
+		},
+		"P0003": {
+			Ja:        "トップレベルに書くことのできない記述です",
+			En:        "unexpected top-level declaration",
+			ExplainJa: "ファイルのトップレベルに書けるのはimport, func, type, var, constの宣言のみです。\n文は関数の中に書いてください。",
+			ExplainEn: "Only import, func, type, var and const declarations may appear at the top level of a file.\nStatements must be placed inside a function.",
+			Example:   "x = 1\n\nfunc main() int {\n\treturn 0\n}",
+		},
+		"P0004": {
+			Ja:        "定数%sには値が必要です",
+			En:        "constant %s needs a value",
+			ExplainJa: "constの宣言には値が必要です。\nconst()のグループの中では、前の定数と同じ式を繰り返すために値を省略できますが、最初の定数には値が必要です。",
+			ExplainEn: "A const declaration needs a value.\nInside a const() group a value may be omitted to repeat the previous expression, but the first constant needs one.",
+			Example:   "const (\n\tA\n\tB\n)",
+		},
+		"P0005": {
+			Ja:        "switchにdefaultが複数あります",
+			En:        "multiple defaults in switch",
+			ExplainJa: "1つのswitchに書けるdefaultは1つだけです。",
+			ExplainEn: "A switch statement may contain at most one default case.",
+			Example:   "func main() int {\n\tx := 1\n\tswitch x {\n\tdefault:\n\t\treturn 1\n\tdefault:\n\t\treturn 2\n\t}\n\treturn 0\n}",
+		},
+		"P0006": {
+			Ja:        "予期しないトークンです: %s, caseかdefaultが必要です",
+			En:        "unexpected token: %s, expected case or default",
+			ExplainJa: "switchの本文にはcaseかdefaultの節のみを書くことができます。",
+			ExplainEn: "The body of a switch statement may only contain case and default clauses.",
+			Example:   "func main() int {\n\tx := 1\n\tswitch x {\n\t\treturn 1\n\t}\n\treturn 0\n}",
+		},
+		"P0007": {
+			Ja:        "予期しないトークンです: %s, 値が必要です",
+			En:        "unexpected token: %s, expected a value",
+			ExplainJa: "式の中で、値(リテラル、変数、関数呼び出しなど)が必要な位置に別のトークンがあります。",
+			ExplainEn: "An expression needs a value (a literal, a variable, a call, ...) at this position, but found another token.",
+			Example:   "func main() int {\n\treturn 1 + \n}",
+		},
+	})
+}
@@ -0,0 +1,84 @@
+package diagnostic
+
+// コード生成と実行時のエラー
+// 意味解析を通ったプログラムでは起こらないものはInternalにする
+func init() {
+	register(map[string]Message{
+		"C0001": {Ja: "宣言する変数が見つかりません: %s", En: "variable to declare not found: %s", Internal: true},
+		"C0002": {Ja: "変数の位置を特定できませんでした: %v", En: "cannot locate variable: %v", Internal: true},
+		"C0003": {Ja: "ゼロ値を持たない型です: %s", En: "type has no zero value: %s", Internal: true},
+		"C0004": {Ja: "フィールド、要素ではありません", En: "not a field or element", Internal: true},
+		"C0005": {Ja: "変数が定義されていません: %s", En: "undefined variable: %s", Internal: true},
+		"C0006": {Ja: "比較できない演算です", En: "not a comparison", Internal: true},
+		"C0007": {Ja: "サポートされていないリテラルです", En: "unsupported literal", Internal: true},
+		"C0008": {Ja: "キャプチャする変数が見つかりません: %s", En: "captured variable not found: %s", Internal: true},
+		"C0009": {Ja: "組み込み関数%sは定義されていません", En: "undefined builtin function %s", Internal: true},
+		"C0010": {Ja: "リンクが不完全です", En: "program is not fully linked", Internal: true},
+
+		"V0001": {Ja: "演算できない値の組み合わせです: %s %s %s", En: "invalid operation: %s %s %s", Internal: true},
+		"V0002": {
+			Ja:        "0で除算することはできません",
+			En:        "integer divide by zero",
+			ExplainJa: "実行中にIntの値を0で除算、剰余算しました。\n除数が0になりうる場合は、事前に確認してください。",
+			ExplainEn: "An Int value was divided by zero, or its remainder by zero was taken, at run time.\nCheck the divisor first when it can be zero.",
+			Example:   "func div(a int, b int) int {\n\treturn a / b\n}\n\nfunc main() int {\n\treturn div(1, 0)\n}",
+		},
+		"V0003": {Ja: "レジスタ%sからデータを取得できませんでした", En: "cannot read register %s", Internal: true},
+		"V0004": {Ja: "命令%sはオペランド%sに対応していません", En: "instruction %s does not accept operand %s", Internal: true},
+		"V0005": {Ja: "%sの変換先が不正です: %s", En: "invalid target of %s: %s", Internal: true},
+		"V0006": {Ja: "%sを%sに変換することはできません", En: "cannot convert %s to %s", Internal: true},
+		"V0007": {Ja: "変換できない値です: %s", En: "value cannot be converted: %s", Internal: true},
+		"V0008": {Ja: "文字列ではありません: %s", En: "not a string: %s", Internal: true},
+		"V0009": {Ja: "文字列を%sとして読むことはできません", En: "cannot read the string as %s", Internal: true},
+		"V0010": {Ja: "未定義のラベルです: %s", En: "undefined label: %s", Internal: true},
+		"V0011": {Ja: "代入元の変数が見つかりません: %s", En: "source variable not found: %s", Internal: true},
+		"V0012": {Ja: "戻り先が不正です: pc=%d", En: "invalid return address: pc=%d", Internal: true},
+		"V0013": {Ja: "命令%sの要素数が不正です: %s", En: "invalid element count for %s: %s", Internal: true},
+		"V0014": {Ja: "インデックスが不正です: %s", En: "invalid index: %s", Internal: true},
+		"V0015": {
+			Ja:        "インデックスが範囲外です: %d (len %d)",
+			En:        "index out of range: %d (len %d)",
+			ExplainJa: "実行中に、配列やスライスの長さ以上、または負のインデックスで要素を参照しました。",
+			ExplainEn: "An array or slice was indexed at run time with a negative index or one not less than its length.",
+			Example:   "func main() int {\n\ts := []int{1, 2}\n\ti := 2\n\treturn s[i]\n}",
+		},
+		"V0016": {Ja: "スライスの範囲が不正です: %s:%s", En: "invalid slice bounds: %s:%s", Internal: true},
+		"V0017": {Ja: "スライスにできないオブジェクトです: %s", En: "cannot slice object: %s", Internal: true},
+		"V0018": {
+			Ja:        "スライスの範囲が範囲外です: %d:%d (cap %d)",
+			En:        "slice bounds out of range: %d:%d (cap %d)",
+			ExplainJa: "実行中に、元の配列の容量を超えるか、開始が終了より大きい範囲でスライスを作りました。",
+			ExplainEn: "A slice was taken at run time with bounds beyond the capacity, or with a start after its end.",
+			Example:   "func main() int {\n\ts := []int{1, 2}\n\th := 3\n\tt := s[0:h]\n\treturn len(t)\n}",
+		},
+		"V0019": {Ja: "スライス以外には追加できません: %s", En: "cannot append to a non-slice: %s", Internal: true},
+		"V0020": {Ja: "マップではありません: %s", En: "not a map: %s", Internal: true},
+		"V0021": {Ja: "closureの関数が不正です: %s", En: "invalid closure function: %s", Internal: true},
+		"V0022": {Ja: "calliのメソッド名が不正です: %s", En: "invalid method name for calli: %s", Internal: true},
+		"V0023": {
+			Ja:        "nilのインターフェースのメソッドを呼び出しました: %s",
+			En:        "method %s called on a nil interface",
+			ExplainJa: "値が格納されていないインターフェースの変数に対して、メソッドを呼び出しました。",
+			ExplainEn: "A method was called through an interface variable that holds no value.",
+			Example:   "type Shape interface {\n\tArea() int\n}\n\nfunc main() int {\n\tvar s Shape\n\treturn s.Area()\n}",
+		},
+		"V0024": {Ja: "メソッド%sが見つかりません", En: "method %s not found", Internal: true},
+		"V0025": {
+			Ja:        "関数ではない値を呼び出しました: %s",
+			En:        "called a value that is not a function: %s",
+			ExplainJa: "関数の型の変数やフィールドに関数が格納されていないまま呼び出しました。",
+			ExplainEn: "A variable or field of function type was called before a function was stored in it.",
+			Example:   "type Button struct {\n\tonClick func() int\n}\n\nfunc main() int {\n\tb := Button{}\n\treturn b.onClick()\n}",
+		},
+		"V0026": {Ja: "マップのキーにできない値です: %s", En: "value cannot be a map key: %s", Internal: true},
+		"V0027": {Ja: "ポインタではありません: %s", En: "not a pointer: %s", Internal: true},
+		"V0028": {Ja: "不正なアドレスです: %d", En: "invalid address: %d", Internal: true},
+		"V0029": {Ja: "不正なポインタです", En: "invalid pointer", Internal: true},
+		"V0030": {Ja: "%sに%s.%sを代入することはできません", En: "cannot set %s to %s.%s", Internal: true},
+		"V0031": {Ja: "終了コードが不正な値です: %s", En: "invalid exit code: %s", Internal: true},
+		"V0032": {Ja: "mainが見つかりません", En: "main label not found", Internal: true},
+		"V0033": {Ja: "pcはopcodeを予想しましたが、%sが発見されました", En: "expected an opcode at pc, found %s", Internal: true},
+		"V0034": {Ja: "サポートされていない命令です: %s", En: "unsupported instruction: %s", Internal: true},
+		"V0035": {Ja: "保存された命令を復元できません: %v", En: "cannot decode instruction: %v", Internal: true},
+	})
+}
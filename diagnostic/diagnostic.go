@@ -0,0 +1,138 @@
+package diagnostic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Severity 診断の重大度
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	}
+	return "error"
+}
+
+// Span ソース上の範囲, 行は1から、列は0から数える
+type Span struct {
+	// File ファイル名, 一つのファイルのみを扱う場合は空
+	File string
+	Line int
+	// Col 行の先頭からの文字数, 0から数える, 表示する時は1から数える
+	Col int
+	Len int
+}
+
+// Related 診断に関係する別の箇所
+type Related struct {
+	Span    *Span
+	Message string
+}
+
+// Diagnostic 位置の付いたエラーや警告
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Message  string
+	// Span 主な箇所, 位置が分からない場合はnil
+	Span    *Span
+	Related []Related
+	Notes   []string
+}
+
+// New spanの位置のエラー, メッセージはcodeの雛形から現在の言語で作る
+func New(span *Span, code string, a ...any) *Diagnostic {
+	return &Diagnostic{
+		Severity: Error,
+		Code:     code,
+		Message:  Text(code, a...),
+		Span:     span,
+	}
+}
+
+// Errorf 位置の分からないエラー, 後から位置を付けることができる
+func Errorf(code string, a ...any) *Diagnostic {
+	return New(nil, code, a...)
+}
+
+// WithRelated 関係する箇所を追加する
+func (d *Diagnostic) WithRelated(span *Span, code string, a ...any) *Diagnostic {
+	d.Related = append(d.Related, Related{Span: span, Message: Text(code, a...)})
+	return d
+}
+
+// WithNote 補足を追加する
+func (d *Diagnostic) WithNote(code string, a ...any) *Diagnostic {
+	d.Notes = append(d.Notes, Text(code, a...))
+	return d
+}
+
+// Error `[行:列] コード: メッセージ`の形にする, 分からない部分は省く
+func (d *Diagnostic) Error() string {
+	msg := d.Message
+	if d.Code != "" {
+		msg = d.Code + ": " + msg
+	}
+	if d.Span == nil {
+		return msg
+	}
+	return fmt.Sprintf("[%d:%d] %s", d.Span.Line, d.Span.Col+1, msg)
+}
+
+// List 1回の実行で見つかった診断
+type List []*Diagnostic
+
+func (l List) Error() string {
+	var messages []string
+	for _, d := range l {
+		messages = append(messages, d.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Add エラーを診断として追加する
+// 位置を持たないエラーはspanの位置のエラーとして扱う
+func (l *List) Add(err error, span *Span) {
+	for _, d := range Flatten(err) {
+		if d.Span == nil {
+			d.Span = span
+		}
+		*l = append(*l, d)
+	}
+}
+
+// Err 診断がなければnil
+func (l List) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// Flatten エラーに含まれる診断を取り出す, 診断でないエラーは位置のない診断にする
+func Flatten(err error) []*Diagnostic {
+	if err == nil {
+		return nil
+	}
+	var list List
+	if errors.As(err, &list) {
+		return list
+	}
+	var d *Diagnostic
+	if errors.As(err, &d) {
+		return []*Diagnostic{d}
+	}
+	return []*Diagnostic{{Severity: Error, Message: err.Error()}}
+}
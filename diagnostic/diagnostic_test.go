@@ -0,0 +1,157 @@
+package diagnostic
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestList_Add(t *testing.T) {
+	_ = SetLanguage(Japanese)
+	var l List
+	l.Add(fmt.Errorf("位置のないエラー"), &Span{Line: 2, Col: 1, Len: 1})
+	l.Add(List{New(&Span{Line: 3, Col: 4, Len: 2}, "A0038", "a"), Errorf("A0038", "b")}, &Span{Line: 5, Col: 0, Len: 1})
+	assert.Equal(t, "[2:2] 位置のないエラー\n[3:5] A0038: aは定義されていません\n[5:1] A0038: bは定義されていません", l.Error())
+	assert.Nil(t, List(nil).Err())
+}
+
+func TestRenderer_Render(t *testing.T) {
+	source := "func main() int {\n\tx := 1\n\tx := 2\n\treturn x\n}\n"
+	_ = SetLanguage(Japanese)
+	d := New(&Span{Line: 3, Col: 1, Len: 1}, "A0092", "x").
+		WithRelated(&Span{Line: 2, Col: 1, Len: 1}, "N0003").
+		WithNote("N0004", "N")
+	var buf bytes.Buffer
+	r := &Renderer{Filename: "main.arr", Source: source}
+	r.Render(&buf, List{d})
+	expect := "error[A0092]: xは既にこのスコープで宣言されています\n" +
+		"  --> main.arr:3:2\n" +
+		"   |\n" +
+		" 3 | \tx := 2\n" +
+		"   | \t^\n" +
+		"   |\n" +
+		" 2 | \tx := 1\n" +
+		"   | \t^ 前の宣言\n" +
+		"   = note: 定数Nの値を計算しています\n" +
+		"\n"
+	assert.Equal(t, expect, buf.String())
+}
+
+func TestRenderer_Files(t *testing.T) {
+	_ = SetLanguage(Japanese)
+	d := New(&Span{File: "b.arr", Line: 1, Col: 5, Len: 1}, "L0002", "m.f").
+		WithRelated(&Span{File: "a.arr", Line: 2, Col: 5, Len: 1}, "N0003")
+	var buf bytes.Buffer
+	r := &Renderer{Files: map[string]string{
+		"a.arr": "var v int = 1\nfunc f() {\n}\n",
+		"b.arr": "func f() {\n}\n",
+	}}
+	r.Render(&buf, List{d})
+	expect := "error[L0002]: m.fが複数のオブジェクトで定義されています\n" +
+		"  --> b.arr:1:6\n" +
+		"   |\n" +
+		" 1 | func f() {\n" +
+		"   |      ^\n" +
+		"  --> a.arr:2:6\n" +
+		"   |\n" +
+		" 2 | func f() {\n" +
+		"   |      ^ 前の宣言\n" +
+		"\n"
+	assert.Equal(t, expect, buf.String())
+}
+
+// verbs 書式が使う引数の番号
+func verbs(format string) []int {
+	var used []int
+	next := 1
+	re := regexp.MustCompile(`%(\[(\d+)\])?[a-zA-Z%]`)
+	for _, m := range re.FindAllStringSubmatch(format, -1) {
+		if m[0] == "%%" {
+			continue
+		}
+		if m[2] != "" {
+			next, _ = strconv.Atoi(m[2])
+		}
+		used = append(used, next)
+		next++
+	}
+	sort.Ints(used)
+	return used
+}
+
+func TestCatalogue(t *testing.T) {
+	for _, code := range Codes() {
+		m := catalogue[code]
+		assert.NotEmpty(t, m.Ja, code)
+		assert.NotEmpty(t, m.En, code)
+		assert.Equal(t, verbs(m.Ja), verbs(m.En), code)
+		if !m.Internal {
+			assert.NotEmpty(t, m.ExplainJa, code)
+			assert.NotEmpty(t, m.ExplainEn, code)
+		}
+		assert.Regexp(t, `^[TPBALCV]\d{4}$`, code)
+	}
+	for code, m := range notes {
+		assert.Equal(t, verbs(m.Ja), verbs(m.En), code)
+	}
+}
+
+func TestSetLanguage(t *testing.T) {
+	defer func() { _ = SetLanguage(Japanese) }()
+
+	assert.NoError(t, SetLanguage(English))
+	d := New(&Span{Line: 1, Col: 2, Len: 1}, "A0029", "Int", "String")
+	assert.Equal(t, "[1:3] A0029: cannot assign String to a variable of type Int", d.Error())
+
+	assert.NoError(t, SetLanguage(Japanese))
+	d = New(&Span{Line: 1, Col: 2, Len: 1}, "A0029", "Int", "String")
+	assert.Equal(t, "[1:3] A0029: 代入された値と宣言の型が一致しません: Int <- String", d.Error())
+
+	assert.Error(t, SetLanguage("fr"))
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		env    map[string]string
+		expect string
+	}{
+		{map[string]string{}, Japanese},
+		{map[string]string{"LANG": "ja_JP.UTF-8"}, Japanese},
+		{map[string]string{"LANG": "en_US.UTF-8"}, English},
+		{map[string]string{"LANG": "C.UTF-8"}, Japanese},
+		{map[string]string{"LC_ALL": "en_US.UTF-8", "LANG": "ja_JP.UTF-8"}, English},
+		{map[string]string{"ARRTTY_LANG": "ja", "LC_ALL": "en_US.UTF-8"}, Japanese},
+	}
+	for _, tt := range tests {
+		getenv := func(key string) string { return tt.env[key] }
+		assert.Equal(t, tt.expect, detectLanguage(getenv), tt.env)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	defer func() { _ = SetLanguage(Japanese) }()
+
+	_ = SetLanguage(English)
+	text, err := Explain("a0093")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(text, "A0093: … declared and not used\n"))
+	assert.Contains(t, text, "Example:\n\n    func main() int {\n")
+
+	// 書式の引数はどれも…になる
+	text, err = Explain("A0029")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(text, "A0029: cannot assign … to a variable of type …\n"))
+
+	// 処理系の不具合によるエラーは共通の説明になる
+	text, err = Explain("V0003")
+	assert.NoError(t, err)
+	assert.Contains(t, text, "bug in arrtty")
+
+	_, err = Explain("A9999")
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package diagnostic
+
+import (
+	"fmt"
+	"github.com/gookit/color"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Renderer 診断をソースの該当箇所に下線を引いて出力する
+type Renderer struct {
+	Filename string
+	Source   string
+	// Files 複数のファイルを扱う場合のファイル名とソース, 位置がファイル名を持つ場合はこちらから選ぶ
+	Files map[string]string
+	// Colored 重大度と下線を色付けする
+	Colored bool
+}
+
+var severityStyles = map[Severity]color.Style{
+	Error:   color.New(color.FgRed, color.OpBold),
+	Warning: color.New(color.FgYellow, color.OpBold),
+	Note:    color.New(color.FgCyan, color.OpBold),
+}
+
+func (r *Renderer) style(s color.Style, text string) string {
+	if !r.Colored {
+		return text
+	}
+	return s.Sprint(text)
+}
+
+// Render errに含まれる全ての診断を出力する
+func (r *Renderer) Render(w io.Writer, err error) {
+	for _, d := range Flatten(err) {
+		r.render(w, d)
+	}
+}
+
+// render
+//
+//	error[A0001]: メッセージ
+//	  --> main.arr:3:2
+//	   |
+//	 3 |     return "x"
+//	   |            ^^^
+//	   = note: 補足
+func (r *Renderer) render(w io.Writer, d *Diagnostic) {
+	severity := severityStyles[d.Severity]
+	head := d.Severity.String()
+	if d.Code != "" {
+		head += "[" + d.Code + "]"
+	}
+	_, _ = fmt.Fprintf(w, "%s: %s\n", r.style(severity, head), r.style(color.New(color.OpBold), d.Message))
+
+	gutter := r.gutterWidth(d)
+	if d.Span != nil {
+		r.location(w, gutter, d.Span)
+		r.snippet(w, gutter, d.Span, severity, "")
+	}
+	for _, rel := range d.Related {
+		if rel.Span == nil {
+			_, _ = fmt.Fprintf(w, "%s= %s: %s\n", strings.Repeat(" ", gutter+1), r.style(severityStyles[Note], "note"), rel.Message)
+			continue
+		}
+		// 別のファイルの箇所はその位置も示す
+		if d.Span == nil || rel.Span.File != d.Span.File {
+			r.location(w, gutter, rel.Span)
+		}
+		r.snippet(w, gutter, rel.Span, severityStyles[Note], rel.Message)
+	}
+	for _, n := range d.Notes {
+		_, _ = fmt.Fprintf(w, "%s= %s: %s\n", strings.Repeat(" ", gutter+1), r.style(severityStyles[Note], "note"), n)
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// source 範囲のあるファイルの名前とソース
+// 渡されていないファイルはその場で読み込む
+func (r *Renderer) source(span *Span) (string, string) {
+	if span.File == "" || span.File == r.Filename {
+		return r.Filename, r.Source
+	}
+	if src, ok := r.Files[span.File]; ok {
+		return span.File, src
+	}
+	data, _ := os.ReadFile(span.File)
+	return span.File, string(data)
+}
+
+// location `--> ファイル:行:列`
+func (r *Renderer) location(w io.Writer, gutter int, span *Span) {
+	name, _ := r.source(span)
+	_, _ = fmt.Fprintf(w, "%s--> %s:%d:%d\n", strings.Repeat(" ", gutter), name, span.Line, span.Col+1)
+}
+
+// gutterWidth 行番号の欄の幅
+func (r *Renderer) gutterWidth(d *Diagnostic) int {
+	width := 1
+	spans := []*Span{d.Span}
+	for _, rel := range d.Related {
+		spans = append(spans, rel.Span)
+	}
+	for _, s := range spans {
+		if s != nil && width < len(strconv.Itoa(s.Line)) {
+			width = len(strconv.Itoa(s.Line))
+		}
+	}
+	return width + 1
+}
+
+// snippet 該当する行と、その下に範囲を示す下線を出力する
+func (r *Renderer) snippet(w io.Writer, gutter int, span *Span, style color.Style, label string) {
+	_, src := r.source(span)
+	lines := strings.Split(src, "\n")
+	pad := strings.Repeat(" ", gutter)
+	if span.Line < 1 || len(lines) < span.Line {
+		return
+	}
+	line := []rune(strings.TrimRight(lines[span.Line-1], "\r"))
+	_, _ = fmt.Fprintf(w, "%s |\n", pad)
+	_, _ = fmt.Fprintf(w, "%*d | %s\n", gutter, span.Line, string(line))
+
+	// タブはそのまま残して、ソースの表示と位置を揃える
+	var indent []rune
+	for i := 0; i < span.Col && i < len(line); i++ {
+		if line[i] == '\t' {
+			indent = append(indent, '\t')
+		} else {
+			indent = append(indent, ' ')
+		}
+	}
+	n := span.Len
+	if n < 1 {
+		n = 1
+	}
+	underline := strings.Repeat("^", n)
+	if label != "" {
+		underline += " " + label
+	}
+	_, _ = fmt.Fprintf(w, "%s | %s%s\n", pad, string(indent), r.style(style, underline))
+}
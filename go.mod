@@ -2,14 +2,16 @@ module github.com/arrietty-lang/arrtty
 
 go 1.20
 
-require github.com/stretchr/testify v1.8.1
+require (
+	github.com/gookit/color v1.5.3
+	github.com/gookit/slog v0.5.1
+	github.com/stretchr/testify v1.8.1
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/gookit/color v1.5.3 // indirect
 	github.com/gookit/goutil v0.6.8 // indirect
 	github.com/gookit/gsr v0.0.8 // indirect
-	github.com/gookit/slog v0.5.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
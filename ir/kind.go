@@ -0,0 +1,135 @@
+package ir
+
+type Kind int
+
+const (
+	_ Kind = iota
+
+	// 文
+
+	Block
+	// Decl 変数の宣言, 値がなければゼロ値で初期化する
+	Decl
+	// Assign 変数、フィールド、要素への代入
+	Assign
+	// MultiAssign 複数の値を返す式を変数に順に代入する
+	MultiAssign
+	// Update `x += 1`, `x++`のような演算と代入
+	Update
+	Return
+	If
+	Switch
+	For
+	ForRange
+	// ExprStmt 値を使用しない式, 値は捨てる
+	ExprStmt
+
+	// 式
+
+	Literal
+	// Var ローカル変数、引数、グローバル変数の値
+	Var
+	// FuncRef 何もキャプチャしない関数の値
+	FuncRef
+	Binary
+	Not
+	// Field 構造体のフィールド
+	Field
+	// Index 配列、スライス、マップの要素
+	Index
+	Slice
+	// Call 関数、メソッドを名前で呼び出す
+	Call
+	// CallValue 関数の値を呼び出す
+	CallValue
+	// CallMethod インターフェースの値を通してメソッドを呼び出す
+	CallMethod
+	Builtin
+	StructLit
+	ListLit
+	DictLit
+	FuncLit
+	// Convert 具体的な型の値をインターフェースの値にする
+	Convert
+)
+
+var kinds = [...]string{
+	Block:       "Block",
+	Decl:        "Decl",
+	Assign:      "Assign",
+	MultiAssign: "MultiAssign",
+	Update:      "Update",
+	Return:      "Return",
+	If:          "If",
+	Switch:      "Switch",
+	For:         "For",
+	ForRange:    "ForRange",
+	ExprStmt:    "ExprStmt",
+	Literal:     "Literal",
+	Var:         "Var",
+	FuncRef:     "FuncRef",
+	Binary:      "Binary",
+	Not:         "Not",
+	Field:       "Field",
+	Index:       "Index",
+	Slice:       "Slice",
+	Call:        "Call",
+	CallValue:   "CallValue",
+	CallMethod:  "CallMethod",
+	Builtin:     "Builtin",
+	StructLit:   "StructLit",
+	ListLit:     "ListLit",
+	DictLit:     "DictLit",
+	FuncLit:     "FuncLit",
+	Convert:     "Convert",
+}
+
+func (k Kind) String() string {
+	if 0 < int(k) && int(k) < len(kinds) {
+		return kinds[k]
+	}
+	return "Unknown"
+}
+
+// Op 二項演算と、演算と代入の演算子
+type Op int
+
+const (
+	_ Op = iota
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpEq
+	OpNe
+	OpLt
+	OpLe
+	OpGt
+	OpGe
+	OpAnd
+	OpOr
+)
+
+var ops = [...]string{
+	OpAdd: "+",
+	OpSub: "-",
+	OpMul: "*",
+	OpDiv: "/",
+	OpMod: "%",
+	OpEq:  "==",
+	OpNe:  "!=",
+	OpLt:  "<",
+	OpLe:  "<=",
+	OpGt:  ">",
+	OpGe:  ">=",
+	OpAnd: "&&",
+	OpOr:  "||",
+}
+
+func (o Op) String() string {
+	if 0 < int(o) && int(o) < len(ops) {
+		return ops[o]
+	}
+	return "?"
+}
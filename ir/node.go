@@ -0,0 +1,209 @@
+package ir
+
+import (
+	"fmt"
+	"github.com/arrietty-lang/arrtty/preprocess/parse"
+	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
+)
+
+type Node struct {
+	Kind Kind
+	Pos  *tokenize.Position
+	// Types 式の値の型, 複数の値を返す呼び出しでは戻り値の型の並び, 文ではnil
+	Types []*parse.DataType
+
+	// Symbol Var, FuncRef, Decl, Callが指す変数や関数
+	Symbol  *Symbol
+	Literal *tokenize.Literal
+
+	BlockField       *BlockField
+	DeclField        *DeclField
+	AssignField      *AssignField
+	MultiAssignField *MultiAssignField
+	ReturnField      *ReturnField
+	IfField          *IfField
+	SwitchField      *SwitchField
+	ForField         *ForField
+	ForRangeField    *ForRangeField
+	UnaryField       *UnaryField
+	BinaryField      *BinaryField
+	FieldField       *FieldField
+	IndexField       *IndexField
+	SliceField       *SliceField
+	CallField        *CallField
+	ListField        *ListField
+	DictField        *DictField
+	FuncLitField     *FuncLitField
+	ConvertField     *ConvertField
+}
+
+func NewNode(kind Kind, pos *tokenize.Position, types []*parse.DataType) *Node {
+	return &Node{
+		Kind:  kind,
+		Pos:   pos,
+		Types: types,
+	}
+}
+
+// Type 1つの値を持つ式の型
+func (n *Node) Type() *parse.DataType {
+	if len(n.Types) != 1 {
+		return parse.RuntimeUnknown
+	}
+	return n.Types[0]
+}
+
+func (n *Node) String() string {
+	s := n.Kind.String()
+	if n.Symbol != nil {
+		s += " " + n.Symbol.Name
+	}
+	if len(n.Types) != 0 {
+		s += fmt.Sprintf(" %v", n.Types)
+	}
+	return s
+}
+
+type BlockField struct {
+	Statements []*Node
+}
+
+type DeclField struct {
+	// Value 初期値, nilならばゼロ値
+	Value *Node
+}
+
+// AssignField Assign, Updateの代入先と値
+// 代入先はVar, Field, Indexのいずれか
+type AssignField struct {
+	Op     Op
+	Target *Node
+	Value  *Node
+}
+
+type MultiAssignField struct {
+	// Targets 代入先の変数, `_`はnil
+	Targets []*Symbol
+	// Declare `:=`で新たに宣言される変数か
+	Declare []bool
+	Value   *Node
+}
+
+type ReturnField struct {
+	Values []*Node
+}
+
+type IfField struct {
+	Cond *Node
+	Then *Node
+	// Else elseのブロックか、続くif, なければnil
+	Else *Node
+}
+
+type SwitchField struct {
+	// Tag なければ各caseの値は条件式
+	Tag   *Node
+	Cases []*CaseField
+}
+
+type CaseField struct {
+	Values    []*Node
+	Body      *Node
+	IsDefault bool
+}
+
+type ForField struct {
+	Init *Node
+	Cond *Node
+	Loop *Node
+	Body *Node
+}
+
+// ForRangeField 対象と現在の位置は隠れた変数に保持する
+type ForRangeField struct {
+	Target *Node
+	// Key, Value 省略されたか`_`ならばnil
+	Key   *Symbol
+	Value *Symbol
+	// Iterated 対象を保持する変数
+	Iterated *Symbol
+	// Keys マップのループの開始時点のキー
+	Keys *Symbol
+	// Position 現在の位置
+	Position *Symbol
+	Body     *Node
+}
+
+// UnaryField Not, ExprStmtの値
+type UnaryField struct {
+	Value *Node
+}
+
+type BinaryField struct {
+	Op  Op
+	Lhs *Node
+	Rhs *Node
+}
+
+type FieldField struct {
+	Target *Node
+	// Index 宣言された順のフィールドの位置
+	Index int
+}
+
+type IndexField struct {
+	Target *Node
+	Index  *Node
+	// Container 対象の型
+	Container *parse.DataType
+	// CommaOk マップの要素を`v, ok`の形で受け取るか
+	CommaOk bool
+}
+
+// IsMap マップの要素か
+func (f *IndexField) IsMap() bool {
+	return f.Container.Type == parse.Map
+}
+
+type SliceField struct {
+	Target *Node
+	// Low, High 省略された場合nil
+	Low  *Node
+	High *Node
+}
+
+type CallField struct {
+	// Callee CallValueの関数の値, CallMethodのインターフェースの値
+	Callee *Node
+	// Name Builtinの組み込み関数名, CallMethodのメソッド名
+	Name string
+	Args []*Node
+	// FuncType 呼び出す関数の型, Builtinではnil
+	FuncType *parse.DataType
+}
+
+// ListField StructLit, ListLitの値
+// StructLitは宣言された順のフィールドの値で、省略されたフィールドはnil
+type ListField struct {
+	Values []*Node
+}
+
+type DictField struct {
+	Keys   []*Node
+	Values []*Node
+}
+
+type FuncLitField struct {
+	Func *Function
+	// Captures 外側の関数でキャプチャされた変数, Func.Capturesと同じ順
+	Captures []*Symbol
+}
+
+type ConvertField struct {
+	Value *Node
+	From  *parse.DataType
+	To    *parse.DataType
+	// Methods インターフェースのメソッドの順の、値の型のメソッド
+	// インターフェース同士の変換ではnil
+	Methods []*Symbol
+}
@@ -0,0 +1,46 @@
+package ir
+
+import "github.com/arrietty-lang/arrtty/preprocess/parse"
+
+// Program 意味解析を終えたプログラム
+// 全ての式は型を持ち、全ての識別子はSymbolに解決されている
+type Program struct {
+	// Globals グローバル変数の宣言(Decl), 宣言の順にmainの始めに初期化する
+	Globals []*Node
+	// Functions 関数とメソッド, 無名関数はそれを含む関数の後に並ぶ
+	Functions []*Function
+}
+
+type Function struct {
+	Symbol *Symbol
+	Params []*Symbol
+	// Captures 無名関数が外側の関数から受け取る変数(受け取る順)
+	Captures []*Symbol
+	Returns  []*parse.DataType
+	// Frame 引数と変数のために確保する領域の数
+	Frame int
+	Body  []*Node
+}
+
+// IsMain プログラムの入口か
+func (f *Function) IsMain() bool {
+	return f.Symbol.Name == "main" && f.Symbol.Package == ""
+}
+
+// Externals 他のパッケージの関数、グローバル変数を参照しているノード
+func (p *Program) Externals() []*Node {
+	var nodes []*Node
+	visit := func(n *Node) bool {
+		if n.Symbol != nil && n.Symbol.External {
+			nodes = append(nodes, n)
+		}
+		return true
+	}
+	for _, g := range p.Globals {
+		Inspect(g, visit)
+	}
+	for _, f := range p.Functions {
+		InspectFunction(f, visit)
+	}
+	return nodes
+}
@@ -0,0 +1,55 @@
+package ir
+
+import (
+	"github.com/arrietty-lang/arrtty/preprocess/parse"
+	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
+)
+
+type SymbolKind int
+
+const (
+	_ SymbolKind = iota
+	// Local 関数内で宣言された変数
+	Local
+	// Param 関数の引数, 呼び出し時に変数の領域に複写する
+	Param
+	// Global グローバル変数, 名前のラベルに格納する
+	Global
+	// Func 関数, 名前のラベルから始まる
+	Func
+)
+
+// Symbol 識別子が指す変数や関数
+// 同じ宣言を指す識別子は同じSymbolを共有する
+type Symbol struct {
+	Kind SymbolKind
+	Name string
+	// Package Global, Funcが属するパッケージ, mainのパッケージは""
+	// 定義側はリンク時に設定される
+	Package string
+	// External 他のパッケージのGlobal, Funcへの参照, リンクによって定義側のSymbolに置き換えられる
+	External bool
+	// Pos 宣言された位置
+	Pos *tokenize.Position
+	// Type 変数の型, 関数では引数と戻り値を持つ関数の型
+	Type *parse.DataType
+	// Slot Local, Paramの領域のBPからの距離
+	// 同時に使われることのない兄弟のスコープの変数は同じ領域を使い回す
+	Slot int
+	// Captured 無名関数にキャプチャされ、ヒープに置かれる変数
+	// 変数の領域にはヒープに置かれた値へのポインタが格納されている
+	Captured bool
+}
+
+// IsVariable 領域を持つ変数か
+func (s *Symbol) IsVariable() bool {
+	return s.Kind == Local || s.Kind == Param
+}
+
+// Label Global, Funcのラベル, 他のパッケージのものは`パッケージ.名前`
+func (s *Symbol) Label() string {
+	if s.Package == "" {
+		return s.Name
+	}
+	return s.Package + "." + s.Name
+}
@@ -0,0 +1,70 @@
+package ir
+
+// Inspect nodeから深さ優先で辿り、各ノードでfを呼び出す
+// fがfalseを返した場合はそのノードの子を辿らない
+// 無名関数の本文はProgram.Functionsの一つとして辿るので、FuncLitの子には含めない
+func Inspect(node *Node, f func(*Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+	for _, child := range node.children() {
+		Inspect(child, f)
+	}
+}
+
+// InspectFunction 関数の本文の全ての文を辿る
+func InspectFunction(fn *Function, f func(*Node) bool) {
+	for _, n := range fn.Body {
+		Inspect(n, f)
+	}
+}
+
+// children 子のノード, 省略されたものはnil
+func (n *Node) children() []*Node {
+	var nodes []*Node
+	switch {
+	case n.BlockField != nil:
+		nodes = append(nodes, n.BlockField.Statements...)
+	case n.DeclField != nil:
+		nodes = append(nodes, n.DeclField.Value)
+	case n.AssignField != nil:
+		nodes = append(nodes, n.AssignField.Target, n.AssignField.Value)
+	case n.MultiAssignField != nil:
+		nodes = append(nodes, n.MultiAssignField.Value)
+	case n.ReturnField != nil:
+		nodes = append(nodes, n.ReturnField.Values...)
+	case n.IfField != nil:
+		nodes = append(nodes, n.IfField.Cond, n.IfField.Then, n.IfField.Else)
+	case n.SwitchField != nil:
+		nodes = append(nodes, n.SwitchField.Tag)
+		for _, c := range n.SwitchField.Cases {
+			nodes = append(nodes, c.Values...)
+			nodes = append(nodes, c.Body)
+		}
+	case n.ForField != nil:
+		nodes = append(nodes, n.ForField.Init, n.ForField.Cond, n.ForField.Loop, n.ForField.Body)
+	case n.ForRangeField != nil:
+		nodes = append(nodes, n.ForRangeField.Target, n.ForRangeField.Body)
+	case n.UnaryField != nil:
+		nodes = append(nodes, n.UnaryField.Value)
+	case n.BinaryField != nil:
+		nodes = append(nodes, n.BinaryField.Lhs, n.BinaryField.Rhs)
+	case n.FieldField != nil:
+		nodes = append(nodes, n.FieldField.Target)
+	case n.IndexField != nil:
+		nodes = append(nodes, n.IndexField.Target, n.IndexField.Index)
+	case n.SliceField != nil:
+		nodes = append(nodes, n.SliceField.Target, n.SliceField.Low, n.SliceField.High)
+	case n.CallField != nil:
+		nodes = append(nodes, n.CallField.Callee)
+		nodes = append(nodes, n.CallField.Args...)
+	case n.ListField != nil:
+		nodes = append(nodes, n.ListField.Values...)
+	case n.DictField != nil:
+		nodes = append(nodes, n.DictField.Keys...)
+		nodes = append(nodes, n.DictField.Values...)
+	case n.ConvertField != nil:
+		nodes = append(nodes, n.ConvertField.Value)
+	}
+	return nodes
+}
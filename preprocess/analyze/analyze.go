@@ -2,20 +2,100 @@ package analyze
 
 import (
 	"fmt"
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/ir"
 	"github.com/arrietty-lang/arrtty/preprocess/parse"
 	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
 	"strings"
+	"unicode"
 )
 
-var nest int
-var knownFunction map[string]*FnDataType
-var outsideFunction []*parse.Node
+// rangeで使用する隠れた変数, 通常の識別子とは衝突しない名前にする
+const (
+	RangeTarget = "-range-target-"
+	RangeKeys   = "-range-keys-"
+	RangeIndex  = "-range-index-"
+)
 
-// var knownValues map[string]map[string][]*parse.DataType
+// enclosingScope 無名関数を解析している間の、外側の関数とその時点のスコープ
+type enclosingScope struct {
+	functionName string
+	scope        *Scope
+}
 
-var knownValues map[string]map[int]map[string][]*parse.DataType
+// Analyzer 1つのパッケージを解析している間の状態を持つ
+// それぞれのAnalyzerは独立しているので、別々のゴルーチンで同時に使える
+type Analyzer struct {
+	knownFunction map[string]*FnDataType
+	// globalValues グローバル変数
+	globalValues map[string][]*parse.DataType
+	// globalSymbols グローバル変数を参照する識別子が解決される先
+	globalSymbols map[string]*ir.Symbol
+	// packages 参照できる他のパッケージ, インポートパスで引く
+	packages map[string]*Package
+	// importedTypes 他のパッケージの構造体、インターフェースを置き換えた型, `インポートパス.型名`で引く
+	importedTypes map[string]*parse.DataType
+	knownTypes    map[string]*parse.DataType
+	enclosing     []enclosingScope
+	// lambdaCount 関数ごとの無名関数の数
+	lambdaCount map[string]int
+	// failedDeclarations 宣言の時点でエラーのあったノード, 以降の解析は行わない
+	failedDeclarations map[*parse.Node]bool
+	// failedNames 宣言にエラーのあったトップレベルの名前, 参照した側では改めて報告しない
+	failedNames    map[string]bool
+	knownConstants map[string]*Constant
+	// declaredConstants 宣言された定数, 値は参照された時点か、全ての宣言を集めた後に評価する
+	declaredConstants map[string]*parse.Node
+	// constStates 定数の宣言ごとの評価の状況
+	constStates map[*parse.Node]constState
+	// diagnostics 解析中に見つかった全てのエラー
+	diagnostics  diagnostic.List
+	currentScope *Scope
+	// fileImports ファイルごとの、名前からインポートしたパッケージへの表
+	// importはそれを書いたファイルの中でのみ有効
+	fileImports map[string]map[string]*importedPackage
+	// types 解析した式の型
+	types map[*parse.Node][]*parse.DataType
+	// symbols 識別子が解決された変数や関数
+	symbols map[*parse.Node]*ir.Symbol
+	ranges  map[*parse.Node]*rangeValues
+	// lambdaCaptures 無名関数がキャプチャする、外側の関数の変数
+	lambdaCaptures map[*parse.Node][]*ir.Symbol
+	// program 作成中のIR
+	program *ir.Program
+}
 
-var outsideValues []*parse.Node
+// NewAnalyzer 新しいAnalyzer
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{}
+}
+
+// builtins 組み込み関数
+var builtins = map[string]bool{
+	"len":    true,
+	"append": true,
+	"delete": true,
+	// 型変換
+	"int":    true,
+	"float":  true,
+	"string": true,
+	// 文字列から数値を読む, 読めたかを2つ目の値で返す
+	"atoi": true,
+	"atof": true,
+}
+
+// IsBuiltin 組み込み関数の名前か
+func IsBuiltin(name string) bool {
+	return builtins[name]
+}
+
+// IsExported 他のパッケージから参照できる名前か, 大文字で始まる名前を公開する
+func IsExported(name string) bool {
+	for _, r := range name {
+		return unicode.IsUpper(r)
+	}
+	return false
+}
 
 func dataTypes(d *parse.DataType) []*parse.DataType {
 	return []*parse.DataType{d}
@@ -33,6 +113,18 @@ func isSameType(x1 []*parse.DataType, x2 []*parse.DataType) bool {
 	return true
 }
 
+// promote 数値の二項演算の結果の型
+// IntとFloatの組み合わせではIntをFloatに昇格する, それ以外は同じ型である必要がある
+func promote(lhs []*parse.DataType, rhs []*parse.DataType) ([]*parse.DataType, bool) {
+	if isSameType(lhs, rhs) {
+		return lhs, true
+	}
+	if isCalculable(lhs) && isCalculable(rhs) {
+		return dataTypes(parse.RuntimeFloat), true
+	}
+	return nil, false
+}
+
 func isCalculable(x []*parse.DataType) bool {
 	if len(x) != 1 {
 		return false
@@ -44,6 +136,389 @@ func isCalculable(x []*parse.DataType) bool {
 	return false
 }
 
+// declareValue 現在のスコープに識別子の変数を登録する
+func (a *Analyzer) declareValue(ident *parse.Node, typ []*parse.DataType) error {
+	v, err := a.currentScope.declare(ident.IdentField.Ident, typ, ident.Pos, false)
+	if err != nil {
+		return err
+	}
+	a.symbols[ident] = v.Symbol
+	return nil
+}
+
+// isAssignable 代入先として使用できるノードか
+// 解析後のノードに対して使用する
+func isAssignable(node *parse.Node) bool {
+	switch node.Kind {
+	case parse.NdIdent, parse.NdVarDecl:
+		return true
+	case parse.NdAccess:
+		return isAddressable(node.AccessField.Target)
+	case parse.NdIndex:
+		// スライス、マップの要素は常に参照先を書き換えられる
+		switch node.IndexField.Container.Type {
+		case parse.Slice, parse.Map:
+			return true
+		}
+		return isAddressable(node.IndexField.Target)
+	}
+	return false
+}
+
+// isAddressable フィールドや要素を書き換えられる値か
+// マップの要素は代入はできるが、その中身を書き換えることはできない
+func isAddressable(node *parse.Node) bool {
+	if node.Kind == parse.NdIndex && node.IndexField.Container.Type == parse.Map {
+		return false
+	}
+	return isAssignable(node)
+}
+
+// lookupValue 現在のスコープから外側に遡り、外側の関数、最後にグローバル変数を調べる
+// 見つかった変数は使用されたものとする
+func (a *Analyzer) lookupValue(functionName string, name string) ([]*parse.DataType, bool) {
+	typ, _, ok := a.lookupSymbol(functionName, name)
+	return typ, ok
+}
+
+// lookupSymbol lookupValueと同じ順に調べ、変数の型と識別子が解決される先を返す
+func (a *Analyzer) lookupSymbol(functionName string, name string) ([]*parse.DataType, *ir.Symbol, bool) {
+	if a.currentScope != nil {
+		if v := a.currentScope.Lookup(name, nil); v != nil {
+			v.used = true
+			return v.DataType, v.Symbol, true
+		}
+	}
+	if v, ok := a.capture(functionName, name); ok {
+		return v.DataType, v.Symbol, true
+	}
+	typ, ok := a.globalValues[name]
+	return typ, a.globalSymbols[name], ok
+}
+
+// lookupTarget 代入先の変数を調べる, 代入しただけでは使用したことにはならない
+func (a *Analyzer) lookupTarget(functionName string, name string) ([]*parse.DataType, *ir.Symbol, bool) {
+	if a.currentScope != nil {
+		if v := a.currentScope.Lookup(name, nil); v != nil {
+			return v.DataType, v.Symbol, true
+		}
+	}
+	return a.lookupSymbol(functionName, name)
+}
+
+// assignTarget 代入先が変数であればその型を調べる
+func (a *Analyzer) assignTarget(node *parse.Node, functionName string) ([]*parse.DataType, bool) {
+	if node.Kind != parse.NdIdent {
+		return nil, false
+	}
+	typ, sym, ok := a.lookupTarget(functionName, node.IdentField.Ident)
+	if ok {
+		a.symbols[node] = sym
+	}
+	return typ, ok
+}
+
+// capture 無名関数の外側の関数の変数を内側から順に探し、見つかればキャプチャする
+// 変数を持つ関数より内側の関数は全て、その変数を外側から受け取る変数として扱う
+// 現在の関数が外側から受け取る変数を返す
+func (a *Analyzer) capture(functionName string, name string) (*Value, bool) {
+	for i := len(a.enclosing) - 1; 0 <= i; i-- {
+		v := a.enclosing[i].scope.Lookup(name, nil)
+		if v == nil {
+			continue
+		}
+		v.used = true
+		v.Symbol.Captured = true
+		var inner []string
+		for _, e := range a.enclosing[i+1:] {
+			inner = append(inner, e.functionName)
+		}
+		inner = append(inner, functionName)
+		for _, fn := range inner {
+			captures := a.knownFunction[fn].Scope.Parent
+			if captures.Local(name, nil) != nil {
+				continue
+			}
+			c, err := captures.declare(name, v.DataType, nil, true)
+			if err != nil {
+				continue
+			}
+			c.used = true
+			c.Symbol.Captured = true
+			a.knownFunction[fn].Captures = append(a.knownFunction[fn].Captures, name)
+		}
+		return a.knownFunction[functionName].Scope.Parent.Local(name, nil), true
+	}
+	return nil, false
+}
+
+// resolveType 型の名前から定義された型を探す
+// `パッケージ名.型名`はfileでインポートしたパッケージから探す
+func (a *Analyzer) resolveType(typ *parse.DataType, file string) (*parse.DataType, error) {
+	switch typ.Type {
+	case parse.Array, parse.Slice:
+		base, err := a.resolveType(typ.Base, file)
+		if err != nil {
+			return nil, err
+		}
+		n := typ.Len
+		if typ.LenExpr != nil {
+			length := parse.ExprString(typ.LenExpr)
+			v, t, err := a.evalConst(typ.LenExpr, 0)
+			if notConstant(err) {
+				return nil, diagnostic.Errorf("A0001", length)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if t != parse.RuntimeInt {
+				return nil, diagnostic.Errorf("A0001", length)
+			}
+			if v.I < 0 {
+				return nil, diagnostic.Errorf("A0002", length, v.I)
+			}
+			n = v.I
+		}
+		return a.compositeType(typ.Type, base, n), nil
+	case parse.Func:
+		var params, returns []*parse.DataType
+		for _, p := range typ.Params {
+			t, err := a.resolveType(p, file)
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, t)
+		}
+		for _, r := range typ.Returns {
+			t, err := a.resolveType(r, file)
+			if err != nil {
+				return nil, err
+			}
+			returns = append(returns, t)
+		}
+		return a.funcType(params, returns), nil
+	case parse.Map:
+		key, err := a.resolveType(typ.Key, file)
+		if err != nil {
+			return nil, err
+		}
+		if !isMapKey(key) {
+			return nil, diagnostic.Errorf("A0003", key.Ident)
+		}
+		value, err := a.resolveType(typ.Base, file)
+		if err != nil {
+			return nil, err
+		}
+		return a.mapType(key, value), nil
+	}
+	if typ.Type != parse.Unknown {
+		return typ, nil
+	}
+	if prefix, name, ok := strings.Cut(typ.Ident, "."); ok {
+		return a.qualifiedType(file, prefix, name)
+	}
+	t, ok := a.knownTypes[typ.Ident]
+	if !ok {
+		if a.failedNames[typ.Ident] {
+			return nil, errReported
+		}
+		return nil, diagnostic.Errorf("A0004", typ.Ident)
+	}
+	return t, nil
+}
+
+// compositeType 同じ要素型の配列、スライスは同じ型として扱うために一つにまとめる
+func (a *Analyzer) compositeType(kind parse.RuntimeDataType, base *parse.DataType, n int) *parse.DataType {
+	var typ *parse.DataType
+	if kind == parse.Array {
+		typ = parse.NewArrayType(base, n)
+	} else {
+		typ = parse.NewSliceType(base)
+	}
+	if t, ok := a.knownTypes[typ.Ident]; ok {
+		return t
+	}
+	a.knownTypes[typ.Ident] = typ
+	return typ
+}
+
+// mapType 同じキーと値のマップは同じ型として扱うために一つにまとめる
+func (a *Analyzer) mapType(key *parse.DataType, value *parse.DataType) *parse.DataType {
+	typ := parse.NewMapType(key, value)
+	if t, ok := a.knownTypes[typ.Ident]; ok {
+		return t
+	}
+	a.knownTypes[typ.Ident] = typ
+	return typ
+}
+
+// funcType 引数と戻り値が同じ関数は同じ型として扱うために一つにまとめる
+func (a *Analyzer) funcType(params []*parse.DataType, returns []*parse.DataType) *parse.DataType {
+	typ := parse.NewFuncType(params, returns)
+	if t, ok := a.knownTypes[typ.Ident]; ok {
+		return t
+	}
+	a.knownTypes[typ.Ident] = typ
+	return typ
+}
+
+// interfaceDef メソッドの型を解決する
+func (a *Analyzer) interfaceDef(name string, typ *parse.DataType, file string) error {
+	seen := map[string]bool{}
+	for _, m := range typ.Methods {
+		if seen[m.Name] {
+			return diagnostic.Errorf("A0005", m.Name, name)
+		}
+		seen[m.Name] = true
+		mt, err := a.resolveType(m.DataType, file)
+		if err != nil {
+			return err
+		}
+		m.DataType = mt
+	}
+	return nil
+}
+
+// MethodName メソッドは`型名.メソッド名`の関数として扱う
+// 他のパッケージの型は`パッケージ名.型名.メソッド名`になり、このパッケージのメソッドと区別される
+func MethodName(typ *parse.DataType, name string) string {
+	return typ.Ident + "." + name
+}
+
+// method 具体的な型に定義されたメソッド, 他のパッケージの型はそのパッケージの宣言から探す
+func (a *Analyzer) method(typ *parse.DataType, name string) (*FnDataType, bool) {
+	if typ.Package != "" {
+		return a.importedMethod(typ, name)
+	}
+	fn, ok := a.knownFunction[MethodName(typ, name)]
+	return fn, ok
+}
+
+// lookupMethod 型に定義されたメソッドの、レシーバを除いた関数の型を探す
+func (a *Analyzer) lookupMethod(typ *parse.DataType, name string) (*parse.DataType, bool) {
+	if typ.Type == parse.Interface {
+		i := typ.MethodIndex(name)
+		if i == -1 {
+			return nil, false
+		}
+		return typ.Methods[i].DataType, true
+	}
+	fn, ok := a.method(typ, name)
+	if !ok {
+		return nil, false
+	}
+	return a.funcType(fn.Params[1:], fn.Returns), true
+}
+
+// implements 型がインターフェースの全てのメソッドを持っているか
+func (a *Analyzer) implements(typ *parse.DataType, iface *parse.DataType) error {
+	for _, m := range iface.Methods {
+		mt, ok := a.lookupMethod(typ, m.Name)
+		if !ok {
+			return diagnostic.Errorf("A0006", typ.Ident, m.Name, iface.Ident)
+		}
+		if mt != m.DataType {
+			return diagnostic.Errorf("A0007", typ.Ident, m.Name, iface.Ident, mt.Ident)
+		}
+	}
+	return nil
+}
+
+// assignableTo 値をdstの型の変数などに格納できるか
+// インターフェースに具体的な型の値を格納する場合は、値のノードを変換のノードで置き換える
+func (a *Analyzer) assignableTo(dst *parse.DataType, src []*parse.DataType, value *parse.Node) error {
+	if isSameType(dataTypes(dst), src) {
+		return nil
+	}
+	if dst.Type != parse.Interface || len(src) != 1 {
+		return diagnostic.Errorf("A0008", dst.Ident)
+	}
+	if err := a.implements(src[0], dst); err != nil {
+		return err
+	}
+	inner := *value
+	*value = *parse.NewConvertNode(value.Pos, &inner, src[0], dst)
+	// 解析済みの値はそのまま変換の中身になる
+	a.types[&inner], a.symbols[&inner] = a.types[value], a.symbols[value]
+	delete(a.symbols, value)
+	a.typed(value, dataTypes(dst))
+	return nil
+}
+
+// argumentsTo 引数や戻り値をまとめて確認する
+// 複数の値を返す関数呼び出しをそのまま渡す場合は、変換せず型の一致のみを確認する
+func (a *Analyzer) argumentsTo(dst []*parse.DataType, src []*parse.DataType, values []*parse.Node) error {
+	if len(values) != len(src) {
+		if !isSameType(dst, src) {
+			return diagnostic.Errorf("A0009")
+		}
+		return nil
+	}
+	if len(dst) != len(src) {
+		return diagnostic.Errorf("A0010", len(dst), len(src))
+	}
+	for i, v := range values {
+		if err := a.assignableTo(dst[i], dataTypes(src[i]), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isMapKey マップのキーとして使用できる型か
+func isMapKey(typ *parse.DataType) bool {
+	switch typ {
+	case parse.RuntimeInt, parse.RuntimeString, parse.RuntimeBool:
+		return true
+	}
+	return false
+}
+
+// resolveTypeNode 型ノードが指す型を定義された型に置き換える
+func (a *Analyzer) resolveTypeNode(node *parse.Node) (*parse.DataType, error) {
+	typ, err := a.resolveType(node.DataTypeField.DataType, fileOf(node))
+	if err != nil {
+		return nil, at(node, err)
+	}
+	node.DataTypeField.DataType = typ
+	return typ, nil
+}
+
+// declareType 型の名前を登録する, 中身は全ての型を登録した後に解決する
+func (a *Analyzer) declareType(node *parse.Node) error {
+	name := node.TypeDefField.Identifier.IdentField.Ident
+	if _, ok := a.knownTypes[name]; ok {
+		return diagnostic.Errorf("A0011", name)
+	}
+	if parse.GetDataTypeByIdent(name).Type != parse.Unknown {
+		return diagnostic.Errorf("A0012", name)
+	}
+	a.knownTypes[name] = node.TypeDefField.Type.DataTypeField.DataType
+	return nil
+}
+
+func (a *Analyzer) typeDef(node *parse.Node) error {
+	name := node.TypeDefField.Identifier.IdentField.Ident
+	typ := node.TypeDefField.Type.DataTypeField.DataType
+	file := fileOf(node)
+	if typ.Type == parse.Interface {
+		return a.interfaceDef(name, typ, file)
+	}
+	seen := map[string]bool{}
+	for _, f := range typ.Fields {
+		if seen[f.Name] {
+			return diagnostic.Errorf("A0013", f.Name, name)
+		}
+		seen[f.Name] = true
+		ft, err := a.resolveType(f.DataType, file)
+		if err != nil {
+			return withSpan(spanAt(f.TypePos, f.DataType.Ident), err)
+		}
+		f.DataType = ft
+	}
+	return nil
+}
+
 func isComparable(x []*parse.DataType) bool {
 	if len(x) != 1 {
 		return false
@@ -55,20 +530,27 @@ func isComparable(x []*parse.DataType) bool {
 	return false
 }
 
-func function(node *parse.Node) error {
+// declareFunction 引数と戻り値の型を登録する
+// 本文は全ての関数を登録した後に解析するので、後に定義された関数も呼び出せる
+func (a *Analyzer) declareFunction(node *parse.Node) error {
 	field := node.FuncDefField
 	name := field.Identifier.IdentField.Ident
-	//knownValues[name] = map[string][]*parse.DataType{}
-	knownValues[name] = map[int]map[string][]*parse.DataType{}
-	knownValues[name][nest] = map[string][]*parse.DataType{}
+	if builtins[name] {
+		return diagnostic.Errorf("A0014", name)
+	}
+	if _, ok := a.knownFunction[name]; ok {
+		return diagnostic.Errorf("A0094", name)
+	}
 
 	var params []*parse.DataType
 	// パラメータの型情報を取り出す
 	if field.Parameters != nil {
 		for _, paramNode := range field.Parameters.PolynomialField.Values {
-			param := paramNode.FuncParam
-			knownValues[name][nest][param.Identifier.IdentField.Ident] = dataTypes(param.DataType.DataTypeField.DataType)
-			params = append(params, param.DataType.DataTypeField.DataType)
+			typ, err := a.resolveTypeNode(paramNode.FuncParam.DataType)
+			if err != nil {
+				return err
+			}
+			params = append(params, typ)
 		}
 	}
 
@@ -76,414 +558,1153 @@ func function(node *parse.Node) error {
 	var definedReturnTypes []*parse.DataType
 	if field.Returns != nil {
 		for _, returnTypeNode := range field.Returns.PolynomialField.Values {
-			definedReturnTypes = append(definedReturnTypes, returnTypeNode.DataTypeField.DataType)
+			typ, err := a.resolveTypeNode(returnTypeNode)
+			if err != nil {
+				return err
+			}
+			definedReturnTypes = append(definedReturnTypes, typ)
 		}
 	}
-	knownFunction[name] = &FnDataType{
+
+	if name == "main" && (!isSameType(definedReturnTypes, nil) && !isSameType(definedReturnTypes, dataTypes(parse.RuntimeInt))) {
+		return diagnostic.Errorf("A0016")
+	}
+	// init関数はプログラムの開始時にリンカが呼び出す
+	if name == "init" && (len(params) != 0 || len(definedReturnTypes) != 0) {
+		return diagnostic.Errorf("A0104")
+	}
+	a.knownFunction[name] = &FnDataType{
 		Params:  params,
 		Returns: definedReturnTypes,
+		Symbol:  &ir.Symbol{Kind: ir.Func, Name: name, Type: a.funcType(params, definedReturnTypes), Pos: field.Identifier.Pos},
 	}
-	// definedReturnTypesNode := NewFunctionNode(definedReturnTypes)
-	// ブロックを解析して得られた実際の戻り値の型
-	analyzedReturnTypes, err := stmt(field.Body, name)
+	return nil
+}
+
+// function 登録済みの関数の本文を解析する
+func (a *Analyzer) function(node *parse.Node) error {
+	field := node.FuncDefField
+	name := field.Identifier.IdentField.Ident
+	fn := a.knownFunction[name]
+	// 引数と本文のスコープの外側に、キャプチャした変数のスコープを置く
+	outer := a.currentScope
+	defer func() {
+		a.currentScope = outer
+	}()
+	a.currentScope = newScope(nil)
+	a.openScope()
+	fn.Scope = a.currentScope
+
+	if field.Parameters != nil {
+		for i, paramNode := range field.Parameters.PolynomialField.Values {
+			param := paramNode.FuncParam
+			v, err := a.currentScope.declare(param.Identifier.IdentField.Ident, dataTypes(fn.Params[i]), param.Identifier.Pos, true)
+			if err != nil {
+				return err
+			}
+			v.Symbol.Kind = ir.Param
+		}
+	}
+
+	// 本文は引数と同じスコープで解析する
+	// それぞれのreturnの型はreturnを解析する時点で調べる
+	a.statements(field.Body.BlockField.Statements, name)
+	a.closeScope()
+	// 戻り値のある関数は、末尾に到達する前に必ずreturnする必要がある
+	if len(fn.Returns) != 0 && !terminatesAll(field.Body.BlockField.Statements) {
+		a.diagnostics = append(a.diagnostics, diagnostic.New(spanOf(field.Identifier), "A0015", name).
+			WithNote("N0001"))
+	}
+	return nil
+}
+
+// declareMethod レシーバを最初の引数とする`型名.メソッド名`の関数として登録する
+func (a *Analyzer) declareMethod(node *parse.Node) error {
+	field := node.FuncDefField
+	typ, err := a.resolveTypeNode(field.Receiver.FuncParam.DataType)
 	if err != nil {
 		return err
 	}
-	if !isSameType(definedReturnTypes, analyzedReturnTypes) {
-		return fmt.Errorf("戻り値の型が定義と一致しません")
+	// 他のパッケージの型にはメソッドを追加できない
+	if typ.Type != parse.Struct || typ.Package != "" {
+		return diagnostic.Errorf("A0017", typ.Ident)
+	}
+	name := field.Identifier.IdentField.Ident
+	if typ.FieldIndex(name) != -1 {
+		return diagnostic.Errorf("A0018", typ.Ident, name)
 	}
+	fullName := MethodName(typ, name)
+	if _, ok := a.knownFunction[fullName]; ok {
+		return diagnostic.Errorf("A0019", fullName)
+	}
+	field.Identifier = parse.NewIdentNode(field.Identifier.Pos, fullName)
+	params := []*parse.Node{field.Receiver}
+	if field.Parameters != nil {
+		params = append(params, field.Parameters.PolynomialField.Values...)
+	}
+	field.Parameters = parse.NewPolynomialNode(parse.NdParams, field.Receiver.Pos, params)
+	return a.declareFunction(node)
+}
+
+// block ブロックを新しいスコープで解析する
+func (a *Analyzer) block(node *parse.Node, functionName string) {
+	a.openScope()
+	a.statements(node.BlockField.Statements, functionName)
+	a.closeScope()
+}
 
-	if name == "main" && (!isSameType(analyzedReturnTypes, nil) && !isSameType(analyzedReturnTypes, dataTypes(parse.RuntimeInt))) {
-		return fmt.Errorf("mainはInt, なし, 以外の戻り値の型をサポートしていません")
+// statements 文を順に解析する
+// エラーのあった文は報告して、スコープを文の前に戻してから次の文に進む
+func (a *Analyzer) statements(nodes []*parse.Node, functionName string) {
+	if err := checkReachable(nodes); err != nil {
+		a.report(nil, err)
+	}
+	for _, s := range nodes {
+		scope := a.currentScope
+		if _, err := a.stmt(s, functionName); err != nil {
+			a.report(s, err)
+			a.currentScope = scope
+			a.poison(s, functionName)
+		}
 	}
-	return nil
 }
 
-//
-//func if_(node *parse.Node, functionName string) ([]*parse.DataType, error) {
-//	var returnTypes []*parse.DataType
-//	nest++
-//	// if
-//	knownValues[functionName][nest] = map[string][]*parse.DataType{}
-//	for _, s := range node.IfElseField.IfBlock.BlockField.Statements {
-//		rt, err := stmt(s, functionName)
-//		if err != nil {
-//			return nil, err
-//		}
-//		if s.Kind == parse.NdReturn {
-//			if returnTypes == nil {
-//				returnTypes = rt
-//			} else {
-//				if !isSameType(returnTypes, rt) {
-//					return nil, fmt.Errorf("ブロック内で返却される戻り値が変化しています")
-//				}
-//			}
-//		}
-//	}
-//	if !node.IfElseField.UseElse {
-//		nest--
-//		return returnTypes, nil
-//	}
-//
-//	// else
-//	if node.IfElseField.ElseBlock.Kind != parse.NdBlock {
-//		rt, err := stmt(node.IfElseField.ElseBlock, functionName)
-//		if err != nil {
-//			return nil, err
-//		}
-//		if node.IfElseField.ElseBlock.Kind == parse.NdReturn {
-//			if returnTypes == nil {
-//				returnTypes = rt
-//			} else {
-//				if !isSameType(returnTypes, rt) {
-//					return nil, fmt.Errorf("ブロック内で返却される戻り値が変化しています")
-//				}
-//			}
-//		}
-//	} else {
-//		knownValues[functionName][nest] = map[string][]*parse.DataType{}
-//		for _, s := range node.IfElseField.ElseBlock.BlockField.Statements {
-//			rt, err := stmt(s, functionName)
-//			if err != nil {
-//				return nil, err
-//			}
-//			if s.Kind == parse.NdReturn {
-//				if returnTypes == nil {
-//					returnTypes = rt
-//				} else {
-//					if !isSameType(returnTypes, rt) {
-//						return nil, fmt.Errorf("ブロック内で返却される戻り値が変化しています")
-//					}
-//				}
-//			}
-//		}
-//	}
-//	nest--
-//	return returnTypes, nil
-//}
-
-func if_(node *parse.Node, functionName string) ([]*parse.DataType, error) {
-	nest++
-	for _, s := range node.BlockField.Statements {
-		rt, err := stmt(s, functionName)
-		if err != nil {
-			return nil, err
-		}
-		if s.Kind == parse.NdReturn {
-			if !isSameType(knownFunction[functionName].Returns, rt) {
-				return nil, fmt.Errorf("期待される戻り値の型と一致しません")
-			}
-		}
-	}
-	nest--
-	return nil, nil
+// poison エラーのあった宣言の変数を、型の分からない変数として宣言する
+// その変数を使用する箇所では改めてエラーを報告しない
+// 文の中で参照していた変数やパッケージは、途中で解析をやめていても使用したことにする
+func (a *Analyzer) poison(node *parse.Node, functionName string) {
+	parse.Inspect(node, func(n *parse.Node) bool {
+		switch n.Kind {
+		case parse.NdIdent:
+			a.lookupSymbol(functionName, n.IdentField.Ident)
+		case parse.NdPrefix:
+			if _, ok := a.lookupValue(functionName, n.PrefixField.Prefix); !ok {
+				a.importedAs(n, n.PrefixField.Prefix)
+			}
+		}
+		return true
+	})
+	var idents []*parse.Node
+	switch node.Kind {
+	case parse.NdVarDecl:
+		idents = append(idents, node.VarDeclField.Identifier)
+	case parse.NdShortVarDecl:
+		idents = append(idents, node.ShortVarDeclField.Identifier)
+	case parse.NdMultiShortVarDecl:
+		idents = append(idents, node.MultiAssignField.Targets...)
+	}
+	for _, id := range idents {
+		if id.Kind != parse.NdIdent || a.currentScope.Local(id.IdentField.Ident, nil) != nil {
+			continue
+		}
+		v, _ := a.currentScope.declare(id.IdentField.Ident, nil, id.Pos, true)
+		v.used = true
+	}
 }
 
-func else_(node *parse.Node, functionName string) ([]*parse.DataType, error) {
-	nest++
-	if node.Kind != parse.NdBlock {
-		_, err := stmt(node, functionName)
+func (a *Analyzer) for_(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	a.openScope()
+	// 初期化で宣言された変数はループ内のスコープに属する
+	if node.ForField.Init != nil {
+		if _, err := a.stmt(node.ForField.Init, functionName); err != nil {
+			return nil, err
+		}
+	}
+	if node.ForField.Cond != nil {
+		cond, err := a.expr(node.ForField.Cond, functionName)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		for _, s := range node.BlockField.Statements {
-			rt, err := stmt(s, functionName)
-			if err != nil {
-				return nil, err
-			}
-			if s.Kind == parse.NdReturn {
-				if !isSameType(knownFunction[functionName].Returns, rt) {
-					return nil, fmt.Errorf("期待される戻り値の型と一致しません")
-				}
-			}
+		if !isSameType(cond, dataTypes(parse.RuntimeBool)) {
+			return nil, diagnostic.Errorf("A0020")
 		}
 	}
-	nest--
+	if node.ForField.Loop != nil {
+		if _, err := a.stmt(node.ForField.Loop, functionName); err != nil {
+			return nil, err
+		}
+	}
+	a.block(node.ForField.Body, functionName)
+	a.closeScope()
 	return nil, nil
 }
 
-func for_(node *parse.Node, functionName string) ([]*parse.DataType, error) {
-	nest++
-	for _, s := range node.ForField.Body.BlockField.Statements {
-		rt, err := stmt(s, functionName)
+// forRange 対象と現在の位置はループ内の隠れた変数として扱う
+func (a *Analyzer) forRange(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	field := node.ForRangeField
+	a.openScope()
+	targetType, err := a.expr(field.Target, functionName)
+	if err != nil {
+		return nil, err
+	}
+	if len(targetType) != 1 {
+		return nil, diagnostic.Errorf("A0021")
+	}
+	typ := targetType[0]
+	field.DataType = typ
+	var keyType *parse.DataType
+	hidden := &rangeValues{}
+	switch typ.Type {
+	case parse.Array, parse.Slice:
+		keyType = parse.RuntimeInt
+	case parse.Map:
+		keyType = typ.Key
+		hidden.keys, _ = a.currentScope.declare(RangeKeys, dataTypes(a.compositeType(parse.Slice, typ.Key, 0)), nil, true)
+	default:
+		return nil, diagnostic.Errorf("A0022", typ.Ident)
+	}
+	hidden.target, _ = a.currentScope.declare(RangeTarget, targetType, nil, true)
+	hidden.index, _ = a.currentScope.declare(RangeIndex, dataTypes(parse.RuntimeInt), nil, true)
+	a.ranges[node] = hidden
+	if field.Key != nil && field.Key.IdentField.Ident != "_" {
+		if err := a.declareValue(field.Key, dataTypes(keyType)); err != nil {
+			return nil, err
+		}
+	}
+	if field.Value != nil && field.Value.IdentField.Ident != "_" {
+		if err := a.declareValue(field.Value, dataTypes(typ.Base)); err != nil {
+			return nil, err
+		}
+	}
+	a.block(field.Body, functionName)
+	a.closeScope()
+	return nil, nil
+}
+
+// isEquatable ==で比較することのできる型か
+func isEquatable(x []*parse.DataType) bool {
+	if len(x) != 1 {
+		return false
+	}
+	switch x[0] {
+	case parse.RuntimeInt, parse.RuntimeFloat, parse.RuntimeString, parse.RuntimeBool:
+		return true
+	}
+	return false
+}
+
+func (a *Analyzer) switch_(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	field := node.SwitchField
+	// タグがなければ各caseの値は条件式として扱う
+	tagType := dataTypes(parse.RuntimeBool)
+	if field.Tag != nil {
+		t, err := a.expr(field.Tag, functionName)
 		if err != nil {
 			return nil, err
 		}
-		if s.Kind == parse.NdReturn {
-			if !isSameType(knownFunction[functionName].Returns, rt) {
-				return nil, fmt.Errorf("期待される戻り値の型と一致しません")
+		if !isEquatable(t) {
+			return nil, diagnostic.Errorf("A0023")
+		}
+		tagType = t
+	}
+	for _, c := range field.Cases {
+		for _, v := range c.CaseField.Values {
+			vt, err := a.expr(v, functionName)
+			if err != nil {
+				return nil, err
+			}
+			if !isSameType(tagType, vt) {
+				return nil, diagnostic.Errorf("A0024", tagType[0].Ident)
 			}
 		}
+		// caseの本文はそれぞれのスコープを持つ
+		a.block(c.CaseField.Body, functionName)
 	}
-	nest--
 	return nil, nil
 }
 
-func stmt(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+func (a *Analyzer) stmt(node *parse.Node, functionName string) ([]*parse.DataType, error) {
 	switch node.Kind {
 	case parse.NdReturn:
 		var returnTypes []*parse.DataType
 		for _, v := range node.PolynomialField.Values {
-			rt, err := expr(v, functionName)
+			rt, err := a.expr(v, functionName)
 			if err != nil {
 				return nil, err
 			}
 			returnTypes = append(returnTypes, rt...)
 		}
+		// インターフェースを返す関数では、返す値をインターフェースに変換する
+		fn := a.knownFunction[functionName]
+		if !isSameType(fn.Returns, returnTypes) {
+			if err := a.argumentsTo(fn.Returns, returnTypes, node.PolynomialField.Values); err != nil {
+				return nil, diagnostic.Errorf("A0025")
+			}
+			returnTypes = fn.Returns
+		}
 		//if node. != nil {
 		//	return expr(node.ReturnField.Values, functionName)
 		//}
 		return returnTypes, nil
 	case parse.NdIfElse:
-		// IF
-		_, err := if_(node.IfElseField.IfBlock, functionName)
+		cond, err := a.expr(node.IfElseField.Cond, functionName)
 		if err != nil {
 			return nil, err
 		}
+		if !isSameType(cond, dataTypes(parse.RuntimeBool)) {
+			return nil, diagnostic.Errorf("A0026")
+		}
+		// IF
+		a.block(node.IfElseField.IfBlock, functionName)
 		//if !isSameType(knownFunction[functionName], rt) {
 		//	return nil, fmt.Errorf("戻り値の型が一致しません")
 		//}
 		if !node.IfElseField.UseElse {
 			return nil, nil
 		}
-		// ELSE
-		_, err = else_(node.IfElseField.ElseBlock, functionName)
-		if err != nil {
-			return nil, err
+		// ELSE, else ifは続くifの各節がスコープを持つ
+		if node.IfElseField.ElseBlock.Kind != parse.NdBlock {
+			if _, err := a.stmt(node.IfElseField.ElseBlock, functionName); err != nil {
+				return nil, err
+			}
+			return nil, nil
 		}
+		a.block(node.IfElseField.ElseBlock, functionName)
 		//if !isSameType(knownFunction[functionName], rt) {
 		//	return nil, fmt.Errorf("戻り値の型が一致しません")
 		//}
 		return nil, nil
+	case parse.NdSwitch:
+		_, err := a.switch_(node, functionName)
+		if err != nil {
+			return nil, err
+		}
+		return nil, nil
 	case parse.NdFor:
-		_, err := for_(node, functionName)
+		_, err := a.for_(node, functionName)
 		if err != nil {
 			return nil, err
 		}
 		return nil, nil
-	case parse.NdBlock:
-		var returnTypes []*parse.DataType
-		for _, s := range node.BlockField.Statements {
-			rt, err := stmt(s, functionName)
-			if err != nil {
-				return nil, err
-			}
-			if s.Kind == parse.NdReturn {
-				if returnTypes == nil {
-					returnTypes = rt
-				} else {
-					if !isSameType(returnTypes, rt) {
-						return nil, fmt.Errorf("ブロック内で返却される戻り値が変化しています")
-					}
-				}
-			}
+	case parse.NdForRange:
+		_, err := a.forRange(node, functionName)
+		if err != nil {
+			return nil, err
 		}
-		return returnTypes, nil
+		return nil, nil
+	case parse.NdBlock:
+		a.block(node, functionName)
+		return nil, nil
 
 	}
-	return expr(node, functionName)
+	return a.expr(node, functionName)
 }
 
-func expr(node *parse.Node, functionName string) ([]*parse.DataType, error) {
-	return assign(node, functionName)
+func (a *Analyzer) expr(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	typ, err := a.assign(node, functionName)
+	if err != nil {
+		return nil, at(node, err)
+	}
+	return typ, nil
 }
 
-func assign(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+func (a *Analyzer) assign(node *parse.Node, functionName string) ([]*parse.DataType, error) {
 	switch node.Kind {
 	case parse.NdVarDecl:
-		name := node.VarDeclField.Identifier.IdentField.Ident
-		typ := node.VarDeclField.Type.DataTypeField.DataType
-		knownValues[functionName][nest][name] = dataTypes(typ)
+		typ, err := a.resolveTypeNode(node.VarDeclField.Type)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.declareValue(node.VarDeclField.Identifier, dataTypes(typ)); err != nil {
+			return nil, err
+		}
 		return dataTypes(typ), nil
 	case parse.NdShortVarDecl:
-		name := node.ShortVarDeclField.Identifier.IdentField.Ident
-		typ, err := expr(node.ShortVarDeclField.Value, functionName)
+		typ, err := a.expr(node.ShortVarDeclField.Value, functionName)
 		if err != nil {
 			return nil, err
 		}
-		knownValues[functionName][nest][name] = typ
+		if err := a.declareValue(node.ShortVarDeclField.Identifier, typ); err != nil {
+			return nil, err
+		}
 		return nil, nil
+	case parse.NdMultiAssign, parse.NdMultiShortVarDecl:
+		return a.multiAssign(node, functionName)
 	case parse.NdAssign:
+		if err := a.checkConstTarget(node.AssignField.To, functionName); err != nil {
+			return nil, err
+		}
 		// 型の変化なし
-		defType, err := assign(node.AssignField.To, functionName)
+		var defType []*parse.DataType
+		var err error
+		if typ, ok := a.assignTarget(node.AssignField.To, functionName); ok {
+			if typ == nil {
+				return nil, errReported
+			}
+			defType = typ
+		} else if defType, err = a.assign(node.AssignField.To, functionName); err != nil {
+			return nil, err
+		}
+		actualType, err := a.assign(node.AssignField.Value, functionName)
 		if err != nil {
 			return nil, err
 		}
-		actualType, err := assign(node.AssignField.Value, functionName)
+		if !isAssignable(node.AssignField.To) {
+			return nil, diagnostic.Errorf("A0027")
+		}
+		if len(defType) != 1 || len(actualType) != 1 {
+			return nil, diagnostic.Errorf("A0028")
+		}
+		if err := a.assignableTo(defType[0], actualType, node.AssignField.Value); err != nil {
+			if defType[0].Type == parse.Interface {
+				return nil, err
+			}
+			return nil, diagnostic.Errorf("A0029", defType[0].Ident, actualType[0].Ident)
+		}
+
+		return nil, nil
+	case parse.NdAddAssign, parse.NdSubAssign, parse.NdMulAssign, parse.NdDivAssign, parse.NdModAssign:
+		if err := a.checkConstTarget(node.AssignField.To, functionName); err != nil {
+			return nil, err
+		}
+		defType, err := a.expr(node.AssignField.To, functionName)
+		if err != nil {
+			return nil, err
+		}
+		if !isAssignable(node.AssignField.To) {
+			return nil, diagnostic.Errorf("A0030")
+		}
+		actualType, err := a.expr(node.AssignField.Value, functionName)
 		if err != nil {
 			return nil, err
 		}
-		if !isSameType(defType, actualType) {
-			return nil, fmt.Errorf("代入された値と宣言の型が一致しません: %v <- %v", defType[0].Ident, actualType[0].Ident)
+		// Floatの変数にはIntを作用させられるが、Intの変数にFloatは作用させられない
+		if typ, ok := promote(defType, actualType); !ok || !isSameType(typ, defType) {
+			if isSameType(defType, dataTypes(parse.RuntimeInt)) && isSameType(actualType, dataTypes(parse.RuntimeFloat)) {
+				return nil, diagnostic.Errorf("A0031")
+			}
+			return nil, diagnostic.Errorf("A0032", defType[0].Ident, actualType[0].Ident)
+		}
+		// += だけは 文字列を許可
+		if !isCalculable(defType) && !(node.Kind == parse.NdAddAssign && isSameType(defType, dataTypes(parse.RuntimeString))) {
+			return nil, diagnostic.Errorf("A0033", defType[0].Ident)
+		}
+		return nil, nil
+	case parse.NdInc, parse.NdDec:
+		if err := a.checkConstTarget(node.UnaryField.Value, functionName); err != nil {
+			return nil, err
+		}
+		typ, err := a.expr(node.UnaryField.Value, functionName)
+		if err != nil {
+			return nil, err
+		}
+		if !isAssignable(node.UnaryField.Value) {
+			return nil, diagnostic.Errorf("A0034")
+		}
+		if !isCalculable(typ) {
+			return nil, diagnostic.Errorf("A0035", typ[0].Ident)
+		}
+		return nil, nil
+	}
+	return a.andor(node, functionName)
+}
+
+// multiAssign 複数の値を返す関数呼び出しか、マップの要素の`v, ok`を複数の変数に代入する
+func (a *Analyzer) multiAssign(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	field := node.MultiAssignField
+	var valueTypes []*parse.DataType
+	var err error
+	if field.Value.Kind == parse.NdIndex {
+		valueTypes, err = a.index(field.Value, functionName)
+		if err != nil {
+			return nil, err
+		}
+		a.typed(field.Value, valueTypes)
+		if field.Value.IndexField.Container.Type == parse.Map {
+			field.Value.IndexField.CommaOk = true
+			valueTypes = append(valueTypes, parse.RuntimeBool)
+		}
+	} else {
+		valueTypes, err = a.expr(field.Value, functionName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(field.Targets) != len(valueTypes) {
+		return nil, diagnostic.Errorf("A0036", len(field.Targets), len(valueTypes))
+	}
+	hasNew := false
+	field.New = make([]bool, len(field.Targets))
+	for i, target := range field.Targets {
+		if target.Kind != parse.NdIdent {
+			return nil, diagnostic.Errorf("A0037")
+		}
+		name := target.IdentField.Ident
+		if name == "_" {
+			continue
+		}
+		if node.Kind == parse.NdMultiShortVarDecl {
+			// 同じスコープで宣言済みの変数には代入だけを行う
+			if a.currentScope.Local(name, nil) == nil {
+				hasNew = true
+				field.New[i] = true
+				if err := a.declareValue(target, dataTypes(valueTypes[i])); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		typ, sym, ok := a.lookupTarget(functionName, name)
+		if !ok {
+			return nil, diagnostic.Errorf("A0038", name)
+		}
+		a.symbols[target] = sym
+		if typ == nil {
+			return nil, errReported
+		}
+		if !isSameType(typ, dataTypes(valueTypes[i])) {
+			return nil, diagnostic.Errorf("A0029", typ[0].Ident, valueTypes[i].Ident)
+		}
+	}
+	if node.Kind == parse.NdMultiShortVarDecl && !hasNew {
+		return nil, diagnostic.Errorf("A0039")
+	}
+	return nil, nil
+}
+
+func (a *Analyzer) andor(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	switch node.Kind {
+	case parse.NdAnd, parse.NdOr:
+		lhs, err := a.andor(node.BinaryField.Lhs, functionName)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := a.andor(node.BinaryField.Rhs, functionName)
+		if err != nil {
+			return nil, err
+		}
+		if !isSameType(lhs, rhs) {
+			return nil, diagnostic.Errorf("A0040", lhs[0].Ident, rhs[0].Ident)
+		}
+		if !isSameType(lhs, dataTypes(parse.RuntimeBool)) {
+			return nil, diagnostic.Errorf("A0041", lhs[0].Ident, rhs[0].Ident)
+		}
+		return a.typed(node, dataTypes(parse.RuntimeBool)), nil
+	}
+	return a.equality(node, functionName)
+}
+
+func (a *Analyzer) equality(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	switch node.Kind {
+	case parse.NdEq, parse.NdNe:
+		// todo : errとnilの関係
+		lhs, err := a.equality(node.BinaryField.Lhs, functionName)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := a.equality(node.BinaryField.Rhs, functionName)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := promote(lhs, rhs); !ok {
+			return nil, diagnostic.Errorf("A0042", lhs[0].Ident, rhs[0].Ident)
+		}
+		// 実行時に比較できるのは値そのものを持つ型のみ
+		if !isEquatable(lhs) {
+			return nil, diagnostic.Errorf("A0106", lhs[0].Ident)
+		}
+		return a.typed(node, dataTypes(parse.RuntimeBool)), nil
+	}
+	return a.relational(node, functionName)
+}
+
+func (a *Analyzer) relational(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	switch node.Kind {
+	case parse.NdLt, parse.NdLe, parse.NdGt, parse.NdGe:
+		lhs, err := a.relational(node.BinaryField.Lhs, functionName)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := a.relational(node.BinaryField.Rhs, functionName)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := promote(lhs, rhs); !ok {
+			return nil, diagnostic.Errorf("A0043", lhs[0].Ident, rhs[0].Ident)
+		}
+		if !isComparable(lhs) || !isComparable(rhs) {
+			return nil, diagnostic.Errorf("A0044", lhs[0].Ident, rhs[0].Ident)
+		}
+		return a.typed(node, dataTypes(parse.RuntimeBool)), nil
+	}
+	return a.add(node, functionName)
+}
+
+func (a *Analyzer) add(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	switch node.Kind {
+	case parse.NdAdd:
+		lhs, err := a.add(node.BinaryField.Lhs, functionName)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := a.add(node.BinaryField.Rhs, functionName)
+		if err != nil {
+			return nil, err
+		}
+		// Intはfloatに昇格する
+		typ, ok := promote(lhs, rhs)
+		if !ok {
+			return nil, diagnostic.Errorf("A0032", lhs[0].Ident, rhs[0].Ident)
+		}
+		// + だけは 文字列を許可
+		if !isCalculable(typ) && !isSameType(typ, dataTypes(parse.RuntimeString)) {
+			return nil, diagnostic.Errorf("A0045", lhs[0].Ident, rhs[0].Ident)
+		}
+		return a.typed(node, typ), nil
+	case parse.NdSub:
+		lhs, err := a.add(node.BinaryField.Lhs, functionName)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := a.add(node.BinaryField.Rhs, functionName)
+		if err != nil {
+			return nil, err
+		}
+		// Intはfloatに昇格する
+		typ, ok := promote(lhs, rhs)
+		if !ok {
+			return nil, diagnostic.Errorf("A0032", lhs[0].Ident, rhs[0].Ident)
+		}
+		if !isCalculable(typ) {
+			return nil, diagnostic.Errorf("A0045", lhs[0].Ident, rhs[0].Ident)
 		}
-
-		return nil, nil
+		return a.typed(node, typ), nil
 	}
-	return andor(node, functionName)
+	return a.mul(node, functionName)
 }
 
-func andor(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+func (a *Analyzer) mul(node *parse.Node, functionName string) ([]*parse.DataType, error) {
 	switch node.Kind {
-	case parse.NdAnd, parse.NdOr:
-		lhs, err := andor(node, functionName)
+	case parse.NdMul, parse.NdDiv, parse.NdMod:
+		lhs, err := a.term(node.BinaryField.Lhs, functionName)
 		if err != nil {
 			return nil, err
 		}
-		rhs, err := andor(node, functionName)
+		rhs, err := a.term(node.BinaryField.Rhs, functionName)
 		if err != nil {
 			return nil, err
 		}
-		if !isSameType(lhs, rhs) {
-			return nil, fmt.Errorf("条件連結は同じ型のみで使用可能です: L:%s, R:%s", lhs[0].Ident, rhs[0].Ident)
+		// Intはfloatに昇格する
+		typ, ok := promote(lhs, rhs)
+		if !ok {
+			return nil, diagnostic.Errorf("A0032", lhs[0].Ident, rhs[0].Ident)
 		}
-		if !isSameType(lhs, dataTypes(parse.RuntimeBool)) {
-			return nil, fmt.Errorf("条件連結はBoolのみで使用可能です: L:%s, R:%s", lhs[0].Ident, rhs[0].Ident)
+		if !isCalculable(typ) {
+			return nil, diagnostic.Errorf("A0045", lhs[0].Ident, rhs[0].Ident)
 		}
-		return dataTypes(parse.RuntimeBool), nil
+		return a.typed(node, typ), nil
 	}
-	return equality(node, functionName)
+	return a.unary(node, functionName)
 }
 
-func equality(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+// term 乗除算の項, 単項のマイナスは`0 - x`になっているので乗除算以外は式として解析する
+func (a *Analyzer) term(node *parse.Node, functionName string) ([]*parse.DataType, error) {
 	switch node.Kind {
-	case parse.NdEq, parse.NdNe:
-		// todo : errとnilの関係
-		lhs, err := equality(node, functionName)
-		if err != nil {
-			return nil, err
-		}
-		rhs, err := equality(node, functionName)
+	case parse.NdMul, parse.NdDiv, parse.NdMod:
+		return a.mul(node, functionName)
+	}
+	return a.expr(node, functionName)
+}
+
+func (a *Analyzer) unary(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	switch node.Kind {
+	case parse.NdNot:
+		p, err := a.primary(node.UnaryField.Value, functionName)
 		if err != nil {
 			return nil, err
 		}
-		if !isSameType(lhs, rhs) {
-			return nil, fmt.Errorf("値比較は同じ型のみで使用可能です: L:%v, R:%v", lhs[0].Ident, rhs[0].Ident)
+		if !isSameType(p, []*parse.DataType{parse.RuntimeBool}) {
+			return nil, diagnostic.Errorf("A0046", p[0].Ident)
 		}
-		return dataTypes(parse.RuntimeBool), nil
+		return a.typed(node, p), nil
+	}
+	return a.primary(node, functionName)
+}
+
+func (a *Analyzer) primary(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	typ, err := a.access(node, functionName)
+	if err != nil {
+		return nil, err
 	}
-	return relational(node, functionName)
+	return a.typed(node, typ), nil
 }
 
-func relational(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+func (a *Analyzer) access(node *parse.Node, functionName string) ([]*parse.DataType, error) {
 	switch node.Kind {
-	case parse.NdLt, parse.NdLe, parse.NdGt, parse.NdGe:
-		lhs, err := relational(node.BinaryField.Lhs, functionName)
-		if err != nil {
-			return nil, err
+	case parse.NdPrefix:
+		prefix := node.PrefixField.Prefix
+		// 変数に続く`.`はフィールドアクセスとして扱う
+		if _, ok := a.lookupValue(functionName, prefix); ok {
+			child := node.PrefixField.Child
+			switch {
+			case child.Kind == parse.NdIdent:
+				field := child.IdentField.Ident
+				*node = *parse.NewAccessNode(node.Pos, parse.NewIdentNode(node.Pos, prefix), field)
+				node.AccessField.FieldPos = child.Pos
+			case child.Kind == parse.NdCall && child.CallField.Identifier.Kind == parse.NdIdent:
+				// フィールドに格納された関数の呼び出し
+				field := child.CallField.Identifier.IdentField.Ident
+				target := parse.NewAccessNode(node.Pos, parse.NewIdentNode(node.Pos, prefix), field)
+				target.AccessField.FieldPos = child.CallField.Identifier.Pos
+				*node = *parse.NewCallNode(node.Pos, target, child.CallField.Args)
+			default:
+				return nil, diagnostic.Errorf("A0047", prefix)
+			}
+			return a.access(node, functionName)
+		}
+		// それ以外は外部のパッケージを参照している
+		if pkg, ok := a.importedAs(node, prefix); ok {
+			return a.packageMember(node, prefix, pkg, functionName)
 		}
-		rhs, err := relational(node.BinaryField.Rhs, functionName)
+		if a.importedElsewhere(prefix) {
+			return nil, diagnostic.New(spanAt(node.Pos, prefix), "A0100", prefix)
+		}
+		return nil, diagnostic.New(spanAt(node.Pos, prefix), "A0102", prefix)
+	case parse.NdAccess:
+		targetType, err := a.expr(node.AccessField.Target, functionName)
 		if err != nil {
 			return nil, err
 		}
-		if !isSameType(lhs, rhs) {
-			return nil, fmt.Errorf("大小比較は同じ型のみで使用できます: L:%v, R:%v", lhs[0].Ident, rhs[0].Ident)
+		if len(targetType) != 1 || targetType[0].Type != parse.Struct {
+			return nil, diagnostic.Errorf("A0048", node.AccessField.Field)
 		}
-		if !isComparable(lhs) || !isComparable(rhs) {
-			return nil, fmt.Errorf("大小比較は比較可能な型のみで使用できます(Int,Float) : L:%v, R:%v", lhs[0].Ident, rhs[0].Ident)
+		i := targetType[0].FieldIndex(node.AccessField.Field)
+		if i == -1 {
+			return nil, diagnostic.Errorf("A0049", targetType[0].Ident, node.AccessField.Field)
+		}
+		if err := checkMemberExported(targetType[0], node.AccessField.Field, fieldSpan(node)); err != nil {
+			return nil, err
 		}
-		return dataTypes(parse.RuntimeBool), nil
+		node.AccessField.Index = i
+		node.AccessField.DataType = targetType[0].Fields[i].DataType
+		return dataTypes(node.AccessField.DataType), nil
+	case parse.NdIndex:
+		return a.index(node, functionName)
+	case parse.NdSlice:
+		return a.slice(node, functionName)
 	}
-	return add(node, functionName)
+	return a.literal(node, functionName)
 }
 
-func add(node *parse.Node, functionName string) ([]*parse.DataType, error) {
-	switch node.Kind {
-	case parse.NdAdd:
-		lhs, err := add(node.BinaryField.Lhs, functionName)
+// packageMember `パッケージ.識別子`を他のパッケージの関数、グローバル変数、定数として解析する
+// 関数、グローバル変数はリンク時に解決される外部のシンボルを指す識別子に置き換える
+func (a *Analyzer) packageMember(node *parse.Node, prefix string, imported *Package, functionName string) ([]*parse.DataType, error) {
+	pkg := imported.Semantics
+	path := imported.Identifier()
+	child := node.PrefixField.Child
+	if name := memberName(child); !IsExported(name) && declares(pkg, name) {
+		return nil, diagnostic.Errorf("A0101", prefix, name)
+	}
+	if child.Kind == parse.NdCall {
+		callee := child.CallField.Identifier
+		if callee.Kind != parse.NdIdent {
+			return nil, diagnostic.Errorf("A0047", prefix)
+		}
+		name := callee.IdentField.Ident
+		fn, ok := pkg.KnownFunctions[name]
+		if !ok {
+			return nil, diagnostic.Errorf("A0097", prefix, name)
+		}
+		*node = *child
+		a.symbols[callee] = externalSymbol(path, fn.Symbol)
+		args, values, err := a.callArgs(node, functionName)
 		if err != nil {
 			return nil, err
 		}
-		rhs, err := add(node.BinaryField.Rhs, functionName)
+		if err := a.argumentsTo(a.importTypes(imported, fn.Params), args, values); err != nil {
+			return nil, diagnostic.Errorf("A0073")
+		}
+		return a.importTypes(imported, fn.Returns), nil
+	}
+	// 他のパッケージの構造体のリテラル`パッケージ名.型名{...}`
+	if child.Kind == parse.NdStructLit {
+		typeNode := child.StructLitField.Type
+		name := typeNode.DataTypeField.DataType.Ident
+		typ, ok := pkg.KnownTypes[name]
+		if !ok {
+			return nil, diagnostic.Errorf("A0097", prefix, name)
+		}
+		typeNode.DataTypeField.DataType = a.importType(imported, typ)
+		*node = *child
+		return a.structLit(node, functionName)
+	}
+	if child.Kind != parse.NdIdent {
+		return nil, diagnostic.Errorf("A0047", prefix)
+	}
+	name := child.IdentField.Ident
+	if c, ok := pkg.Constants[name]; ok {
+		*node = *parse.NewLiteralNode(node.Pos, c.Literal)
+		return dataTypes(a.importType(imported, c.DataType)), nil
+	}
+	var sym *ir.Symbol
+	if fn, ok := pkg.KnownFunctions[name]; ok {
+		sym = externalSymbol(path, fn.Symbol)
+	} else if typ, ok := pkg.Globals[name]; ok {
+		sym = &ir.Symbol{Kind: ir.Global, Name: name, Package: path, External: true, Type: typ[0]}
+	} else {
+		return nil, diagnostic.Errorf("A0097", prefix, name)
+	}
+	*node = *parse.NewIdentNode(node.Pos, name)
+	a.symbols[node] = sym
+	// シンボルの型はリンク時に定義と比べるので、定義したパッケージの型のまま残す
+	return dataTypes(a.importType(imported, sym.Type)), nil
+}
+
+// memberName `パッケージ.識別子`の識別子, 構造体のリテラルは型の名前
+func memberName(child *parse.Node) string {
+	switch child.Kind {
+	case parse.NdCall:
+		return memberName(child.CallField.Identifier)
+	case parse.NdIdent:
+		return child.IdentField.Ident
+	case parse.NdStructLit:
+		return child.StructLitField.Type.DataTypeField.DataType.Ident
+	}
+	return ""
+}
+
+// declares パッケージにその名前の関数、グローバル変数、定数、型が宣言されているか
+func declares(pkg *Semantics, name string) bool {
+	_, fn := pkg.KnownFunctions[name]
+	_, global := pkg.Globals[name]
+	_, constant := pkg.Constants[name]
+	_, typ := pkg.KnownTypes[name]
+	return fn || global || constant || typ
+}
+
+// externalSymbol 他のパッケージの関数への参照
+func externalSymbol(pkg string, sym *ir.Symbol) *ir.Symbol {
+	return &ir.Symbol{Kind: sym.Kind, Name: sym.Name, Package: pkg, External: true, Type: sym.Type}
+}
+
+// isIndexable 配列またはスライスか
+func isIndexable(x []*parse.DataType) bool {
+	return len(x) == 1 && (x[0].Type == parse.Array || x[0].Type == parse.Slice)
+}
+
+// isMap マップか
+func isMap(x []*parse.DataType) bool {
+	return len(x) == 1 && x[0].Type == parse.Map
+}
+
+func (a *Analyzer) index(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	targetType, err := a.expr(node.IndexField.Target, functionName)
+	if err != nil {
+		return nil, err
+	}
+	indexType, err := a.expr(node.IndexField.Index, functionName)
+	if err != nil {
+		return nil, err
+	}
+	if isMap(targetType) {
+		if !isSameType(indexType, dataTypes(targetType[0].Key)) {
+			return nil, diagnostic.Errorf("A0050", targetType[0].Key.Ident)
+		}
+		node.IndexField.Container = targetType[0]
+		node.IndexField.DataType = targetType[0].Base
+		return dataTypes(node.IndexField.DataType), nil
+	}
+	if !isIndexable(targetType) {
+		return nil, diagnostic.Errorf("A0051")
+	}
+	if !isSameType(indexType, dataTypes(parse.RuntimeInt)) {
+		return nil, diagnostic.Errorf("A0052")
+	}
+	// 即値であれば配列の範囲外へのアクセスをここで検出できる
+	i := node.IndexField.Index
+	if targetType[0].Type == parse.Array && i.Kind == parse.NdLiteral {
+		if i.LiteralField.Literal.I < 0 || targetType[0].Len <= i.LiteralField.Literal.I {
+			return nil, diagnostic.Errorf("A0053", i.LiteralField.Literal.I, targetType[0].Len)
+		}
+	}
+	node.IndexField.Container = targetType[0]
+	node.IndexField.DataType = targetType[0].Base
+	return dataTypes(node.IndexField.DataType), nil
+}
+
+func (a *Analyzer) slice(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	targetType, err := a.expr(node.SliceField.Target, functionName)
+	if err != nil {
+		return nil, err
+	}
+	if !isIndexable(targetType) {
+		return nil, diagnostic.Errorf("A0054")
+	}
+	// 配列は元の配列を参照するスライスになるので、変数などである必要がある
+	if targetType[0].Type == parse.Array && !isAddressable(node.SliceField.Target) {
+		return nil, diagnostic.Errorf("A0055")
+	}
+	for _, bound := range []*parse.Node{node.SliceField.Low, node.SliceField.High} {
+		if bound == nil {
+			continue
+		}
+		typ, err := a.expr(bound, functionName)
 		if err != nil {
 			return nil, err
 		}
-		// todo : intとfloatの計算について
-		// Intはfloatに自動キャストできる.
-		if !isSameType(lhs, rhs) {
-			return nil, fmt.Errorf("計算は同じ型のみで行えます")
+		if !isSameType(typ, dataTypes(parse.RuntimeInt)) {
+			return nil, diagnostic.Errorf("A0056")
 		}
-		// + だけは 文字列を許可
-		if (!isCalculable(lhs) || !isCalculable(rhs)) && !isSameType(lhs, dataTypes(parse.RuntimeString)) {
-			return nil, fmt.Errorf("掛け算は計算可能な型(Int, Float)のみで使用できます: L:%v, R:%v", lhs[0].Ident, rhs[0].Ident)
+	}
+	node.SliceField.DataType = a.compositeType(parse.Slice, targetType[0].Base, 0)
+	return dataTypes(node.SliceField.DataType), nil
+}
+
+func (a *Analyzer) structLit(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	typ, err := a.resolveTypeNode(node.StructLitField.Type)
+	if err != nil {
+		return nil, err
+	}
+	if typ.Type != parse.Struct {
+		return nil, diagnostic.Errorf("A0057", typ.Ident)
+	}
+	seen := map[string]bool{}
+	for _, kv := range node.StructLitField.Fields {
+		name := kv.KVField.Key.IdentField.Ident
+		if seen[name] {
+			return nil, diagnostic.Errorf("A0058", name)
 		}
-		return lhs, nil
-	case parse.NdSub:
-		lhs, err := add(node.BinaryField.Lhs, functionName)
-		if err != nil {
+		seen[name] = true
+		i := typ.FieldIndex(name)
+		if i == -1 {
+			return nil, diagnostic.Errorf("A0049", typ.Ident, name)
+		}
+		if err := checkMemberExported(typ, name, spanOf(kv.KVField.Key)); err != nil {
 			return nil, err
 		}
-		rhs, err := add(node.BinaryField.Rhs, functionName)
+		vt, err := a.expr(kv.KVField.Value, functionName)
 		if err != nil {
 			return nil, err
 		}
-		// todo : intとfloatの計算について
-		// Intはfloatに自動キャストできる.
-		if !isSameType(lhs, rhs) {
-			return nil, fmt.Errorf("計算は同じ型のみで行えます")
-		}
-		if !isCalculable(lhs) || !isCalculable(rhs) {
-			return nil, fmt.Errorf("掛け算は計算可能な型(Int, Float)のみで使用できます: L:%v, R:%v", lhs[0].Ident, rhs[0].Ident)
+		if err := a.assignableTo(typ.Fields[i].DataType, vt, kv.KVField.Value); err != nil {
+			return nil, diagnostic.Errorf("A0059", name)
 		}
-		return lhs, nil
 	}
-	return mul(node, functionName)
+	return dataTypes(typ), nil
 }
 
-func mul(node *parse.Node, functionName string) ([]*parse.DataType, error) {
-	switch node.Kind {
-	case parse.NdMul, parse.NdDiv, parse.NdMod:
-		lhs, err := mul(node.BinaryField.Lhs, functionName)
+func (a *Analyzer) dictLit(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	typ, err := a.resolveTypeNode(node.DictField.Type)
+	if err != nil {
+		return nil, err
+	}
+	if typ.Type != parse.Map {
+		return nil, diagnostic.Errorf("A0060", typ.Ident)
+	}
+	for _, kv := range node.DictField.Entries {
+		kt, err := a.expr(kv.KVField.Key, functionName)
 		if err != nil {
 			return nil, err
 		}
-		rhs, err := mul(node.BinaryField.Rhs, functionName)
+		if !isSameType(dataTypes(typ.Key), kt) {
+			return nil, diagnostic.Errorf("A0050", typ.Key.Ident)
+		}
+		vt, err := a.expr(kv.KVField.Value, functionName)
 		if err != nil {
 			return nil, err
 		}
-		// todo : intとfloatの計算について
-		// Intはfloatに自動キャストできる.
-		if !isSameType(lhs, rhs) {
-			return nil, fmt.Errorf("計算は同じ型のみで行えます")
-		}
-		if !isCalculable(lhs) || !isCalculable(rhs) {
-			return nil, fmt.Errorf("掛け算は計算可能な型(Int, Float)のみで使用できます: L:%v, R:%v", lhs[0].Ident, rhs[0].Ident)
+		if err := a.assignableTo(typ.Base, vt, kv.KVField.Value); err != nil {
+			return nil, diagnostic.Errorf("A0061", typ.Base.Ident)
 		}
-		return lhs, nil
 	}
-	return unary(node, functionName)
+	return dataTypes(typ), nil
 }
 
-func unary(node *parse.Node, functionName string) ([]*parse.DataType, error) {
-	switch node.Kind {
-	case parse.NdNot:
-		p, err := primary(node.UnaryField.Value, functionName)
+func (a *Analyzer) listLit(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	typ, err := a.resolveTypeNode(node.ListField.Type)
+	if err != nil {
+		return nil, err
+	}
+	if typ.Type == parse.Array && typ.Len < len(node.ListField.Values) {
+		return nil, diagnostic.Errorf("A0062", len(node.ListField.Values), typ.Len)
+	}
+	for _, v := range node.ListField.Values {
+		vt, err := a.expr(v, functionName)
 		if err != nil {
 			return nil, err
 		}
-		if !isSameType(p, []*parse.DataType{parse.RuntimeBool}) {
-			return nil, fmt.Errorf("notはbool以外を値にできません: %v", p[0].Ident)
+		if err := a.assignableTo(typ.Base, vt, v); err != nil {
+			return nil, diagnostic.Errorf("A0063", typ.Base.Ident, vt[0].Ident)
+		}
+	}
+	return dataTypes(typ), nil
+}
+
+// builtinCall 組み込み関数の呼び出し
+func (a *Analyzer) builtinCall(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	name := node.CallField.Identifier.IdentField.Ident
+	var args [][]*parse.DataType
+	if node.CallField.Args != nil {
+		for _, arg := range node.CallField.Args.PolynomialField.Values {
+			argT, err := a.expr(arg, functionName)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, argT)
+		}
+	}
+	switch name {
+	case "len":
+		if len(args) != 1 || !(isIndexable(args[0]) || isMap(args[0]) || isSameType(args[0], dataTypes(parse.RuntimeString))) {
+			return nil, diagnostic.Errorf("A0064")
+		}
+		return dataTypes(parse.RuntimeInt), nil
+	case "append":
+		if len(args) == 0 || len(args[0]) != 1 || args[0][0].Type != parse.Slice {
+			return nil, diagnostic.Errorf("A0065")
+		}
+		for i, arg := range args[1:] {
+			if err := a.assignableTo(args[0][0].Base, arg, node.CallField.Args.PolynomialField.Values[i+1]); err != nil {
+				return nil, diagnostic.Errorf("A0066", args[0][0].Ident)
+			}
+		}
+		return args[0], nil
+	case "delete":
+		if len(args) != 2 || !isMap(args[0]) {
+			return nil, diagnostic.Errorf("A0067")
+		}
+		if !isSameType(dataTypes(args[0][0].Key), args[1]) {
+			return nil, diagnostic.Errorf("A0050", args[0][0].Key.Ident)
+		}
+		return nil, nil
+	case "int", "float":
+		if len(args) != 1 || !isCalculable(args[0]) {
+			return nil, diagnostic.Errorf("A0068", name)
+		}
+		return dataTypes(parse.GetDataTypeByIdent(name)), nil
+	case "string":
+		if len(args) != 1 || !(isCalculable(args[0]) || isSameType(args[0], dataTypes(parse.RuntimeString))) {
+			return nil, diagnostic.Errorf("A0069")
+		}
+		return dataTypes(parse.RuntimeString), nil
+	case "atoi", "atof":
+		if len(args) != 1 || !isSameType(args[0], dataTypes(parse.RuntimeString)) {
+			return nil, diagnostic.Errorf("A0070", name)
 		}
-		return p, nil
+		if name == "atoi" {
+			return []*parse.DataType{parse.RuntimeInt, parse.RuntimeBool}, nil
+		}
+		return []*parse.DataType{parse.RuntimeFloat, parse.RuntimeBool}, nil
+	}
+	return nil, diagnostic.Errorf("A0071", name)
+}
+
+// funcLit 無名関数は名前を付けて通常の関数と同様に解析する
+func (a *Analyzer) funcLit(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	a.lambdaCount[functionName]++
+	name := fmt.Sprintf("%s.func%d", functionName, a.lambdaCount[functionName])
+	node.FuncDefField.Identifier = parse.NewIdentNode(node.Pos, name)
+
+	if err := a.declareFunction(node); err != nil {
+		return nil, err
+	}
+	a.enclosing = append(a.enclosing, enclosingScope{functionName: functionName, scope: a.currentScope})
+	err := a.function(node)
+	a.enclosing = a.enclosing[:len(a.enclosing)-1]
+	if err != nil {
+		return nil, err
+	}
+	// キャプチャする変数は無名関数の本文を全て解析した時点で確定する
+	fn := a.knownFunction[name]
+	for _, c := range fn.Captures {
+		a.lambdaCaptures[node] = append(a.lambdaCaptures[node], a.currentScope.Lookup(c, nil).Symbol)
+	}
+	return dataTypes(a.funcType(fn.Params, fn.Returns)), nil
+}
+
+// indirectCall 関数の値の呼び出し
+func (a *Analyzer) indirectCall(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	calleeType, err := a.expr(node.CallField.Identifier, functionName)
+	if err != nil {
+		return nil, err
+	}
+	if len(calleeType) != 1 || calleeType[0].Type != parse.Func {
+		return nil, diagnostic.Errorf("A0072")
+	}
+	args, values, err := a.callArgs(node, functionName)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.argumentsTo(calleeType[0].Params, args, values); err != nil {
+		return nil, diagnostic.Errorf("A0073")
 	}
-	return primary(node, functionName)
+	node.CallField.FuncType = calleeType[0]
+	return calleeType[0].Returns, nil
 }
 
-func primary(node *parse.Node, functionName string) ([]*parse.DataType, error) {
-	return access(node, functionName)
+// callArgs 引数の型と、引数のノード
+func (a *Analyzer) callArgs(node *parse.Node, functionName string) ([]*parse.DataType, []*parse.Node, error) {
+	if node.CallField.Args == nil {
+		return nil, nil, nil
+	}
+	var args []*parse.DataType
+	values := node.CallField.Args.PolynomialField.Values
+	for _, arg := range values {
+		argT, err := a.expr(arg, functionName)
+		if err != nil {
+			return nil, nil, err
+		}
+		args = append(args, argT...)
+	}
+	return args, values, nil
 }
 
-func access(node *parse.Node, functionName string) ([]*parse.DataType, error) {
-	if node.Kind == parse.NdPrefix {
-		return literal(node.PrefixField.Child, functionName)
+// methodCall `値.メソッド名(引数)`
+// 具体的な型のメソッドはレシーバを最初の引数とする関数呼び出しに置き換え、
+// インターフェースのメソッドは呼び出す関数を実行時に決める
+func (a *Analyzer) methodCall(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+	callee := node.CallField.Identifier
+	targetType, err := a.expr(callee.AccessField.Target, functionName)
+	if err != nil {
+		return nil, err
+	}
+	if len(targetType) != 1 {
+		return a.indirectCall(node, functionName)
+	}
+	typ := targetType[0]
+	name := callee.AccessField.Field
+	if err := checkMemberExported(typ, name, fieldSpan(callee)); err != nil {
+		return nil, err
+	}
+	mt, ok := a.lookupMethod(typ, name)
+	if !ok {
+		if typ.Type == parse.Interface {
+			return nil, diagnostic.Errorf("A0074", typ.Ident, name)
+		}
+		// フィールドに格納された関数
+		return a.indirectCall(node, functionName)
+	}
+	args, values, err := a.callArgs(node, functionName)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.argumentsTo(mt.Params, args, values); err != nil {
+		return nil, diagnostic.Errorf("A0075", name)
 	}
-	return literal(node, functionName)
+	if typ.Type == parse.Interface {
+		node.CallField.Identifier = callee.AccessField.Target
+		node.CallField.Method = name
+		node.CallField.FuncType = mt
+		return mt.Returns, nil
+	}
+	receiver := callee.AccessField.Target
+	node.CallField.Identifier = parse.NewIdentNode(callee.Pos, MethodName(typ, name))
+	fn, _ := a.method(typ, name)
+	a.symbols[node.CallField.Identifier] = fn.Symbol
+	node.CallField.Args = parse.NewPolynomialNode(parse.NdArgs, node.Pos, append([]*parse.Node{receiver}, values...))
+	return mt.Returns, nil
 }
 
-func literal(node *parse.Node, functionName string) ([]*parse.DataType, error) {
+func (a *Analyzer) literal(node *parse.Node, functionName string) ([]*parse.DataType, error) {
 	switch node.Kind {
 	case parse.NdParenthesis:
-		return expr(node.UnaryField.Value, functionName)
+		return a.expr(node.UnaryField.Value, functionName)
+	case parse.NdStructLit:
+		return a.structLit(node, functionName)
+	case parse.NdList:
+		return a.listLit(node, functionName)
+	case parse.NdDict:
+		return a.dictLit(node, functionName)
 	case parse.NdIdent:
 		if node.IdentField.Ident == "true" || node.IdentField.Ident == "false" {
 			return dataTypes(parse.RuntimeBool), nil
@@ -491,53 +1712,82 @@ func literal(node *parse.Node, functionName string) ([]*parse.DataType, error) {
 		if node.IdentField.Ident == "nil" {
 			return dataTypes(parse.RuntimeNil), nil
 		}
-		// todo : global変数
-		// nestの深い値は、0になるまで遡って定義を調べることでif文などから関数内の値の方を参照する
-		for i := nest; 0 <= i; i-- {
-			typ, ok := knownValues[functionName][i][node.IdentField.Ident]
-			if ok {
+		// 内側のスコープから外側に遡って定義を調べる
+		typ, sym, ok := a.lookupSymbol(functionName, node.IdentField.Ident)
+		if !ok {
+			// 定数は値に置き換える
+			if typ, ok := a.constValue(node); ok {
 				return typ, nil
 			}
+			if node.IdentField.Ident == "iota" {
+				return nil, diagnostic.Errorf("A0076")
+			}
+			// 関数名は関数の値として扱う
+			if fn, ok := a.knownFunction[node.IdentField.Ident]; ok {
+				if node.IdentField.Ident == "init" {
+					return nil, diagnostic.New(spanOf(node), "A0105")
+				}
+				a.symbols[node] = fn.Symbol
+				return dataTypes(a.funcType(fn.Params, fn.Returns)), nil
+			}
+			if a.failedNames[node.IdentField.Ident] {
+				return nil, errReported
+			}
+			return nil, diagnostic.New(spanOf(node), "A0038", node.IdentField.Ident)
 		}
-		if strings.Contains(node.IdentField.Ident, ".") {
-			outsideValues = append(outsideValues, node)
-			return dataTypes(parse.RuntimeUnknown), nil
-		}
-		typ, ok := knownValues["-global-"][0][node.IdentField.Ident]
-		if !ok {
-			return nil, fmt.Errorf("ana: %s is not defined", node.IdentField.Ident)
+		a.symbols[node] = sym
+		// エラーのあった宣言の変数
+		if typ == nil {
+			return nil, errReported
 		}
 		return typ, nil
+	case parse.NdFuncLit:
+		return a.funcLit(node, functionName)
+	case parse.NdConvert:
+		return dataTypes(node.ConvertField.To), nil
 	case parse.NdCall:
+		callee := node.CallField.Identifier
+		if callee.Kind == parse.NdAccess {
+			return a.methodCall(node, functionName)
+		}
+		if callee.Kind != parse.NdIdent {
+			return a.indirectCall(node, functionName)
+		}
+		if builtins[callee.IdentField.Ident] {
+			return a.builtinCall(node, functionName)
+		}
+		// 変数に格納された関数
+		if _, ok := a.lookupValue(functionName, callee.IdentField.Ident); ok {
+			return a.indirectCall(node, functionName)
+		}
 		// 期待する引数型
-		typ, ok := knownFunction[node.CallField.Identifier.IdentField.Ident]
+		typ, ok := a.knownFunction[callee.IdentField.Ident]
 		if !ok {
-			if strings.Contains(node.CallField.Identifier.IdentField.Ident, ".") {
-				outsideFunction = append(outsideFunction, node)
-				return dataTypes(parse.RuntimeUnknown), nil
+			if a.failedNames[callee.IdentField.Ident] {
+				return nil, errReported
 			}
-			return nil, fmt.Errorf("function %s is not defined", node.CallField.Identifier.IdentField.Ident) // ?
+			return nil, diagnostic.New(spanOf(callee), "A0077", callee.IdentField.Ident)
 		}
+		if callee.IdentField.Ident == "init" {
+			return nil, diagnostic.New(spanOf(callee), "A0105")
+		}
+		a.symbols[callee] = typ.Symbol
 
 		// 関数呼び出しで引数を渡さなかった場合、NILポインタが発生するのでチェックしてあげる
 		if node.CallField.Args == nil {
 			if !isSameType(typ.Params, nil) {
-				return nil, fmt.Errorf("関数呼び出しの引数と与えられた型が異なります")
+				return nil, diagnostic.Errorf("A0073")
 			}
 			return typ.Returns, nil
 		}
 
 		//node.CallField.Args
-		var args []*parse.DataType
-		for _, arg := range node.CallField.Args.PolynomialField.Values {
-			argT, err := expr(arg, functionName)
-			if err != nil {
-				return nil, err
-			}
-			args = append(args, argT...)
+		args, values, err := a.callArgs(node, functionName)
+		if err != nil {
+			return nil, err
 		}
-		if !isSameType(typ.Params, args) {
-			return nil, fmt.Errorf("関数呼び出しの引数と与えられた型が異なります")
+		if err := a.argumentsTo(typ.Params, args, values); err != nil {
+			return nil, diagnostic.Errorf("A0073")
 		}
 		return typ.Returns, nil
 	default:
@@ -554,64 +1804,258 @@ func literal(node *parse.Node, functionName string) ([]*parse.DataType, error) {
 			return dataTypes(parse.RuntimeNil), nil
 		}
 	}
-	return nil, fmt.Errorf("unknown data type")
+	return nil, diagnostic.Errorf("A0078")
 }
 
-func globalDecl(node *parse.Node) error {
-	knownValues["-global-"][0][node.VarDeclField.Identifier.IdentField.Ident] = dataTypes(node.VarDeclField.Type.DataTypeField.DataType)
+func (a *Analyzer) globalDecl(node *parse.Node) error {
+	name := node.VarDeclField.Identifier.IdentField.Ident
+	if c, ok := a.declaredConstants[name]; ok {
+		// 同じファイルで変数が先に書かれていれば、後の定数の宣言を報告する
+		if p, q := node.Pos, c.Pos; p != nil && q != nil && p.File == q.File &&
+			(p.LineNo < q.LineNo || p.LineNo == q.LineNo && p.Lat < q.Lat) {
+			return diagnostic.New(spanOf(c.ConstDeclField.Identifier), "A0083", name)
+		}
+		return diagnostic.Errorf("A0079", name)
+	}
+	typ, err := a.resolveTypeNode(node.VarDeclField.Type)
+	if err != nil {
+		return err
+	}
+	a.globalValues[name] = dataTypes(typ)
+	a.globalSymbols[name] = &ir.Symbol{Kind: ir.Global, Name: name, Type: typ, Pos: node.VarDeclField.Identifier.Pos}
 	return nil
 }
-func globalAssign(node *parse.Node) error {
-	if err := globalDecl(node.AssignField.To); err != nil {
+func (a *Analyzer) globalAssign(node *parse.Node) error {
+	if err := a.globalDecl(node.AssignField.To); err != nil {
 		return err
 	}
-	typ := knownValues["-global-"][0][node.AssignField.To.VarDeclField.Identifier.IdentField.Ident]
+	typ := a.globalValues[node.AssignField.To.VarDeclField.Identifier.IdentField.Ident]
 
-	if node.AssignField.Value.Kind != parse.NdLiteral {
-		return fmt.Errorf("グローバル変数では即値以外を代入することはできません")
+	if err := a.foldConst(node.AssignField.Value); err != nil {
+		return err
 	}
-	valType, err := expr(node.AssignField.Value, "-global-")
+	valType, err := a.expr(node.AssignField.Value, "-global-")
 	if err != nil {
 		return err
 	}
+	// 定数は即値に置き換えられている
+	if node.AssignField.Value.Kind != parse.NdLiteral {
+		return diagnostic.Errorf("A0080")
+	}
 
 	if !isSameType(typ, valType) {
-		return fmt.Errorf("global 型の異なる値を代入することはできません")
+		return diagnostic.Errorf("A0081")
 	}
 
 	return nil
 }
 
-func Analyze(nodes []*parse.Node) (*Semantics, error) {
-	knownValues = map[string]map[int]map[string][]*parse.DataType{}
-	knownValues["-global-"] = map[int]map[string][]*parse.DataType{}
-	knownValues["-global-"][0] = map[string][]*parse.DataType{}
-	knownFunction = map[string]*FnDataType{}
-	outsideValues = []*parse.Node{}
-	outsideFunction = []*parse.Node{}
+// declare 宣言の時点のエラーを報告し、以降の解析から外す
+func (a *Analyzer) declare(node *parse.Node, at *parse.Node, err error) {
+	if err != nil {
+		a.failedDeclarations[node] = true
+		if name := declaredName(node); name != "" {
+			a.failedNames[name] = true
+		}
+		a.report(at, err)
+	}
+}
+
+// poisonTypes 宣言にエラーのあった型をフィールドやメソッドに使う型も、エラーのあった型として扱う
+// 型を参照した側ではknownTypesにない型をfailedNamesで引くので、改めて報告しない
+func (a *Analyzer) poisonTypes(nodes []*parse.Node) {
+	failed := map[*parse.DataType]bool{}
+	for _, node := range nodes {
+		if node.Kind == parse.NdTypeDef && a.failedDeclarations[node] {
+			failed[node.TypeDefField.Type.DataTypeField.DataType] = true
+		}
+	}
+	for changed := len(failed) != 0; changed; {
+		changed = false
+		for _, node := range nodes {
+			if node.Kind != parse.NdTypeDef || a.failedDeclarations[node] {
+				continue
+			}
+			typ := node.TypeDefField.Type.DataTypeField.DataType
+			if !membersRefer(typ, failed) {
+				continue
+			}
+			name := declaredName(node)
+			a.failedDeclarations[node] = true
+			a.failedNames[name] = true
+			delete(a.knownTypes, name)
+			failed[typ] = true
+			changed = true
+		}
+	}
+}
+
+// membersRefer 構造体のフィールド、インターフェースのメソッドの型がfailedの型を含むか
+func membersRefer(typ *parse.DataType, failed map[*parse.DataType]bool) bool {
+	for _, f := range typ.Fields {
+		if refers(f.DataType, failed) {
+			return true
+		}
+	}
+	for _, m := range typ.Methods {
+		if refers(m.DataType, failed) {
+			return true
+		}
+	}
+	return false
+}
+
+// refers 型がfailedの型を含むか, 名前のある型の中身はたどらない
+func refers(typ *parse.DataType, failed map[*parse.DataType]bool) bool {
+	if typ == nil {
+		return false
+	}
+	if failed[typ] {
+		return true
+	}
+	if refers(typ.Base, failed) || refers(typ.Key, failed) {
+		return true
+	}
+	for _, t := range typ.Params {
+		if refers(t, failed) {
+			return true
+		}
+	}
+	for _, t := range typ.Returns {
+		if refers(t, failed) {
+			return true
+		}
+	}
+	return false
+}
+
+// declaredName トップレベルの宣言が定義する名前, メソッドはレシーバの型で引くので含めない
+func declaredName(node *parse.Node) string {
+	var id *parse.Node
+	switch node.Kind {
+	case parse.NdTypeDef:
+		id = node.TypeDefField.Identifier
+	case parse.NdConstDecl:
+		id = node.ConstDeclField.Identifier
+	case parse.NdVarDecl:
+		id = node.VarDeclField.Identifier
+	case parse.NdAssign:
+		id = node.AssignField.To.VarDeclField.Identifier
+	case parse.NdFuncDef:
+		if node.FuncDefField.Receiver == nil {
+			id = node.FuncDefField.Identifier
+		}
+	}
+	if id == nil || id.Kind != parse.NdIdent {
+		return ""
+	}
+	return id.IdentField.Ident
+}
 
+// declarations 関数の本文を解析する前に、全てのトップレベルの宣言を登録する
+// 型、定数、関数、グローバル変数の順に登録するので、宣言の順序によらず参照できる
+func (a *Analyzer) declarations(nodes []*parse.Node) {
+	var consts []*parse.Node
 	for _, node := range nodes {
 		switch node.Kind {
-		case parse.NdFuncDef:
-			//ne
-			if err := function(node); err != nil {
-				return nil, err
+		case parse.NdTypeDef:
+			a.declare(node, node, a.declareType(node))
+		case parse.NdConstDecl:
+			consts = append(consts, node)
+		case parse.NdConstGroup:
+			consts = append(consts, node.PolynomialField.Values...)
+		}
+	}
+	for _, spec := range consts {
+		a.declare(spec, spec, a.declareConstant(spec))
+	}
+	// 型の中身は配列の長さに定数を使用することがある
+	for _, node := range nodes {
+		if node.Kind == parse.NdTypeDef && !a.failedDeclarations[node] {
+			a.declare(node, node, a.typeDef(node))
+			if a.failedDeclarations[node] {
+				delete(a.knownTypes, declaredName(node))
 			}
+		}
+	}
+	a.poisonTypes(nodes)
+	for _, spec := range consts {
+		if !a.failedDeclarations[spec] && a.evaluateConstant(spec) != nil {
+			a.failedNames[declaredName(spec)] = true
+		}
+	}
+	// グローバル変数の初期化の式は、後に宣言された関数も参照することがある
+	for _, node := range nodes {
+		if node.Kind != parse.NdFuncDef {
+			continue
+		}
+		if node.FuncDefField.Receiver != nil {
+			a.declare(node, node.FuncDefField.Identifier, a.declareMethod(node))
+		} else {
+			a.declare(node, node.FuncDefField.Identifier, a.declareFunction(node))
+		}
+	}
+	for _, node := range nodes {
+		switch node.Kind {
 		case parse.NdVarDecl:
-			if err := globalDecl(node); err != nil {
-				return nil, err
-			}
+			a.declare(node, node, a.globalDecl(node))
 		case parse.NdAssign:
-			if err := globalAssign(node); err != nil {
-				return nil, err
-			}
+			a.declare(node, node, a.globalAssign(node))
+		}
+	}
+}
+
+// Analyze 新しいAnalyzerで解析する
+func Analyze(nodes []*parse.Node, imports ...*Package) (*Semantics, error) {
+	return NewAnalyzer().Analyze(nodes, imports...)
+}
+
+// Analyze importsはimportすると`名前.識別子`で参照できる解析済みのパッケージ
+func (a *Analyzer) Analyze(nodes []*parse.Node, imports ...*Package) (*Semantics, error) {
+	a.packages = map[string]*Package{}
+	for _, pkg := range imports {
+		a.packages[pkg.Identifier()] = pkg
+	}
+	a.importedTypes = map[string]*parse.DataType{}
+	a.globalValues = map[string][]*parse.DataType{}
+	a.globalSymbols = map[string]*ir.Symbol{}
+	a.types = map[*parse.Node][]*parse.DataType{}
+	a.symbols = map[*parse.Node]*ir.Symbol{}
+	a.ranges = map[*parse.Node]*rangeValues{}
+	a.lambdaCaptures = map[*parse.Node][]*ir.Symbol{}
+	a.currentScope = nil
+	a.knownFunction = map[string]*FnDataType{}
+	a.knownTypes = map[string]*parse.DataType{}
+	a.enclosing = nil
+	a.lambdaCount = map[string]int{}
+	a.knownConstants = map[string]*Constant{}
+	a.declaredConstants = map[string]*parse.Node{}
+	a.constStates = map[*parse.Node]constState{}
+	a.failedDeclarations = map[*parse.Node]bool{}
+	a.failedNames = map[string]bool{}
+	a.diagnostics = nil
+
+	a.declareImports(nodes)
+	a.declarations(nodes)
+	for _, node := range nodes {
+		if node.Kind == parse.NdFuncDef && !a.failedDeclarations[node] {
+			a.report(node.FuncDefField.Identifier, a.function(node))
 		}
 	}
+	a.unusedImports(nodes)
+	if len(a.diagnostics) != 0 {
+		a.sortDiagnostics()
+		return nil, a.diagnostics
+	}
+	prog, err := a.lower(nodes)
+	if err != nil {
+		return nil, err
+	}
 	return &Semantics{
-		KnownValues:      knownValues,
-		KnownFunctions:   knownFunction,
-		OutsideValues:    outsideValues,
-		OutsideFunctions: outsideFunction,
-		Tree:             nodes,
+		Globals:        a.globalValues,
+		KnownFunctions: a.knownFunction,
+		KnownTypes:     a.knownTypes,
+		Constants:      a.knownConstants,
+		Program:        prog,
 	}, nil
 }
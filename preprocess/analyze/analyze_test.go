@@ -2,6 +2,8 @@ package analyze_test
 
 import (
 	"fmt"
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/ir"
 	"github.com/arrietty-lang/arrtty/preprocess/analyze"
 	"github.com/arrietty-lang/arrtty/preprocess/parse"
 	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
@@ -15,8 +17,8 @@ func TestAnalyze_1(t *testing.T) {
 		var hello string = "hello, "
 		return hello + name + "!"
 	}
-	func sayHello(name string) {
-		fmt.printf(sayHelloS(name))
+	func sayHello(name string) string {
+		return sayHelloS(name)
 	}
 	func sub(x int, y int) (int, bool) {
 		var isMinus bool
@@ -30,6 +32,14 @@ func TestAnalyze_1(t *testing.T) {
 		}
 		return z, isMinus
 	}
+	func sum(n int) int {
+		s := 0
+		for i := 0; i < n; i++ {
+			s += i
+		}
+		s *= 2
+		return s
+	}
 	`
 	head, err := tokenize.Tokenize(code)
 	if err != nil {
@@ -46,3 +56,759 @@ func TestAnalyze_1(t *testing.T) {
 
 	fmt.Println(sem)
 }
+
+func TestAnalyze_Scope(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		ok   bool
+	}{
+		{
+			"shadowing",
+			`func f(x int) int {
+				y := x
+				if 0 < x {
+					x := 2
+					y += x
+				}
+				{
+					y := 3
+					x = y
+				}
+				return x + y
+			}`,
+			true,
+		},
+		{
+			"redeclared",
+			`func f() int {
+				x := 1
+				var x int
+				return x
+			}`,
+			false,
+		},
+		{
+			"redeclared param",
+			`func f(x int) int {
+				x := 1
+				return x
+			}`,
+			false,
+		},
+		{
+			"unused",
+			`func f() int {
+				x := 1
+				x = 2
+				return 0
+			}`,
+			false,
+		},
+		{
+			"unused in block",
+			`func f(x int) int {
+				for i := 0; i < x; i++ {
+					y := i
+				}
+				return x
+			}`,
+			false,
+		},
+		{
+			"out of scope",
+			`func f(x int) int {
+				if 0 < x {
+					y := x
+					y++
+				}
+				return y
+			}`,
+			false,
+		},
+		{
+			"used by closure",
+			`func f() func() int {
+				x := 1
+				return func() int {
+					return x
+				}
+			}`,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head, err := tokenize.Tokenize(tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes, err := parse.Parse(head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = analyze.Analyze(nodes)
+			if tt.ok && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("エラーになるべきコードが解析できてしまいました")
+			}
+		})
+	}
+}
+
+func TestAnalyze_Flow(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		ok   bool
+	}{
+		{
+			"if else returns",
+			`func sign(x int) int {
+				if x < 0 {
+					return -1
+				} else if x == 0 {
+					return 0
+				} else {
+					return 1
+				}
+			}`,
+			true,
+		},
+		{
+			"switch with default returns",
+			`func name(x int) string {
+				switch x {
+				case 0:
+					return "zero"
+				default:
+					return "other"
+				}
+			}`,
+			true,
+		},
+		{
+			"infinite loop",
+			`func f(x int) int {
+				for {
+					x++
+				}
+			}`,
+			true,
+		},
+		{
+			"missing return",
+			`func f(x int) int {
+				if 0 < x {
+					return x
+				}
+			}`,
+			false,
+		},
+		{
+			"missing return without default",
+			`func f(x int) int {
+				switch x {
+				case 0:
+					return 1
+				}
+			}`,
+			false,
+		},
+		{
+			"unreachable",
+			`func f(x int) int {
+				return x
+				x++
+			}`,
+			false,
+		},
+		{
+			"unreachable after if",
+			`func f(x int) int {
+				if 0 < x {
+					return 1
+				} else {
+					return 2
+				}
+				return 3
+			}`,
+			false,
+		},
+		{
+			"return type in block",
+			`func f(x int) int {
+				{
+					return "x"
+				}
+			}`,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head, err := tokenize.Tokenize(tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes, err := parse.Parse(head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = analyze.Analyze(nodes)
+			if tt.ok && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("エラーになるべきコードが解析できてしまいました")
+			}
+		})
+	}
+}
+
+func TestAnalyze_Declarations(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		ok   bool
+	}{
+		{
+			"mutual recursion",
+			`func even(n int) bool {
+				if n == 0 {
+					return true
+				}
+				return odd(n - 1)
+			}
+
+			func odd(n int) bool {
+				if n == 0 {
+					return false
+				}
+				return even(n - 1)
+			}`,
+			true,
+		},
+		{
+			"types, constants and globals declared later",
+			`func main() int {
+				var ps [N]Point
+				return len(ps) + ps[0].next.x + g
+			}
+
+			var g int = M
+
+			const N = M + 1
+			const M = 2
+
+			type Point struct {
+				next Pair
+			}
+
+			type Pair struct {
+				x int
+			}`,
+			true,
+		},
+		{
+			"method declared later",
+			`func main() int {
+				p := Point{x: 1}
+				return p.Get()
+			}
+
+			func (p Point) Get() int {
+				return p.x
+			}
+
+			type Point struct {
+				x int
+			}`,
+			true,
+		},
+		{
+			"constant cycle",
+			`const A = B + 1
+			const B = A * 2`,
+			false,
+		},
+		{
+			"mixed constant",
+			`const F float = 1 + 2.5
+			const G = F > 3`,
+			true,
+		},
+		{
+			"mixed constant to int",
+			`const I int = 2 * 1.5`,
+			false,
+		},
+		{
+			"constant expression in a global initializer",
+			`const L = 3
+
+			var G int = L * 2
+			var H int = 2 * 3
+			var S string = "n" + string(L)`,
+			true,
+		},
+		{
+			"variable in a global initializer",
+			`var G int = 1
+			var H int = G * 2`,
+			false,
+		},
+		{
+			"constant expression as an array length",
+			`const L = 3
+
+			var xs [L*2]int
+			var ys [(L + 1) % 3]int`,
+			true,
+		},
+		{
+			"negative array length",
+			`const L = 3
+
+			var xs [1 - L]int`,
+			false,
+		},
+		{
+			"compare structs",
+			`type P struct {
+				x int
+			}
+
+			func main() int {
+				a := P{x: 1}
+				b := P{x: 1}
+				if a == b {
+					return 1
+				}
+				return 0
+			}`,
+			false,
+		},
+		{
+			"compare struct fields",
+			`type P struct {
+				x int
+			}
+
+			func main() int {
+				a := P{x: 1}
+				b := P{x: 1}
+				if a.x == b.x {
+					return 1
+				}
+				return 0
+			}`,
+			true,
+		},
+		{
+			"nested function types",
+			`func outer() func() func() int {
+				return func() func() int {
+					return func() int {
+						return 1
+					}
+				}
+			}
+
+			func apply(f func() func() int) int {
+				return f()()
+			}
+
+			func main() int {
+				var f func() func() int
+				f = outer()
+				return apply(f)
+			}`,
+			true,
+		},
+		{
+			"nested function type with a wrong return value",
+			`func outer() func() func() int {
+				return func() int {
+					return 1
+				}
+			}`,
+			false,
+		},
+		{
+			"init",
+			`var N int
+
+			func init() {
+				N = 1
+			}`,
+			true,
+		},
+		{
+			"init with return value",
+			`func init() int {
+				return 1
+			}`,
+			false,
+		},
+		{
+			"call init",
+			`func init() {
+			}
+
+			func main() int {
+				init()
+				return 0
+			}`,
+			false,
+		},
+		{
+			"duplicate function",
+			`func f() int {
+				return 1
+			}
+
+			func f() int {
+				return 2
+			}`,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head, err := tokenize.Tokenize(tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes, err := parse.Parse(head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = analyze.Analyze(nodes)
+			if tt.ok && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("エラーになるべきコードが解析できてしまいました")
+			}
+		})
+	}
+}
+
+func TestAnalyze_Equality(t *testing.T) {
+	tests := []struct {
+		name  string
+		expr  string
+		codes []string
+	}{
+		{"int and float", "1 == 1.5", nil},
+		{"strings", "\"a\" != \"b\"", nil},
+		{"bools", "true == false", nil},
+		{"structs", "P{x: 1} == P{x: 1}", []string{"A0106"}},
+		{"arrays", "[2]int{1, 2} != [2]int{1, 2}", []string{"A0106"}},
+		{"slices", "[]int{1} == []int{1}", []string{"A0106"}},
+		{"maps", "map[string]int{} == map[string]int{}", []string{"A0106"}},
+		{"functions", "f == f", []string{"A0106"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := "type P struct {\n\tx int\n}\n\nfunc f() int {\n\treturn 0\n}\n\n" +
+				"func main() int {\n\tb := " + tt.expr + "\n\tif b {\n\t\treturn 1\n\t}\n\treturn 0\n}"
+			head, err := tokenize.Tokenize(code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes, err := parse.Parse(head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = analyze.Analyze(nodes)
+			var codes []string
+			for _, d := range diagnostic.Flatten(err) {
+				codes = append(codes, d.Code)
+			}
+			if fmt.Sprint(codes) != fmt.Sprint(tt.codes) {
+				t.Fatalf("報告されたエラーが正しくありません: %v", err)
+			}
+		})
+	}
+}
+
+func TestAnalyze_IR(t *testing.T) {
+	code := `
+	var g int = 3
+	func add(x int, y int) int {
+		z := x + y
+		return z + g
+	}
+	func main() int {
+		return add(1, 2)
+	}
+	`
+	head, err := tokenize.Tokenize(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := parse.Parse(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sem, err := analyze.Analyze(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog := sem.Program
+
+	if len(prog.Globals) != 1 || prog.Globals[0].Symbol.Kind != ir.Global {
+		t.Fatalf("グローバル変数が正しくありません: %v", prog.Globals)
+	}
+	if len(prog.Functions) != 2 {
+		t.Fatalf("関数の数が正しくありません: %d", len(prog.Functions))
+	}
+	add := prog.Functions[0]
+	if add.Symbol.Name != "add" || len(add.Params) != 2 || add.Frame != 3 {
+		t.Fatalf("関数addが正しくありません: %v params=%d frame=%d", add.Symbol.Name, len(add.Params), add.Frame)
+	}
+	for i, p := range add.Params {
+		if p.Kind != ir.Param || p.Slot != i+1 || p.Type.Type != parse.Int {
+			t.Errorf("引数%dが正しくありません: %+v", i, p)
+		}
+	}
+
+	// z := x + y
+	decl := add.Body[0]
+	if decl.Kind != ir.Decl || decl.Symbol.Kind != ir.Local || decl.Symbol.Slot != 3 {
+		t.Fatalf("変数zの宣言が正しくありません: %v", decl)
+	}
+	sum := decl.DeclField.Value
+	if sum.Kind != ir.Binary || sum.Type().Type != parse.Int || sum.BinaryField.Lhs.Symbol != add.Params[0] {
+		t.Errorf("x + yが正しくありません: %v", sum)
+	}
+
+	// return z + g
+	ret := add.Body[1].ReturnField.Values[0]
+	if ret.BinaryField.Lhs.Symbol != decl.Symbol || ret.BinaryField.Rhs.Symbol != prog.Globals[0].Symbol {
+		t.Errorf("z + gの参照先が正しくありません: %v", ret)
+	}
+
+	// return add(1, 2)
+	call := prog.Functions[1].Body[0].ReturnField.Values[0]
+	if call.Kind != ir.Call || call.Symbol != add.Symbol || call.Type().Type != parse.Int {
+		t.Errorf("add(1, 2)が正しくありません: %v", call)
+	}
+}
+
+func TestAnalyze_Diagnostics(t *testing.T) {
+	code := `func f(x int) int {
+	y := undefined + 1
+	z := y * 2
+	return "x"
+}
+func g() {
+	var s string = 1
+	s = "a"
+}
+const C = 1 + missing
+var G unknown
+func h() int {
+	a := 1
+	b := a + undefined
+	return b + C + G + broken()
+}
+func broken() unknown {
+	return 0
+}
+func main() int {
+	return f(1) + h()
+}
+var V int = later()
+func later() int {
+	return 1
+}
+type Q struct {
+	p P
+}
+type P struct {
+	x missing
+}
+func k(p P) int {
+	return p.x
+}
+func l(q Q) int {
+	return q.p.x
+}
+func m(a missing) int {
+	return 0
+}
+`
+	head, err := tokenize.Tokenize(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := parse.Parse(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = analyze.Analyze(nodes)
+	var got []string
+	for _, d := range diagnostic.Flatten(err) {
+		got = append(got, fmt.Sprintf("%d:%d %s", d.Span.Line, d.Span.Col, d.Code))
+	}
+	// yの宣言のエラーが原因となるzの宣言は改めて報告しない
+	// エラーのあった文で参照したaや、エラーのあった宣言のsは使用したことにする
+	// エラーのあった定数、グローバル変数、関数を参照しても改めて報告しない
+	// グローバル変数の初期化で後に宣言された関数を呼び出すと、定義されていないのではなく定数でないことを報告する
+	// 型のエラーは型の位置に報告し、エラーのあった型やそれをフィールドに持つ型を使用しても改めて報告しない
+	want := "[2:6 A0038 4:1 A0025 7:1 A0029 10:6 A0087 11:6 A0004 14:10 A0038 17:14 A0004 23:0 A0080 31:3 A0004 39:9 A0004]"
+	if fmt.Sprint(got) != want {
+		t.Fatalf("報告されたエラーが正しくありません: %v", err)
+	}
+}
+
+func TestAnalyze_Imports(t *testing.T) {
+	// parseFiles ファイルごとに読み、一つのパッケージとして並べる
+	parseFiles := func(t *testing.T, files ...string) []*parse.Node {
+		var nodes []*parse.Node
+		for i, code := range files {
+			head, err := tokenize.TokenizeFile(fmt.Sprintf("%d.arr", i), code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := parse.Parse(head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes = append(nodes, n...)
+		}
+		return nodes
+	}
+	lib, err := analyze.Analyze(parseFiles(t, "func F() int {\n\treturn 1\n}\n\nfunc g() int {\n\treturn 2\n}\n\ntype point struct {\n\tx int\n}\n\ntype Point struct {\n\tX int\n\ty int\n}\n\nfunc (p Point) Sum() int {\n\treturn p.X + p.y\n}\n\nfunc (p Point) scale() int {\n\treturn p.X * p.y\n}\n\nconst K = 4\n\nconst k = 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &analyze.Package{Name: "util", Path: "a/util", Semantics: lib}
+
+	tests := []struct {
+		name  string
+		files []string
+		codes []string
+	}{
+		{
+			"alias",
+			[]string{"import u \"a/util\"\n\nfunc main() int {\n\treturn u.F()\n}"},
+			nil,
+		},
+		{
+			"grouped",
+			[]string{"import (\n\t\"a/util\"\n)\n\nfunc main() int {\n\treturn util.F()\n}"},
+			nil,
+		},
+		{
+			"unused",
+			[]string{"import \"a/util\"\n\nfunc main() int {\n\treturn 0\n}"},
+			[]string{"1:7 A0099"},
+		},
+		{
+			"imported only in another file",
+			[]string{
+				"import \"a/util\"\n\nfunc main() int {\n\treturn g()\n}",
+				"func g() int {\n\treturn util.F()\n}",
+			},
+			[]string{"1:7 A0099", "2:8 A0100"},
+		},
+		{
+			"used in a failed statement",
+			[]string{"import \"a/util\"\n\nfunc main() int {\n\ty := undefined + util.F()\n\treturn y\n}"},
+			[]string{"4:6 A0038"},
+		},
+		{
+			"duplicate name",
+			[]string{"import (\n\t\"a/util\"\n\tutil \"a/util\"\n)\n\nfunc main() int {\n\treturn util.F()\n}"},
+			[]string{"3:6 A0098"},
+		},
+		{
+			"not imported",
+			[]string{"func main() int {\n\tfmt.Println(1)\n\treturn 0\n}"},
+			[]string{"2:1 A0102"},
+		},
+		{
+			"package not given to the analyzer",
+			[]string{"import \"a/other\"\n\nfunc main() int {\n\treturn other.F()\n}"},
+			[]string{"4:8 A0102"},
+		},
+		{
+			"unexported function",
+			[]string{"import \"a/util\"\n\nfunc main() int {\n\treturn util.g()\n}"},
+			[]string{"4:8 A0101"},
+		},
+		{
+			"unexported type",
+			[]string{"import \"a/util\"\n\nfunc main() int {\n\tp := util.point{x: 1}\n\treturn p.x\n}"},
+			[]string{"4:6 A0101"},
+		},
+		{
+			"imported constant in constant expressions",
+			[]string{"import \"a/util\"\n\nconst Z = util.K * 2\n\nvar G int = util.K + Z\n\nvar xs [util.K]int\n\nfunc main() int {\n\treturn G + len(xs)\n}"},
+			nil,
+		},
+		{
+			"unexported constant in a constant expression",
+			[]string{"import \"a/util\"\n\nconst Z = util.k * 2\n\nfunc main() int {\n\treturn Z\n}"},
+			[]string{"3:6 A0101"},
+		},
+		{
+			"unexported type name",
+			[]string{"import \"a/util\"\n\nvar q util.point\n\nfunc main() int {\n\treturn 0\n}"},
+			[]string{"3:6 A0101"},
+		},
+		{
+			"undefined type name",
+			[]string{"import \"a/util\"\n\nvar q util.Line\n\nfunc main() int {\n\treturn 0\n}"},
+			[]string{"3:6 A0097"},
+		},
+		{
+			"exported type",
+			[]string{"import \"a/util\"\n\nvar q util.Point\n\nfunc main() int {\n\tq = util.Point{X: 4}\n\treturn q.X + q.Sum()\n}"},
+			nil,
+		},
+		{
+			"unexported field",
+			[]string{"import \"a/util\"\n\nfunc main() int {\n\tp := util.Point{X: 4}\n\treturn 1 + p.y\n}"},
+			[]string{"5:14 A0103"},
+		},
+		{
+			"assignment to unexported field",
+			[]string{"import \"a/util\"\n\nfunc main() int {\n\tp := util.Point{X: 4}\n\tp.y = 3\n\treturn p.X\n}"},
+			[]string{"5:3 A0103"},
+		},
+		{
+			"unexported field in literal",
+			[]string{"import \"a/util\"\n\nfunc main() int {\n\tp := util.Point{y: 4}\n\treturn p.X\n}"},
+			[]string{"4:17 A0103"},
+		},
+		{
+			"unexported method",
+			[]string{"import \"a/util\"\n\nfunc main() int {\n\tp := util.Point{X: 4}\n\treturn p.scale()\n}"},
+			[]string{"5:10 A0103"},
+		},
+		{
+			"method on imported type",
+			[]string{"import \"a/util\"\n\nfunc (p util.Point) Twice() int {\n\treturn p.X * 2\n}\n\nfunc main() int {\n\treturn 0\n}"},
+			[]string{"3:20 A0017"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := analyze.Analyze(parseFiles(t, tt.files...), pkg)
+			var codes []string
+			for _, d := range diagnostic.Flatten(err) {
+				codes = append(codes, fmt.Sprintf("%d:%d %s", d.Span.Line, d.Span.Col, d.Code))
+			}
+			if fmt.Sprint(codes) != fmt.Sprint(tt.codes) {
+				t.Fatalf("報告されたエラーが正しくありません: %v", err)
+			}
+		})
+	}
+}
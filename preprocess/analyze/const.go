@@ -0,0 +1,383 @@
+package analyze
+
+import (
+	"errors"
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/preprocess/parse"
+	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
+	"strconv"
+)
+
+// Constant コンパイル時に評価された定数
+type Constant struct {
+	Literal  *tokenize.Literal
+	DataType *parse.DataType
+}
+
+type constState int
+
+const (
+	constPending constState = iota
+	constEvaluating
+	constEvaluated
+	constFailed
+)
+
+// declareConstant 定数の名前を登録する
+func (a *Analyzer) declareConstant(node *parse.Node) error {
+	name := node.ConstDeclField.Identifier.IdentField.Ident
+	if name == "_" {
+		return nil
+	}
+	if _, ok := a.declaredConstants[name]; ok {
+		return diagnostic.Errorf("A0082", name)
+	}
+	a.declaredConstants[name] = node
+	return nil
+}
+
+// evaluateConstant 宣言された定数をまだ評価していなければ評価する
+// エラーは定数の宣言の位置に報告し、参照した側にはerrReportedを返す
+func (a *Analyzer) evaluateConstant(node *parse.Node) error {
+	switch a.constStates[node] {
+	case constEvaluating:
+		return diagnostic.Errorf("A0095", node.ConstDeclField.Identifier.IdentField.Ident)
+	case constEvaluated:
+		return nil
+	case constFailed:
+		return errReported
+	}
+	a.constStates[node] = constEvaluating
+	if err := a.constDecl(node); err != nil {
+		a.constStates[node] = constFailed
+		a.report(node.ConstDeclField.Identifier, err)
+		return errReported
+	}
+	a.constStates[node] = constEvaluated
+	return nil
+}
+
+// lookupConstant 名前の定数を探す, 宣言のみの定数はその場で評価する
+func (a *Analyzer) lookupConstant(name string) (*Constant, bool, error) {
+	if c, ok := a.knownConstants[name]; ok {
+		return c, true, nil
+	}
+	node, ok := a.declaredConstants[name]
+	if !ok {
+		return nil, false, nil
+	}
+	if err := a.evaluateConstant(node); err != nil {
+		return nil, true, err
+	}
+	return a.knownConstants[name], true, nil
+}
+
+func (a *Analyzer) constDecl(node *parse.Node) error {
+	field := node.ConstDeclField
+	name := field.Identifier.IdentField.Ident
+	value, typ, err := a.evalConst(field.Value, field.Iota)
+	if err != nil {
+		var d *diagnostic.Diagnostic
+		if errors.As(err, &d) {
+			d.WithNote("N0004", name)
+		}
+		return err
+	}
+	if field.Type != nil {
+		t, err := a.resolveTypeNode(field.Type)
+		if err != nil {
+			return err
+		}
+		if t != typ {
+			return diagnostic.Errorf("A0084", name, t.Ident, typ.Ident)
+		}
+	}
+	if name != "_" {
+		a.knownConstants[name] = &Constant{Literal: value, DataType: typ}
+	}
+	return nil
+}
+
+// checkConstTarget 代入先が定数でないか
+// 定数はリテラルに置き換えられるので、置き換える前に調べる
+func (a *Analyzer) checkConstTarget(node *parse.Node, functionName string) error {
+	if node.Kind != parse.NdIdent {
+		return nil
+	}
+	name := node.IdentField.Ident
+	if _, _, ok := a.lookupTarget(functionName, name); ok {
+		return nil
+	}
+	if _, ok := a.knownConstants[name]; ok {
+		return diagnostic.Errorf("A0085", name)
+	}
+	return nil
+}
+
+// constValue 定数を参照するノードを値のリテラルに置き換える
+func (a *Analyzer) constValue(node *parse.Node) ([]*parse.DataType, bool) {
+	c, ok := a.knownConstants[node.IdentField.Ident]
+	if !ok {
+		return nil, false
+	}
+	*node = *parse.NewLiteralNode(node.Pos, c.Literal)
+	return dataTypes(c.DataType), true
+}
+
+// foldConst 定数式であれば評価した値のリテラルに置き換える
+// 定数式でなければそのままにする, 定数式の中のエラーは返す
+func (a *Analyzer) foldConst(node *parse.Node) error {
+	if node.Kind == parse.NdLiteral {
+		return nil
+	}
+	// iotaは定数の宣言の中でのみ使える
+	usesIota := false
+	parse.Inspect(node, func(n *parse.Node) bool {
+		if n.Kind == parse.NdIdent && n.IdentField.Ident == "iota" {
+			usesIota = true
+		}
+		return !usesIota
+	})
+	if usesIota {
+		return nil
+	}
+	v, _, err := a.evalConst(node, 0)
+	if notConstant(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	*node = *parse.NewLiteralNode(node.Pos, v)
+	return nil
+}
+
+func literalType(l *tokenize.Literal) (*parse.DataType, error) {
+	switch l.Kind {
+	case tokenize.LInt:
+		return parse.RuntimeInt, nil
+	case tokenize.LFloat:
+		return parse.RuntimeFloat, nil
+	case tokenize.LString:
+		return parse.RuntimeString, nil
+	case tokenize.LBool:
+		return parse.RuntimeBool, nil
+	}
+	return nil, diagnostic.Errorf("A0086")
+}
+
+// evalConst 定数式を評価する
+func (a *Analyzer) evalConst(node *parse.Node, iota int) (*tokenize.Literal, *parse.DataType, error) {
+	switch node.Kind {
+	case parse.NdLiteral:
+		typ, err := literalType(node.LiteralField.Literal)
+		if err != nil {
+			return nil, nil, err
+		}
+		return node.LiteralField.Literal, typ, nil
+	case parse.NdIdent:
+		switch name := node.IdentField.Ident; name {
+		case "iota":
+			return tokenize.NewIntLiteral(iota), parse.RuntimeInt, nil
+		case "true", "false":
+			return tokenize.NewBoolLiteral(name == "true"), parse.RuntimeBool, nil
+		default:
+			c, ok, err := a.lookupConstant(name)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !ok {
+				return nil, nil, diagnostic.Errorf("A0087", name)
+			}
+			return c.Literal, c.DataType, nil
+		}
+	case parse.NdPrefix:
+		// 他のパッケージの定数`パッケージ名.定数`
+		prefix, child := node.PrefixField.Prefix, node.PrefixField.Child
+		if child.Kind != parse.NdIdent {
+			break
+		}
+		name := child.IdentField.Ident
+		pkg, ok := a.importedAs(node, prefix)
+		if !ok {
+			return nil, nil, diagnostic.Errorf("A0087", prefix+"."+name)
+		}
+		if !IsExported(name) && declares(pkg.Semantics, name) {
+			return nil, nil, diagnostic.Errorf("A0101", prefix, name)
+		}
+		c, ok := pkg.Semantics.Constants[name]
+		if !ok {
+			return nil, nil, diagnostic.Errorf("A0087", prefix+"."+name)
+		}
+		return c.Literal, c.DataType, nil
+	case parse.NdParenthesis:
+		return a.evalConst(node.UnaryField.Value, iota)
+	case parse.NdNot:
+		v, typ, err := a.evalConst(node.UnaryField.Value, iota)
+		if err != nil {
+			return nil, nil, err
+		}
+		if typ != parse.RuntimeBool {
+			return nil, nil, diagnostic.Errorf("A0046", typ.Ident)
+		}
+		return tokenize.NewBoolLiteral(!v.B), typ, nil
+	case parse.NdAnd, parse.NdOr, parse.NdEq, parse.NdNe, parse.NdLt, parse.NdLe, parse.NdGt, parse.NdGe,
+		parse.NdAdd, parse.NdSub, parse.NdMul, parse.NdDiv, parse.NdMod:
+		lhs, lt, err := a.evalConst(node.BinaryField.Lhs, iota)
+		if err != nil {
+			return nil, nil, err
+		}
+		rhs, rt, err := a.evalConst(node.BinaryField.Rhs, iota)
+		if err != nil {
+			return nil, nil, err
+		}
+		// 式と同じく、intはfloatに昇格する
+		typ, ok := promote(dataTypes(lt), dataTypes(rt))
+		if !ok {
+			return nil, nil, diagnostic.Errorf("A0032", lt.Ident, rt.Ident)
+		}
+		return evalBinary(node.Kind, promoteLiteral(lhs, lt, typ[0]), promoteLiteral(rhs, rt, typ[0]), typ[0])
+	case parse.NdCall:
+		// 型変換のみ定数式の中で使用できる
+		callee, args := node.CallField.Identifier, node.CallField.Args
+		if callee.Kind != parse.NdIdent || args == nil || len(args.PolynomialField.Values) != 1 {
+			break
+		}
+		name := callee.IdentField.Ident
+		if name != "int" && name != "float" && name != "string" {
+			break
+		}
+		v, typ, err := a.evalConst(args.PolynomialField.Values[0], iota)
+		if err != nil {
+			return nil, nil, err
+		}
+		return evalConvert(name, v, typ)
+	}
+	return nil, nil, diagnostic.Errorf("A0088")
+}
+
+// promoteLiteral 昇格した型の値にする
+func promoteLiteral(v *tokenize.Literal, from, to *parse.DataType) *tokenize.Literal {
+	if from == parse.RuntimeInt && to == parse.RuntimeFloat {
+		return tokenize.NewFloatLiteral(float64(v.I))
+	}
+	return v
+}
+
+// evalConvert 実行時の変換と同じく、intへの変換は0方向に切り捨て、stringへの変換は10進数の表記にする
+func evalConvert(name string, v *tokenize.Literal, typ *parse.DataType) (*tokenize.Literal, *parse.DataType, error) {
+	switch {
+	case name == "int" && typ == parse.RuntimeInt, name == "float" && typ == parse.RuntimeFloat:
+		return v, typ, nil
+	case name == "int" && typ == parse.RuntimeFloat:
+		return tokenize.NewIntLiteral(int(v.F)), parse.RuntimeInt, nil
+	case name == "float" && typ == parse.RuntimeInt:
+		return tokenize.NewFloatLiteral(float64(v.I)), parse.RuntimeFloat, nil
+	case name == "string" && typ == parse.RuntimeInt:
+		return tokenize.NewStringLiteral(strconv.Itoa(v.I)), parse.RuntimeString, nil
+	case name == "string" && typ == parse.RuntimeFloat:
+		return tokenize.NewStringLiteral(strconv.FormatFloat(v.F, 'g', -1, 64)), parse.RuntimeString, nil
+	case name == "string" && typ == parse.RuntimeString:
+		return v, typ, nil
+	}
+	if name == "string" {
+		return nil, nil, diagnostic.Errorf("A0069")
+	}
+	return nil, nil, diagnostic.Errorf("A0068", name)
+}
+
+func evalBinary(kind parse.NodeKind, lhs, rhs *tokenize.Literal, typ *parse.DataType) (*tokenize.Literal, *parse.DataType, error) {
+	switch kind {
+	case parse.NdAnd, parse.NdOr:
+		if typ != parse.RuntimeBool {
+			return nil, nil, diagnostic.Errorf("A0041", typ.Ident, typ.Ident)
+		}
+		if kind == parse.NdAnd {
+			return tokenize.NewBoolLiteral(lhs.B && rhs.B), typ, nil
+		}
+		return tokenize.NewBoolLiteral(lhs.B || rhs.B), typ, nil
+	case parse.NdEq, parse.NdNe:
+		var eq bool
+		switch typ {
+		case parse.RuntimeInt:
+			eq = lhs.I == rhs.I
+		case parse.RuntimeFloat:
+			eq = lhs.F == rhs.F
+		case parse.RuntimeString:
+			eq = lhs.S == rhs.S
+		case parse.RuntimeBool:
+			eq = lhs.B == rhs.B
+		}
+		if kind == parse.NdNe {
+			eq = !eq
+		}
+		return tokenize.NewBoolLiteral(eq), parse.RuntimeBool, nil
+	case parse.NdLt, parse.NdLe, parse.NdGt, parse.NdGe:
+		var cmp int
+		switch typ {
+		case parse.RuntimeInt:
+			cmp = compare(lhs.I, rhs.I)
+		case parse.RuntimeFloat:
+			cmp = compare(lhs.F, rhs.F)
+		default:
+			return nil, nil, diagnostic.Errorf("A0044", typ.Ident, typ.Ident)
+		}
+		result := map[parse.NodeKind]bool{
+			parse.NdLt: cmp < 0,
+			parse.NdLe: cmp <= 0,
+			parse.NdGt: cmp > 0,
+			parse.NdGe: cmp >= 0,
+		}[kind]
+		return tokenize.NewBoolLiteral(result), parse.RuntimeBool, nil
+	}
+
+	switch typ {
+	case parse.RuntimeInt:
+		switch kind {
+		case parse.NdAdd:
+			return tokenize.NewIntLiteral(lhs.I + rhs.I), typ, nil
+		case parse.NdSub:
+			return tokenize.NewIntLiteral(lhs.I - rhs.I), typ, nil
+		case parse.NdMul:
+			return tokenize.NewIntLiteral(lhs.I * rhs.I), typ, nil
+		case parse.NdDiv, parse.NdMod:
+			if rhs.I == 0 {
+				return nil, nil, diagnostic.Errorf("A0089")
+			}
+			if kind == parse.NdDiv {
+				return tokenize.NewIntLiteral(lhs.I / rhs.I), typ, nil
+			}
+			return tokenize.NewIntLiteral(lhs.I % rhs.I), typ, nil
+		}
+	case parse.RuntimeFloat:
+		switch kind {
+		case parse.NdAdd:
+			return tokenize.NewFloatLiteral(lhs.F + rhs.F), typ, nil
+		case parse.NdSub:
+			return tokenize.NewFloatLiteral(lhs.F - rhs.F), typ, nil
+		case parse.NdMul:
+			return tokenize.NewFloatLiteral(lhs.F * rhs.F), typ, nil
+		case parse.NdDiv:
+			if rhs.F == 0 {
+				return nil, nil, diagnostic.Errorf("A0089")
+			}
+			return tokenize.NewFloatLiteral(lhs.F / rhs.F), typ, nil
+		}
+	case parse.RuntimeString:
+		if kind == parse.NdAdd {
+			return tokenize.NewStringLiteral(lhs.S + rhs.S), typ, nil
+		}
+	}
+	return nil, nil, diagnostic.Errorf("A0090", typ.Ident)
+}
+
+// compare intはfloat64を経由すると精度が落ちるので、それぞれの型のまま比較する
+func compare[T int | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package analyze
+
+import (
+	"errors"
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/preprocess/parse"
+	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
+	"sort"
+)
+
+// errReported 既に報告したエラーが原因で続けられない, 改めて報告はしない
+var errReported = errors.New("reported")
+
+// spanAt 位置から名前の長さの範囲
+func spanAt(pos *tokenize.Position, name string) *diagnostic.Span {
+	if pos == nil {
+		return nil
+	}
+	return pos.Span(len([]rune(name)))
+}
+
+// spanOf ノードの範囲, 識別子以外は先頭の1文字とする
+func spanOf(node *parse.Node) *diagnostic.Span {
+	if node == nil || node.Pos == nil {
+		return nil
+	}
+	if node.Kind == parse.NdIdent {
+		return spanAt(node.Pos, node.IdentField.Ident)
+	}
+	return node.Pos.Span(1)
+}
+
+// fieldSpan フィールドアクセスのフィールド名の範囲
+func fieldSpan(node *parse.Node) *diagnostic.Span {
+	if node.AccessField.FieldPos == nil {
+		return spanOf(node)
+	}
+	return spanAt(node.AccessField.FieldPos, node.AccessField.Field)
+}
+
+// at 位置を持たない診断にノードの位置を付ける
+func at(node *parse.Node, err error) error {
+	return withSpan(spanOf(node), err)
+}
+
+// withSpan 位置を持たない診断にspanを付ける
+func withSpan(span *diagnostic.Span, err error) error {
+	if err == nil || errors.Is(err, errReported) {
+		return err
+	}
+	var d *diagnostic.Diagnostic
+	if !errors.As(err, &d) {
+		return err
+	}
+	if d.Span == nil {
+		d.Span = span
+	}
+	return d
+}
+
+// notConstant 式が定数式でないことによるエラーか, 定数式の中の他のエラーとは区別する
+func notConstant(err error) bool {
+	var d *diagnostic.Diagnostic
+	return errors.As(err, &d) && (d.Code == "A0087" || d.Code == "A0088")
+}
+
+// report エラーを記録して解析を続ける
+func (a *Analyzer) report(node *parse.Node, err error) {
+	if err == nil || errors.Is(err, errReported) {
+		return
+	}
+	a.diagnostics.Add(err, spanOf(node))
+}
+
+// sortDiagnostics 報告した順ではなく、ソース上の位置の順に並べる
+func (a *Analyzer) sortDiagnostics() {
+	sort.SliceStable(a.diagnostics, func(i, j int) bool {
+		l, r := a.diagnostics[i].Span, a.diagnostics[j].Span
+		if l == nil || r == nil {
+			return l != nil
+		}
+		if l.Line != r.Line {
+			return l.Line < r.Line
+		}
+		return l.Col < r.Col
+	})
+}
@@ -0,0 +1,51 @@
+package analyze
+
+import (
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/preprocess/parse"
+)
+
+// terminates 文の実行が次の文に進まずに必ず関数から抜けるか
+func terminates(node *parse.Node) bool {
+	switch node.Kind {
+	case parse.NdReturn:
+		return true
+	case parse.NdBlock:
+		return terminatesAll(node.BlockField.Statements)
+	case parse.NdIfElse:
+		field := node.IfElseField
+		return field.UseElse && terminates(field.IfBlock) && terminates(field.ElseBlock)
+	case parse.NdFor:
+		// 抜け出す手段がないので、条件のないループは終わらない
+		return node.ForField.Cond == nil
+	case parse.NdSwitch:
+		// defaultがなければ、どのcaseにも合致せずに次の文に進むことがある
+		hasDefault := false
+		for _, c := range node.SwitchField.Cases {
+			if c.CaseField.IsDefault {
+				hasDefault = true
+			}
+			if !terminates(c.CaseField.Body) {
+				return false
+			}
+		}
+		return hasDefault
+	}
+	return false
+}
+
+// terminatesAll 文の並びの最後の文が必ず関数から抜けるか
+func terminatesAll(nodes []*parse.Node) bool {
+	return len(nodes) != 0 && terminates(nodes[len(nodes)-1])
+}
+
+// checkReachable 関数から抜ける文の後ろに文があればエラーにする
+func checkReachable(nodes []*parse.Node) error {
+	for i := 0; i+1 < len(nodes); i++ {
+		if terminates(nodes[i]) {
+			return diagnostic.New(spanOf(nodes[i+1]), "A0091").
+				WithRelated(spanOf(nodes[i]), "N0002")
+		}
+	}
+	return nil
+}
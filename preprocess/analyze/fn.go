@@ -1,8 +1,17 @@
 package analyze
 
-import "github.com/arrietty-lang/arrtty/preprocess/parse"
+import (
+	"github.com/arrietty-lang/arrtty/ir"
+	"github.com/arrietty-lang/arrtty/preprocess/parse"
+)
 
 type FnDataType struct {
 	Params  []*parse.DataType
 	Returns []*parse.DataType
+	// Captures 無名関数が外側の関数から受け取る変数(受け取る順)
+	Captures []string
+	// Scope 引数と本文のスコープ, 親はキャプチャした変数のスコープ
+	Scope *Scope
+	// Symbol 関数名を参照する識別子が解決される先
+	Symbol *ir.Symbol
 }
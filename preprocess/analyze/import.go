@@ -0,0 +1,192 @@
+package analyze
+
+import (
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/preprocess/parse"
+	"strings"
+)
+
+// importedPackage importで名前を付けたパッケージ
+type importedPackage struct {
+	pkg  *Package
+	node *parse.Node
+	used bool
+}
+
+// fileOf ノードがあるファイル
+func fileOf(node *parse.Node) string {
+	if node.Pos == nil {
+		return ""
+	}
+	return node.Pos.File
+}
+
+// importSpan インポートパスの文字列の範囲
+func importSpan(node *parse.Node) *diagnostic.Span {
+	if node.Pos == nil {
+		return nil
+	}
+	return node.Pos.Span(len([]rune(node.ImportField.Target)) + 2)
+}
+
+// declareImports importをファイルごとの表に登録する
+// 解析器に渡されていないパッケージのimportは無視し、その参照は定義されていない名前として報告する
+func (a *Analyzer) declareImports(nodes []*parse.Node) {
+	a.fileImports = map[string]map[string]*importedPackage{}
+	for _, node := range parse.Imports(nodes) {
+		pkg, ok := a.packages[node.ImportField.Target]
+		if !ok {
+			continue
+		}
+		name := node.ImportField.Name()
+		// `_`で受け取ったパッケージは参照できない
+		if name == "_" {
+			continue
+		}
+		file := fileOf(node)
+		table, ok := a.fileImports[file]
+		if !ok {
+			table = map[string]*importedPackage{}
+			a.fileImports[file] = table
+		}
+		if prev, ok := table[name]; ok {
+			d := diagnostic.New(importSpan(node), "A0098", name)
+			a.diagnostics = append(a.diagnostics, d.WithRelated(importSpan(prev.node), "N0003"))
+			continue
+		}
+		table[name] = &importedPackage{pkg: pkg, node: node}
+	}
+}
+
+// importedAs ノードがあるファイルでその名前を付けたパッケージ
+func (a *Analyzer) importedAs(node *parse.Node, name string) (*Package, bool) {
+	return a.importedIn(fileOf(node), name)
+}
+
+// importedIn ファイルでその名前を付けたパッケージ
+func (a *Analyzer) importedIn(file string, name string) (*Package, bool) {
+	imported, ok := a.fileImports[file][name]
+	if !ok {
+		return nil, false
+	}
+	imported.used = true
+	return imported.pkg, true
+}
+
+// importedElsewhere 他のファイルでその名前を付けたパッケージがあるか
+func (a *Analyzer) importedElsewhere(name string) bool {
+	for _, table := range a.fileImports {
+		if _, ok := table[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// unusedImports 一度も参照されなかったimportを報告する
+func (a *Analyzer) unusedImports(nodes []*parse.Node) {
+	for _, node := range parse.Imports(nodes) {
+		imported, ok := a.fileImports[fileOf(node)][node.ImportField.Name()]
+		if ok && imported.node == node && !imported.used {
+			a.diagnostics = append(a.diagnostics, diagnostic.New(importSpan(node), "A0099", node.ImportField.Target))
+		}
+	}
+}
+
+// importType 他のパッケージの宣言にある型を、このパッケージで使う型に置き換える
+// 構造体、インターフェースは定義したパッケージと名前ごとに一つの`パッケージ名.型名`の型にまとめ、
+// 複合型は置き換えた要素から作り直すので、このパッケージの型と同じように比較できる
+func (a *Analyzer) importType(pkg *Package, typ *parse.DataType) *parse.DataType {
+	if typ == nil {
+		return nil
+	}
+	switch typ.Type {
+	case parse.Array, parse.Slice:
+		return a.compositeType(typ.Type, a.importType(pkg, typ.Base), typ.Len)
+	case parse.Map:
+		return a.mapType(a.importType(pkg, typ.Key), a.importType(pkg, typ.Base))
+	case parse.Func:
+		return a.funcType(a.importTypes(pkg, typ.Params), a.importTypes(pkg, typ.Returns))
+	case parse.Struct, parse.Interface:
+	default:
+		return typ
+	}
+	path, ident := pkg.Identifier(), pkg.Name+"."+typ.Ident
+	// インポートしたパッケージが、更に他のパッケージからインポートした型
+	if typ.Package != "" {
+		path, ident = typ.Package, typ.Ident
+	}
+	key := path + "." + typeName(ident)
+	if t, ok := a.importedTypes[key]; ok {
+		return t
+	}
+	t := &parse.DataType{Ident: ident, Type: typ.Type, Package: path}
+	// 自身を参照する型のために、中身より先に登録する
+	a.importedTypes[key] = t
+	for _, f := range typ.Fields {
+		t.Fields = append(t.Fields, &parse.StructField{Name: f.Name, DataType: a.importType(pkg, f.DataType)})
+	}
+	for _, m := range typ.Methods {
+		t.Methods = append(t.Methods, &parse.Method{Name: m.Name, DataType: a.importType(pkg, m.DataType)})
+	}
+	return t
+}
+
+// qualifiedType `パッケージ名.型名`の型を、ファイルでインポートしたパッケージから探す
+func (a *Analyzer) qualifiedType(file string, prefix string, name string) (*parse.DataType, error) {
+	pkg, ok := a.importedIn(file, prefix)
+	if !ok {
+		if a.importedElsewhere(prefix) {
+			return nil, diagnostic.Errorf("A0100", prefix)
+		}
+		return nil, diagnostic.Errorf("A0102", prefix)
+	}
+	typ, ok := pkg.Semantics.KnownTypes[name]
+	if !ok {
+		return nil, diagnostic.Errorf("A0097", prefix, name)
+	}
+	if !IsExported(name) {
+		return nil, diagnostic.Errorf("A0101", prefix, name)
+	}
+	return a.importType(pkg, typ), nil
+}
+
+func (a *Analyzer) importTypes(pkg *Package, types []*parse.DataType) []*parse.DataType {
+	var list []*parse.DataType
+	for _, t := range types {
+		list = append(list, a.importType(pkg, t))
+	}
+	return list
+}
+
+// typeName `パッケージ名.型名`の型名
+func typeName(ident string) string {
+	return ident[strings.LastIndex(ident, ".")+1:]
+}
+
+// importedMethod 他のパッケージの型のメソッドを、定義したパッケージの宣言から探す
+// 引数と戻り値の型はこのパッケージの型に置き換え、関数はリンク時に解決される外部のシンボルを指す
+func (a *Analyzer) importedMethod(typ *parse.DataType, name string) (*FnDataType, bool) {
+	pkg, ok := a.packages[typ.Package]
+	// 小文字で始まるメソッドは、他のパッケージのインターフェースを満たすことにもならない
+	if !ok || !IsExported(name) {
+		return nil, false
+	}
+	fn, ok := pkg.Semantics.KnownFunctions[typeName(typ.Ident)+"."+name]
+	if !ok {
+		return nil, false
+	}
+	return &FnDataType{
+		Params:  a.importTypes(pkg, fn.Params),
+		Returns: a.importTypes(pkg, fn.Returns),
+		Symbol:  externalSymbol(pkg.Identifier(), fn.Symbol),
+	}, true
+}
+
+// checkMemberExported 他のパッケージの型の、小文字で始まるフィールドやメソッドは参照できない
+func checkMemberExported(typ *parse.DataType, name string, span *diagnostic.Span) error {
+	if typ.Package != "" && !IsExported(name) {
+		return diagnostic.New(span, "A0103", typ.Ident, name)
+	}
+	return nil
+}
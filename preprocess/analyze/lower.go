@@ -0,0 +1,523 @@
+package analyze
+
+import (
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/ir"
+	"github.com/arrietty-lang/arrtty/preprocess/parse"
+	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
+)
+
+// rangeValues rangeの隠れた変数
+type rangeValues struct {
+	target *Value
+	keys   *Value
+	index  *Value
+}
+
+// typed 式の型を記録する
+func (a *Analyzer) typed(node *parse.Node, typ []*parse.DataType) []*parse.DataType {
+	a.types[node] = typ
+	return typ
+}
+
+// lower 解析を終えたトップレベルの宣言から、記録した型と識別子の解決先を使ってIRを作る
+func (a *Analyzer) lower(nodes []*parse.Node) (*ir.Program, error) {
+	a.program = &ir.Program{}
+	for _, node := range nodes {
+		switch node.Kind {
+		case parse.NdVarDecl:
+			a.program.Globals = append(a.program.Globals, declNode(node.Pos, a.globalSymbols[node.VarDeclField.Identifier.IdentField.Ident], nil))
+		case parse.NdAssign:
+			// 初期値は定数を置き換えたリテラル
+			value, err := a.lowerExpr(node.AssignField.Value)
+			if err != nil {
+				return nil, err
+			}
+			name := node.AssignField.To.VarDeclField.Identifier.IdentField.Ident
+			a.program.Globals = append(a.program.Globals, declNode(node.Pos, a.globalSymbols[name], value))
+		case parse.NdFuncDef:
+			if _, err := a.lowerFunction(node); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return a.program, nil
+}
+
+// lowerFunction 関数をプログラムに追加する, 本文の無名関数はその後ろに並ぶ
+func (a *Analyzer) lowerFunction(node *parse.Node) (*ir.Function, error) {
+	field := node.FuncDefField
+	fn := a.knownFunction[field.Identifier.IdentField.Ident]
+	f := &ir.Function{Symbol: fn.Symbol, Returns: fn.Returns}
+	a.program.Functions = append(a.program.Functions, f)
+
+	// 引数は本文のスコープの始めに宣言されている
+	for _, v := range fn.Scope.Values[:len(fn.Params)] {
+		f.Params = append(f.Params, v.Symbol)
+	}
+	captures := fn.Scope.Parent
+	for _, name := range fn.Captures {
+		f.Captures = append(f.Captures, captures.Local(name, nil).Symbol)
+	}
+	f.Frame = captures.allocate(1) - 1
+
+	body, err := a.lowerStmts(field.Body.BlockField.Statements)
+	if err != nil {
+		return nil, err
+	}
+	f.Body = body
+	return f, nil
+}
+
+func declNode(pos *tokenize.Position, sym *ir.Symbol, value *ir.Node) *ir.Node {
+	n := ir.NewNode(ir.Decl, pos, nil)
+	n.Symbol = sym
+	n.DeclField = &ir.DeclField{Value: value}
+	return n
+}
+
+func (a *Analyzer) lowerStmts(nodes []*parse.Node) ([]*ir.Node, error) {
+	var stmts []*ir.Node
+	for _, s := range nodes {
+		n, err := a.lowerStmt(s)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, n)
+	}
+	return stmts, nil
+}
+
+// lowerOptional 省略できる文
+func (a *Analyzer) lowerOptional(node *parse.Node) (*ir.Node, error) {
+	if node == nil {
+		return nil, nil
+	}
+	return a.lowerStmt(node)
+}
+
+var updateOps = map[parse.NodeKind]ir.Op{
+	parse.NdAddAssign: ir.OpAdd,
+	parse.NdSubAssign: ir.OpSub,
+	parse.NdMulAssign: ir.OpMul,
+	parse.NdDivAssign: ir.OpDiv,
+	parse.NdModAssign: ir.OpMod,
+	parse.NdInc:       ir.OpAdd,
+	parse.NdDec:       ir.OpSub,
+}
+
+func (a *Analyzer) lowerStmt(node *parse.Node) (*ir.Node, error) {
+	switch node.Kind {
+	case parse.NdReturn:
+		values, err := a.lowerExprs(node.PolynomialField.Values)
+		if err != nil {
+			return nil, err
+		}
+		n := ir.NewNode(ir.Return, node.Pos, nil)
+		n.ReturnField = &ir.ReturnField{Values: values}
+		return n, nil
+	case parse.NdVarDecl:
+		return declNode(node.Pos, a.symbols[node.VarDeclField.Identifier], nil), nil
+	case parse.NdShortVarDecl:
+		value, err := a.lowerExpr(node.ShortVarDeclField.Value)
+		if err != nil {
+			return nil, err
+		}
+		return declNode(node.Pos, a.symbols[node.ShortVarDeclField.Identifier], value), nil
+	case parse.NdAssign:
+		value, err := a.lowerExpr(node.AssignField.Value)
+		if err != nil {
+			return nil, err
+		}
+		to := node.AssignField.To
+		if to.Kind == parse.NdVarDecl {
+			return declNode(node.Pos, a.symbols[to.VarDeclField.Identifier], value), nil
+		}
+		target, err := a.lowerExpr(to)
+		if err != nil {
+			return nil, err
+		}
+		n := ir.NewNode(ir.Assign, node.Pos, nil)
+		n.AssignField = &ir.AssignField{Target: target, Value: value}
+		return n, nil
+	case parse.NdAddAssign, parse.NdSubAssign, parse.NdMulAssign, parse.NdDivAssign, parse.NdModAssign:
+		target, err := a.lowerExpr(node.AssignField.To)
+		if err != nil {
+			return nil, err
+		}
+		value, err := a.lowerExpr(node.AssignField.Value)
+		if err != nil {
+			return nil, err
+		}
+		n := ir.NewNode(ir.Update, node.Pos, nil)
+		n.AssignField = &ir.AssignField{Op: updateOps[node.Kind], Target: target, Value: value}
+		return n, nil
+	case parse.NdInc, parse.NdDec:
+		target, err := a.lowerExpr(node.UnaryField.Value)
+		if err != nil {
+			return nil, err
+		}
+		one := ir.NewNode(ir.Literal, node.Pos, dataTypes(parse.RuntimeInt))
+		one.Literal = tokenize.NewIntLiteral(1)
+		n := ir.NewNode(ir.Update, node.Pos, nil)
+		n.AssignField = &ir.AssignField{Op: updateOps[node.Kind], Target: target, Value: one}
+		return n, nil
+	case parse.NdMultiAssign, parse.NdMultiShortVarDecl:
+		field := node.MultiAssignField
+		value, err := a.lowerExpr(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		if field.Value.Kind == parse.NdIndex && field.Value.IndexField.CommaOk {
+			// 要素の値とキーが存在したかの2つの値になる
+			value.IndexField.CommaOk = true
+			value.Types = []*parse.DataType{value.Type(), parse.RuntimeBool}
+		}
+		targets := make([]*ir.Symbol, len(field.Targets))
+		for i, t := range field.Targets {
+			if t.IdentField.Ident != "_" {
+				targets[i] = a.symbols[t]
+			}
+		}
+		declare := field.New
+		if declare == nil {
+			declare = make([]bool, len(targets))
+		}
+		n := ir.NewNode(ir.MultiAssign, node.Pos, nil)
+		n.MultiAssignField = &ir.MultiAssignField{Targets: targets, Declare: declare, Value: value}
+		return n, nil
+	case parse.NdBlock:
+		stmts, err := a.lowerStmts(node.BlockField.Statements)
+		if err != nil {
+			return nil, err
+		}
+		n := ir.NewNode(ir.Block, node.Pos, nil)
+		n.BlockField = &ir.BlockField{Statements: stmts}
+		return n, nil
+	case parse.NdIfElse:
+		field := node.IfElseField
+		cond, err := a.lowerExpr(field.Cond)
+		if err != nil {
+			return nil, err
+		}
+		then, err := a.lowerStmt(field.IfBlock)
+		if err != nil {
+			return nil, err
+		}
+		var else_ *ir.Node
+		if field.UseElse {
+			if else_, err = a.lowerStmt(field.ElseBlock); err != nil {
+				return nil, err
+			}
+		}
+		n := ir.NewNode(ir.If, node.Pos, nil)
+		n.IfField = &ir.IfField{Cond: cond, Then: then, Else: else_}
+		return n, nil
+	case parse.NdSwitch:
+		return a.lowerSwitch(node)
+	case parse.NdFor:
+		field := node.ForField
+		init, err := a.lowerOptional(field.Init)
+		if err != nil {
+			return nil, err
+		}
+		var cond *ir.Node
+		if field.Cond != nil {
+			if cond, err = a.lowerExpr(field.Cond); err != nil {
+				return nil, err
+			}
+		}
+		loop, err := a.lowerOptional(field.Loop)
+		if err != nil {
+			return nil, err
+		}
+		body, err := a.lowerStmt(field.Body)
+		if err != nil {
+			return nil, err
+		}
+		n := ir.NewNode(ir.For, node.Pos, nil)
+		n.ForField = &ir.ForField{Init: init, Cond: cond, Loop: loop, Body: body}
+		return n, nil
+	case parse.NdForRange:
+		return a.lowerForRange(node)
+	}
+	value, err := a.lowerExpr(node)
+	if err != nil {
+		return nil, err
+	}
+	n := ir.NewNode(ir.ExprStmt, node.Pos, nil)
+	n.UnaryField = &ir.UnaryField{Value: value}
+	return n, nil
+}
+
+func (a *Analyzer) lowerSwitch(node *parse.Node) (*ir.Node, error) {
+	field := node.SwitchField
+	var tag *ir.Node
+	if field.Tag != nil {
+		t, err := a.lowerExpr(field.Tag)
+		if err != nil {
+			return nil, err
+		}
+		tag = t
+	}
+	var cases []*ir.CaseField
+	for _, c := range field.Cases {
+		values, err := a.lowerExprs(c.CaseField.Values)
+		if err != nil {
+			return nil, err
+		}
+		body, err := a.lowerStmt(c.CaseField.Body)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, &ir.CaseField{Values: values, Body: body, IsDefault: c.CaseField.IsDefault})
+	}
+	n := ir.NewNode(ir.Switch, node.Pos, nil)
+	n.SwitchField = &ir.SwitchField{Tag: tag, Cases: cases}
+	return n, nil
+}
+
+func (a *Analyzer) lowerForRange(node *parse.Node) (*ir.Node, error) {
+	field := node.ForRangeField
+	target, err := a.lowerExpr(field.Target)
+	if err != nil {
+		return nil, err
+	}
+	body, err := a.lowerStmt(field.Body)
+	if err != nil {
+		return nil, err
+	}
+	// `_`や省略された変数には格納しない
+	symbolOf := func(ident *parse.Node) *ir.Symbol {
+		if ident == nil || ident.IdentField.Ident == "_" {
+			return nil
+		}
+		return a.symbols[ident]
+	}
+	hidden := a.ranges[node]
+	n := ir.NewNode(ir.ForRange, node.Pos, nil)
+	n.ForRangeField = &ir.ForRangeField{
+		Target:   target,
+		Key:      symbolOf(field.Key),
+		Value:    symbolOf(field.Value),
+		Iterated: hidden.target.Symbol,
+		Position: hidden.index.Symbol,
+		Body:     body,
+	}
+	if hidden.keys != nil {
+		n.ForRangeField.Keys = hidden.keys.Symbol
+	}
+	return n, nil
+}
+
+func (a *Analyzer) lowerExprs(nodes []*parse.Node) ([]*ir.Node, error) {
+	var values []*ir.Node
+	for _, v := range nodes {
+		n, err := a.lowerExpr(v)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+var binaryOps = map[parse.NodeKind]ir.Op{
+	parse.NdAdd: ir.OpAdd,
+	parse.NdSub: ir.OpSub,
+	parse.NdMul: ir.OpMul,
+	parse.NdDiv: ir.OpDiv,
+	parse.NdMod: ir.OpMod,
+	parse.NdEq:  ir.OpEq,
+	parse.NdNe:  ir.OpNe,
+	parse.NdLt:  ir.OpLt,
+	parse.NdLe:  ir.OpLe,
+	parse.NdGt:  ir.OpGt,
+	parse.NdGe:  ir.OpGe,
+	parse.NdAnd: ir.OpAnd,
+	parse.NdOr:  ir.OpOr,
+}
+
+func (a *Analyzer) lowerExpr(node *parse.Node) (*ir.Node, error) {
+	typ := a.types[node]
+	if op, ok := binaryOps[node.Kind]; ok {
+		lhs, err := a.lowerExpr(node.BinaryField.Lhs)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := a.lowerExpr(node.BinaryField.Rhs)
+		if err != nil {
+			return nil, err
+		}
+		n := ir.NewNode(ir.Binary, node.Pos, typ)
+		n.BinaryField = &ir.BinaryField{Op: op, Lhs: lhs, Rhs: rhs}
+		return n, nil
+	}
+	switch node.Kind {
+	case parse.NdLiteral:
+		n := ir.NewNode(ir.Literal, node.Pos, typ)
+		n.Literal = node.LiteralField.Literal
+		return n, nil
+	case parse.NdIdent:
+		sym, ok := a.symbols[node]
+		if !ok {
+			break
+		}
+		kind := ir.Var
+		if sym.Kind == ir.Func {
+			kind = ir.FuncRef
+		}
+		n := ir.NewNode(kind, node.Pos, typ)
+		n.Symbol = sym
+		return n, nil
+	case parse.NdParenthesis:
+		return a.lowerExpr(node.UnaryField.Value)
+	case parse.NdNot:
+		value, err := a.lowerExpr(node.UnaryField.Value)
+		if err != nil {
+			return nil, err
+		}
+		n := ir.NewNode(ir.Not, node.Pos, typ)
+		n.UnaryField = &ir.UnaryField{Value: value}
+		return n, nil
+	case parse.NdAccess:
+		target, err := a.lowerExpr(node.AccessField.Target)
+		if err != nil {
+			return nil, err
+		}
+		n := ir.NewNode(ir.Field, node.Pos, typ)
+		n.FieldField = &ir.FieldField{Target: target, Index: node.AccessField.Index}
+		return n, nil
+	case parse.NdIndex:
+		target, err := a.lowerExpr(node.IndexField.Target)
+		if err != nil {
+			return nil, err
+		}
+		index, err := a.lowerExpr(node.IndexField.Index)
+		if err != nil {
+			return nil, err
+		}
+		n := ir.NewNode(ir.Index, node.Pos, typ)
+		n.IndexField = &ir.IndexField{Target: target, Index: index, Container: node.IndexField.Container}
+		return n, nil
+	case parse.NdSlice:
+		field := node.SliceField
+		target, err := a.lowerExpr(field.Target)
+		if err != nil {
+			return nil, err
+		}
+		n := ir.NewNode(ir.Slice, node.Pos, typ)
+		n.SliceField = &ir.SliceField{Target: target}
+		if field.Low != nil {
+			if n.SliceField.Low, err = a.lowerExpr(field.Low); err != nil {
+				return nil, err
+			}
+		}
+		if field.High != nil {
+			if n.SliceField.High, err = a.lowerExpr(field.High); err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
+	case parse.NdCall:
+		return a.lowerCall(node)
+	case parse.NdStructLit:
+		// フィールドの値は宣言された順に並べる
+		st := typ[0]
+		values := make([]*ir.Node, len(st.Fields))
+		for _, kv := range node.StructLitField.Fields {
+			v, err := a.lowerExpr(kv.KVField.Value)
+			if err != nil {
+				return nil, err
+			}
+			values[st.FieldIndex(kv.KVField.Key.IdentField.Ident)] = v
+		}
+		n := ir.NewNode(ir.StructLit, node.Pos, typ)
+		n.ListField = &ir.ListField{Values: values}
+		return n, nil
+	case parse.NdList:
+		values, err := a.lowerExprs(node.ListField.Values)
+		if err != nil {
+			return nil, err
+		}
+		n := ir.NewNode(ir.ListLit, node.Pos, typ)
+		n.ListField = &ir.ListField{Values: values}
+		return n, nil
+	case parse.NdDict:
+		n := ir.NewNode(ir.DictLit, node.Pos, typ)
+		n.DictField = &ir.DictField{}
+		for _, kv := range node.DictField.Entries {
+			key, err := a.lowerExpr(kv.KVField.Key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := a.lowerExpr(kv.KVField.Value)
+			if err != nil {
+				return nil, err
+			}
+			n.DictField.Keys = append(n.DictField.Keys, key)
+			n.DictField.Values = append(n.DictField.Values, value)
+		}
+		return n, nil
+	case parse.NdFuncLit:
+		f, err := a.lowerFunction(node)
+		if err != nil {
+			return nil, err
+		}
+		n := ir.NewNode(ir.FuncLit, node.Pos, typ)
+		n.FuncLitField = &ir.FuncLitField{Func: f, Captures: a.lambdaCaptures[node]}
+		return n, nil
+	case parse.NdConvert:
+		field := node.ConvertField
+		value, err := a.lowerExpr(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		n := ir.NewNode(ir.Convert, node.Pos, typ)
+		n.ConvertField = &ir.ConvertField{Value: value, From: field.From, To: field.To}
+		if field.From.Type != parse.Interface {
+			for _, m := range field.To.Methods {
+				fn, _ := a.method(field.From, m.Name)
+				n.ConvertField.Methods = append(n.ConvertField.Methods, fn.Symbol)
+			}
+		}
+		return n, nil
+	}
+	return nil, diagnostic.New(spanOf(node), "A0096", node.Kind)
+}
+
+func (a *Analyzer) lowerCall(node *parse.Node) (*ir.Node, error) {
+	field := node.CallField
+	var args []*ir.Node
+	if field.Args != nil {
+		values, err := a.lowerExprs(field.Args.PolynomialField.Values)
+		if err != nil {
+			return nil, err
+		}
+		args = values
+	}
+	typ := a.types[node]
+	switch {
+	case field.Method != "" || field.FuncType != nil:
+		callee, err := a.lowerExpr(field.Identifier)
+		if err != nil {
+			return nil, err
+		}
+		kind := ir.CallValue
+		if field.Method != "" {
+			kind = ir.CallMethod
+		}
+		n := ir.NewNode(kind, node.Pos, typ)
+		n.CallField = &ir.CallField{Callee: callee, Name: field.Method, Args: args, FuncType: field.FuncType}
+		return n, nil
+	case builtins[field.Identifier.IdentField.Ident]:
+		n := ir.NewNode(ir.Builtin, node.Pos, typ)
+		n.CallField = &ir.CallField{Name: field.Identifier.IdentField.Ident, Args: args}
+		return n, nil
+	}
+	sym := a.symbols[field.Identifier]
+	n := ir.NewNode(ir.Call, node.Pos, typ)
+	n.Symbol = sym
+	n.CallField = &ir.CallField{Args: args, FuncType: sym.Type}
+	return n, nil
+}
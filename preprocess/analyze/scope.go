@@ -0,0 +1,121 @@
+package analyze
+
+import (
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/arrietty-lang/arrtty/ir"
+	"github.com/arrietty-lang/arrtty/preprocess/parse"
+	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
+)
+
+// Scope ブロックごとに宣言された変数の表
+// 関数の引数と本文が一つのスコープになり、その親は無名関数がキャプチャした変数のスコープになる
+// ブロック、ifとelseの各節、for、switchの各caseはそれぞれ子のスコープを持つ
+type Scope struct {
+	Parent   *Scope
+	Children []*Scope
+	// Values 宣言された順の変数
+	Values []*Value
+}
+
+// Value スコープに宣言された変数
+type Value struct {
+	Name     string
+	DataType []*parse.DataType
+	// Symbol 変数を参照する識別子が解決される先, 領域は関数の解析を終えた時点で割り当てる
+	Symbol *ir.Symbol
+	// Pos 宣言された位置, 引数や隠れた変数ではnilになることがある
+	Pos  *tokenize.Position
+	used bool
+	// implicit 引数や隠れた変数など、使用されていなくてもエラーにしない変数
+	implicit bool
+}
+
+func newScope(parent *Scope) *Scope {
+	s := &Scope{Parent: parent}
+	if parent != nil {
+		parent.Children = append(parent.Children, s)
+	}
+	return s
+}
+
+// Local このスコープだけから名前の一致する変数を探す
+// visibleがnilでなければ、visibleを満たす変数のみを対象にする
+func (s *Scope) Local(name string, visible func(*Value) bool) *Value {
+	// 後に宣言されたものを優先する
+	for i := len(s.Values) - 1; 0 <= i; i-- {
+		v := s.Values[i]
+		if v.Name == name && (visible == nil || visible(v)) {
+			return v
+		}
+	}
+	return nil
+}
+
+// Lookup このスコープから外側に向かって変数を探す
+func (s *Scope) Lookup(name string, visible func(*Value) bool) *Value {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if v := scope.Local(name, visible); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// declare 同じスコープで同じ名前の変数は宣言できない
+func (s *Scope) declare(name string, typ []*parse.DataType, pos *tokenize.Position, implicit bool) (*Value, error) {
+	if prev := s.Local(name, nil); name != "_" && prev != nil {
+		d := diagnostic.New(spanAt(pos, name), "A0092", name)
+		if prev.Pos != nil {
+			d.WithRelated(prev.Pos.Span(len(name)), "N0003")
+		}
+		return nil, d
+	}
+	v := &Value{Name: name, DataType: typ, Pos: pos, implicit: implicit || name == "_"}
+	v.Symbol = &ir.Symbol{Kind: ir.Local, Name: name}
+	if len(typ) == 1 {
+		v.Symbol.Type = typ[0]
+	}
+	s.Values = append(s.Values, v)
+	return v, nil
+}
+
+// allocate スコープの変数にBPからの距離を割り当て、使用した領域の終わりを返す
+// 兄弟のスコープが同時に使われることはないので、同じ領域を使い回す
+func (s *Scope) allocate(next int) int {
+	for _, v := range s.Values {
+		v.Symbol.Slot = next
+		next++
+	}
+	end := next
+	for _, child := range s.Children {
+		if e := child.allocate(next); end < e {
+			end = e
+		}
+	}
+	return end
+}
+
+// unused 使用されていない変数
+func (s *Scope) unused() []*Value {
+	var values []*Value
+	for _, v := range s.Values {
+		if !v.used && !v.implicit {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// openScope 新しいスコープを作り、現在のスコープにする
+func (a *Analyzer) openScope() {
+	a.currentScope = newScope(a.currentScope)
+}
+
+// closeScope 使用されていない変数を全て報告し、外側のスコープに戻る
+func (a *Analyzer) closeScope() {
+	s := a.currentScope
+	a.currentScope = s.Parent
+	for _, v := range s.unused() {
+		a.diagnostics = append(a.diagnostics, diagnostic.New(spanAt(v.Pos, v.Name), "A0093", v.Name))
+	}
+}
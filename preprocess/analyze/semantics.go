@@ -1,11 +1,34 @@
 package analyze
 
-import "github.com/arrietty-lang/arrtty/preprocess/parse"
+import (
+	"github.com/arrietty-lang/arrtty/ir"
+	"github.com/arrietty-lang/arrtty/preprocess/parse"
+)
 
 type Semantics struct {
-	KnownValues      map[string]map[int]map[string][]*parse.DataType
-	KnownFunctions   map[string]*FnDataType
-	OutsideValues    []*parse.Node
-	OutsideFunctions []*parse.Node
-	Tree             []*parse.Node
+	// Globals グローバル変数
+	Globals        map[string][]*parse.DataType
+	KnownFunctions map[string]*FnDataType
+	KnownTypes     map[string]*parse.DataType
+	// Constants コンパイル時に評価された定数, 使用箇所はリテラルに置き換えられている
+	Constants map[string]*Constant
+	// Program 型と識別子の解決先を持つIR, コード生成はこれのみを使用する
+	Program *ir.Program
+}
+
+// Package 解析済みのパッケージ, 解析するプログラムからは`Name.識別子`で参照する
+type Package struct {
+	Name string
+	// Path パッケージを識別するインポートパス, リンク時のObjectのIdentifierと一致させる
+	// 省略した場合はName
+	Path      string
+	Semantics *Semantics
+}
+
+// Identifier リンク時にパッケージを識別する名前
+func (p *Package) Identifier() string {
+	if p.Path == "" {
+		return p.Name
+	}
+	return p.Path
 }
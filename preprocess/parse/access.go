@@ -0,0 +1,14 @@
+package parse
+
+import "github.com/arrietty-lang/arrtty/preprocess/tokenize"
+
+type AccessField struct {
+	Target *Node
+	Field  string
+	// FieldPos フィールド名の位置
+	FieldPos *tokenize.Position
+	// Index 意味解析で確定するフィールドの位置
+	Index int
+	// DataType 意味解析で確定するフィールドの型
+	DataType *DataType
+}
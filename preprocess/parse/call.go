@@ -1,6 +1,13 @@
 package parse
 
 type CallField struct {
+	// Identifier 関数名, 関数の値を呼び出す場合は任意の式
 	Identifier *Node
 	Args       *Node
+	// FuncType 意味解析で確定する、関数の値を呼び出す場合の関数の型
+	// 関数名で直接呼び出す場合はnil
+	FuncType *DataType
+	// Method 意味解析で確定する、インターフェースの値を通して呼び出すメソッド名
+	// このときIdentifierはインターフェースの値
+	Method string
 }
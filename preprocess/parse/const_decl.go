@@ -0,0 +1,10 @@
+package parse
+
+type ConstDeclField struct {
+	Identifier *Node
+	// Type 省略された場合はnil
+	Type  *Node
+	Value *Node
+	// Iota グループ内での位置
+	Iota int
+}
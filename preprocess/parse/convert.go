@@ -0,0 +1,10 @@
+package parse
+
+// ConvertField 意味解析で挿入される、値をインターフェースの値に変換するノード
+type ConvertField struct {
+	Value *Node
+	// From 変換前の値の型
+	From *DataType
+	// To 変換先のインターフェース
+	To *DataType
+}
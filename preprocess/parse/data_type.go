@@ -1,5 +1,12 @@
 package parse
 
+import (
+	"fmt"
+	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
+	"strconv"
+	"strings"
+)
+
 // 型について
 
 // RuntimeDataType 言語として用意された消せない型
@@ -12,12 +19,176 @@ const (
 	String
 	Bool
 	Nil // これ型じゃないんだけど、ポインタ実装するまでは型として扱う
+	Struct
+	Array
+	Slice
+	Map
+	Func
+	Interface
 )
 
 type DataType struct {
 	Ident string
 	Type  RuntimeDataType
-	Base  *DataType
+	// Base 配列、スライスの要素の型
+	Base *DataType
+	// Key マップのキーの型, 値の型はBase
+	Key *DataType
+	// Len 配列の長さ
+	Len int
+	// LenExpr 長さを定数式で指定した配列の、意味解析で評価される式
+	LenExpr *Node
+	// Fields 構造体のフィールド(宣言順)
+	Fields []*StructField
+	// Params, Returns 関数の引数と戻り値の型
+	Params  []*DataType
+	Returns []*DataType
+	// Methods インターフェースが要求するメソッド(宣言順)
+	Methods []*Method
+	// Package 他のパッケージで定義された構造体、インターフェースの、定義したパッケージのインポートパス
+	// インポートした側で作り直した型のみが持ち、その場合Identは`パッケージ名.型名`になる
+	Package string
+}
+
+type StructField struct {
+	Name     string
+	DataType *DataType
+	// TypePos フィールドの型の位置, 型のエラーを報告するために使う
+	TypePos *tokenize.Position
+}
+
+// Method メソッドの名前と、レシーバを除いた関数の型
+type Method struct {
+	Name     string
+	DataType *DataType
+}
+
+// FieldIndex フィールドの宣言位置を返す, 存在しなければ-1
+func (d *DataType) FieldIndex(name string) int {
+	for i, f := range d.Fields {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// MethodIndex メソッドの宣言位置を返す, 存在しなければ-1
+func (d *DataType) MethodIndex(name string) int {
+	for i, m := range d.Methods {
+		if m.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// NewSliceType `[]base`
+func NewSliceType(base *DataType) *DataType {
+	return &DataType{
+		Ident: "[]" + base.Ident,
+		Type:  Slice,
+		Base:  base,
+	}
+}
+
+// NewArrayType `[n]base`
+func NewArrayType(base *DataType, n int) *DataType {
+	return &DataType{
+		Ident: fmt.Sprintf("[%d]%s", n, base.Ident),
+		Type:  Array,
+		Base:  base,
+		Len:   n,
+	}
+}
+
+// NewConstArrayType `[N]base`, `[N*2]base`
+func NewConstArrayType(base *DataType, length *Node) *DataType {
+	return &DataType{
+		Ident:   fmt.Sprintf("[%s]%s", ExprString(length), base.Ident),
+		Type:    Array,
+		Base:    base,
+		LenExpr: length,
+	}
+}
+
+// ExprString 型の名前やエラーに使う、定数式の表記
+func ExprString(n *Node) string {
+	switch n.Kind {
+	case NdLiteral:
+		l := n.LiteralField.Literal
+		switch l.Kind {
+		case tokenize.LInt:
+			return strconv.Itoa(l.I)
+		case tokenize.LFloat:
+			return strconv.FormatFloat(l.F, 'g', -1, 64)
+		case tokenize.LString:
+			return strconv.Quote(l.S)
+		case tokenize.LBool:
+			return strconv.FormatBool(l.B)
+		}
+	case NdIdent:
+		return n.IdentField.Ident
+	case NdPrefix:
+		return n.PrefixField.Prefix + "." + ExprString(n.PrefixField.Child)
+	case NdParenthesis:
+		return "(" + ExprString(n.UnaryField.Value) + ")"
+	case NdNot:
+		return "!" + ExprString(n.UnaryField.Value)
+	case NdCall:
+		var args []string
+		if n.CallField.Args != nil {
+			for _, v := range n.CallField.Args.PolynomialField.Values {
+				args = append(args, ExprString(v))
+			}
+		}
+		return ExprString(n.CallField.Identifier) + "(" + strings.Join(args, ", ") + ")"
+	}
+	if op, ok := binaryOperators[n.Kind]; ok {
+		return ExprString(n.BinaryField.Lhs) + op + ExprString(n.BinaryField.Rhs)
+	}
+	return "..."
+}
+
+var binaryOperators = map[NodeKind]string{
+	NdAnd: "&&", NdOr: "||", NdEq: "==", NdNe: "!=", NdLt: "<", NdLe: "<=", NdGt: ">", NdGe: ">=",
+	NdAdd: "+", NdSub: "-", NdMul: "*", NdDiv: "/", NdMod: "%",
+}
+
+// NewMapType `map[key]value`
+func NewMapType(key *DataType, value *DataType) *DataType {
+	return &DataType{
+		Ident: fmt.Sprintf("map[%s]%s", key.Ident, value.Ident),
+		Type:  Map,
+		Base:  value,
+		Key:   key,
+	}
+}
+
+// NewFuncType `func(params) returns`
+func NewFuncType(params []*DataType, returns []*DataType) *DataType {
+	ident := "func(" + joinIdents(params) + ")"
+	switch len(returns) {
+	case 0:
+	case 1:
+		ident += " " + returns[0].Ident
+	default:
+		ident += " (" + joinIdents(returns) + ")"
+	}
+	return &DataType{
+		Ident:   ident,
+		Type:    Func,
+		Params:  params,
+		Returns: returns,
+	}
+}
+
+func joinIdents(types []*DataType) string {
+	var idents []string
+	for _, t := range types {
+		idents = append(idents, t.Ident)
+	}
+	return strings.Join(idents, ", ")
 }
 
 func GetDataTypeByIdent(ident string) *DataType {
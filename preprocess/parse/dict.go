@@ -0,0 +1,7 @@
+package parse
+
+// DictField `map[K]V{k: v}`, 要素はNdKV
+type DictField struct {
+	Type    *Node
+	Entries []*Node
+}
@@ -6,3 +6,14 @@ type ForField struct {
 	Loop *Node
 	Body *Node
 }
+
+// ForRangeField `for k, v := range x`
+// Key, Valueは省略された場合nil
+type ForRangeField struct {
+	Key    *Node
+	Value  *Node
+	Target *Node
+	Body   *Node
+	// DataType 意味解析で確定する対象の型
+	DataType *DataType
+}
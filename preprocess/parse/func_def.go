@@ -1,6 +1,8 @@
 package parse
 
 type FuncDefField struct {
+	// Receiver メソッドのレシーバ, 関数であればnil
+	Receiver   *Node
 	Identifier *Node
 	Parameters *Node
 	Returns    *Node
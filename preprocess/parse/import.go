@@ -4,4 +4,33 @@ package parse
 
 type ImportField struct {
 	Target string
+	// Alias `import 名前 "パス"`で付けた名前, 省略した場合は空
+	Alias string
+}
+
+// Name パッケージを参照する名前, 別名がなければパスの最後の要素
+func (f *ImportField) Name() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	for i := len(f.Target) - 1; 0 <= i; i-- {
+		if f.Target[i] == '/' {
+			return f.Target[i+1:]
+		}
+	}
+	return f.Target
+}
+
+// Imports トップレベルの全てのimport, まとめて書かれたものは展開する
+func Imports(nodes []*Node) []*Node {
+	var imports []*Node
+	for _, node := range nodes {
+		switch node.Kind {
+		case NdImport:
+			imports = append(imports, node)
+		case NdImportGroup:
+			imports = append(imports, node.PolynomialField.Values...)
+		}
+	}
+	return imports
 }
@@ -0,0 +1,26 @@
+package parse
+
+type ListField struct {
+	Type   *Node
+	Values []*Node
+}
+
+type IndexField struct {
+	Target *Node
+	Index  *Node
+	// Container 意味解析で確定する対象の型
+	Container *DataType
+	// DataType 意味解析で確定する要素の型
+	DataType *DataType
+	// CommaOk マップの要素を`v, ok`の形で受け取るか
+	CommaOk bool
+}
+
+type SliceField struct {
+	Target *Node
+	// Low, High 省略された場合はnil
+	Low  *Node
+	High *Node
+	// DataType 意味解析で確定するスライスの型
+	DataType *DataType
+}
@@ -0,0 +1,9 @@
+package parse
+
+// MultiAssignField `a, b = f()`, `v, ok := m[k]`
+type MultiAssignField struct {
+	Targets []*Node
+	Value   *Node
+	// New 意味解析で確定する、`:=`で新たに宣言される変数か
+	New []bool
+}
@@ -32,6 +32,20 @@ type Node struct {
 	PolynomialField   *PolynomialField
 	FuncParam         *FuncParam
 	PrefixField       *PrefixField
+	SwitchField       *SwitchField
+	CaseField         *CaseField
+	TypeDefField      *TypeDefField
+	AccessField       *AccessField
+	StructLitField    *StructLitField
+	KVField           *KVField
+	ListField         *ListField
+	IndexField        *IndexField
+	SliceField        *SliceField
+	DictField         *DictField
+	MultiAssignField  *MultiAssignField
+	ForRangeField     *ForRangeField
+	ConvertField      *ConvertField
+	ConstDeclField    *ConstDeclField
 }
 
 func (n *Node) String() string {
@@ -45,11 +59,11 @@ func (n *Node) String() string {
 		s = fmt.Sprintf("%v", n.IdentField)
 	case NdVarDecl:
 		s = fmt.Sprintf("%v", n.VarDeclField)
-	case NdAssign:
+	case NdAssign, NdAddAssign, NdSubAssign, NdMulAssign, NdDivAssign, NdModAssign:
 		s = fmt.Sprintf("%v", n.AssignField)
 	case NdComment:
 		s = fmt.Sprintf("%v", n.CommentField)
-	case NdFuncDef:
+	case NdFuncDef, NdFuncLit:
 		s = fmt.Sprintf("%v", n.FuncDefField)
 	case NdBlock:
 		s = fmt.Sprintf("%v", n.BlockField)
@@ -61,9 +75,13 @@ func (n *Node) String() string {
 		s = fmt.Sprintf("%v", n.ForField)
 	case NdShortVarDecl:
 		s = fmt.Sprintf("%v", n.ShortVarDeclField)
+	case NdSwitch:
+		s = fmt.Sprintf("%v", n.SwitchField)
+	case NdCase:
+		s = fmt.Sprintf("%v", n.CaseField)
 	case NdAnd, NdOr, NdEq, NdNe, NdLt, NdLe, NdGt, NdGe, NdAdd, NdSub, NdMul, NdDiv, NdMod:
 		s = fmt.Sprintf("%v", n.BinaryField)
-	case NdNot, NdParenthesis:
+	case NdNot, NdParenthesis, NdInc, NdDec:
 		s = fmt.Sprintf("%v", n.UnaryField)
 	case NdLiteral:
 		s = fmt.Sprintf("%v", n.LiteralField)
@@ -73,6 +91,34 @@ func (n *Node) String() string {
 		s = fmt.Sprintf("%v", n.PolynomialField)
 	case NdParam:
 		s = fmt.Sprintf("%v", n.FuncParam)
+	case NdPrefix:
+		s = fmt.Sprintf("%v", n.PrefixField)
+	case NdTypeDef:
+		s = fmt.Sprintf("%v", n.TypeDefField)
+	case NdAccess:
+		s = fmt.Sprintf("%v", n.AccessField)
+	case NdStructLit:
+		s = fmt.Sprintf("%v", n.StructLitField)
+	case NdKV:
+		s = fmt.Sprintf("%v", n.KVField)
+	case NdList:
+		s = fmt.Sprintf("%v", n.ListField)
+	case NdIndex:
+		s = fmt.Sprintf("%v", n.IndexField)
+	case NdSlice:
+		s = fmt.Sprintf("%v", n.SliceField)
+	case NdDict:
+		s = fmt.Sprintf("%v", n.DictField)
+	case NdMultiAssign, NdMultiShortVarDecl:
+		s = fmt.Sprintf("%v", n.MultiAssignField)
+	case NdForRange:
+		s = fmt.Sprintf("%v", n.ForRangeField)
+	case NdConvert:
+		s = fmt.Sprintf("%v", n.ConvertField)
+	case NdConstDecl:
+		s = fmt.Sprintf("%v", n.ConstDeclField)
+	case NdConstGroup, NdImportGroup:
+		s = fmt.Sprintf("%v", n.PolynomialField)
 	}
 	return fmt.Sprintf("Node(%d-%d) %s", n.Pos.LineNo, n.Pos.Lat, s)
 }
@@ -109,9 +155,9 @@ func NewDataTypeNode(pos *tokenize.Position, datatype *DataType) *Node {
 	return n
 }
 
-func NewImportNode(pos *tokenize.Position, target string) *Node {
+func NewImportNode(pos *tokenize.Position, target string, alias string) *Node {
 	n := NewNode(NdImport, pos)
-	n.ImportField = &ImportField{Target: target}
+	n.ImportField = &ImportField{Target: target, Alias: alias}
 	return n
 }
 
@@ -139,12 +185,33 @@ func NewAssignNode(pos *tokenize.Position, to, value *Node) *Node {
 	return n
 }
 
+// NewCompoundAssignNode `x += 1`のような演算と代入を同時に行うノード
+func NewCompoundAssignNode(kind NodeKind, pos *tokenize.Position, to, value *Node) *Node {
+	n := NewNode(kind, pos)
+	n.AssignField = &AssignField{
+		To:    to,
+		Value: value,
+	}
+	return n
+}
+
 func NewCommentNode(pos *tokenize.Position, comment string) *Node {
 	n := NewNode(NdComment, pos)
 	n.CommentField = &CommentField{Comment: comment}
 	return n
 }
 
+// NewFuncLitNode 無名関数, 名前は意味解析で付けられる
+func NewFuncLitNode(pos *tokenize.Position, params, returns, body *Node) *Node {
+	n := NewNode(NdFuncLit, pos)
+	n.FuncDefField = &FuncDefField{
+		Parameters: params,
+		Returns:    returns,
+		Body:       body,
+	}
+	return n
+}
+
 func NewFuncDefNode(pos *tokenize.Position, ident, params, returns, body *Node) *Node {
 	n := NewNode(NdFuncDef, pos)
 	n.FuncDefField = &FuncDefField{
@@ -190,6 +257,36 @@ func NewForNode(pos *tokenize.Position, init, cond, loop, body *Node) *Node {
 	return n
 }
 
+func NewForRangeNode(pos *tokenize.Position, key, value, target, body *Node) *Node {
+	n := NewNode(NdForRange, pos)
+	n.ForRangeField = &ForRangeField{
+		Key:    key,
+		Value:  value,
+		Target: target,
+		Body:   body,
+	}
+	return n
+}
+
+func NewSwitchNode(pos *tokenize.Position, tag *Node, cases []*Node) *Node {
+	n := NewNode(NdSwitch, pos)
+	n.SwitchField = &SwitchField{
+		Tag:   tag,
+		Cases: cases,
+	}
+	return n
+}
+
+func NewCaseNode(pos *tokenize.Position, isDefault bool, values []*Node, body *Node) *Node {
+	n := NewNode(NdCase, pos)
+	n.CaseField = &CaseField{
+		IsDefault: isDefault,
+		Values:    values,
+		Body:      body,
+	}
+	return n
+}
+
 func NewShortVarDeclNode(pos *tokenize.Position, ident, value *Node) *Node {
 	n := NewNode(NdShortVarDecl, pos)
 	n.ShortVarDeclField = &ShortVarDeclField{
@@ -199,6 +296,24 @@ func NewShortVarDeclNode(pos *tokenize.Position, ident, value *Node) *Node {
 	return n
 }
 
+func NewMultiAssignNode(kind NodeKind, pos *tokenize.Position, targets []*Node, value *Node) *Node {
+	n := NewNode(kind, pos)
+	n.MultiAssignField = &MultiAssignField{
+		Targets: targets,
+		Value:   value,
+	}
+	return n
+}
+
+func NewDictNode(pos *tokenize.Position, typ *Node, entries []*Node) *Node {
+	n := NewNode(NdDict, pos)
+	n.DictField = &DictField{
+		Type:    typ,
+		Entries: entries,
+	}
+	return n
+}
+
 func NewBinaryNode(kind NodeKind, pos *tokenize.Position, lhs, rhs *Node) *Node {
 	n := NewNode(kind, pos)
 	n.BinaryField = &BinaryField{
@@ -254,3 +369,157 @@ func NewPrefixNode(pos *tokenize.Position, prefix string, child *Node) *Node {
 	}
 	return n
 }
+
+func NewConstDeclNode(pos *tokenize.Position, ident, typ, value *Node, iota int) *Node {
+	n := NewNode(NdConstDecl, pos)
+	n.ConstDeclField = &ConstDeclField{
+		Identifier: ident,
+		Type:       typ,
+		Value:      value,
+		Iota:       iota,
+	}
+	return n
+}
+
+func NewConvertNode(pos *tokenize.Position, value *Node, from, to *DataType) *Node {
+	n := NewNode(NdConvert, pos)
+	n.ConvertField = &ConvertField{
+		Value: value,
+		From:  from,
+		To:    to,
+	}
+	return n
+}
+
+func NewTypeDefNode(pos *tokenize.Position, ident, typ *Node) *Node {
+	n := NewNode(NdTypeDef, pos)
+	n.TypeDefField = &TypeDefField{
+		Identifier: ident,
+		Type:       typ,
+	}
+	return n
+}
+
+func NewAccessNode(pos *tokenize.Position, target *Node, field string) *Node {
+	n := NewNode(NdAccess, pos)
+	n.AccessField = &AccessField{
+		Target: target,
+		Field:  field,
+		Index:  -1,
+	}
+	return n
+}
+
+func NewStructLitNode(pos *tokenize.Position, typ *Node, fields []*Node) *Node {
+	n := NewNode(NdStructLit, pos)
+	n.StructLitField = &StructLitField{
+		Type:   typ,
+		Fields: fields,
+	}
+	return n
+}
+
+func NewKVNode(pos *tokenize.Position, key, value *Node) *Node {
+	n := NewNode(NdKV, pos)
+	n.KVField = &KVField{
+		Key:   key,
+		Value: value,
+	}
+	return n
+}
+
+func NewListNode(pos *tokenize.Position, typ *Node, values []*Node) *Node {
+	n := NewNode(NdList, pos)
+	n.ListField = &ListField{
+		Type:   typ,
+		Values: values,
+	}
+	return n
+}
+
+func NewIndexNode(pos *tokenize.Position, target, index *Node) *Node {
+	n := NewNode(NdIndex, pos)
+	n.IndexField = &IndexField{
+		Target: target,
+		Index:  index,
+	}
+	return n
+}
+
+func NewSliceNode(pos *tokenize.Position, target, low, high *Node) *Node {
+	n := NewNode(NdSlice, pos)
+	n.SliceField = &SliceField{
+		Target: target,
+		Low:    low,
+		High:   high,
+	}
+	return n
+}
+
+// Inspect ノードとその子を深さ優先で順に訪れる, fがfalseを返すとその子は訪れない
+func Inspect(node *Node, f func(*Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+	var children []*Node
+	switch {
+	case node.VarDeclField != nil:
+		children = []*Node{node.VarDeclField.Identifier}
+	case node.AssignField != nil:
+		children = []*Node{node.AssignField.To, node.AssignField.Value}
+	case node.FuncDefField != nil:
+		children = []*Node{node.FuncDefField.Receiver, node.FuncDefField.Parameters, node.FuncDefField.Body}
+	case node.BlockField != nil:
+		children = node.BlockField.Statements
+	case node.ReturnField != nil:
+		children = []*Node{node.ReturnField.Value}
+	case node.IfElseField != nil:
+		children = []*Node{node.IfElseField.Cond, node.IfElseField.IfBlock, node.IfElseField.ElseBlock}
+	case node.ForField != nil:
+		children = []*Node{node.ForField.Init, node.ForField.Cond, node.ForField.Loop, node.ForField.Body}
+	case node.ShortVarDeclField != nil:
+		children = []*Node{node.ShortVarDeclField.Identifier, node.ShortVarDeclField.Value}
+	case node.BinaryField != nil:
+		children = []*Node{node.BinaryField.Lhs, node.BinaryField.Rhs}
+	case node.UnaryField != nil:
+		children = []*Node{node.UnaryField.Value}
+	case node.CallField != nil:
+		children = []*Node{node.CallField.Identifier, node.CallField.Args}
+	case node.PolynomialField != nil:
+		children = node.PolynomialField.Values
+	case node.FuncParam != nil:
+		children = []*Node{node.FuncParam.Identifier}
+	case node.PrefixField != nil:
+		children = []*Node{node.PrefixField.Child}
+	case node.SwitchField != nil:
+		children = append([]*Node{node.SwitchField.Tag}, node.SwitchField.Cases...)
+	case node.CaseField != nil:
+		children = append(append([]*Node{}, node.CaseField.Values...), node.CaseField.Body)
+	case node.AccessField != nil:
+		children = []*Node{node.AccessField.Target}
+	case node.StructLitField != nil:
+		children = node.StructLitField.Fields
+	case node.KVField != nil:
+		children = []*Node{node.KVField.Key, node.KVField.Value}
+	case node.ListField != nil:
+		children = node.ListField.Values
+	case node.IndexField != nil:
+		children = []*Node{node.IndexField.Target, node.IndexField.Index}
+	case node.SliceField != nil:
+		children = []*Node{node.SliceField.Target, node.SliceField.Low, node.SliceField.High}
+	case node.DictField != nil:
+		children = node.DictField.Entries
+	case node.MultiAssignField != nil:
+		children = append(append([]*Node{}, node.MultiAssignField.Targets...), node.MultiAssignField.Value)
+	case node.ForRangeField != nil:
+		field := node.ForRangeField
+		children = []*Node{field.Key, field.Value, field.Target, field.Body}
+	case node.ConvertField != nil:
+		children = []*Node{node.ConvertField.Value}
+	case node.ConstDeclField != nil:
+		children = []*Node{node.ConstDeclField.Value}
+	}
+	for _, child := range children {
+		Inspect(child, f)
+	}
+}
@@ -12,8 +12,12 @@ const (
 	NdIfElse
 	NdWhile
 	NdFor
+	NdForRange
+	NdSwitch
+	NdCase
 
 	NdImport
+	NdImportGroup // import ( ... )
 
 	NdNot // !
 	NdPlus
@@ -34,13 +38,28 @@ const (
 	NdMod // %
 
 	NdFuncDef
+	NdFuncLit
+	NdTypeDef
 	NdVarDecl
+	NdConstDecl
+	NdConstGroup // const ( ... )
 	NdShortVarDecl
-	NdAssign // =
+	NdMultiAssign       // a, b =
+	NdMultiShortVarDecl // a, b :=
+	NdAssign            // =
+	NdAddAssign         // +=
+	NdSubAssign         // -=
+	NdMulAssign         // *=
+	NdDivAssign         // /=
+	NdModAssign         // %=
+	NdInc               // ++
+	NdDec               // --
 
 	NdDataType
 
 	NdLiteral
+	NdStructLit
+	NdConvert // インターフェースへの暗黙の変換
 
 	NdIdent
 	NdCall
@@ -63,6 +82,8 @@ const (
 	NdParam
 
 	NdAccess
+	NdIndex
+	NdSlice
 	NdParenthesis
 
 	NdPrefix
@@ -1,109 +1,196 @@
 package parse
 
 import (
-	"fmt"
+	"github.com/arrietty-lang/arrtty/diagnostic"
 	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
 )
 
-var token *tokenize.Token
+// Parser 読んでいるトークンと解析中の文脈を持つ
+// それぞれのParserは独立しているので、別々のゴルーチンで同時に使える
+type Parser struct {
+	token *tokenize.Token
+	// noCompositeLit if, for, switchの条件部分では`ident {`をブロックの開始として扱う
+	noCompositeLit bool
+}
+
+// NewParser 新しいParser
+func NewParser() *Parser {
+	return &Parser{}
+}
+
+// Parse 新しいParserでトークン列を解析する
+func Parse(head *tokenize.Token) ([]*Node, error) {
+	return NewParser().Parse(head)
+}
 
-func isEof() bool {
-	return token.Kind == tokenize.Eof
+// controlClause 複合リテラルを使用できない条件部分を解析する
+func (p *Parser) controlClause(f func() (*Node, error)) (*Node, error) {
+	prev := p.noCompositeLit
+	p.noCompositeLit = true
+	defer func() {
+		p.noCompositeLit = prev
+	}()
+	return f()
 }
 
-func peekKind(kind tokenize.TokenKind) *tokenize.Token {
-	if token.Kind == kind {
-		return token
+// nested 括弧の中では条件部分であっても複合リテラルを使用できる
+func (p *Parser) nested(f func() (*Node, error)) (*Node, error) {
+	prev := p.noCompositeLit
+	p.noCompositeLit = false
+	defer func() {
+		p.noCompositeLit = prev
+	}()
+	return f()
+}
+
+func (p *Parser) isEof() bool {
+	return p.token.Kind == tokenize.Eof
+}
+
+func (p *Parser) peekKind(kind tokenize.TokenKind) *tokenize.Token {
+	if p.token.Kind == kind {
+		return p.token
 	}
 	return nil
 }
 
-func peekNextKind(kind tokenize.TokenKind) *tokenize.Token {
-	if token.Next.Kind == kind {
-		return token.Next
+func (p *Parser) peekNextKind(kind tokenize.TokenKind) *tokenize.Token {
+	if p.token.Next.Kind == kind {
+		return p.token.Next
 	}
 	return nil
 }
 
-func consumeKind(kind tokenize.TokenKind) *tokenize.Token {
-	if token.Kind == kind {
-		tok := token
-		token = token.Next
+func (p *Parser) consumeKind(kind tokenize.TokenKind) *tokenize.Token {
+	if p.token.Kind == kind {
+		tok := p.token
+		p.token = p.token.Next
 		return tok
 	}
 	return nil
 }
 
-func consumeIdent(s string) *tokenize.Token {
-	if token.Kind == tokenize.Ident && s == token.Literal.S {
-		tok := token
-		token = token.Next
+func (p *Parser) peekIdent(s string) *tokenize.Token {
+	if p.token.Kind == tokenize.Ident && s == p.token.Literal.S {
+		return p.token
+	}
+	return nil
+}
+
+func (p *Parser) consumeIdent(s string) *tokenize.Token {
+	if p.token.Kind == tokenize.Ident && s == p.token.Literal.S {
+		tok := p.token
+		p.token = p.token.Next
 		return tok
 	}
 	return nil
 }
 
-func expectKind(kind tokenize.TokenKind) (*tokenize.Token, error) {
-	if token.Kind == kind {
-		tok := token
-		token = token.Next
+func (p *Parser) expectKind(kind tokenize.TokenKind) (*tokenize.Token, error) {
+	if p.token.Kind == kind {
+		tok := p.token
+		p.token = p.token.Next
 		return tok, nil
 	}
-	return nil, fmt.Errorf("[%d:%d] unexpected token: %v expected %s", token.Pos.LineNo, token.Pos.Lat, token.Kind.String(), kind.String())
+	return nil, diagnostic.New(p.token.Span(), "P0001", p.token.Kind.String(), kind.String())
 }
 
-func Parse(head *tokenize.Token) ([]*Node, error) {
-	token = head
-	return program()
+// Parse トークン列を解析する
+func (p *Parser) Parse(head *tokenize.Token) ([]*Node, error) {
+	p.token = head
+	p.noCompositeLit = false
+	return p.program()
 }
 
-func program() ([]*Node, error) {
+func (p *Parser) program() ([]*Node, error) {
 	var nodes []*Node
-	for !isEof() {
-		n, err := toplevel()
+	var diagnostics diagnostic.List
+	for !p.isEof() {
+		start := p.token
+		n, err := p.toplevel()
 		if err != nil {
-			return nil, err
+			diagnostics.Add(err, start.Span())
+			p.recover_(start)
+			continue
 		}
 		nodes = append(nodes, n)
 	}
+	if err := diagnostics.Err(); err != nil {
+		return nil, err
+	}
 	return nodes, nil
 }
 
-func toplevel() (*Node, error) {
+// toplevelKeywords 定義の始まりになる識別子
+var toplevelKeywords = map[string]bool{
+	"func": true, "type": true, "var": true, "const": true, "import": true,
+}
+
+// recover_ エラーの後、行の先頭にある次の定義まで読み飛ばす
+func (p *Parser) recover_(start *tokenize.Token) {
+	if p.token == start && !p.isEof() {
+		p.token = p.token.Next
+	}
+	for !p.isEof() {
+		if p.token.Kind == tokenize.Ident && toplevelKeywords[p.token.Literal.S] && p.token.Pos.Lat == 0 {
+			return
+		}
+		p.token = p.token.Next
+	}
+}
+
+func (p *Parser) toplevel() (*Node, error) {
 	// コメント
-	if t := consumeKind(tokenize.Comment); t != nil {
+	if t := p.consumeKind(tokenize.Comment); t != nil {
 		return NewCommentNode(t.Pos, t.Literal.S), nil
 	}
 
 	// 関数定義
-	if t := consumeIdent("func"); t != nil {
-		// "func" <ident>
-		id, err := expectKind(tokenize.Ident)
+	if t := p.consumeIdent("func"); t != nil {
+		// "func" <("(" ident types ")")?>
+		var receiver *Node
+		if lrb := p.consumeKind(tokenize.Lrb); lrb != nil {
+			recvId, err := p.expectKind(tokenize.Ident)
+			if err != nil {
+				return nil, err
+			}
+			recvType, err := p.types()
+			if err != nil {
+				return nil, err
+			}
+			_, err = p.expectKind(tokenize.Rrb)
+			if err != nil {
+				return nil, err
+			}
+			receiver = NewFuncParamNode(lrb.Pos, NewIdentNode(recvId.Pos, recvId.Literal.S), recvType)
+		}
+		// "func" receiver? <ident>
+		id, err := p.expectKind(tokenize.Ident)
 		if err != nil {
 			return nil, err
 		}
 		// "func" ident <"(">
-		_, err = expectKind(tokenize.Lrb)
+		_, err = p.expectKind(tokenize.Lrb)
 		if err != nil {
 			return nil, err
 		}
 		// "func" ident "(" <funcParams?>
 		var params *Node
-		if consumeKind(tokenize.Rrb) == nil {
-			params, err = funcParams()
+		if p.consumeKind(tokenize.Rrb) == nil {
+			params, err = p.funcParams()
 			if err != nil {
 				return nil, err
 			}
 			// "func" ident "(" funcParams? <")">
-			_, err = expectKind(tokenize.Rrb)
+			_, err = p.expectKind(tokenize.Rrb)
 			if err != nil {
 				return nil, err
 			}
 		}
 		// "func" ident "(" funcParams ")" <funcReturns? block
 		var returns *Node = nil
-		if peekKind(tokenize.Lcb) == nil {
-			returns, err = funcReturns()
+		if p.peekKind(tokenize.Lcb) == nil {
+			returns, err = p.funcReturns()
 			if err != nil {
 				return nil, err
 			}
@@ -131,55 +218,164 @@ func toplevel() (*Node, error) {
 		//	return nil, err
 		//}
 
-		body, err := stmt()
+		// 本体はブロックのみ, 戻り値の型を読み損ねた場合に関数リテラルなどを本体としない
+		if p.peekKind(tokenize.Lcb) == nil {
+			_, err = p.expectKind(tokenize.Lcb)
+			return nil, err
+		}
+		body, err := p.stmt()
 		if err != nil {
 			return nil, err
 		}
 
-		return NewFuncDefNode(t.Pos,
+		def := NewFuncDefNode(t.Pos,
 			NewIdentNode(id.Pos, id.Literal.S),
 			params,
 			returns,
-			body), nil
+			body)
+		def.FuncDefField.Receiver = receiver
+		return def, nil
 	}
 
-	// import
-	if t := consumeIdent("import"); t != nil {
-		// "import" <target>
-		target, err := expectKind(tokenize.String)
+	// 型定義
+	if t := p.consumeIdent("type"); t != nil {
+		// "type" <ident>
+		id, err := p.expectKind(tokenize.Ident)
 		if err != nil {
 			return nil, err
 		}
-		return NewImportNode(t.Pos, target.Literal.S), nil
+		// "type" ident <"interface">
+		if it := p.consumeIdent("interface"); it != nil {
+			typ, err := p.interfaceType(it, id.Literal.S)
+			if err != nil {
+				return nil, err
+			}
+			return NewTypeDefNode(t.Pos, NewIdentNode(id.Pos, id.Literal.S), typ), nil
+		}
+		// "type" ident <"struct">
+		st := p.consumeIdent("struct")
+		if st == nil {
+			return nil, diagnostic.New(p.token.Span(), "P0002", p.token.Kind.String())
+		}
+		typ, err := p.structType(st, id.Literal.S)
+		if err != nil {
+			return nil, err
+		}
+		return NewTypeDefNode(t.Pos, NewIdentNode(id.Pos, id.Literal.S), typ), nil
+	}
+
+	// import
+	if t := p.consumeIdent("import"); t != nil {
+		// "import" <"(">
+		lrb := p.consumeKind(tokenize.Lrb)
+		if lrb == nil {
+			return p.importSpec()
+		}
+		// "import" "(" <importSpec*> ")"
+		var specs []*Node
+		for p.consumeKind(tokenize.Rrb) == nil {
+			spec, err := p.importSpec()
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, spec)
+			_ = p.consumeKind(tokenize.Semi)
+		}
+		return NewPolynomialNode(NdImportGroup, lrb.Pos, specs), nil
+	}
+
+	// 定数定義
+	if c := p.consumeIdent("const"); c != nil {
+		// "const" <"(">
+		lrb := p.consumeKind(tokenize.Lrb)
+		if lrb == nil {
+			return p.constSpec(c, 0, nil)
+		}
+		// "const" "(" <constSpec*> ")"
+		var specs []*Node
+		var prev *Node
+		for p.consumeKind(tokenize.Rrb) == nil {
+			spec, err := p.constSpec(c, len(specs), prev)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, spec)
+			prev = spec
+			_ = p.consumeKind(tokenize.Semi)
+		}
+		return NewPolynomialNode(NdConstGroup, lrb.Pos, specs), nil
 	}
 
 	// 変数定義
-	if c := consumeIdent("var"); c != nil {
+	if c := p.consumeIdent("var"); c != nil {
 		// "var" <ident>
-		id, err := expectKind(tokenize.Ident)
+		id, err := p.expectKind(tokenize.Ident)
 		if err != nil {
 			return nil, err
 		}
 		// "var" ident <types>
-		typ, err := types()
+		typ, err := p.types()
 		if err != nil {
 			return nil, err
 		}
 		// "var" ident types "="?
-		if a := consumeKind(tokenize.Assign); a == nil {
+		if a := p.consumeKind(tokenize.Assign); a == nil {
 			// var decl
 			return NewVarDeclNode(c.Pos, NewIdentNode(id.Pos, id.Literal.S), typ), nil
 		}
 		// var assign
 		// "var" ident types "=" <andor>
-		value, err := andor()
+		value, err := p.andor()
 		if err != nil {
 			return nil, err
 		}
 		return NewAssignNode(c.Pos, NewVarDeclNode(c.Pos, NewIdentNode(id.Pos, id.Literal.S), typ), value), nil
 	}
 
-	return nil, fmt.Errorf("PARSE: unexpected toplevel")
+	return nil, diagnostic.New(p.token.Span(), "P0003")
+}
+
+// importSpec `ident? string`
+// 位置はパスの文字列のものにする
+func (p *Parser) importSpec() (*Node, error) {
+	alias := ""
+	if id := p.consumeKind(tokenize.Ident); id != nil {
+		alias = id.Literal.S
+	}
+	target, err := p.expectKind(tokenize.String)
+	if err != nil {
+		return nil, err
+	}
+	return NewImportNode(target.Pos, target.Literal.S, alias), nil
+}
+
+// constSpec `ident types? "=" andor`
+// グループ内で値を省略した場合は、直前の型と値をiotaだけ変えて繰り返す
+// 改行を区別しないので、型は後ろに"="が続く場合のみ書ける
+func (p *Parser) constSpec(c *tokenize.Token, iota int, prev *Node) (*Node, error) {
+	id, err := p.expectKind(tokenize.Ident)
+	if err != nil {
+		return nil, err
+	}
+	ident := NewIdentNode(id.Pos, id.Literal.S)
+	var typ *Node
+	if p.peekKind(tokenize.Ident) != nil && p.peekNextKind(tokenize.Assign) != nil {
+		typ, err = p.types()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.consumeKind(tokenize.Assign) == nil {
+		if prev == nil || typ != nil {
+			return nil, diagnostic.New(id.Span(), "P0004", id.Literal.S)
+		}
+		return NewConstDeclNode(c.Pos, ident, prev.ConstDeclField.Type, prev.ConstDeclField.Value, iota), nil
+	}
+	value, err := p.andor()
+	if err != nil {
+		return nil, err
+	}
+	return NewConstDeclNode(c.Pos, ident, typ, value, iota), nil
 }
 
 //func block() (*Node, error) {
@@ -201,17 +397,17 @@ func toplevel() (*Node, error) {
 //	return NewBlockNode(lcb.Pos, statements), nil
 //}
 
-func stmt() (*Node, error) {
+func (p *Parser) stmt() (*Node, error) {
 	// コメント
-	if comment := consumeKind(tokenize.Comment); comment != nil {
+	if comment := p.consumeKind(tokenize.Comment); comment != nil {
 		return NewCommentNode(comment.Pos, comment.Literal.S), nil
 	}
 
 	// block
-	if lcb := consumeKind(tokenize.Lcb); lcb != nil {
+	if lcb := p.consumeKind(tokenize.Lcb); lcb != nil {
 		var statements []*Node
-		for consumeKind(tokenize.Rcb) == nil {
-			statement, err := stmt()
+		for p.consumeKind(tokenize.Rcb) == nil {
+			statement, err := p.stmt()
 			if err != nil {
 				return nil, err
 			}
@@ -223,22 +419,20 @@ func stmt() (*Node, error) {
 	// return
 	// 行終端の";"を消しちゃったからexpr?が判別できないかも。
 	// とりあえず"}"が存在するかで判断をする
-	if return_ := consumeIdent("return"); return_ != nil {
+	if return_ := p.consumeIdent("return"); return_ != nil {
 		var values []*Node
-		//if peekKind(tokenize.Rcb) != nil {
-		//	return NewPolynomialNode(NdReturn, return_.Pos, nil), nil
-		//}
-		for peekKind(tokenize.Rcb) == nil {
-			value, err := expr()
+		// switchの中では次のcase, defaultも終端になる
+		if p.peekKind(tokenize.Rcb) != nil || p.peekIdent("case") != nil || p.peekIdent("default") != nil {
+			return NewPolynomialNode(NdReturn, return_.Pos, values), nil
+		}
+		for {
+			value, err := p.expr()
 			if err != nil {
 				return nil, err
 			}
 			values = append(values, value)
-			if peekKind(tokenize.Rcb) == nil {
-				_, err = expectKind(tokenize.Comma)
-				if err != nil {
-					return nil, err
-				}
+			if p.consumeKind(tokenize.Comma) == nil {
+				break
 			}
 		}
 		//return NewReturnNode(return_.Pos, values), nil
@@ -246,20 +440,20 @@ func stmt() (*Node, error) {
 	}
 
 	// if else
-	if if_ := consumeIdent("if"); if_ != nil {
-		cond, err := expr()
+	if if_ := p.consumeIdent("if"); if_ != nil {
+		cond, err := p.controlClause(p.expr)
 		if err != nil {
 			return nil, err
 		}
-		ifBlock, err := stmt()
+		ifBlock, err := p.stmt()
 		if err != nil {
 			return nil, err
 		}
 		// 続いてelseがなかったら
-		if consumeIdent("else") == nil { // elseあった場合はここで消費される
+		if p.consumeIdent("else") == nil { // elseあった場合はここで消費される
 			return NewIfElseNode(if_.Pos, false, cond, ifBlock, nil), nil
 		}
-		elseBlock, err := stmt()
+		elseBlock, err := p.stmt()
 		if err != nil {
 			return nil, err
 		}
@@ -267,38 +461,45 @@ func stmt() (*Node, error) {
 	}
 
 	// for
-	if for_ := consumeIdent("for"); for_ != nil {
+	if for_ := p.consumeIdent("for"); for_ != nil {
 		// for {}
-		if peekKind(tokenize.Lcb) != nil {
-			body, err := stmt()
+		if p.peekKind(tokenize.Lcb) != nil {
+			body, err := p.stmt()
 			if err != nil {
 				return nil, err
 			}
 			return NewForNode(for_.Pos, nil, nil, nil, body), nil
 		}
+		// for k, v := range x {}
+		if forRange, err := p.rangeClause(for_); forRange != nil || err != nil {
+			return forRange, err
+		}
 
 		var init *Node
 		var cond *Node
 		var loop *Node
+		// 各要素は";"で区切ることもできる
 		// init
-		if peekKind(tokenize.Lcb) == nil {
-			i, err := expr()
+		if p.peekKind(tokenize.Lcb) == nil && p.peekKind(tokenize.Semi) == nil {
+			i, err := p.controlClause(p.expr)
 			if err != nil {
 				return nil, err
 			}
 			init = i
 		}
+		initSemi := p.consumeKind(tokenize.Semi)
 		// cond
-		if peekKind(tokenize.Lcb) == nil {
-			c, err := expr()
+		if p.peekKind(tokenize.Lcb) == nil && p.peekKind(tokenize.Semi) == nil {
+			c, err := p.controlClause(p.expr)
 			if err != nil {
 				return nil, err
 			}
 			cond = c
 		}
+		condSemi := p.consumeKind(tokenize.Semi)
 		// loop
-		if peekKind(tokenize.Lcb) == nil {
-			l, err := expr()
+		if p.peekKind(tokenize.Lcb) == nil {
+			l, err := p.controlClause(p.expr)
 			if err != nil {
 				return nil, err
 			}
@@ -308,44 +509,145 @@ func stmt() (*Node, error) {
 		// 3このときは正常
 		// 2このときはinit, condのじ順番で埋められるので正常
 		// 1このときはinitだけ埋められるけど、これはcondであるべきなので修正する
-		if init != nil && cond == nil && loop == nil {
+		// ";"で区切られている場合は位置が明確なので修正しない
+		if initSemi == nil && condSemi == nil && init != nil && cond == nil && loop == nil {
 			cond = init
 			init = nil
 		}
 
 		// loop block
-		body, err := stmt()
+		body, err := p.stmt()
 		if err != nil {
 			return nil, err
 		}
 		return NewForNode(for_.Pos, init, cond, loop, body), nil
 	}
 
-	return expr()
+	// switch
+	if switch_ := p.consumeIdent("switch"); switch_ != nil {
+		return p.switchStmt(switch_)
+	}
+
+	return p.expr()
 }
 
-func expr() (*Node, error) {
-	return assign()
+// rangeClause `for range x`, `for k := range x`, `for k, v := range x`
+// rangeでなければトークンを戻してnilを返す
+func (p *Parser) rangeClause(for_ *tokenize.Token) (*Node, error) {
+	start := p.token
+	var key, value *Node
+	if p.consumeIdent("range") == nil {
+		k := p.consumeKind(tokenize.Ident)
+		if k == nil {
+			p.token = start
+			return nil, nil
+		}
+		key = NewIdentNode(k.Pos, k.Literal.S)
+		if p.consumeKind(tokenize.Comma) != nil {
+			v := p.consumeKind(tokenize.Ident)
+			if v == nil {
+				p.token = start
+				return nil, nil
+			}
+			value = NewIdentNode(v.Pos, v.Literal.S)
+		}
+		if p.consumeKind(tokenize.ColonAssign) == nil || p.consumeIdent("range") == nil {
+			p.token = start
+			return nil, nil
+		}
+	}
+	target, err := p.controlClause(p.expr)
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.stmt()
+	if err != nil {
+		return nil, err
+	}
+	return NewForRangeNode(for_.Pos, key, value, target, body), nil
 }
 
-func assign() (*Node, error) {
+func (p *Parser) switchStmt(switch_ *tokenize.Token) (*Node, error) {
+	// タグなし
+	var tag *Node
+	if p.peekKind(tokenize.Lcb) == nil {
+		t, err := p.controlClause(p.expr)
+		if err != nil {
+			return nil, err
+		}
+		tag = t
+	}
+	_, err := p.expectKind(tokenize.Lcb)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []*Node
+	hasDefault := false
+	for p.consumeKind(tokenize.Rcb) == nil {
+		var c *tokenize.Token
+		var values []*Node
+		isDefault := false
+		if c = p.consumeIdent("case"); c != nil {
+			for {
+				v, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, v)
+				if p.consumeKind(tokenize.Comma) == nil {
+					break
+				}
+			}
+		} else if c = p.consumeIdent("default"); c != nil {
+			if hasDefault {
+				return nil, diagnostic.New(c.Span(), "P0005")
+			}
+			hasDefault = true
+			isDefault = true
+		} else {
+			return nil, diagnostic.New(p.token.Span(), "P0006", p.token.Kind.String())
+		}
+		_, err := p.expectKind(tokenize.Colon)
+		if err != nil {
+			return nil, err
+		}
+		// 次のcase, default, "}"までが本文
+		var statements []*Node
+		for p.peekIdent("case") == nil && p.peekIdent("default") == nil && p.peekKind(tokenize.Rcb) == nil {
+			statement, err := p.stmt()
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, statement)
+		}
+		cases = append(cases, NewCaseNode(c.Pos, isDefault, values, NewBlockNode(c.Pos, statements)))
+	}
+	return NewSwitchNode(switch_.Pos, tag, cases), nil
+}
+
+func (p *Parser) expr() (*Node, error) {
+	return p.assign()
+}
+
+func (p *Parser) assign() (*Node, error) {
 	// var
-	if var_ := consumeIdent("var"); var_ != nil {
-		id, err := expectKind(tokenize.Ident)
+	if var_ := p.consumeIdent("var"); var_ != nil {
+		id, err := p.expectKind(tokenize.Ident)
 		if err != nil {
 			return nil, err
 		}
-		typ, err := types()
+		typ, err := p.types()
 		if err != nil {
 			return nil, err
 		}
 		idNode := NewIdentNode(id.Pos, id.Literal.S)
 		// イコール、代入がなかった場合
-		if eq := consumeKind(tokenize.Assign); eq == nil {
+		if eq := p.consumeKind(tokenize.Assign); eq == nil {
 			return NewVarDeclNode(var_.Pos, idNode, typ), nil
 		}
 		// 代入あった場合
-		value, err := andor()
+		value, err := p.andor()
 		if err != nil {
 			return nil, err
 		}
@@ -353,44 +655,105 @@ func assign() (*Node, error) {
 	}
 
 	// assign系
-	andor_, err := andor()
+	andor_, err := p.andor()
 	if err != nil {
 		return nil, err
 	}
+	// 複数の値の代入
+	if p.peekKind(tokenize.Comma) != nil {
+		if multi := p.multiAssign(andor_); multi != nil {
+			return multi, nil
+		}
+	}
 	// 代入
-	if consumeKind(tokenize.Assign) != nil {
-		value, err := andor()
+	if p.consumeKind(tokenize.Assign) != nil {
+		value, err := p.andor()
 		if err != nil {
 			return nil, err
 		}
 		return NewAssignNode(andor_.Pos, andor_, value), nil
 	}
 	// 簡略代入
-	if consumeKind(tokenize.ColonAssign) != nil {
-		value, err := andor()
+	if p.consumeKind(tokenize.ColonAssign) != nil {
+		value, err := p.andor()
 		if err != nil {
 			return nil, err
 		}
 		return NewShortVarDeclNode(andor_.Pos, andor_, value), nil
 	}
+	// 複合代入
+	compoundAssigns := []struct {
+		tokenKind tokenize.TokenKind
+		nodeKind  NodeKind
+	}{
+		{tokenize.AddAssign, NdAddAssign},
+		{tokenize.SubAssign, NdSubAssign},
+		{tokenize.MulAssign, NdMulAssign},
+		{tokenize.DivAssign, NdDivAssign},
+		{tokenize.ModAssign, NdModAssign},
+	}
+	for _, ca := range compoundAssigns {
+		if p.consumeKind(ca.tokenKind) != nil {
+			value, err := p.andor()
+			if err != nil {
+				return nil, err
+			}
+			return NewCompoundAssignNode(ca.nodeKind, andor_.Pos, andor_, value), nil
+		}
+	}
+	// インクリメント、デクリメント
+	if p.consumeKind(tokenize.Inc) != nil {
+		return NewUnaryNode(NdInc, andor_.Pos, andor_), nil
+	}
+	if p.consumeKind(tokenize.Dec) != nil {
+		return NewUnaryNode(NdDec, andor_.Pos, andor_), nil
+	}
 
 	return andor_, nil
 }
 
-func andor() (*Node, error) {
-	n, err := equality()
+// multiAssign `a, b = f()`, `a, b := f()`
+// 引数などのカンマ区切りの値であればトークンを戻してnilを返す
+func (p *Parser) multiAssign(first *Node) *Node {
+	start := p.token
+	targets := []*Node{first}
+	for p.consumeKind(tokenize.Comma) != nil {
+		target, err := p.andor()
+		if err != nil {
+			p.token = start
+			return nil
+		}
+		targets = append(targets, target)
+	}
+	kind := NdMultiAssign
+	if p.consumeKind(tokenize.ColonAssign) != nil {
+		kind = NdMultiShortVarDecl
+	} else if p.consumeKind(tokenize.Assign) == nil {
+		p.token = start
+		return nil
+	}
+	value, err := p.andor()
+	if err != nil {
+		p.token = start
+		return nil
+	}
+	return NewMultiAssignNode(kind, first.Pos, targets, value)
+}
+
+func (p *Parser) andor() (*Node, error) {
+	n, err := p.equality()
 	if err != nil {
 		return nil, err
 	}
 	for {
-		if and := consumeKind(tokenize.And); and != nil {
-			rhs, err := equality()
+		if and := p.consumeKind(tokenize.And); and != nil {
+			rhs, err := p.equality()
 			if err != nil {
 				return nil, err
 			}
 			n = NewBinaryNode(NdAnd, and.Pos, n, rhs)
-		} else if or := consumeKind(tokenize.Or); or != nil {
-			rhs, err := equality()
+		} else if or := p.consumeKind(tokenize.Or); or != nil {
+			rhs, err := p.equality()
 			if err != nil {
 				return nil, err
 			}
@@ -402,20 +765,20 @@ func andor() (*Node, error) {
 	return n, nil
 }
 
-func equality() (*Node, error) {
-	n, err := relational()
+func (p *Parser) equality() (*Node, error) {
+	n, err := p.relational()
 	if err != nil {
 		return nil, err
 	}
 	for {
-		if eq := consumeKind(tokenize.Eq); eq != nil {
-			rhs, err := relational()
+		if eq := p.consumeKind(tokenize.Eq); eq != nil {
+			rhs, err := p.relational()
 			if err != nil {
 				return nil, err
 			}
 			n = NewBinaryNode(NdEq, eq.Pos, n, rhs)
-		} else if ne := consumeKind(tokenize.Ne); ne != nil {
-			rhs, err := relational()
+		} else if ne := p.consumeKind(tokenize.Ne); ne != nil {
+			rhs, err := p.relational()
 			if err != nil {
 				return nil, err
 			}
@@ -427,32 +790,32 @@ func equality() (*Node, error) {
 	return n, nil
 }
 
-func relational() (*Node, error) {
-	n, err := add()
+func (p *Parser) relational() (*Node, error) {
+	n, err := p.add()
 	if err != nil {
 		return nil, err
 	}
 	for {
-		if lt := consumeKind(tokenize.Lt); lt != nil {
-			rhs, err := add()
+		if lt := p.consumeKind(tokenize.Lt); lt != nil {
+			rhs, err := p.add()
 			if err != nil {
 				return nil, err
 			}
 			n = NewBinaryNode(NdLt, lt.Pos, n, rhs)
-		} else if le := consumeKind(tokenize.Le); le != nil {
-			rhs, err := add()
+		} else if le := p.consumeKind(tokenize.Le); le != nil {
+			rhs, err := p.add()
 			if err != nil {
 				return nil, err
 			}
 			n = NewBinaryNode(NdLe, le.Pos, n, rhs)
-		} else if gt := consumeKind(tokenize.Gt); gt != nil {
-			rhs, err := add()
+		} else if gt := p.consumeKind(tokenize.Gt); gt != nil {
+			rhs, err := p.add()
 			if err != nil {
 				return nil, err
 			}
 			n = NewBinaryNode(NdGt, gt.Pos, n, rhs)
-		} else if ge := consumeKind(tokenize.Ge); ge != nil {
-			rhs, err := add()
+		} else if ge := p.consumeKind(tokenize.Ge); ge != nil {
+			rhs, err := p.add()
 			if err != nil {
 				return nil, err
 			}
@@ -464,20 +827,20 @@ func relational() (*Node, error) {
 	return n, nil
 }
 
-func add() (*Node, error) {
-	n, err := mul()
+func (p *Parser) add() (*Node, error) {
+	n, err := p.mul()
 	if err != nil {
 		return nil, err
 	}
 	for {
-		if plus := consumeKind(tokenize.Add); plus != nil {
-			rhs, err := mul()
+		if plus := p.consumeKind(tokenize.Add); plus != nil {
+			rhs, err := p.mul()
 			if err != nil {
 				return nil, err
 			}
 			n = NewBinaryNode(NdAdd, plus.Pos, n, rhs)
-		} else if minus := consumeKind(tokenize.Sub); minus != nil {
-			rhs, err := mul()
+		} else if minus := p.consumeKind(tokenize.Sub); minus != nil {
+			rhs, err := p.mul()
 			if err != nil {
 				return nil, err
 			}
@@ -489,26 +852,26 @@ func add() (*Node, error) {
 	return n, nil
 }
 
-func mul() (*Node, error) {
-	n, err := unary()
+func (p *Parser) mul() (*Node, error) {
+	n, err := p.unary()
 	if err != nil {
 		return nil, err
 	}
 	for {
-		if star := consumeKind(tokenize.Mul); star != nil {
-			rhs, err := unary()
+		if star := p.consumeKind(tokenize.Mul); star != nil {
+			rhs, err := p.unary()
 			if err != nil {
 				return nil, err
 			}
 			n = NewBinaryNode(NdMul, star.Pos, n, rhs)
-		} else if div := consumeKind(tokenize.Div); div != nil {
-			rhs, err := unary()
+		} else if div := p.consumeKind(tokenize.Div); div != nil {
+			rhs, err := p.unary()
 			if err != nil {
 				return nil, err
 			}
 			n = NewBinaryNode(NdDiv, div.Pos, n, rhs)
-		} else if mod := consumeKind(tokenize.Mod); mod != nil {
-			rhs, err := unary()
+		} else if mod := p.consumeKind(tokenize.Mod); mod != nil {
+			rhs, err := p.unary()
 			if err != nil {
 				return nil, err
 			}
@@ -520,11 +883,11 @@ func mul() (*Node, error) {
 	return n, nil
 }
 
-func unary() (*Node, error) {
-	if plus := consumeKind(tokenize.Add); plus != nil {
-		return primary()
-	} else if minus := consumeKind(tokenize.Sub); minus != nil {
-		v, err := primary()
+func (p *Parser) unary() (*Node, error) {
+	if plus := p.consumeKind(tokenize.Add); plus != nil {
+		return p.primary()
+	} else if minus := p.consumeKind(tokenize.Sub); minus != nil {
+		v, err := p.primary()
 		if err != nil {
 			return nil, err
 		}
@@ -533,96 +896,505 @@ func unary() (*Node, error) {
 			minus.Pos,
 			NewLiteralNode(minus.Pos, tokenize.NewIntLiteral(0)),
 			v), nil
-	} else if not := consumeKind(tokenize.Not); not != nil {
-		v, err := primary()
+	} else if not := p.consumeKind(tokenize.Not); not != nil {
+		v, err := p.primary()
 		if err != nil {
 			return nil, err
 		}
 		return NewUnaryNode(NdNot, not.Pos, v), nil
 	}
-	return primary()
+	return p.primary()
 }
 
-func primary() (*Node, error) {
-	return access()
+func (p *Parser) primary() (*Node, error) {
+	return p.access()
 }
 
-func access() (*Node, error) {
-	if peekKind(tokenize.Ident) != nil && peekNextKind(tokenize.Dot) != nil {
-		p := consumeKind(tokenize.Ident)
-		_ = consumeKind(tokenize.Dot)
-		l, err := literal()
+func (p *Parser) access() (*Node, error) {
+	var n *Node
+	if p.peekKind(tokenize.Ident) != nil && p.peekNextKind(tokenize.Dot) != nil {
+		prefix := p.consumeKind(tokenize.Ident)
+		_ = p.consumeKind(tokenize.Dot)
+		l, err := p.literal()
 		if err != nil {
 			return nil, err
 		}
-		return NewPrefixNode(p.Pos, p.Literal.S, l), nil
+		n = NewPrefixNode(prefix.Pos, prefix.Literal.S, l)
+	} else {
+		l, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		n = l
+	}
+	// フィールドアクセス, インデックス, スライス
+	for {
+		if dot := p.consumeKind(tokenize.Dot); dot != nil {
+			field, err := p.expectKind(tokenize.Ident)
+			if err != nil {
+				return nil, err
+			}
+			n = NewAccessNode(dot.Pos, n, field.Literal.S)
+			n.AccessField.FieldPos = field.Pos
+			continue
+		}
+		if lsb := p.consumeKind(tokenize.Lsb); lsb != nil {
+			index, err := p.indexOrSlice(lsb, n)
+			if err != nil {
+				return nil, err
+			}
+			n = index
+			continue
+		}
+		// 関数の値の呼び出し
+		if lrb := p.consumeKind(tokenize.Lrb); lrb != nil {
+			var args *Node
+			if p.consumeKind(tokenize.Rrb) == nil {
+				a, err := p.nested(p.callArgs)
+				if err != nil {
+					return nil, err
+				}
+				_, err = p.expectKind(tokenize.Rrb)
+				if err != nil {
+					return nil, err
+				}
+				args = a
+			}
+			n = NewCallNode(lrb.Pos, n, args)
+			continue
+		}
+		break
 	}
-	return literal()
+	return n, nil
 }
 
-func literal() (*Node, error) {
+// indexOrSlice `a[i]`, `a[i:j]`
+func (p *Parser) indexOrSlice(lsb *tokenize.Token, target *Node) (*Node, error) {
+	var low *Node
+	var err error
+	if p.peekKind(tokenize.Colon) == nil {
+		low, err = p.nested(p.expr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.consumeKind(tokenize.Colon) == nil {
+		_, err = p.expectKind(tokenize.Rsb)
+		if err != nil {
+			return nil, err
+		}
+		return NewIndexNode(lsb.Pos, target, low), nil
+	}
+	var high *Node
+	if p.peekKind(tokenize.Rsb) == nil {
+		high, err = p.nested(p.expr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.expectKind(tokenize.Rsb)
+	if err != nil {
+		return nil, err
+	}
+	return NewSliceNode(lsb.Pos, target, low, high), nil
+}
+
+func (p *Parser) literal() (*Node, error) {
 	// "(" expr ")"
-	if lrb := consumeKind(tokenize.Lrb); lrb != nil {
-		expression, err := expr()
+	if lrb := p.consumeKind(tokenize.Lrb); lrb != nil {
+		expression, err := p.nested(p.expr)
 		if err != nil {
 			return nil, err
 		}
-		_, err = expectKind(tokenize.Rrb)
+		_, err = p.expectKind(tokenize.Rrb)
 		if err != nil {
 			return nil, err
 		}
 		return NewUnaryNode(NdParenthesis, expression.Pos, expression), nil
 	}
 
-	if id := consumeKind(tokenize.Ident); id != nil {
+	// マップのリテラル
+	if p.peekIdent("map") != nil && p.peekNextKind(tokenize.Lsb) != nil {
+		return p.dictLit()
+	}
+	// 無名関数
+	if p.peekIdent("func") != nil && p.peekNextKind(tokenize.Lrb) != nil {
+		return p.funcLit()
+	}
+
+	if id := p.consumeKind(tokenize.Ident); id != nil {
 		// call
-		if lrb := consumeKind(tokenize.Lrb); lrb != nil {
-			if consumeKind(tokenize.Rrb) != nil {
+		if lrb := p.consumeKind(tokenize.Lrb); lrb != nil {
+			if p.consumeKind(tokenize.Rrb) != nil {
 				return NewCallNode(id.Pos, NewIdentNode(id.Pos, id.Literal.S), nil), nil
 			}
-			args, err := callArgs()
+			args, err := p.nested(p.callArgs)
 			if err != nil {
 				return nil, err
 			}
-			_, err = expectKind(tokenize.Rrb)
+			_, err = p.expectKind(tokenize.Rrb)
 			if err != nil {
 				return nil, err
 			}
 			return NewCallNode(id.Pos, NewIdentNode(id.Pos, id.Literal.S), args), nil
 		}
+		// 複合リテラル
+		if !p.noCompositeLit && p.peekKind(tokenize.Lcb) != nil {
+			return p.structLit(id)
+		}
 		// ident
 		return NewIdentNode(id.Pos, id.Literal.S), nil
 	}
 
-	if i := consumeKind(tokenize.Int); i != nil {
+	// 配列、スライスのリテラル
+	if p.peekKind(tokenize.Lsb) != nil {
+		return p.listLit()
+	}
+
+	if i := p.consumeKind(tokenize.Int); i != nil {
 		return NewLiteralNode(i.Pos, i.Literal), nil
 	}
-	if f := consumeKind(tokenize.Float); f != nil {
+	if f := p.consumeKind(tokenize.Float); f != nil {
 		return NewLiteralNode(f.Pos, f.Literal), nil
 	}
-	if s := consumeKind(tokenize.String); s != nil {
+	if s := p.consumeKind(tokenize.String); s != nil {
 		return NewLiteralNode(s.Pos, s.Literal), nil
 	}
-	if b := consumeKind(tokenize.Bool); b != nil {
+	if b := p.consumeKind(tokenize.Bool); b != nil {
 		return NewLiteralNode(b.Pos, b.Literal), nil
 	}
-	if n := consumeKind(tokenize.Nil); n != nil {
+	if n := p.consumeKind(tokenize.Nil); n != nil {
 		return NewLiteralNode(n.Pos, n.Literal), nil
 	}
 
-	return nil, fmt.Errorf("[%d:%d] unexpected token: %v, expected literal",
-		token.Pos.LineNo, token.Pos.Lat, token.Kind.String())
+	return nil, diagnostic.New(p.token.Span(), "P0007", p.token.Kind.String())
 }
 
-func types() (*Node, error) {
-	id, err := expectKind(tokenize.Ident)
+// structLit `Point{x: 1, y: 2.0}`
+func (p *Parser) structLit(id *tokenize.Token) (*Node, error) {
+	_, err := p.expectKind(tokenize.Lcb)
+	if err != nil {
+		return nil, err
+	}
+	var fields []*Node
+	for p.consumeKind(tokenize.Rcb) == nil {
+		key, err := p.expectKind(tokenize.Ident)
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expectKind(tokenize.Colon)
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.nested(p.expr)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, NewKVNode(key.Pos, NewIdentNode(key.Pos, key.Literal.S), value))
+		// 最後の要素の後ろのカンマは省略可能
+		if p.consumeKind(tokenize.Comma) == nil {
+			_, err = p.expectKind(tokenize.Rcb)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return NewStructLitNode(id.Pos, NewDataTypeNode(id.Pos, GetDataTypeByIdent(id.Literal.S)), fields), nil
+}
+
+// listLit `[]int{1, 2, 3}`, `[3]int{1, 2, 3}`
+func (p *Parser) listLit() (*Node, error) {
+	typ, err := p.types()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.expectKind(tokenize.Lcb)
+	if err != nil {
+		return nil, err
+	}
+	var values []*Node
+	for p.consumeKind(tokenize.Rcb) == nil {
+		value, err := p.nested(p.expr)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		// 最後の要素の後ろのカンマは省略可能
+		if p.consumeKind(tokenize.Comma) == nil {
+			_, err = p.expectKind(tokenize.Rcb)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return NewListNode(typ.Pos, typ, values), nil
+}
+
+// funcType `func(int, string) (int, bool)`
+func (p *Parser) funcType() (*Node, error) {
+	f := p.consumeIdent("func")
+	_ = p.consumeKind(tokenize.Lrb)
+	typ, err := p.signature()
+	if err != nil {
+		return nil, err
+	}
+	return NewDataTypeNode(f.Pos, typ), nil
+}
+
+// signature 関数型の`(`より後ろ `int, string) (int, bool)`
+func (p *Parser) signature() (*DataType, error) {
+	var params []*DataType
+	var rrb *tokenize.Token
+	for rrb = p.consumeKind(tokenize.Rrb); rrb == nil; rrb = p.consumeKind(tokenize.Rrb) {
+		typ, err := p.types()
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, typ.DataTypeField.DataType)
+		if p.consumeKind(tokenize.Comma) == nil {
+			rrb, err = p.expectKind(tokenize.Rrb)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	var returns []*DataType
+	if p.hasFuncTypeReturns(rrb) {
+		rt, err := p.funcReturns()
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rt.PolynomialField.Values {
+			returns = append(returns, r.DataTypeField.DataType)
+		}
+	}
+	return NewFuncType(params, returns), nil
+}
+
+// hasFuncTypeReturns 関数型の`)`の後ろに戻り値の型が続くか
+// 改行を区別しないので、戻り値の型は`)`と同じ行に書かれ、型の始まりになるトークンで始まるものとする
+// `func`も識別子なので、`func() func() int`の戻り値も関数型として読む
+func (p *Parser) hasFuncTypeReturns(rrb *tokenize.Token) bool {
+	if p.token.Pos == nil || rrb.Pos == nil || p.token.Pos.File != rrb.Pos.File || p.token.Pos.LineNo != rrb.Pos.LineNo {
+		return false
+	}
+	switch p.token.Kind {
+	case tokenize.Lrb, tokenize.Lsb, tokenize.Ident:
+		return true
+	}
+	return false
+}
+
+// funcLit `func(x int) int { return x }`
+func (p *Parser) funcLit() (*Node, error) {
+	f := p.consumeIdent("func")
+	_ = p.consumeKind(tokenize.Lrb)
+	var params *Node
+	var err error
+	if p.consumeKind(tokenize.Rrb) == nil {
+		params, err = p.funcParams()
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expectKind(tokenize.Rrb)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var returns *Node
+	if p.peekKind(tokenize.Lcb) == nil {
+		returns, err = p.funcReturns()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// 条件部分に書かれていても本体はブロックとして解析する
+	body, err := p.nested(p.stmt)
 	if err != nil {
 		return nil, err
 	}
+	return NewFuncLitNode(f.Pos, params, returns, body), nil
+}
+
+// dictLit `map[string]int{"a": 1, "b": 2}`
+func (p *Parser) dictLit() (*Node, error) {
+	typ, err := p.types()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.expectKind(tokenize.Lcb)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*Node
+	for p.consumeKind(tokenize.Rcb) == nil {
+		key, err := p.nested(p.expr)
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expectKind(tokenize.Colon)
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.nested(p.expr)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, NewKVNode(key.Pos, key, value))
+		// 最後の要素の後ろのカンマは省略可能
+		if p.consumeKind(tokenize.Comma) == nil {
+			_, err = p.expectKind(tokenize.Rcb)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return NewDictNode(typ.Pos, typ, entries), nil
+}
+
+// structType `struct { x int; y float }`
+// フィールドの型は意味解析で解決される
+func (p *Parser) structType(st *tokenize.Token, name string) (*Node, error) {
+	_, err := p.expectKind(tokenize.Lcb)
+	if err != nil {
+		return nil, err
+	}
+	var fields []*StructField
+	for p.consumeKind(tokenize.Rcb) == nil {
+		fieldId, err := p.expectKind(tokenize.Ident)
+		if err != nil {
+			return nil, err
+		}
+		typ, err := p.types()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, &StructField{
+			Name:     fieldId.Literal.S,
+			DataType: typ.DataTypeField.DataType,
+			TypePos:  typ.Pos,
+		})
+		_ = p.consumeKind(tokenize.Semi)
+	}
+	return NewDataTypeNode(st.Pos, &DataType{
+		Ident:  name,
+		Type:   Struct,
+		Base:   nil,
+		Fields: fields,
+	}), nil
+}
+
+// interfaceType `interface { Area() int; Name() string }`
+// メソッドの型は意味解析で解決される
+func (p *Parser) interfaceType(it *tokenize.Token, name string) (*Node, error) {
+	_, err := p.expectKind(tokenize.Lcb)
+	if err != nil {
+		return nil, err
+	}
+	var methods []*Method
+	for p.consumeKind(tokenize.Rcb) == nil {
+		methodId, err := p.expectKind(tokenize.Ident)
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expectKind(tokenize.Lrb)
+		if err != nil {
+			return nil, err
+		}
+		typ, err := p.signature()
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, &Method{
+			Name:     methodId.Literal.S,
+			DataType: typ,
+		})
+		_ = p.consumeKind(tokenize.Semi)
+	}
+	return NewDataTypeNode(it.Pos, &DataType{
+		Ident:   name,
+		Type:    Interface,
+		Methods: methods,
+	}), nil
+}
+
+func (p *Parser) types() (*Node, error) {
+	if lsb := p.consumeKind(tokenize.Lsb); lsb != nil {
+		// "[" "]" types
+		if p.consumeKind(tokenize.Rsb) != nil {
+			base, err := p.types()
+			if err != nil {
+				return nil, err
+			}
+			return NewDataTypeNode(lsb.Pos, NewSliceType(base.DataTypeField.DataType)), nil
+		}
+		// "[" int "]" types
+		if n := p.peekKind(tokenize.Int); n != nil && p.peekNextKind(tokenize.Rsb) != nil {
+			_ = p.consumeKind(tokenize.Int)
+			_ = p.consumeKind(tokenize.Rsb)
+			base, err := p.types()
+			if err != nil {
+				return nil, err
+			}
+			return NewDataTypeNode(lsb.Pos, NewArrayType(base.DataTypeField.DataType, n.Literal.I)), nil
+		}
+		// "[" expr "]" types, 長さの定数式は意味解析で評価する
+		length, err := p.nested(p.expr)
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expectKind(tokenize.Rsb)
+		if err != nil {
+			return nil, err
+		}
+		base, err := p.types()
+		if err != nil {
+			return nil, err
+		}
+		return NewDataTypeNode(lsb.Pos, NewConstArrayType(base.DataTypeField.DataType, length)), nil
+	}
+	// "func" "(" (types ("," types)*)? ")" funcReturns?
+	if p.peekIdent("func") != nil && p.peekNextKind(tokenize.Lrb) != nil {
+		return p.funcType()
+	}
+	// "map" "[" types "]" types
+	if p.peekIdent("map") != nil && p.peekNextKind(tokenize.Lsb) != nil {
+		m := p.consumeIdent("map")
+		_ = p.consumeKind(tokenize.Lsb)
+		key, err := p.types()
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expectKind(tokenize.Rsb)
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.types()
+		if err != nil {
+			return nil, err
+		}
+		return NewDataTypeNode(m.Pos, NewMapType(key.DataTypeField.DataType, value.DataTypeField.DataType)), nil
+	}
+	id, err := p.expectKind(tokenize.Ident)
+	if err != nil {
+		return nil, err
+	}
+	// 他のパッケージの型`パッケージ名.型名`, 意味解析でインポートしたパッケージから探す
+	if p.consumeKind(tokenize.Dot) != nil {
+		name, err := p.expectKind(tokenize.Ident)
+		if err != nil {
+			return nil, err
+		}
+		return NewDataTypeNode(id.Pos, GetDataTypeByIdent(id.Literal.S+"."+name.Literal.S)), nil
+	}
 	return NewDataTypeNode(id.Pos, GetDataTypeByIdent(id.Literal.S)), nil
 }
 
-func callArgs() (*Node, error) {
+func (p *Parser) callArgs() (*Node, error) {
 	var args []*Node
 	//first, err := expr()
 	//if err != nil {
@@ -637,39 +1409,39 @@ func callArgs() (*Node, error) {
 	//	args = append(args, arg)
 	//}
 	for {
-		arg, err := expr()
+		arg, err := p.expr()
 		if err != nil {
 			return nil, err
 		}
 		args = append(args, arg)
-		if consumeKind(tokenize.Comma) == nil {
+		if p.consumeKind(tokenize.Comma) == nil {
 			break
 		}
 	}
 	return NewPolynomialNode(NdArgs, args[0].Pos, args), nil
 }
 
-func funcParams() (*Node, error) {
+func (p *Parser) funcParams() (*Node, error) {
 	var params []*Node
 
-	firstIdT, err := expectKind(tokenize.Ident)
+	firstIdT, err := p.expectKind(tokenize.Ident)
 	if err != nil {
 		return nil, err
 	}
 	firstId := NewIdentNode(firstIdT.Pos, firstIdT.Literal.S)
-	firstType, err := types()
+	firstType, err := p.types()
 	if err != nil {
 		return nil, err
 	}
 	params = append(params, NewFuncParamNode(firstIdT.Pos, firstId, firstType))
 
-	for consumeKind(tokenize.Comma) != nil {
-		idt, err := expectKind(tokenize.Ident)
+	for p.consumeKind(tokenize.Comma) != nil {
+		idt, err := p.expectKind(tokenize.Ident)
 		if err != nil {
 			return nil, err
 		}
 		id := NewIdentNode(idt.Pos, idt.Literal.S)
-		typ, err := types()
+		typ, err := p.types()
 		if err != nil {
 			return nil, err
 		}
@@ -679,26 +1451,26 @@ func funcParams() (*Node, error) {
 	return NewPolynomialNode(NdParams, firstIdT.Pos, params), nil
 }
 
-func funcReturns() (*Node, error) {
+func (p *Parser) funcReturns() (*Node, error) {
 	var returnTypes []*Node
 
-	lrb := consumeKind(tokenize.Lrb)
+	lrb := p.consumeKind(tokenize.Lrb)
 	if lrb == nil {
-		typ, err := types()
+		typ, err := p.types()
 		if err != nil {
 			return nil, err
 		}
 		return NewPolynomialNode(NdReturnTypes, typ.Pos, []*Node{typ}), nil
 	}
 
-	for consumeKind(tokenize.Rrb) == nil {
-		typ, err := types()
+	for p.consumeKind(tokenize.Rrb) == nil {
+		typ, err := p.types()
 		if err != nil {
 			return nil, err
 		}
 		returnTypes = append(returnTypes, typ)
-		if consumeKind(tokenize.Comma) == nil {
-			_, err = expectKind(tokenize.Rrb)
+		if p.consumeKind(tokenize.Comma) == nil {
+			_, err = p.expectKind(tokenize.Rrb)
 			if err != nil {
 				return nil, err
 			}
@@ -2,6 +2,7 @@ package parse_test
 
 import (
 	"fmt"
+	"github.com/arrietty-lang/arrtty/diagnostic"
 	"github.com/arrietty-lang/arrtty/preprocess/parse"
 	"github.com/arrietty-lang/arrtty/preprocess/tokenize"
 	"testing"
@@ -14,6 +15,11 @@ func TestNewNode(t *testing.T) {
 
 func TestParseWork(t *testing.T) {
 	code := `
+	import "fmt"
+	import (
+		"example.com/geo/shapes"
+		m "math"; _ "init"
+	)
 	var gA int = 1
 	func sayHelloS(name sting) string {
 		var hello string = "hello, "
@@ -32,6 +38,54 @@ func TestParseWork(t *testing.T) {
 		}
 		return z, isMinus
 	}
+	func kind(n int) string {
+		switch n {
+		case 0, 1:
+			return "small"
+		default:
+		}
+		switch {
+		case n < 0:
+			return "minus"
+		}
+		return "other"
+	}
+	func head(xs []int, grid [2][2]int) []int {
+		xs[0] = grid[1][0]
+		return append(xs[1:], []int{1, 2,}[0], xs[:1][0])
+	}
+	func keys(m map[string]int) int {
+		m["a"] = 1
+		v, ok := m["b"]
+		for k, v := range m {
+			delete(m, k)
+		}
+		return len(map[int]bool{1: true, 2: false,})
+	}
+	func adder(n int) func(int) int {
+		f := func(x int) int { return x + n }
+		return func(x int) int { return f(x) }
+	}
+	func compose(f func(int) int, g func(int) int) int {
+		return g(f(1))
+	}
+	const Max = 10
+	const (
+		A int = iota * 2
+		B
+		C
+	)
+	func grid() [Max][C]int {
+		var g [Max][C]int
+		return g
+	}
+	type Shape interface {
+		Area() int
+		Scale(float) (Shape, bool)
+	}
+	func (r Rect) Area() int {
+		return r.w * r.h
+	}
 	`
 	head, err := tokenize.Tokenize(code)
 	if err != nil {
@@ -43,3 +97,123 @@ func TestParseWork(t *testing.T) {
 	}
 	fmt.Println(nodes)
 }
+
+func TestParse_Imports(t *testing.T) {
+	code := `import "a/b/c"
+import (
+	"x"
+	alias "y/z"
+)
+`
+	head, err := tokenize.Tokenize(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := parse.Parse(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, node := range parse.Imports(nodes) {
+		names = append(names, node.ImportField.Target+"="+node.ImportField.Name())
+	}
+	if fmt.Sprint(names) != "[a/b/c=c x=x y/z=alias]" {
+		t.Fatalf("importが正しく読めていません: %v", names)
+	}
+}
+
+func TestParse_QualifiedType(t *testing.T) {
+	code := `var q geo.Point
+func f(ps []geo.Point) map[string]geo.Point {
+	return nil
+}
+`
+	head, err := tokenize.Tokenize(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := parse.Parse(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := nodes[1].FuncDefField
+	idents := []string{
+		nodes[0].VarDeclField.Type.DataTypeField.DataType.Ident,
+		fn.Parameters.PolynomialField.Values[0].FuncParam.DataType.DataTypeField.DataType.Ident,
+		fn.Returns.PolynomialField.Values[0].DataTypeField.DataType.Ident,
+	}
+	if fmt.Sprint(idents) != "[geo.Point []geo.Point map[string]geo.Point]" {
+		t.Fatalf("パッケージ名の付いた型が正しく読めていません: %v", idents)
+	}
+}
+
+func TestParse_FuncType(t *testing.T) {
+	code := `func outer(f func() func() int) func() func() int {
+	var g func() func() int
+	var h func()
+	x = 1
+	var k func()
+	x += 1
+	return g
+}
+`
+	head, err := tokenize.Tokenize(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := parse.Parse(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := nodes[0].FuncDefField
+	stmts := fn.Body.BlockField.Statements
+	idents := []string{
+		fn.Parameters.PolynomialField.Values[0].FuncParam.DataType.DataTypeField.DataType.Ident,
+		fn.Returns.PolynomialField.Values[0].DataTypeField.DataType.Ident,
+		stmts[0].VarDeclField.Type.DataTypeField.DataType.Ident,
+		// 次の行の文は戻り値の型にしない
+		stmts[1].VarDeclField.Type.DataTypeField.DataType.Ident,
+		stmts[3].VarDeclField.Type.DataTypeField.DataType.Ident,
+	}
+	want := "[func() func() int func() func() int func() func() int func() func()]"
+	if fmt.Sprint(idents) != want {
+		t.Fatalf("関数型が正しく読めていません: %v", idents)
+	}
+	if len(stmts) != 6 {
+		t.Fatalf("文の数が正しくありません: %d", len(stmts))
+	}
+
+	// 本体がブロックでない関数の定義はエラーにする
+	head, err = tokenize.Tokenize("func f() int\nreturn 1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse.Parse(head); err == nil {
+		t.Fatal("本体のない関数の定義が読めてしまいました")
+	}
+}
+
+func TestParse_Recover(t *testing.T) {
+	code := `func f() int {
+	return 1 +
+}
+func g() {
+	x := [
+}
+var ok int
+func h() {
+}
+`
+	head, err := tokenize.Tokenize(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parse.Parse(head)
+	diagnostics := diagnostic.Flatten(err)
+	if len(diagnostics) != 2 {
+		t.Fatalf("2つの定義のエラーが報告されるべきです: %v", err)
+	}
+	if diagnostics[0].Span.Line != 3 || diagnostics[1].Span.Line != 6 {
+		t.Fatalf("エラーの位置が正しくありません: %v", err)
+	}
+}
@@ -0,0 +1,11 @@
+package parse
+
+type StructLitField struct {
+	Type   *Node
+	Fields []*Node
+}
+
+type KVField struct {
+	Key   *Node
+	Value *Node
+}
@@ -0,0 +1,13 @@
+package parse
+
+type SwitchField struct {
+	// Tag `switch {`のようにタグを省略した場合はnil
+	Tag   *Node
+	Cases []*Node
+}
+
+type CaseField struct {
+	IsDefault bool
+	Values    []*Node
+	Body      *Node
+}
@@ -0,0 +1,6 @@
+package parse
+
+type TypeDefField struct {
+	Identifier *Node
+	Type       *Node
+}
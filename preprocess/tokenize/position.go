@@ -1,6 +1,10 @@
 package tokenize
 
+import "github.com/arrietty-lang/arrtty/diagnostic"
+
 type Position struct {
+	// File トークンを読み込んだファイル, 文字列から読み込んだ場合は空
+	File   string
 	LineNo int
 	Lat    int
 	Wat    int
@@ -8,6 +12,7 @@ type Position struct {
 
 func (p *Position) Clone() *Position {
 	return &Position{
+		File:   p.File,
 		LineNo: p.LineNo,
 		Lat:    p.Lat,
 		Wat:    p.Wat,
@@ -21,3 +26,8 @@ func NewPosition(ln, lat, wat int) *Position {
 		Wat:    wat,
 	}
 }
+
+// Span 位置からn文字の範囲
+func (p *Position) Span(n int) *diagnostic.Span {
+	return &diagnostic.Span{File: p.File, Line: p.LineNo, Col: p.Lat, Len: n}
+}
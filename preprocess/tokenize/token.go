@@ -1,6 +1,9 @@
 package tokenize
 
-import "log"
+import (
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"log"
+)
 
 type Token struct {
 	Kind TokenKind
@@ -119,6 +122,10 @@ func NewOpSymbolChain(cur *Token, pos *Position, str string) *Token {
 		tok = NewToken(ModAssign, pos, nil)
 	case ":=":
 		tok = NewToken(ColonAssign, pos, nil)
+	case "++":
+		tok = NewToken(Inc, pos, nil)
+	case "--":
+		tok = NewToken(Dec, pos, nil)
 
 	case "&&":
 		tok = NewToken(And, pos, nil)
@@ -194,3 +201,15 @@ func NewNewlineChain(cur *Token, pos *Position, s string) *Token {
 	cur.Next = tok
 	return tok
 }
+
+// Span トークンの範囲, 識別子と文字列以外は先頭の1文字とする
+func (t *Token) Span() *diagnostic.Span {
+	n := 1
+	switch {
+	case t.Kind == Ident:
+		n = len([]rune(t.Literal.S))
+	case t.Kind == String:
+		n = len([]rune(t.Literal.S)) + 2
+	}
+	return t.Pos.Span(n)
+}
@@ -51,6 +51,8 @@ const (
 	DivAssign
 	ModAssign
 	ColonAssign
+	Inc
+	Dec
 
 	And
 	Or
@@ -99,6 +101,8 @@ var tokenKinds = [...]string{
 	DivAssign:   "DivAssign",
 	ModAssign:   "ModAssign",
 	ColonAssign: "ColonAssign",
+	Inc:         "Inc",
+	Dec:         "Dec",
 	And:         "And",
 	Or:          "Or",
 	Not:         "Not",
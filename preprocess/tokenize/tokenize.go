@@ -1,14 +1,22 @@
 package tokenize
 
 import (
-	"fmt"
+	"github.com/arrietty-lang/arrtty/diagnostic"
 	"strconv"
 	"unicode"
 )
 
-var userInput []rune
+// Tokenizer 入力と読んでいる位置を持つ
+// それぞれのTokenizerは独立しているので、別々のゴルーチンで同時に使える
+type Tokenizer struct {
+	userInput  []rune
+	currentPos *Position
+}
 
-var currentPos *Position
+// NewTokenizer 新しいTokenizer
+func NewTokenizer() *Tokenizer {
+	return &Tokenizer{}
+}
 
 var singleOpSymbols []string
 var compositeOpSymbols []string
@@ -26,13 +34,14 @@ func init() {
 		"==", "!=", ">=", "<=",
 		"+=", "-=", "*=", "/=", "%=",
 		"&&", "||", ":=",
+		"++", "--",
 	}
 }
 
-func startWith(s string) bool {
+func (t *Tokenizer) startWith(s string) bool {
 	runes := []rune(s)
 	for i := 0; i < len(runes); i++ {
-		if len(userInput) <= currentPos.Wat+i || userInput[currentPos.Wat+i] != runes[i] {
+		if len(t.userInput) <= t.currentPos.Wat+i || t.userInput[t.currentPos.Wat+i] != runes[i] {
 			return false
 		}
 	}
@@ -43,118 +52,118 @@ func isIdentRune(r rune) bool {
 	return ('a' <= r && r <= 'z') ||
 		('A' <= r && r <= 'Z') ||
 		('0' <= r && r <= '9') ||
-		('_' == r || '.' == r)
+		'_' == r
 }
 
-func isNotEof() bool {
-	return currentPos.Wat < len(userInput)
+func (t *Tokenizer) isNotEof() bool {
+	return t.currentPos.Wat < len(t.userInput)
 }
 
-func consumeComment() string {
+func (t *Tokenizer) consumeComment() string {
 	// "//"
-	currentPos.Lat += 2
-	currentPos.Wat += 2
+	t.currentPos.Lat += 2
+	t.currentPos.Wat += 2
 	var s string
-	for isNotEof() {
-		if userInput[currentPos.Wat] == '\n' {
+	for t.isNotEof() {
+		if t.userInput[t.currentPos.Wat] == '\n' {
 			break
 		}
-		s += string(userInput[currentPos.Wat])
-		currentPos.Lat++
-		currentPos.Wat++
+		s += string(t.userInput[t.currentPos.Wat])
+		t.currentPos.Lat++
+		t.currentPos.Wat++
 	}
 	return s
 }
 
-func consumeIdent() string {
+func (t *Tokenizer) consumeIdent() string {
 	var s string
-	for isNotEof() {
-		if !isIdentRune(userInput[currentPos.Wat]) {
+	for t.isNotEof() {
+		if !isIdentRune(t.userInput[t.currentPos.Wat]) {
 			break
 		}
-		s += string(userInput[currentPos.Wat])
-		currentPos.Lat++
-		currentPos.Wat++
+		s += string(t.userInput[t.currentPos.Wat])
+		t.currentPos.Lat++
+		t.currentPos.Wat++
 	}
 	// textは後で検査
 	return s
 }
 
-func consumeString() string {
+func (t *Tokenizer) consumeString() string {
 	var s string
 	// "
-	currentPos.Lat++
-	currentPos.Wat++
+	t.currentPos.Lat++
+	t.currentPos.Wat++
 
-	for isNotEof() {
-		if userInput[currentPos.Wat] == '"' {
+	for t.isNotEof() {
+		if t.userInput[t.currentPos.Wat] == '"' {
 			break
 		}
 		// escaped double quotation
-		if userInput[currentPos.Wat] == '\\' && userInput[currentPos.Wat+1] == '"' {
+		if t.userInput[t.currentPos.Wat] == '\\' && t.userInput[t.currentPos.Wat+1] == '"' {
 			s += "\""
-			currentPos.Lat += 2
-			currentPos.Wat += 2
+			t.currentPos.Lat += 2
+			t.currentPos.Wat += 2
 			continue
 		}
 		// newline
-		if userInput[currentPos.Wat] == '\\' && userInput[currentPos.Wat+1] == 'n' {
+		if t.userInput[t.currentPos.Wat] == '\\' && t.userInput[t.currentPos.Wat+1] == 'n' {
 			s += "\n"
-			currentPos.Lat += 2
-			currentPos.Wat += 2
+			t.currentPos.Lat += 2
+			t.currentPos.Wat += 2
 			continue
 		}
 		// tab
-		if userInput[currentPos.Wat] == '\\' && userInput[currentPos.Wat+1] == 't' {
+		if t.userInput[t.currentPos.Wat] == '\\' && t.userInput[t.currentPos.Wat+1] == 't' {
 			s += "\t"
-			currentPos.Lat += 2
-			currentPos.Wat += 2
+			t.currentPos.Lat += 2
+			t.currentPos.Wat += 2
 			continue
 		}
 		// escaped single quotation
-		if userInput[currentPos.Wat] == '\\' && userInput[currentPos.Wat+1] == '\'' {
+		if t.userInput[t.currentPos.Wat] == '\\' && t.userInput[t.currentPos.Wat+1] == '\'' {
 			s += "'"
-			currentPos.Lat += 2
-			currentPos.Wat += 2
+			t.currentPos.Lat += 2
+			t.currentPos.Wat += 2
 			continue
 		}
 		// escaped? slash
-		if userInput[currentPos.Wat] == '\\' && userInput[currentPos.Wat+1] == '\\' {
+		if t.userInput[t.currentPos.Wat] == '\\' && t.userInput[t.currentPos.Wat+1] == '\\' {
 			s += "\\"
-			currentPos.Lat += 2
-			currentPos.Wat += 2
+			t.currentPos.Lat += 2
+			t.currentPos.Wat += 2
 			continue
 		}
 
-		s += string(userInput[currentPos.Wat])
-		currentPos.Lat++
-		currentPos.Wat++
+		s += string(t.userInput[t.currentPos.Wat])
+		t.currentPos.Lat++
+		t.currentPos.Wat++
 	}
 
 	// "
-	currentPos.Lat++
-	currentPos.Wat++
+	t.currentPos.Lat++
+	t.currentPos.Wat++
 	return s
 }
 
-func consumeNumber() (string, bool) {
+func (t *Tokenizer) consumeNumber() (string, bool) {
 	isFloat := false
 	var s string
-	for isNotEof() {
-		if unicode.IsDigit(userInput[currentPos.Wat]) {
-			s += string(userInput[currentPos.Wat])
-			currentPos.Lat++
-			currentPos.Wat++
+	for t.isNotEof() {
+		if unicode.IsDigit(t.userInput[t.currentPos.Wat]) {
+			s += string(t.userInput[t.currentPos.Wat])
+			t.currentPos.Lat++
+			t.currentPos.Wat++
 			continue
-		} else if userInput[currentPos.Wat] == '.' {
+		} else if t.userInput[t.currentPos.Wat] == '.' {
 			// ポイントの次が、数字じゃなければ強制終了
-			if len(userInput) <= currentPos.Wat+1 ||
-				!unicode.IsDigit(userInput[currentPos.Wat+1]) {
+			if len(t.userInput) <= t.currentPos.Wat+1 ||
+				!unicode.IsDigit(t.userInput[t.currentPos.Wat+1]) {
 				break
 			}
-			s += string(userInput[currentPos.Wat])
-			currentPos.Lat++
-			currentPos.Wat++
+			s += string(t.userInput[t.currentPos.Wat])
+			t.currentPos.Lat++
+			t.currentPos.Wat++
 			isFloat = true
 			continue
 		} else {
@@ -164,13 +173,13 @@ func consumeNumber() (string, bool) {
 	return s, isFloat
 }
 
-func consumeWhite() string {
+func (t *Tokenizer) consumeWhite() string {
 	var s string
-	for isNotEof() {
-		if userInput[currentPos.Wat] == ' ' || userInput[currentPos.Wat] == '\t' {
-			s += string(userInput[currentPos.Wat])
-			currentPos.Lat++
-			currentPos.Wat++
+	for t.isNotEof() {
+		if t.userInput[t.currentPos.Wat] == ' ' || t.userInput[t.currentPos.Wat] == '\t' {
+			s += string(t.userInput[t.currentPos.Wat])
+			t.currentPos.Lat++
+			t.currentPos.Wat++
 		} else {
 			break
 		}
@@ -178,81 +187,114 @@ func consumeWhite() string {
 	return s
 }
 
+// Tokenize 新しいTokenizerで入力を読み込む
 func Tokenize(input string) (*Token, error) {
-	userInput = []rune(input)
-	currentPos = NewPosition(1, 0, 0)
+	return NewTokenizer().Tokenize(input)
+}
+
+// TokenizeFile 新しいTokenizerでファイルの内容を読み込む
+func TokenizeFile(filename string, input string) (*Token, error) {
+	return NewTokenizer().TokenizeFile(filename, input)
+}
+
+// Tokenize 入力を読み込む
+func (t *Tokenizer) Tokenize(input string) (*Token, error) {
+	return t.TokenizeFile("", input)
+}
+
+// TokenizeFile ファイルの内容を読み込む, 全ての位置はファイル名を持つ
+func (t *Tokenizer) TokenizeFile(filename string, input string) (*Token, error) {
+	t.userInput = []rune(input)
+	t.currentPos = NewPosition(1, 0, 0)
+	t.currentPos.File = filename
 	var head Token
 	cur := &head
+	// 読めない文字は飛ばして、全て報告する
+	var diagnostics diagnostic.List
 inputLoop:
-	for isNotEof() {
+	for t.isNotEof() {
 		// white
-		if userInput[currentPos.Wat] == ' ' || userInput[currentPos.Wat] == '\t' {
+		if t.userInput[t.currentPos.Wat] == ' ' || t.userInput[t.currentPos.Wat] == '\t' {
 			// もし、入力の完全な復元をするのであれば、これは捨てるべきではない
-			_ = currentPos.Clone()
-			_ = consumeWhite()
+			_ = t.currentPos.Clone()
+			_ = t.consumeWhite()
 			continue
 		}
 		// newline
-		if userInput[currentPos.Wat] == '\n' || userInput[currentPos.Wat] == '\r' {
+		if t.userInput[t.currentPos.Wat] == '\n' || t.userInput[t.currentPos.Wat] == '\r' {
 			//cur = NewNewlineChain(cur, currentPos.Clone(), "\n")
-			currentPos.LineNo++
-			currentPos.Lat = 0
-			currentPos.Wat++
+			t.currentPos.LineNo++
+			t.currentPos.Lat = 0
+			t.currentPos.Wat++
 			continue
 		}
 		// comment
-		if userInput[currentPos.Wat] == '/' && userInput[currentPos.Wat+1] == '/' {
+		if t.userInput[t.currentPos.Wat] == '/' && t.userInput[t.currentPos.Wat+1] == '/' {
 			//pos := currentPos.Clone()
-			_ = consumeComment()
+			_ = t.consumeComment()
 			//cur = NewCommentChain(cur, pos, s)
 			continue
 		}
 		// op, symbols
 		for _, r := range append(compositeOpSymbols, singleOpSymbols...) {
-			if startWith(r) {
-				cur = NewOpSymbolChain(cur, currentPos.Clone(), r)
-				currentPos.Lat += len(r)
-				currentPos.Wat += len(r)
+			if t.startWith(r) {
+				cur = NewOpSymbolChain(cur, t.currentPos.Clone(), r)
+				t.currentPos.Lat += len(r)
+				t.currentPos.Wat += len(r)
 				// 直前のループではなく全体をコンティニュー
 				continue inputLoop
 			}
 		}
 		// ident
-		if isIdentRune(userInput[currentPos.Wat]) && !unicode.IsDigit(userInput[currentPos.Wat]) {
-			pos := currentPos.Clone()
-			id := consumeIdent()
+		if isIdentRune(t.userInput[t.currentPos.Wat]) && !unicode.IsDigit(t.userInput[t.currentPos.Wat]) {
+			pos := t.currentPos.Clone()
+			id := t.consumeIdent()
+			// true, false, nilはリテラルとして扱う
+			switch id {
+			case "true", "false":
+				cur = NewLiteralChain(cur, pos, NewBoolLiteral(id == "true"))
+				continue
+			case "nil":
+				cur = NewLiteralChain(cur, pos, NewNilLiteral())
+				continue
+			}
 			cur = NewIdentChain(cur, pos, id)
 			continue
 		}
 		// string
-		if userInput[currentPos.Wat] == '"' {
-			pos := currentPos.Clone()
-			s := consumeString()
+		if t.userInput[t.currentPos.Wat] == '"' {
+			pos := t.currentPos.Clone()
+			s := t.consumeString()
 			cur = NewLiteralChain(cur, pos, NewStringLiteral(s))
 			continue
 		}
 		// number
-		if unicode.IsDigit(userInput[currentPos.Wat]) {
-			pos := currentPos.Clone()
-			numS, isFloat := consumeNumber()
+		if unicode.IsDigit(t.userInput[t.currentPos.Wat]) {
+			pos := t.currentPos.Clone()
+			numS, isFloat := t.consumeNumber()
 			if isFloat {
 				n, err := strconv.ParseFloat(numS, 64)
 				if err != nil {
-					return nil, err
+					diagnostics.Add(err, pos.Span(len(numS)))
 				}
 				cur = NewLiteralChain(cur, pos, NewFloatLiteral(n))
 				continue
 			} else {
 				n, err := strconv.ParseInt(numS, 10, 0)
 				if err != nil {
-					return nil, err
+					diagnostics.Add(err, pos.Span(len(numS)))
 				}
 				cur = NewLiteralChain(cur, pos, NewIntLiteral(int(n)))
 				continue
 			}
 		}
-		return nil, fmt.Errorf("unexpected charactor: %v", userInput[currentPos.Wat])
+		diagnostics = append(diagnostics, diagnostic.New(t.currentPos.Span(1), "T0001", t.userInput[t.currentPos.Wat]))
+		t.currentPos.Lat++
+		t.currentPos.Wat++
+	}
+	if err := diagnostics.Err(); err != nil {
+		return nil, err
 	}
-	cur = NewEofChain(cur, currentPos.Clone())
+	cur = NewEofChain(cur, t.currentPos.Clone())
 	return head.Next, nil
 }
@@ -52,6 +52,26 @@ func TestTokenize(t *testing.T) {
 				},
 			},
 		},
+		{
+			"inc",
+			"i++",
+			&Token{
+				Kind:    Ident,
+				Pos:     GenPosForTest(""),
+				Literal: NewStringLiteral("i"),
+				Next: &Token{
+					Kind:    Inc,
+					Pos:     GenPosForTest("i"),
+					Literal: nil,
+					Next: &Token{
+						Kind:    Eof,
+						Pos:     GenPosForTest("i++"),
+						Literal: nil,
+						Next:    nil,
+					},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
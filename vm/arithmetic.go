@@ -0,0 +1,168 @@
+package vm
+
+import (
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"github.com/gookit/slog"
+	"math"
+)
+
+func (v *Vm) mul(from, to Literal) (Literal, error) {
+	// [o] int *= float (floatに昇格)
+	// [o] float *= int
+	// [o] int *= int
+	// [o] float *= float
+	// other: error
+	switch to.GetKind() {
+	case KInt:
+		switch from.GetKind() {
+		case KInt:
+			return *NewLiteral(to.GetInt() * from.GetInt()), nil
+		case KFloat:
+			return *NewLiteral(float64(to.GetInt()) * from.GetFloat()), nil
+		default:
+			return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "*=", from.GetKind().String())
+		}
+	case KFloat:
+		switch from.GetKind() {
+		case KInt:
+			return *NewLiteral(to.GetFloat() * float64(from.GetInt())), nil
+		case KFloat:
+			return *NewLiteral(to.GetFloat() * from.GetFloat()), nil
+		default:
+			return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "*=", from.GetKind().String())
+		}
+	default:
+		return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "*=", from.GetKind().String())
+	}
+}
+
+func (v *Vm) div(from, to Literal) (Literal, error) {
+	// [o] int /= float (floatに昇格)
+	// [o] float /= int
+	// [o] int /= int
+	// [o] float /= float
+	// other: error
+	switch to.GetKind() {
+	case KInt:
+		switch from.GetKind() {
+		case KInt:
+			if from.GetInt() == 0 {
+				return Literal{}, diagnostic.Errorf("V0002")
+			}
+			return *NewLiteral(to.GetInt() / from.GetInt()), nil
+		case KFloat:
+			return *NewLiteral(float64(to.GetInt()) / from.GetFloat()), nil
+		default:
+			return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "/=", from.GetKind().String())
+		}
+	case KFloat:
+		switch from.GetKind() {
+		case KInt:
+			return *NewLiteral(to.GetFloat() / float64(from.GetInt())), nil
+		case KFloat:
+			return *NewLiteral(to.GetFloat() / from.GetFloat()), nil
+		default:
+			return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "/=", from.GetKind().String())
+		}
+	default:
+		return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "/=", from.GetKind().String())
+	}
+}
+
+func (v *Vm) mod(from, to Literal) (Literal, error) {
+	// [o] int %= float (floatに昇格)
+	// [o] float %= int
+	// [o] int %= int
+	// [o] float %= float
+	// other: error
+	switch to.GetKind() {
+	case KInt:
+		switch from.GetKind() {
+		case KInt:
+			if from.GetInt() == 0 {
+				return Literal{}, diagnostic.Errorf("V0002")
+			}
+			return *NewLiteral(to.GetInt() % from.GetInt()), nil
+		case KFloat:
+			return *NewLiteral(math.Mod(float64(to.GetInt()), from.GetFloat())), nil
+		default:
+			return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "%=", from.GetKind().String())
+		}
+	case KFloat:
+		switch from.GetKind() {
+		case KInt:
+			return *NewLiteral(math.Mod(to.GetFloat(), float64(from.GetInt()))), nil
+		case KFloat:
+			return *NewLiteral(math.Mod(to.GetFloat(), from.GetFloat())), nil
+		default:
+			return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "%=", from.GetKind().String())
+		}
+	default:
+		return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "%=", from.GetKind().String())
+	}
+}
+
+// arithmetic ADD, SUBと同じオペランドの組み合わせ(レジスタ同士、オフセットとリテラル)で計算を行う
+func (v *Vm) arithmetic(op Opcode, calc func(from, to Literal) (Literal, error)) error {
+	defer func() {
+		v.pc += 1 + op.CountOfOperand()
+	}()
+	from := v.program[v.pc+1]
+	to := v.program[v.pc+2]
+	switch to.kind {
+	case KRegisterTag:
+		switch from.kind {
+		case KRegisterTag:
+			// RTo op= RFrom
+			pFromVal, ok := v.GetRegisterByTag(from.registerTag)
+			if !ok {
+				return diagnostic.Errorf("V0003", from.registerTag.String())
+			}
+			pToVal, ok := v.GetRegisterByTag(to.registerTag)
+			if !ok {
+				return diagnostic.Errorf("V0003", to.registerTag.String())
+			}
+			slog.Info(op.String(), "from", from.registerTag.String(), "to", to.registerTag.String())
+			result, err := calc(pFromVal.literal, pToVal.literal)
+			if err != nil {
+				return err
+			}
+			return v.SetRegisterByTag(to.registerTag, NewLiteralData(result))
+		default:
+			return diagnostic.Errorf("V0004", op.String(), from.kind.String())
+		}
+	case KOffset:
+		switch from.kind {
+		case KLiteral:
+			// OffsetTo op= LiteralFrom
+			offset, err := v.calculateOffset(to.offset)
+			if err != nil {
+				return err
+			}
+			toVal := *v.stack[offset]
+			slog.Info(op.String(), "from", from.literal.String(), "to", to.offset.AddressString())
+			result, err := calc(from.literal, toVal.literal)
+			if err != nil {
+				return err
+			}
+			v.stack[offset] = NewLiteralData(result)
+			return nil
+		default:
+			return diagnostic.Errorf("V0004", op.String(), from.kind.String())
+		}
+	default:
+		return diagnostic.Errorf("V0004", op.String(), to.kind.String())
+	}
+}
+
+func (v *Vm) Mul() error {
+	return v.arithmetic(MUL, v.mul)
+}
+
+func (v *Vm) Div() error {
+	return v.arithmetic(DIV, v.div)
+}
+
+func (v *Vm) Mod() error {
+	return v.arithmetic(MOD, v.mod)
+}
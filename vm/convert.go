@@ -0,0 +1,103 @@
+package vm
+
+import (
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"strconv"
+)
+
+// conversionKind `conv`, `parse`のオペランドの変換先
+func (v *Vm) conversionKind(op Opcode) (string, error) {
+	kind := v.program[v.pc+1]
+	if kind.kind != KLiteral || kind.literal.GetKind() != KString {
+		return "", diagnostic.Errorf("V0005", op.String(), kind.String())
+	}
+	return kind.literal.GetString(), nil
+}
+
+// convert intへの変換は0方向に切り捨て、stringへの変換は10進数の表記にする
+func convert(l Literal, kind string) (Literal, error) {
+	switch kind {
+	case "int":
+		switch l.GetKind() {
+		case KInt:
+			return l, nil
+		case KFloat:
+			return *NewLiteral(int(l.GetFloat())), nil
+		}
+	case "float":
+		switch l.GetKind() {
+		case KInt:
+			return *NewLiteral(float64(l.GetInt())), nil
+		case KFloat:
+			return l, nil
+		}
+	case "string":
+		switch l.GetKind() {
+		case KInt:
+			return *NewLiteral(strconv.Itoa(l.GetInt())), nil
+		case KFloat:
+			return *NewLiteral(strconv.FormatFloat(l.GetFloat(), 'g', -1, 64)), nil
+		case KString:
+			return l, nil
+		}
+	}
+	return Literal{}, diagnostic.Errorf("V0006", l.GetKind().String(), kind)
+}
+
+func (v *Vm) Conv() error {
+	defer func() {
+		v.pc += 1 + CONV.CountOfOperand()
+	}()
+	kind, err := v.conversionKind(CONV)
+	if err != nil {
+		return err
+	}
+	d := v._pop()
+	if d.kind != KLiteral {
+		return diagnostic.Errorf("V0007", d.String())
+	}
+	result, err := convert(d.literal, kind)
+	if err != nil {
+		return err
+	}
+	v._push(*NewLiteralData(result))
+	return nil
+}
+
+// Parse 読めなかった場合はゼロ値をプッシュする
+func (v *Vm) Parse() error {
+	defer func() {
+		v.pc += 1 + PARSE.CountOfOperand()
+	}()
+	kind, err := v.conversionKind(PARSE)
+	if err != nil {
+		return err
+	}
+	d := v._pop()
+	if d.kind != KLiteral || d.literal.GetKind() != KString {
+		return diagnostic.Errorf("V0008", d.String())
+	}
+	var result *Literal
+	var perr error
+	switch kind {
+	case "int":
+		var i int
+		i, perr = strconv.Atoi(d.literal.GetString())
+		result = NewLiteral(i)
+	case "float":
+		var f float64
+		f, perr = strconv.ParseFloat(d.literal.GetString(), 64)
+		result = NewLiteral(f)
+	default:
+		return diagnostic.Errorf("V0009", kind)
+	}
+	if perr != nil {
+		v._push(*NewLiteralDataWithRaw(0))
+		zero, _ := convert(*NewLiteral(0), kind)
+		v._push(*NewLiteralData(zero))
+		return nil
+	}
+	v._push(*NewLiteralDataWithRaw(1))
+	v._push(*NewLiteralData(*result))
+	return nil
+}
@@ -60,6 +60,11 @@ func (d *Data) String() string {
 	return fmt.Sprintf("Data{ kind: %s, val: %s }", d.kind.String(), s)
 }
 
+// GetLabel ラベルであればそのラベル
+func (d *Data) GetLabel() (Label, bool) {
+	return d.label, d.kind == KLabel
+}
+
 func NewLiteralData(literal Literal) *Data {
 	return &Data{
 		kind:    KLiteral,
@@ -0,0 +1,189 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/arrietty-lang/arrtty/diagnostic"
+	"io"
+	"math"
+)
+
+// 命令をファイルに保存するための表現
+//
+//	kind  literal: kind 値 | offset: pointer relation | registerTag | label: define name | opcode
+//
+// 整数は可変長、文字列は長さの後にバイト列を続ける
+
+// MarshalBinary encoding.BinaryMarshaler
+func (d Data) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	putInt(&b, int(d.kind))
+	switch d.kind {
+	case KLiteral:
+		putInt(&b, int(d.literal.kind))
+		switch d.literal.kind {
+		case KString:
+			putString(&b, d.literal.s)
+		case KInt, KPointer:
+			putInt(&b, d.literal.i)
+		case KFloat:
+			putInt(&b, int(math.Float64bits(d.literal.f)))
+		}
+	case KOffset:
+		putInt(&b, int(d.offset.pointer))
+		putInt(&b, d.offset.relation)
+	case KRegisterTag:
+		putInt(&b, int(d.registerTag))
+	case KLabel:
+		define := 0
+		if d.label.define {
+			define = 1
+		}
+		putInt(&b, define)
+		putString(&b, d.label.name)
+	case KOpcode:
+		putInt(&b, int(d.opcode))
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary encoding.BinaryUnmarshaler
+func (d *Data) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	kind, err := getInt(r)
+	if err != nil {
+		return err
+	}
+	*d = Data{kind: DataKind(kind)}
+	switch d.kind {
+	case KLiteral:
+		k, err := getInt(r)
+		if err != nil {
+			return err
+		}
+		d.literal.kind = LiteralKind(k)
+		switch d.literal.kind {
+		case KString:
+			d.literal.s, err = getString(r)
+		case KInt, KPointer:
+			d.literal.i, err = getInt(r)
+		case KFloat:
+			var bits int
+			bits, err = getInt(r)
+			d.literal.f = math.Float64frombits(uint64(bits))
+		default:
+			return diagnostic.Errorf("V0035", d.literal.kind.String())
+		}
+		return err
+	case KOffset:
+		p, err := getInt(r)
+		if err != nil {
+			return err
+		}
+		d.offset.pointer = Pointer(p)
+		d.offset.relation, err = getInt(r)
+		return err
+	case KRegisterTag:
+		tag, err := getInt(r)
+		d.registerTag = RegisterTag(tag)
+		return err
+	case KLabel:
+		define, err := getInt(r)
+		if err != nil {
+			return err
+		}
+		d.label.define = define == 1
+		d.label.name, err = getString(r)
+		return err
+	case KOpcode:
+		op, err := getInt(r)
+		d.opcode = Opcode(op)
+		return err
+	}
+	return diagnostic.Errorf("V0035", d.kind.String())
+}
+
+func putInt(b *bytes.Buffer, n int) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutVarint(buf[:], int64(n))])
+}
+
+func putString(b *bytes.Buffer, s string) {
+	putInt(b, len(s))
+	b.WriteString(s)
+}
+
+func getInt(r *bytes.Reader) (int, error) {
+	n, err := binary.ReadVarint(r)
+	if err != nil {
+		return 0, diagnostic.Errorf("V0035", err)
+	}
+	return int(n), nil
+}
+
+func getString(r *bytes.Reader) (string, error) {
+	n, err := getInt(r)
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || r.Len() < n {
+		return "", diagnostic.Errorf("V0035", "string length")
+	}
+	s := make([]byte, n)
+	_, _ = r.Read(s)
+	return string(s), nil
+}
+
+// ProgramMagic リンクしたプログラムを保存したファイルの先頭
+const ProgramMagic = "arrx"
+
+// EncodeProgram リンクしたプログラムを書き出す
+func EncodeProgram(w io.Writer, program []Data) error {
+	var b bytes.Buffer
+	b.WriteString(ProgramMagic)
+	putInt(&b, len(program))
+	for _, d := range program {
+		data, err := d.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		putInt(&b, len(data))
+		b.Write(data)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// DecodeProgram EncodeProgramで書き出したプログラムを読み込む
+func DecodeProgram(r io.Reader) ([]Data, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, diagnostic.Errorf("V0035", err)
+	}
+	if !bytes.HasPrefix(raw, []byte(ProgramMagic)) {
+		return nil, diagnostic.Errorf("V0035", "not a program")
+	}
+	b := bytes.NewReader(raw[len(ProgramMagic):])
+	n, err := getInt(b)
+	if err != nil {
+		return nil, err
+	}
+	var program []Data
+	for i := 0; i < n; i++ {
+		size, err := getInt(b)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 || b.Len() < size {
+			return nil, diagnostic.Errorf("V0035", "instruction length")
+		}
+		data := make([]byte, size)
+		_, _ = b.Read(data)
+		var d Data
+		if err := d.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		program = append(program, d)
+	}
+	return program, nil
+}
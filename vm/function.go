@@ -1,8 +1,9 @@
 package vm
 
 import (
-	"fmt"
+	"github.com/arrietty-lang/arrtty/diagnostic"
 	"github.com/gookit/slog"
+	"unicode/utf8"
 )
 
 func (v *Vm) Push() error {
@@ -20,7 +21,7 @@ func (v *Vm) Push() error {
 	case KRegisterTag:
 		pData, ok := v.GetRegisterByTag(value.registerTag)
 		if !ok {
-			return fmt.Errorf("レジスタ%sからデータを取得できませんでした", value.registerTag)
+			return diagnostic.Errorf("V0003", value.registerTag)
 		}
 		data = pData
 	case KOffset:
@@ -32,11 +33,11 @@ func (v *Vm) Push() error {
 	case KLabel:
 		d, ok := v.data[value.label.GetName()]
 		if !ok {
-			return fmt.Errorf("未定義: %s", value.label.GetName())
+			return diagnostic.Errorf("V0010", value.label.GetName())
 		}
 		data = d
 	default:
-		return fmt.Errorf("pushはこれをサポートしていません: %s", value.kind.String())
+		return diagnostic.Errorf("V0004", PUSH.String(), value.kind.String())
 	}
 	v._push(*data)
 	return nil
@@ -66,26 +67,32 @@ func (v *Vm) Pop() error {
 		slog.Info("Pop", "kind", "register", "into(register)", into.registerTag, "val", value.String())
 		return nil
 	}
-	return fmt.Errorf("popの値代入先が不正です: %s", into.kind)
+	return diagnostic.Errorf("V0004", POP.String(), into.kind.String())
 }
 
 func (v *Vm) add(from, to Literal) (Literal, error) {
-	// [x] int += float
+	// [o] int += float (floatに昇格)
 	// [o] float += int
 	// [o] int += int
 	// [o] float += float
+	// [o] string += string
 	// other: error
 	switch to.GetKind() {
+	case KString:
+		if from.GetKind() != KString {
+			return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "+=", from.GetKind().String())
+		}
+		return *NewLiteral(to.GetString() + from.GetString()), nil
 	case KInt:
 		switch from.GetKind() {
 		case KInt:
 			// [o] int += int
 			return *NewLiteral(to.GetInt() + from.GetInt()), nil
 		case KFloat:
-			// [x] int += float
-			return Literal{}, fmt.Errorf("許されていないペアの加算です: %s += %s", to.GetKind().String(), from.GetKind().String())
+			// [o] int += float
+			return *NewLiteral(float64(to.GetInt()) + from.GetFloat()), nil
 		default:
-			return Literal{}, fmt.Errorf("加算元の型が不正です: %s += %s", to.GetKind().String(), from.GetKind().String())
+			return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "+=", from.GetKind().String())
 		}
 	case KFloat:
 		switch from.GetKind() {
@@ -96,15 +103,15 @@ func (v *Vm) add(from, to Literal) (Literal, error) {
 			// [o] float += float
 			return *NewLiteral(to.GetFloat() + from.GetFloat()), nil
 		default:
-			return Literal{}, fmt.Errorf("加算元の型が不正です: %s += %s", to.GetKind().String(), from.GetKind().String())
+			return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "+=", from.GetKind().String())
 		}
 	default:
-		return Literal{}, fmt.Errorf("加算先の型が不正です: %s += %s", to.GetKind().String(), from.GetKind().String())
+		return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "+=", from.GetKind().String())
 	}
 }
 
 func (v *Vm) sub(from, to Literal) (Literal, error) {
-	// [x] int -= float
+	// [o] int -= float (floatに昇格)
 	// [o] float -= int
 	// [o] int -= int
 	// [o] float -= float
@@ -116,10 +123,10 @@ func (v *Vm) sub(from, to Literal) (Literal, error) {
 			// [o] int -= int
 			return *NewLiteral(to.GetInt() - from.GetInt()), nil
 		case KFloat:
-			// [x] int -= float
-			return Literal{}, fmt.Errorf("許されていないペアの減算です: %s -= %s", to.GetKind().String(), from.GetKind().String())
+			// [o] int -= float
+			return *NewLiteral(float64(to.GetInt()) - from.GetFloat()), nil
 		default:
-			return Literal{}, fmt.Errorf("減算元の型が不正です: %s -= %s", to.GetKind().String(), from.GetKind().String())
+			return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "-=", from.GetKind().String())
 		}
 	case KFloat:
 		switch from.GetKind() {
@@ -130,10 +137,10 @@ func (v *Vm) sub(from, to Literal) (Literal, error) {
 			// [o] float -= float
 			return *NewLiteral(to.GetFloat() - from.GetFloat()), nil
 		default:
-			return Literal{}, fmt.Errorf("減算元の型が不正です: %s -= %s", to.GetKind().String(), from.GetKind().String())
+			return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "-=", from.GetKind().String())
 		}
 	default:
-		return Literal{}, fmt.Errorf("減算先の型が不正です: %s -= %s", to.GetKind().String(), from.GetKind().String())
+		return Literal{}, diagnostic.Errorf("V0001", to.GetKind().String(), "-=", from.GetKind().String())
 	}
 }
 
@@ -151,13 +158,13 @@ func (v *Vm) Add() error {
 			//fromVal := *v.registers[from.registerTag]
 			pFromVal, ok := v.GetRegisterByTag(from.registerTag)
 			if !ok {
-				return fmt.Errorf("レジスタ%sからデータを取得できませんでした", from.registerTag.String())
+				return diagnostic.Errorf("V0003", from.registerTag.String())
 			}
 			fromVal := *pFromVal
 			//toVal := *v.registers[to.registerTag]
 			pToVal, ok := v.GetRegisterByTag(to.registerTag)
 			if !ok {
-				return fmt.Errorf("レジスタ%sからデータを取得できませんでした", to.registerTag.String())
+				return diagnostic.Errorf("V0003", to.registerTag.String())
 			}
 			toVal := *pToVal
 			slog.Info("ADD", "from", from.registerTag.String(), "to", to.registerTag.String())
@@ -169,7 +176,7 @@ func (v *Vm) Add() error {
 			err = v.SetRegisterByTag(to.registerTag, NewLiteralData(result))
 			return err
 		default:
-			return fmt.Errorf("addはfrom: %sに対応していません", from.kind.String())
+			return diagnostic.Errorf("V0004", ADD.String(), from.kind.String())
 		}
 	case KOffset:
 		switch from.kind {
@@ -189,10 +196,10 @@ func (v *Vm) Add() error {
 			v.stack[offset] = NewLiteralData(result)
 			return nil
 		default:
-			return fmt.Errorf("addはfrom: %sに対応していません", from.kind.String())
+			return diagnostic.Errorf("V0004", ADD.String(), from.kind.String())
 		}
 	default:
-		return fmt.Errorf("addはto: %sに対応していません", to.kind.String())
+		return diagnostic.Errorf("V0004", ADD.String(), to.kind.String())
 	}
 	// return fmt.Errorf("予期しないエラー")
 }
@@ -211,13 +218,13 @@ func (v *Vm) Sub() error {
 			//fromVal := *v.registers[from.registerTag]
 			pFromVal, ok := v.GetRegisterByTag(from.registerTag)
 			if !ok {
-				return fmt.Errorf("レジスタ%sからデータを取得できませんでした", from.registerTag.String())
+				return diagnostic.Errorf("V0003", from.registerTag.String())
 			}
 			fromVal := *pFromVal
 			//toVal := *v.registers[to.registerTag]
 			pToVal, ok := v.GetRegisterByTag(to.registerTag)
 			if !ok {
-				return fmt.Errorf("レジスタ%sからデータを取得できませんでした", to.registerTag.String())
+				return diagnostic.Errorf("V0003", to.registerTag.String())
 			}
 			toVal := *pToVal
 			slog.Info("SUB", "from", from.registerTag.String(), "to", to.registerTag.String())
@@ -229,7 +236,7 @@ func (v *Vm) Sub() error {
 			err = v.SetRegisterByTag(to.registerTag, NewLiteralData(result))
 			return err
 		default:
-			return fmt.Errorf("fromはfrom: %sに対応していません", from.kind.String())
+			return diagnostic.Errorf("V0004", SUB.String(), from.kind.String())
 		}
 	case KOffset:
 		switch from.kind {
@@ -249,10 +256,10 @@ func (v *Vm) Sub() error {
 			v.stack[offset] = NewLiteralData(result)
 			return nil
 		default:
-			return fmt.Errorf("subはfrom: %sに対応していません", from.kind.String())
+			return diagnostic.Errorf("V0004", SUB.String(), from.kind.String())
 		}
 	default:
-		return fmt.Errorf("fromはto: %sに対応していません", to.kind.String())
+		return diagnostic.Errorf("V0004", SUB.String(), to.kind.String())
 	}
 }
 
@@ -271,7 +278,7 @@ func (v *Vm) Mov() error {
 			//fromVal := *v.registers[from.registerTag]
 			pFromVal, ok := v.GetRegisterByTag(from.registerTag)
 			if !ok {
-				return fmt.Errorf("レジスタ%sからデータを取得できませんでした", from.registerTag.String())
+				return diagnostic.Errorf("V0003", from.registerTag.String())
 			}
 			fromVal := *pFromVal
 			//v.registers[to.registerTag] = &fromVal
@@ -291,13 +298,13 @@ func (v *Vm) Mov() error {
 			// RTo = FromLabel
 			fromVal, ok := v.GetDataByLabel(from.label.GetName())
 			if !ok {
-				return fmt.Errorf("代入元の変数が見つかりません: %s", from.label.GetName())
+				return diagnostic.Errorf("V0011", from.label.GetName())
 			}
 			//v.registers[to.registerTag] = &fromVal
 			err := v.SetRegisterByTag(to.registerTag, &fromVal)
 			return err
 		default:
-			return fmt.Errorf("代入元が不明です: %s", from.kind.String())
+			return diagnostic.Errorf("V0004", MOV.String(), from.kind.String())
 		}
 	case KOffset:
 		switch from.kind {
@@ -306,7 +313,7 @@ func (v *Vm) Mov() error {
 			//fromVal := *v.registers[from.registerTag]
 			pFromVal, ok := v.GetRegisterByTag(from.registerTag)
 			if !ok {
-				return fmt.Errorf("レジスタ%sからデータを取得できませんでした", from.registerTag.String())
+				return diagnostic.Errorf("V0003", from.registerTag.String())
 			}
 			fromVal := *pFromVal
 			toLoc, err := v.calculateOffset(to.offset)
@@ -332,7 +339,7 @@ func (v *Vm) Mov() error {
 			// ToOffset = FromLabel
 			fromVal, ok := v.GetDataByLabel(from.label.GetName())
 			if !ok {
-				return fmt.Errorf("代入元の変数が見つかりません: %s", from.label.GetName())
+				return diagnostic.Errorf("V0011", from.label.GetName())
 			}
 			toLoc, err := v.calculateOffset(to.offset)
 			if err != nil {
@@ -341,7 +348,7 @@ func (v *Vm) Mov() error {
 			v.stack[toLoc] = &fromVal
 			return nil
 		default:
-			return fmt.Errorf("代入元が不明です: %s", from.kind.String())
+			return diagnostic.Errorf("V0004", MOV.String(), from.kind.String())
 		}
 	case KLabel:
 		switch from.kind {
@@ -350,7 +357,7 @@ func (v *Vm) Mov() error {
 			//fromVal := *v.registers[from.registerTag]
 			pFromVal, ok := v.GetRegisterByTag(from.registerTag)
 			if !ok {
-				return fmt.Errorf("レジスタ%sからデータを取得できませんでした", from.registerTag.String())
+				return diagnostic.Errorf("V0003", from.registerTag.String())
 			}
 			fromVal := *pFromVal
 			v.data[to.label.GetName()] = &fromVal
@@ -368,15 +375,15 @@ func (v *Vm) Mov() error {
 			// ToLabel = FromLabel
 			fromVal, ok := v.GetDataByLabel(from.label.GetName())
 			if !ok {
-				return fmt.Errorf("代入元の変数が見つかりません: %s", from.label.GetName())
+				return diagnostic.Errorf("V0011", from.label.GetName())
 			}
 			v.data[to.label.GetName()] = &fromVal
 			return nil
 		default:
-			return fmt.Errorf("代入元が不明です: %s", from.kind.String())
+			return diagnostic.Errorf("V0004", MOV.String(), from.kind.String())
 		}
 	default:
-		return fmt.Errorf("代入先が不明です: %s", to.kind.String())
+		return diagnostic.Errorf("V0004", MOV.String(), to.kind.String())
 	}
 	//return fmt.Errorf("mov 不明なエラー")
 }
@@ -389,7 +396,7 @@ func (v *Vm) Jmp() error {
 		v.pc = loc
 		return nil
 	default:
-		return fmt.Errorf("ジャンプ先の型が不正です: %s", newLocLabel.kind.String())
+		return diagnostic.Errorf("V0004", JMP.String(), newLocLabel.kind.String())
 	}
 }
 
@@ -405,7 +412,23 @@ func (v *Vm) Jz() error {
 		v.pc = loc
 		return nil
 	default:
-		return fmt.Errorf("ジャンプ先の型が不正です: %s", newLocLabel.kind.String())
+		return diagnostic.Errorf("V0004", JZ.String(), newLocLabel.kind.String())
+	}
+}
+
+func (v *Vm) Jnz() error {
+	newLocLabel := v.program[v.pc+1]
+	switch newLocLabel.kind {
+	case KLabel:
+		if v.zf == 1 {
+			v.pc += 1 + JNZ.CountOfOperand()
+			return nil
+		}
+		loc := v.labelLocation[newLocLabel.label.GetName()]
+		v.pc = loc
+		return nil
+	default:
+		return diagnostic.Errorf("V0004", JNZ.String(), newLocLabel.kind.String())
 	}
 }
 
@@ -423,7 +446,7 @@ func (v *Vm) lt(lhs, rhs Literal) (bool, error) {
 			// int < float
 			return float64(lhs.GetInt()) < rhs.GetFloat(), nil
 		default:
-			return false, fmt.Errorf("右辺の型が不正です: %s < %s", lhs.GetKind().String(), rhs.GetKind().String())
+			return false, diagnostic.Errorf("V0001", lhs.GetKind().String(), "<", rhs.GetKind().String())
 		}
 	case KFloat:
 		switch rhs.GetKind() {
@@ -434,10 +457,10 @@ func (v *Vm) lt(lhs, rhs Literal) (bool, error) {
 			// float < float
 			return lhs.GetFloat() < rhs.GetFloat(), nil
 		default:
-			return false, fmt.Errorf("右辺の型が不正です: %s < %s", lhs.GetKind().String(), rhs.GetKind().String())
+			return false, diagnostic.Errorf("V0001", lhs.GetKind().String(), "<", rhs.GetKind().String())
 		}
 	default:
-		return false, fmt.Errorf("左辺の型が不正です: %s < %s", lhs.GetKind().String(), rhs.GetKind().String())
+		return false, diagnostic.Errorf("V0001", lhs.GetKind().String(), "<", rhs.GetKind().String())
 	}
 }
 
@@ -455,7 +478,7 @@ func (v *Vm) le(lhs, rhs Literal) (bool, error) {
 			// int <= float
 			return float64(lhs.GetInt()) <= rhs.GetFloat(), nil
 		default:
-			return false, fmt.Errorf("右辺の型が不正です: %s <= %s", lhs.GetKind().String(), rhs.GetKind().String())
+			return false, diagnostic.Errorf("V0001", lhs.GetKind().String(), "<=", rhs.GetKind().String())
 		}
 	case KFloat:
 		switch rhs.GetKind() {
@@ -466,10 +489,10 @@ func (v *Vm) le(lhs, rhs Literal) (bool, error) {
 			// float <= float
 			return lhs.GetFloat() <= rhs.GetFloat(), nil
 		default:
-			return false, fmt.Errorf("右辺の型が不正です: %s <= %s", lhs.GetKind().String(), rhs.GetKind().String())
+			return false, diagnostic.Errorf("V0001", lhs.GetKind().String(), "<=", rhs.GetKind().String())
 		}
 	default:
-		return false, fmt.Errorf("左辺の型が不正です: %s <= %s", lhs.GetKind().String(), rhs.GetKind().String())
+		return false, diagnostic.Errorf("V0001", lhs.GetKind().String(), "<=", rhs.GetKind().String())
 	}
 }
 
@@ -503,10 +526,10 @@ func (v *Vm) Lt() error {
 
 			return nil
 		default:
-			return fmt.Errorf("右辺は不正な型です: %s < %s", lhs.kind.String(), rhs.kind.String())
+			return diagnostic.Errorf("V0004", LT.String(), rhs.kind.String())
 		}
 	default:
-		return fmt.Errorf("左辺は不正な型です: %s < %s", lhs.kind.String(), rhs.kind.String())
+		return diagnostic.Errorf("V0004", LT.String(), lhs.kind.String())
 	}
 }
 
@@ -540,10 +563,10 @@ func (v *Vm) Le() error {
 
 			return nil
 		default:
-			return fmt.Errorf("右辺は不正な型です: %s <= %s", lhs.kind.String(), rhs.kind.String())
+			return diagnostic.Errorf("V0004", LE.String(), rhs.kind.String())
 		}
 	default:
-		return fmt.Errorf("左辺は不正な型です: %s <= %s", lhs.kind.String(), rhs.kind.String())
+		return diagnostic.Errorf("V0004", LE.String(), lhs.kind.String())
 	}
 }
 
@@ -554,6 +577,12 @@ func (v *Vm) Exit() error {
 
 func (v *Vm) cmp(lhs, rhs Literal) (bool, error) {
 	switch lhs.GetKind() {
+	case KString:
+		if rhs.GetKind() != KString {
+			return false, diagnostic.Errorf("V0001", lhs.GetKind().String(), "==", rhs.GetKind().String())
+		}
+		// string == string
+		return lhs.GetString() == rhs.GetString(), nil
 	case KInt:
 		switch rhs.GetKind() {
 		case KInt:
@@ -563,7 +592,7 @@ func (v *Vm) cmp(lhs, rhs Literal) (bool, error) {
 			// int == float
 			return float64(lhs.GetInt()) == rhs.GetFloat(), nil
 		default:
-			return false, fmt.Errorf("サポートしていません: %s == %s", lhs.GetKind().String(), rhs.GetKind().String())
+			return false, diagnostic.Errorf("V0001", lhs.GetKind().String(), "==", rhs.GetKind().String())
 		}
 	case KFloat:
 		switch rhs.GetKind() {
@@ -574,10 +603,10 @@ func (v *Vm) cmp(lhs, rhs Literal) (bool, error) {
 			// float == float
 			return lhs.GetFloat() == rhs.GetFloat(), nil
 		default:
-			return false, fmt.Errorf("サポートしていません: %s == %s", lhs.GetKind().String(), rhs.GetKind().String())
+			return false, diagnostic.Errorf("V0001", lhs.GetKind().String(), "==", rhs.GetKind().String())
 		}
 	default:
-		return false, fmt.Errorf("サポートしていません: %s == %s", lhs.GetKind().String(), rhs.GetKind().String())
+		return false, diagnostic.Errorf("V0001", lhs.GetKind().String(), "==", rhs.GetKind().String())
 	}
 }
 
@@ -604,10 +633,10 @@ func (v *Vm) Cmp() error {
 			}
 			return nil
 		default:
-			return fmt.Errorf("サポートされていません: %s == %s", lhs.kind.String(), rhs.kind.String())
+			return diagnostic.Errorf("V0004", CMP.String(), rhs.kind.String())
 		}
 	default:
-		return fmt.Errorf("サポートされていません: %s == %s", lhs.kind.String(), rhs.kind.String())
+		return diagnostic.Errorf("V0004", CMP.String(), lhs.kind.String())
 	}
 }
 
@@ -617,21 +646,415 @@ func (v *Vm) Call() error {
 	case KLabel:
 		loc, ok := v.labelLocation[newLoc.label.GetName()]
 		if !ok {
-			return fmt.Errorf("未定義ラベル: %s", newLoc.label.GetName())
+			return diagnostic.Errorf("V0010", newLoc.label.GetName())
 		}
 		v._push(*NewLiteralDataWithRaw(v.pc + 2))
 		v.pc = loc
 		return nil
 	default:
-		return fmt.Errorf("不正な宛先: %s", newLoc.label.GetName())
+		return diagnostic.Errorf("V0004", CALL.String(), newLoc.kind.String())
 	}
 }
 
 func (v *Vm) Ret() error {
 	newLoc := v._pop()
 	if newLoc.kind != KLiteral || newLoc.literal.GetKind() != KInt {
-		return fmt.Errorf("戻り先が不正です: pc=%d", newLoc.literal.GetInt())
+		return diagnostic.Errorf("V0012", newLoc.literal.GetInt())
 	}
 	v.pc = newLoc.literal.GetInt()
 	return nil
 }
+
+func (v *Vm) New() error {
+	defer func() {
+		v.pc += 1 + NEW.CountOfOperand()
+	}()
+	count := v.program[v.pc+1]
+	if count.kind != KLiteral || count.literal.GetKind() != KInt {
+		return diagnostic.Errorf("V0013", NEW.String(), count.String())
+	}
+	values := make([]*Data, count.literal.GetInt())
+	// 先頭の要素から順にプッシュされているので、末尾から取り出す
+	for i := len(values) - 1; 0 <= i; i-- {
+		d := v._pop()
+		values[i] = &d
+	}
+	addr := v.alloc(NewObject(OStruct, values))
+	v._push(*NewLiteralData(*NewPointerLiteral(addr)))
+	return nil
+}
+
+// element インデックスとポインタから、対象のオブジェクトと位置を返す
+func (v *Vm) element(index Data, ptr Data) (*Object, int, error) {
+	if index.kind != KLiteral || index.literal.GetKind() != KInt {
+		return nil, 0, diagnostic.Errorf("V0014", index.String())
+	}
+	obj, err := v.deref(ptr)
+	if err != nil {
+		return nil, 0, err
+	}
+	i := index.literal.GetInt()
+	if obj.kind == OSlice {
+		// スライスは参照している配列の要素を返す
+		array, offset, length, _ := sliceHeader(obj)
+		if i < 0 || length <= i {
+			return nil, 0, diagnostic.Errorf("V0015", i, length)
+		}
+		return v.heap[array], offset + i, nil
+	}
+	if i < 0 || len(obj.values) <= i {
+		return nil, 0, diagnostic.Errorf("V0015", i, len(obj.values))
+	}
+	return obj, i, nil
+}
+
+func (v *Vm) Get() error {
+	defer func() {
+		v.pc += 1 + GET.CountOfOperand()
+	}()
+	index := v._pop()
+	ptr := v._pop()
+	obj, i, err := v.element(index, ptr)
+	if err != nil {
+		return err
+	}
+	v._push(*obj.values[i])
+	return nil
+}
+
+func (v *Vm) Set() error {
+	defer func() {
+		v.pc += 1 + SET.CountOfOperand()
+	}()
+	value := v._pop()
+	index := v._pop()
+	ptr := v._pop()
+	// マップはキーの位置に格納する
+	if obj, err := v.deref(ptr); err == nil && obj.kind == OMap {
+		key, err := toMapKey(index)
+		if err != nil {
+			return err
+		}
+		obj.store(key, value)
+		return nil
+	}
+	obj, i, err := v.element(index, ptr)
+	if err != nil {
+		return err
+	}
+	obj.values[i] = &value
+	return nil
+}
+
+func (v *Vm) Copy() error {
+	defer func() {
+		v.pc += 1 + COPY.CountOfOperand()
+	}()
+	obj, err := v.deref(v._pop())
+	if err != nil {
+		return err
+	}
+	c, err := v.deepCopy(obj)
+	if err != nil {
+		return err
+	}
+	v._push(*NewLiteralData(*NewPointerLiteral(v.alloc(c))))
+	return nil
+}
+
+func (v *Vm) Len() error {
+	defer func() {
+		v.pc += 1 + LEN.CountOfOperand()
+	}()
+	d := v._pop()
+	if d.kind == KLiteral && d.literal.GetKind() == KString {
+		v._push(*NewLiteralDataWithRaw(utf8.RuneCountInString(d.literal.GetString())))
+		return nil
+	}
+	obj, err := v.deref(d)
+	if err != nil {
+		return err
+	}
+	switch obj.kind {
+	case OSlice:
+		_, _, length, _ := sliceHeader(obj)
+		v._push(*NewLiteralDataWithRaw(length))
+		return nil
+	case OMap:
+		v._push(*NewLiteralDataWithRaw(len(obj.entries)))
+		return nil
+	}
+	v._push(*NewLiteralDataWithRaw(len(obj.values)))
+	return nil
+}
+
+func (v *Vm) Array() error {
+	defer func() {
+		v.pc += 1 + ARRAY.CountOfOperand()
+	}()
+	count := v.program[v.pc+1]
+	if count.kind != KLiteral || count.literal.GetKind() != KInt {
+		return diagnostic.Errorf("V0013", ARRAY.String(), count.String())
+	}
+	values := make([]*Data, count.literal.GetInt())
+	for i := len(values) - 1; 0 <= i; i-- {
+		d := v._pop()
+		values[i] = &d
+	}
+	addr := v.alloc(NewObject(OArray, values))
+	v._push(*NewLiteralData(*NewPointerLiteral(addr)))
+	return nil
+}
+
+func (v *Vm) Slice() error {
+	defer func() {
+		v.pc += 1 + SLICE.CountOfOperand()
+	}()
+	high := v._pop()
+	low := v._pop()
+	ptr := v._pop()
+	if high.kind != KLiteral || high.literal.GetKind() != KInt || low.kind != KLiteral || low.literal.GetKind() != KInt {
+		return diagnostic.Errorf("V0016", low.String(), high.String())
+	}
+	obj, err := v.deref(ptr)
+	if err != nil {
+		return err
+	}
+	var array, offset, capacity int
+	switch obj.kind {
+	case OArray:
+		array, offset, capacity = ptr.literal.GetInt(), 0, len(obj.values)
+	case OSlice:
+		array, offset, _, capacity = sliceHeader(obj)
+	default:
+		return diagnostic.Errorf("V0017", obj.kind.String())
+	}
+	l, h := low.literal.GetInt(), high.literal.GetInt()
+	if l < 0 || h < l || capacity < h {
+		return diagnostic.Errorf("V0018", l, h, capacity)
+	}
+	v._push(v.newSlice(array, offset+l, h-l, capacity-l))
+	return nil
+}
+
+func (v *Vm) Append() error {
+	defer func() {
+		v.pc += 1 + APPEND.CountOfOperand()
+	}()
+	value := v._pop()
+	ptr := v._pop()
+	obj, err := v.deref(ptr)
+	if err != nil {
+		return err
+	}
+	if obj.kind != OSlice {
+		return diagnostic.Errorf("V0019", obj.kind.String())
+	}
+	array, offset, length, capacity := sliceHeader(obj)
+	// 容量が残っていれば参照している配列に書き込む
+	if length < capacity {
+		v.heap[array].values[offset+length] = &value
+		v._push(v.newSlice(array, offset, length+1, capacity))
+		return nil
+	}
+	// 容量が足りなければ倍の大きさの配列に移し替える
+	newCapacity := capacity * 2
+	if newCapacity == 0 {
+		newCapacity = 1
+	}
+	values := make([]*Data, newCapacity)
+	copy(values, v.heap[array].values[offset:offset+length])
+	values[length] = &value
+	newArray := v.alloc(NewObject(OArray, values))
+	v._push(v.newSlice(newArray, 0, length+1, newCapacity))
+	return nil
+}
+
+func (v *Vm) Map() error {
+	defer func() {
+		v.pc += 1 + MAP.CountOfOperand()
+	}()
+	count := v.program[v.pc+1]
+	if count.kind != KLiteral || count.literal.GetKind() != KInt {
+		return diagnostic.Errorf("V0013", MAP.String(), count.String())
+	}
+	keys := make([]Data, count.literal.GetInt())
+	values := make([]Data, count.literal.GetInt())
+	for i := len(keys) - 1; 0 <= i; i-- {
+		values[i] = v._pop()
+		keys[i] = v._pop()
+	}
+	// 先に書かれた要素から順に追加する
+	obj := NewMapObject()
+	for i, k := range keys {
+		key, err := toMapKey(k)
+		if err != nil {
+			return err
+		}
+		obj.store(key, values[i])
+	}
+	v._push(*NewLiteralData(*NewPointerLiteral(v.alloc(obj))))
+	return nil
+}
+
+// mapOf スタックから取り出したポインタが指すマップを返す
+func (v *Vm) mapOf(ptr Data) (*Object, error) {
+	obj, err := v.deref(ptr)
+	if err != nil {
+		return nil, err
+	}
+	if obj.kind != OMap {
+		return nil, diagnostic.Errorf("V0020", obj.kind.String())
+	}
+	return obj, nil
+}
+
+func (v *Vm) Lookup() error {
+	defer func() {
+		v.pc += 1 + LOOKUP.CountOfOperand()
+	}()
+	count := v.program[v.pc+1]
+	if count.kind != KLiteral || count.literal.GetKind() != KInt {
+		return diagnostic.Errorf("V0013", LOOKUP.String(), count.String())
+	}
+	def := v._pop()
+	key, err := toMapKey(v._pop())
+	if err != nil {
+		return err
+	}
+	obj, err := v.mapOf(v._pop())
+	if err != nil {
+		return err
+	}
+	value, ok := obj.entries[key]
+	if count.literal.GetInt() == 2 {
+		if ok {
+			v._push(*NewLiteralDataWithRaw(1))
+		} else {
+			v._push(*NewLiteralDataWithRaw(0))
+		}
+	}
+	if ok {
+		v._push(*value)
+	} else {
+		v._push(def)
+	}
+	return nil
+}
+
+func (v *Vm) Delete() error {
+	defer func() {
+		v.pc += 1 + DELETE.CountOfOperand()
+	}()
+	key, err := toMapKey(v._pop())
+	if err != nil {
+		return err
+	}
+	obj, err := v.mapOf(v._pop())
+	if err != nil {
+		return err
+	}
+	obj.remove(key)
+	return nil
+}
+
+func (v *Vm) Keys() error {
+	defer func() {
+		v.pc += 1 + KEYS.CountOfOperand()
+	}()
+	obj, err := v.mapOf(v._pop())
+	if err != nil {
+		return err
+	}
+	values := make([]*Data, len(obj.keys))
+	for i, k := range obj.keys {
+		d := k.data()
+		values[i] = &d
+	}
+	array := v.alloc(NewObject(OArray, values))
+	v._push(v.newSlice(array, 0, len(values), len(values)))
+	return nil
+}
+
+func (v *Vm) Closure() error {
+	defer func() {
+		v.pc += 1 + CLOSURE.CountOfOperand()
+	}()
+	label := v.program[v.pc+1]
+	if label.kind != KLabel {
+		return diagnostic.Errorf("V0021", label.String())
+	}
+	count := v.program[v.pc+2]
+	if count.kind != KLiteral || count.literal.GetKind() != KInt {
+		return diagnostic.Errorf("V0013", CLOSURE.String(), count.String())
+	}
+	values := make([]*Data, count.literal.GetInt()+1)
+	for i := len(values) - 1; 1 <= i; i-- {
+		d := v._pop()
+		values[i] = &d
+	}
+	values[0] = NewLiteralDataWithRaw(label.label.GetName())
+	v._push(*NewLiteralData(*NewPointerLiteral(v.alloc(NewObject(OClosure, values)))))
+	return nil
+}
+
+func (v *Vm) CallR() error {
+	return v.callClosure(v._pop(), v.pc+1+CALLR.CountOfOperand())
+}
+
+// CallI インターフェースの値は[メソッド名から関数の値へのマップ, 値]
+// 値は構造体であれば複製してレシーバとして渡す
+func (v *Vm) CallI() error {
+	name := v.program[v.pc+1]
+	if name.kind != KLiteral || name.literal.GetKind() != KString {
+		return diagnostic.Errorf("V0022", name.String())
+	}
+	ptr := v._pop()
+	iface, err := v.deref(ptr)
+	if err != nil {
+		return diagnostic.Errorf("V0023", name.literal.GetString())
+	}
+	methods, err := v.mapOf(*iface.values[0])
+	if err != nil {
+		return err
+	}
+	key, err := toMapKey(name)
+	if err != nil {
+		return err
+	}
+	fn, ok := methods.entries[key]
+	if !ok {
+		return diagnostic.Errorf("V0024", name.literal.GetString())
+	}
+	receiver := *iface.values[1]
+	if obj, err := v.deref(receiver); err == nil && (obj.kind == OStruct || obj.kind == OArray) {
+		c, err := v.deepCopy(obj)
+		if err != nil {
+			return err
+		}
+		receiver = *NewLiteralData(*NewPointerLiteral(v.alloc(c)))
+	}
+	v._push(receiver)
+	return v.callClosure(*fn, v.pc+1+CALLI.CountOfOperand())
+}
+
+// callClosure 関数の値をR11に格納し、その関数を呼び出す
+func (v *Vm) callClosure(ptr Data, next int) error {
+	obj, err := v.deref(ptr)
+	if err != nil {
+		return diagnostic.Errorf("V0025", ptr.String())
+	}
+	if obj.kind != OClosure {
+		return diagnostic.Errorf("V0025", obj.kind.String())
+	}
+	name := obj.values[0].literal.GetString()
+	loc, ok := v.labelLocation[name]
+	if !ok {
+		return diagnostic.Errorf("V0010", name)
+	}
+	// 呼び出された関数はR11からキャプチャした変数を取り出す
+	v.registers[R11] = &ptr
+	v._push(*NewLiteralDataWithRaw(next))
+	v.pc = loc
+	return nil
+}
@@ -0,0 +1,180 @@
+package vm
+
+import "github.com/arrietty-lang/arrtty/diagnostic"
+
+type ObjectKind int
+
+const (
+	// OStruct 値として扱われるので、コピーされるときは中身も複製される
+	OStruct ObjectKind = iota
+	// OArray 構造体と同様に値として扱われる
+	OArray
+	// OSlice 配列の一部を参照する. [配列のポインタ, 開始位置, 長さ, 容量]を持ち、作成後は変更されない
+	OSlice
+	// OMap キーから値を引く. スライスと同様に参照として扱われる
+	OMap
+	// OClosure [関数のラベル名, キャプチャした変数...]を持つ関数の値
+	OClosure
+)
+
+func (ok ObjectKind) String() string {
+	switch ok {
+	case OStruct:
+		return "OStruct"
+	case OArray:
+		return "OArray"
+	case OSlice:
+		return "OSlice"
+	case OMap:
+		return "OMap"
+	case OClosure:
+		return "OClosure"
+	default:
+		return "illegal"
+	}
+}
+
+// Object ヒープに確保される複数の値をまとめたもの
+type Object struct {
+	kind   ObjectKind
+	values []*Data
+	// entries マップの要素
+	entries map[mapKey]*Data
+	// keys マップのキーを追加された順に保持する
+	keys []mapKey
+}
+
+// mapKey マップのキーとして使用できる値(int, string, bool)
+type mapKey struct {
+	kind LiteralKind
+	i    int
+	s    string
+}
+
+func toMapKey(d Data) (mapKey, error) {
+	if d.kind != KLiteral {
+		return mapKey{}, diagnostic.Errorf("V0026", d.String())
+	}
+	switch d.literal.GetKind() {
+	case KInt:
+		return mapKey{kind: KInt, i: d.literal.GetInt()}, nil
+	case KString:
+		return mapKey{kind: KString, s: d.literal.GetString()}, nil
+	}
+	return mapKey{}, diagnostic.Errorf("V0026", d.String())
+}
+
+func (k mapKey) data() Data {
+	if k.kind == KString {
+		return *NewLiteralDataWithRaw(k.s)
+	}
+	return *NewLiteralDataWithRaw(k.i)
+}
+
+func NewMapObject() *Object {
+	return &Object{
+		kind:    OMap,
+		entries: map[mapKey]*Data{},
+	}
+}
+
+// store マップに値を格納する
+func (o *Object) store(key mapKey, value Data) {
+	if _, ok := o.entries[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.entries[key] = &value
+}
+
+// remove マップから値を取り除く
+func (o *Object) remove(key mapKey) {
+	if _, ok := o.entries[key]; !ok {
+		return
+	}
+	delete(o.entries, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+func NewObject(kind ObjectKind, values []*Data) *Object {
+	return &Object{
+		kind:   kind,
+		values: values,
+	}
+}
+
+func (o *Object) GetKind() ObjectKind {
+	return o.kind
+}
+
+func (o *Object) GetValues() []*Data {
+	return o.values
+}
+
+// alloc ヒープにオブジェクトを確保してアドレスを返す
+func (v *Vm) alloc(obj *Object) int {
+	v.heap = append(v.heap, obj)
+	return len(v.heap) - 1
+}
+
+// deref ポインタが指すオブジェクトを取得する
+func (v *Vm) deref(d Data) (*Object, error) {
+	if d.kind != KLiteral || d.literal.GetKind() != KPointer {
+		return nil, diagnostic.Errorf("V0027", d.String())
+	}
+	addr := d.literal.GetInt()
+	if addr < 0 || len(v.heap) <= addr || v.heap[addr] == nil {
+		return nil, diagnostic.Errorf("V0028", addr)
+	}
+	return v.heap[addr], nil
+}
+
+// deepCopy 構造体、配列を中に含まれる構造体、配列ごと複製する
+// スライスは参照先を共有するので複製しない
+func (v *Vm) deepCopy(obj *Object) (*Object, error) {
+	values := make([]*Data, len(obj.values))
+	for i, val := range obj.values {
+		if val == nil {
+			continue
+		}
+		d := *val
+		if d.kind == KLiteral && d.literal.GetKind() == KPointer {
+			inner, err := v.deref(d)
+			if err != nil {
+				return nil, err
+			}
+			if inner.kind == OStruct || inner.kind == OArray {
+				c, err := v.deepCopy(inner)
+				if err != nil {
+					return nil, err
+				}
+				d = *NewLiteralData(*NewPointerLiteral(v.alloc(c)))
+			}
+		}
+		values[i] = &d
+	}
+	return NewObject(obj.kind, values), nil
+}
+
+// newSlice スライスを確保してそのポインタを返す
+func (v *Vm) newSlice(array int, offset int, length int, capacity int) Data {
+	header := NewObject(OSlice, []*Data{
+		NewLiteralData(*NewPointerLiteral(array)),
+		NewLiteralDataWithRaw(offset),
+		NewLiteralDataWithRaw(length),
+		NewLiteralDataWithRaw(capacity),
+	})
+	return *NewLiteralData(*NewPointerLiteral(v.alloc(header)))
+}
+
+// sliceHeader スライスが参照する配列のアドレス、開始位置、長さ、容量を返す
+func sliceHeader(obj *Object) (int, int, int, int) {
+	return obj.values[0].literal.GetInt(),
+		obj.values[1].literal.GetInt(),
+		obj.values[2].literal.GetInt(),
+		obj.values[3].literal.GetInt()
+}
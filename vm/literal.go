@@ -8,6 +8,8 @@ const (
 	KString LiteralKind = iota
 	KInt
 	KFloat
+	// KPointer ヒープ上のオブジェクトのアドレス
+	KPointer
 )
 
 func (lk LiteralKind) String() string {
@@ -18,6 +20,8 @@ func (lk LiteralKind) String() string {
 		return "KInt"
 	case KFloat:
 		return "KFloat"
+	case KPointer:
+		return "KPointer"
 	default:
 		return "illegal"
 	}
@@ -51,6 +55,13 @@ func NewLiteral[T string | int | float64](v T) *Literal {
 	return nil
 }
 
+func NewPointerLiteral(addr int) *Literal {
+	return &Literal{
+		kind: KPointer,
+		i:    addr,
+	}
+}
+
 func (l *Literal) String() string {
 	var v any
 	switch l.kind {
@@ -60,6 +71,8 @@ func (l *Literal) String() string {
 		v = l.i
 	case KFloat:
 		v = l.f
+	case KPointer:
+		v = fmt.Sprintf("&%d", l.i)
 	}
 	return fmt.Sprintf("Literal{ kind: %s, value: %v }", l.kind.String(), v)
 }
@@ -10,8 +10,12 @@ const (
 	ADD
 	// SUB `sub x1 x2`でx2 -= x1
 	SUB
+	// MUL `mul x1 x2`でx2 *= x1
 	MUL
+	// DIV `div x1 x2`でx2 /= x1
 	DIV
+	// MOD `mod x1 x2`でx2 %= x1
+	MOD
 	// CMP `cmp x1 x2`で一致したらZF=1, そうでなければZF=0
 	CMP
 	LT
@@ -35,11 +39,46 @@ const (
 	POP
 	// MSG `msg r '...'`でrに'...'を代入
 	MSG
-	// LEN 文字の長さBYTEを取得する
+	// LEN スタックのトップの文字列、配列、スライスを取り出し、その長さをプッシュする
 	LEN
 	// SYSCALL kernel call
 	SYSCALL
 
+	// NEW `new n`でスタックからn個の値を取り出して構造体を作り、そのポインタをプッシュする
+	NEW
+	// GET スタックからインデックス、ポインタの順に取り出し、その位置の値をプッシュする
+	GET
+	// SET スタックから値、インデックス、ポインタの順に取り出し、その位置に値を格納する
+	SET
+	// COPY スタックのトップのポインタが指す構造体を複製し、複製したポインタに置き換える
+	COPY
+	// ARRAY `array n`でスタックからn個の値を取り出して配列を作り、そのポインタをプッシュする
+	ARRAY
+	// SLICE スタックから上限、下限、ポインタの順に取り出し、その範囲を参照するスライスをプッシュする
+	SLICE
+	// APPEND スタックから値、スライスの順に取り出し、値を追加したスライスをプッシュする
+	APPEND
+	// MAP `map n`でスタックからn組のキーと値を取り出してマップを作り、そのポインタをプッシュする
+	MAP
+	// LOOKUP `lookup n`でスタックからデフォルト値、キー、マップの順に取り出し、値をプッシュする
+	// n=2の場合は値の前に、キーが存在したか(1 or 0)をプッシュする
+	LOOKUP
+	// DELETE スタックからキー、マップの順に取り出し、その要素を取り除く
+	DELETE
+	// KEYS スタックからマップを取り出し、キーのスライスをプッシュする
+	KEYS
+	// CLOSURE `closure label n`でスタックからn個の値を取り出し、labelの関数と組み合わせた関数の値をプッシュする
+	CLOSURE
+	// CALLR スタックから関数の値を取り出してR11に格納し、その関数を呼び出す
+	CALLR
+	// CALLI `calli name`でスタックからインターフェースの値を取り出し、
+	// 格納された値をプッシュしてから、そのメソッドnameを呼び出す
+	CALLI
+	// CONV `conv kind`でスタックから値を取り出し、kind(int, float, string)に変換した値をプッシュする
+	CONV
+	// PARSE `parse kind`でスタックから文字列を取り出し、kind(int, float)として読めたか(1 or 0)、値の順にプッシュする
+	PARSE
+
 	EXIT
 )
 
@@ -55,6 +94,8 @@ func (o Opcode) CountOfOperand() int {
 		return 2
 	case DIV:
 		return 2
+	case MOD:
+		return 2
 	case CMP:
 		return 2
 	case LT:
@@ -98,9 +139,41 @@ func (o Opcode) CountOfOperand() int {
 	case MSG:
 		return 2
 	case LEN:
-		return 2
+		return 0
 	case SYSCALL:
 		return 1
+	case NEW:
+		return 1
+	case GET:
+		return 0
+	case SET:
+		return 0
+	case COPY:
+		return 0
+	case ARRAY:
+		return 1
+	case SLICE:
+		return 0
+	case APPEND:
+		return 0
+	case MAP:
+		return 1
+	case LOOKUP:
+		return 1
+	case DELETE:
+		return 0
+	case KEYS:
+		return 0
+	case CLOSURE:
+		return 2
+	case CALLR:
+		return 0
+	case CALLI:
+		return 1
+	case CONV:
+		return 1
+	case PARSE:
+		return 1
 	}
 	return -1
 }
@@ -111,6 +184,7 @@ var opcodes = [...]string{
 	SUB:     "SUB",
 	MUL:     "MUL",
 	DIV:     "DIV",
+	MOD:     "MOD",
 	CMP:     "CMP",
 	LT:      "LT",
 	GT:      "GT",
@@ -134,6 +208,22 @@ var opcodes = [...]string{
 	MSG:     "MSG",
 	LEN:     "LEN",
 	SYSCALL: "SYSCALL",
+	NEW:     "NEW",
+	GET:     "GET",
+	SET:     "SET",
+	COPY:    "COPY",
+	ARRAY:   "ARRAY",
+	SLICE:   "SLICE",
+	APPEND:  "APPEND",
+	MAP:     "MAP",
+	LOOKUP:  "LOOKUP",
+	DELETE:  "DELETE",
+	KEYS:    "KEYS",
+	CLOSURE: "CLOSURE",
+	CALLR:   "CALLR",
+	CALLI:   "CALLI",
+	CONV:    "CONV",
+	PARSE:   "PARSE",
 }
 
 func (o Opcode) String() string {
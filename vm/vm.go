@@ -1,7 +1,7 @@
 package vm
 
 import (
-	"fmt"
+	"github.com/arrietty-lang/arrtty/diagnostic"
 	"github.com/gookit/slog"
 )
 
@@ -15,6 +15,7 @@ type Vm struct {
 	registers     map[RegisterTag]*Data
 	labelLocation map[string]int
 	data          map[string]*Data
+	heap          []*Object
 	exited        bool
 }
 
@@ -59,7 +60,7 @@ func (v *Vm) calculateOffset(offset Offset) (int, error) {
 	case BP:
 		return v.bp + offset.GetRelation(), nil
 	}
-	return 0, fmt.Errorf("不正なポインタです")
+	return 0, diagnostic.Errorf("V0029")
 }
 
 func (v *Vm) _push(d Data) {
@@ -111,13 +112,13 @@ func (v *Vm) SetRegisterByTag(tag RegisterTag, data *Data) error {
 	switch tag {
 	case RSP:
 		if data.kind != KLiteral || data.literal.GetKind() != KInt {
-			return fmt.Errorf("SPに%s.%sを代入することはできません", data.kind.String(), data.literal.GetKind())
+			return diagnostic.Errorf("V0030", "SP", data.kind.String(), data.literal.GetKind())
 		}
 		v.sp = data.literal.GetInt()
 		return nil
 	case RBP:
 		if data.kind != KLiteral || data.literal.GetKind() != KInt {
-			return fmt.Errorf("BPに%s.%sを代入することはできません", data.kind.String(), data.literal.GetKind())
+			return diagnostic.Errorf("V0030", "BP", data.kind.String(), data.literal.GetKind())
 		}
 		v.bp = data.literal.GetInt()
 		return nil
@@ -138,7 +139,7 @@ func (v *Vm) ExitCode() (int, error) {
 		return 0, nil
 	}
 	if d.kind != KLiteral || d.literal.GetKind() != KInt {
-		return 0, fmt.Errorf("終了コードが不正な値です: %s", d.String())
+		return 0, diagnostic.Errorf("V0031", d.String())
 	}
 	return d.literal.GetInt(), nil
 }
@@ -151,7 +152,7 @@ func (v *Vm) Execute() error {
 
 	entryPoint, ok := v.labelLocation["main"]
 	if !ok {
-		return fmt.Errorf("main label not found")
+		return diagnostic.Errorf("V0032")
 	}
 	v.pc = entryPoint
 
@@ -161,7 +162,7 @@ func (v *Vm) Execute() error {
 			v.pc++
 			continue
 		} else if v.program[v.pc].kind != KOpcode {
-			return fmt.Errorf("pcはopcodeを予想しましたが、%sが発見されました", v.program[v.pc].kind.String())
+			return diagnostic.Errorf("V0033", v.program[v.pc].kind.String())
 		}
 		switch v.program[v.pc].opcode {
 		case PUSH:
@@ -184,6 +185,21 @@ func (v *Vm) Execute() error {
 			if err != nil {
 				return err
 			}
+		case MUL:
+			err := v.Mul()
+			if err != nil {
+				return err
+			}
+		case DIV:
+			err := v.Div()
+			if err != nil {
+				return err
+			}
+		case MOD:
+			err := v.Mod()
+			if err != nil {
+				return err
+			}
 		case MOV:
 			err := v.Mov()
 			if err != nil {
@@ -204,6 +220,11 @@ func (v *Vm) Execute() error {
 			if err != nil {
 				return err
 			}
+		case JNZ:
+			err := v.Jnz()
+			if err != nil {
+				return err
+			}
 		case EXIT:
 			err := v.Exit()
 			if err != nil {
@@ -229,8 +250,93 @@ func (v *Vm) Execute() error {
 			if err != nil {
 				return err
 			}
+		case NEW:
+			err := v.New()
+			if err != nil {
+				return err
+			}
+		case GET:
+			err := v.Get()
+			if err != nil {
+				return err
+			}
+		case SET:
+			err := v.Set()
+			if err != nil {
+				return err
+			}
+		case COPY:
+			err := v.Copy()
+			if err != nil {
+				return err
+			}
+		case LEN:
+			err := v.Len()
+			if err != nil {
+				return err
+			}
+		case ARRAY:
+			err := v.Array()
+			if err != nil {
+				return err
+			}
+		case SLICE:
+			err := v.Slice()
+			if err != nil {
+				return err
+			}
+		case APPEND:
+			err := v.Append()
+			if err != nil {
+				return err
+			}
+		case MAP:
+			err := v.Map()
+			if err != nil {
+				return err
+			}
+		case LOOKUP:
+			err := v.Lookup()
+			if err != nil {
+				return err
+			}
+		case DELETE:
+			err := v.Delete()
+			if err != nil {
+				return err
+			}
+		case KEYS:
+			err := v.Keys()
+			if err != nil {
+				return err
+			}
+		case CLOSURE:
+			err := v.Closure()
+			if err != nil {
+				return err
+			}
+		case CALLR:
+			err := v.CallR()
+			if err != nil {
+				return err
+			}
+		case CALLI:
+			err := v.CallI()
+			if err != nil {
+				return err
+			}
+		case CONV:
+			err := v.Conv()
+			if err != nil {
+				return err
+			}
+		case PARSE:
+			err := v.Parse()
+			if err != nil {
+				return err
+			}
 		default:
-			return fmt.Errorf("サポートされていない操作です: %s", v.program[v.pc].opcode.String())
+			return diagnostic.Errorf("V0034", v.program[v.pc].opcode.String())
 		}
 	}
 	return nil
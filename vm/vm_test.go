@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"bytes"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -104,6 +105,26 @@ func TestVm_Add2(t *testing.T) {
 	assert.Equal(t, 0, nonNilStacks)
 }
 
+func TestVm_Add_Promote(t *testing.T) {
+	stackSize := 10
+	add := []Data{
+		*NewLabelData(*NewLabel(true, "main")),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(POP), *NewRegisterTagData(R1),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(0.5),
+		*NewOpcodeData(POP), *NewRegisterTagData(R2),
+		*NewOpcodeData(ADD), *NewRegisterTagData(R2), *NewRegisterTagData(R1), // R1(int) += R2(float)
+	}
+
+	virtualMachine := NewVm(add, stackSize)
+	err := virtualMachine.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, NewLiteralDataWithRaw(1.5), virtualMachine.registers[R1])
+}
+
 func TestVm_Sub(t *testing.T) {
 	stackSize := 10
 	add := []Data{
@@ -157,6 +178,254 @@ func TestVm_Sub2(t *testing.T) {
 	assert.Equal(t, 0, nonNilStacks)
 }
 
+func TestVm_MulDivMod(t *testing.T) {
+	stackSize := 10
+	arith := []Data{
+		*NewLabelData(*NewLabel(true, "main")),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(7), // R1
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(6), // R2
+		*NewOpcodeData(POP), *NewRegisterTagData(R2), // stack[8] -> R2
+		*NewOpcodeData(POP), *NewRegisterTagData(R1), // stack[7] -> R1
+		*NewOpcodeData(MUL), *NewRegisterTagData(R2), *NewRegisterTagData(R1), // R1 *= R2
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(4),
+		*NewOpcodeData(POP), *NewRegisterTagData(R2),
+		*NewOpcodeData(DIV), *NewRegisterTagData(R2), *NewRegisterTagData(R1), // R1 /= R2
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(4),
+		*NewOpcodeData(POP), *NewRegisterTagData(R2),
+		*NewOpcodeData(MOD), *NewRegisterTagData(R2), *NewRegisterTagData(R1), // R1 %= R2
+	}
+
+	virtualMachine := NewVm(arith, stackSize)
+	err := virtualMachine.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, NewLiteralDataWithRaw(2), virtualMachine.registers[R1])
+	nonNilStacks := 0
+	for i := 0; i < stackSize; i++ {
+		if virtualMachine.stack[i] != nil {
+			nonNilStacks++
+		}
+	}
+	assert.Equal(t, 0, nonNilStacks)
+}
+
+func TestVm_Div_Zero(t *testing.T) {
+	stackSize := 10
+	div := []Data{
+		*NewLabelData(*NewLabel(true, "main")),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(0),
+		*NewOpcodeData(POP), *NewRegisterTagData(R1),
+		*NewOpcodeData(DIV), *NewRegisterTagData(R1), *NewRegisterTagData(R1), // R1 /= R1
+	}
+
+	virtualMachine := NewVm(div, stackSize)
+	err := virtualMachine.Execute()
+	assert.Error(t, err)
+}
+
+func TestVm_NewGetSet(t *testing.T) {
+	stackSize := 10
+	obj := []Data{
+		*NewLabelData(*NewLabel(true, "main")),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(2),
+		*NewOpcodeData(NEW), *NewLiteralDataWithRaw(2), // {1, 2}
+		*NewOpcodeData(POP), *NewRegisterTagData(R3),
+		*NewOpcodeData(PUSH), *NewRegisterTagData(R3),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(5),
+		*NewOpcodeData(SET), // {1, 5}
+		*NewOpcodeData(PUSH), *NewRegisterTagData(R3),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(GET),
+		*NewOpcodeData(POP), *NewRegisterTagData(R1),
+	}
+
+	virtualMachine := NewVm(obj, stackSize)
+	err := virtualMachine.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, NewLiteralDataWithRaw(5), virtualMachine.registers[R1])
+}
+
+func TestVm_SliceAppend(t *testing.T) {
+	stackSize := 10
+	slice := []Data{
+		*NewLabelData(*NewLabel(true, "main")),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(2),
+		*NewOpcodeData(ARRAY), *NewLiteralDataWithRaw(2), // [1, 2]
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(2),
+		*NewOpcodeData(SLICE), // [2]
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(3),
+		*NewOpcodeData(APPEND), // [2, 3]
+		*NewOpcodeData(LEN),
+		*NewOpcodeData(POP), *NewRegisterTagData(R1),
+	}
+
+	virtualMachine := NewVm(slice, stackSize)
+	err := virtualMachine.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, NewLiteralDataWithRaw(2), virtualMachine.registers[R1])
+}
+
+func TestVm_Slice_OutOfRange(t *testing.T) {
+	stackSize := 10
+	slice := []Data{
+		*NewLabelData(*NewLabel(true, "main")),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(2),
+		*NewOpcodeData(ARRAY), *NewLiteralDataWithRaw(2),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(0),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(SLICE), // [1]
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(GET), // 容量内でも長さを超えるのでエラー
+	}
+
+	virtualMachine := NewVm(slice, stackSize)
+	err := virtualMachine.Execute()
+	assert.Error(t, err)
+}
+
+func TestVm_MapLookup(t *testing.T) {
+	stackSize := 20
+	m := []Data{
+		*NewLabelData(*NewLabel(true, "main")),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw("a"),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(MAP), *NewLiteralDataWithRaw(1), // {"a": 1}
+		*NewOpcodeData(POP), *NewRegisterTagData(R3),
+		*NewOpcodeData(PUSH), *NewRegisterTagData(R3),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw("b"),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(2),
+		*NewOpcodeData(SET), // {"a": 1, "b": 2}
+		*NewOpcodeData(PUSH), *NewRegisterTagData(R3),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw("a"),
+		*NewOpcodeData(DELETE), // {"b": 2}
+		*NewOpcodeData(PUSH), *NewRegisterTagData(R3),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw("a"),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(-1),
+		*NewOpcodeData(LOOKUP), *NewLiteralDataWithRaw(2),
+		*NewOpcodeData(POP), *NewRegisterTagData(R1), // デフォルト値
+		*NewOpcodeData(POP), *NewRegisterTagData(R2), // 存在しない
+		*NewOpcodeData(PUSH), *NewRegisterTagData(R3),
+		*NewOpcodeData(LEN),
+		*NewOpcodeData(POP), *NewRegisterTagData(R10),
+	}
+
+	virtualMachine := NewVm(m, stackSize)
+	err := virtualMachine.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, NewLiteralDataWithRaw(-1), virtualMachine.registers[R1])
+	assert.Equal(t, NewLiteralDataWithRaw(0), virtualMachine.registers[R2])
+	assert.Equal(t, NewLiteralDataWithRaw(1), virtualMachine.registers[R10])
+}
+
+func TestVm_ClosureCallR(t *testing.T) {
+	stackSize := 20
+	m := []Data{
+		// R11の関数の値に含まれる1つ目の値を返す
+		*NewLabelData(*NewLabel(true, "f")),
+		*NewOpcodeData(PUSH), *NewRegisterTagData(R11),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(GET),
+		*NewOpcodeData(POP), *NewRegisterTagData(R1),
+		*NewOpcodeData(RET),
+
+		*NewLabelData(*NewLabel(true, "main")),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(7),
+		*NewOpcodeData(CLOSURE), *NewLabelData(*NewLabel(false, "f")), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(CALLR),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(11),
+		*NewOpcodeData(POP), *NewRegisterTagData(R2),
+	}
+
+	virtualMachine := NewVm(m, stackSize)
+	err := virtualMachine.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, NewLiteralDataWithRaw(7), virtualMachine.registers[R1])
+	assert.Equal(t, NewLiteralDataWithRaw(11), virtualMachine.registers[R2])
+}
+
+func TestVm_CallI(t *testing.T) {
+	stackSize := 20
+	m := []Data{
+		// レシーバの1つ目のフィールドを返す
+		*NewLabelData(*NewLabel(true, "T.f")),
+		*NewOpcodeData(PUSH), *NewOffsetData(*NewOffset(SP, 1)),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(0),
+		*NewOpcodeData(GET),
+		*NewOpcodeData(POP), *NewRegisterTagData(R1),
+		*NewOpcodeData(RET),
+
+		*NewLabelData(*NewLabel(true, "main")),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw("f"),
+		*NewOpcodeData(CLOSURE), *NewLabelData(*NewLabel(false, "T.f")), *NewLiteralDataWithRaw(0),
+		*NewOpcodeData(MAP), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(5),
+		*NewOpcodeData(NEW), *NewLiteralDataWithRaw(1),
+		*NewOpcodeData(NEW), *NewLiteralDataWithRaw(2), // [{"f": T.f}, T{5}]
+		*NewOpcodeData(CALLI), *NewLiteralDataWithRaw("f"),
+		*NewOpcodeData(POP), *NewRegisterTagData(R2), // レシーバ
+	}
+
+	virtualMachine := NewVm(m, stackSize)
+	err := virtualMachine.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, NewLiteralDataWithRaw(5), virtualMachine.registers[R1])
+}
+
+func TestVm_ConvParse(t *testing.T) {
+	stackSize := 20
+	m := []Data{
+		*NewLabelData(*NewLabel(true, "main")),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(-2.7),
+		*NewOpcodeData(CONV), *NewLiteralDataWithRaw("int"),
+		*NewOpcodeData(POP), *NewRegisterTagData(R1),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(12),
+		*NewOpcodeData(CONV), *NewLiteralDataWithRaw("string"),
+		*NewOpcodeData(POP), *NewRegisterTagData(R2),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw("3.5"),
+		*NewOpcodeData(PARSE), *NewLiteralDataWithRaw("float"),
+		*NewOpcodeData(POP), *NewRegisterTagData(R3),
+		*NewOpcodeData(POP), *NewRegisterTagData(R10), // 読めた
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw("3.5"),
+		*NewOpcodeData(PARSE), *NewLiteralDataWithRaw("int"),
+		*NewOpcodeData(POP), *NewRegisterTagData(R11),
+		*NewOpcodeData(POP), *NewRegisterTagData(R11), // 読めない
+	}
+
+	virtualMachine := NewVm(m, stackSize)
+	err := virtualMachine.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, NewLiteralDataWithRaw(-2), virtualMachine.registers[R1])
+	assert.Equal(t, NewLiteralDataWithRaw("12"), virtualMachine.registers[R2])
+	assert.Equal(t, NewLiteralDataWithRaw(3.5), virtualMachine.registers[R3])
+	assert.Equal(t, NewLiteralDataWithRaw(1), virtualMachine.registers[R10])
+	assert.Equal(t, NewLiteralDataWithRaw(0), virtualMachine.registers[R11])
+}
+
 func TestVm_Mov(t *testing.T) {
 	stackSize := 10
 	mov := []Data{
@@ -434,6 +703,58 @@ func TestVm_Jmp3(t *testing.T) {
 	assert.Equal(t, 0, nonNilStacks)
 }
 
+func TestVm_Jnz(t *testing.T) {
+	tests := []struct {
+		name   string
+		lhs    int
+		rhs    int
+		expect int
+	}{
+		// 比較が偽(ZF=0)ならジャンプする
+		{"taken", 3, 1, 10},
+		// 比較が真(ZF=1)ならジャンプせずに次の命令へ進む
+		{"not taken", 1, 3, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stackSize := 10
+			jnz := []Data{
+				*NewLabelData(*NewLabel(true, "main")),
+				*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(tt.lhs), // R1
+				*NewOpcodeData(POP), *NewRegisterTagData(R1), // stack[8] -> R1
+				*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(tt.rhs), // R2
+				*NewOpcodeData(POP), *NewRegisterTagData(R2), // stack[8] -> R2
+				*NewOpcodeData(LT), *NewRegisterTagData(R1), *NewRegisterTagData(R2), // R1 < R2
+
+				*NewOpcodeData(JNZ), *NewLabelData(*NewLabel(false, "afterExit")),
+				*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(1), // R1
+				*NewOpcodeData(POP), *NewRegisterTagData(R1), // stack[8] -> R1
+				*NewOpcodeData(EXIT),
+				*NewLabelData(*NewLabel(true, "afterExit")),
+
+				*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(10), // R1
+				*NewOpcodeData(POP), *NewRegisterTagData(R1), // stack[8] -> R1
+			}
+
+			virtualMachine := NewVm(jnz, stackSize)
+			err := virtualMachine.Execute()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, NewLiteralDataWithRaw(tt.expect), virtualMachine.registers[R1])
+
+			nonNilStacks := 0
+			for i := 0; i < stackSize; i++ {
+				if virtualMachine.stack[i] != nil {
+					nonNilStacks++
+				}
+			}
+			assert.Equal(t, 0, nonNilStacks)
+		})
+	}
+}
+
 func TestVm_Cmp(t *testing.T) {
 	stackSize := 10
 	jmp := []Data{
@@ -614,3 +935,26 @@ func TestVm_Execute_If_False(t *testing.T) {
 	}
 	assert.Equal(t, 0, nonNilStacks)
 }
+
+func TestEncodeProgram(t *testing.T) {
+	program := []Data{
+		*NewLabelData(*NewLabel(true, "main")),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(-3),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw(1.5),
+		*NewOpcodeData(PUSH), *NewLiteralDataWithRaw("文字列"),
+		*NewOpcodeData(MOV), *NewRegisterTagData(RSP), *NewOffsetData(*NewOffset(BP, -2)),
+		*NewOpcodeData(JMP), *NewLabelData(*NewLabel(false, "pkg.f")),
+	}
+	var buf bytes.Buffer
+	if err := EncodeProgram(&buf, program); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeProgram(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, program, decoded)
+
+	_, err = DecodeProgram(bytes.NewReader([]byte("arrx\x04\x02")))
+	assert.Error(t, err)
+}